@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/knol"
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+	"github.com/spf13/pflag"
+)
+
+// runAddCard captures a card from the command line, e.g. from a script:
+// `knolhash add-card --q "..." --a "..." --file notes/inbox.md` appends it to
+// the file, in the card syntax of the local source holding it, syncs the source
+// and prints the card's hash. Without --file the card goes to inbox.md in the
+// inbox, which is added as a source if it isn't one yet.
+func runAddCard(db *storage.DB, cfg *Config, args []string) error {
+	flags := pflag.NewFlagSet("add-card", pflag.ContinueOnError)
+	question := flags.String("q", "", "the question")
+	answer := flags.String("a", "", "the answer")
+	context := flags.String("c", "", "the context, e.g. Networking/TCP")
+	file := flags.String("file", "", "the Markdown file to append the card to (default inbox.md in the inbox)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*question) == "" || strings.TrimSpace(*answer) == "" {
+		return errors.New(`usage: knolhash add-card --q "question" --a "answer" [--c context] [--file notes/inbox.md]`)
+	}
+
+	path, syntax, err := cardFile(db, cfg, *file)
+	if err != nil {
+		return err
+	}
+	block := syntax.Format(strings.TrimSpace(*question), strings.TrimSpace(*answer), strings.TrimSpace(*context))
+	cards, err := appendCards(path, syntax, block)
+	if err != nil {
+		return err
+	}
+	if len(cards) != 1 {
+		return fmt.Errorf("the card would be read back as %d cards; check its text", len(cards))
+	}
+	return syncAdded(db, path, cards)
+}
+
+// cardFile returns the Markdown file cards are added to, file or else inbox.md
+// in the inbox, adding the inbox as a source if it isn't one yet, and the
+// syntax of its cards in the local source holding it.
+func cardFile(db *storage.DB, cfg *Config, file string) (string, parser.Config, error) {
+	path := file
+	if path == "" {
+		if err := os.MkdirAll(cfg.InboxDir, 0o755); err != nil {
+			return "", parser.Config{}, fmt.Errorf("failed to create inbox: %w", err)
+		}
+		if err := addNewSource(db, cfg.InboxDir); err != nil {
+			return "", parser.Config{}, err
+		}
+		path = filepath.Join(cfg.InboxDir, "inbox.md")
+	}
+	source, err := sync.FindLocalSource(db, path)
+	if err != nil {
+		return "", parser.Config{}, err
+	}
+	syntax, err := sync.FileSyntax(source, path)
+	if err != nil {
+		return "", parser.Config{}, err
+	}
+	if !syntax.IsMarkdown(path) {
+		return "", parser.Config{}, fmt.Errorf("%s is not a Markdown file of its source", path)
+	}
+	return path, syntax, nil
+}
+
+// appendCards appends a block of cards to the file at path, creating it if need
+// be, and returns the cards as parsed back. It refuses a block the parser warns
+// about or finds no cards in, e.g. an answer without a question, and one the
+// file would hide, e.g. after a knolhash:off comment.
+func appendCards(path string, cfg parser.Config, block string) ([]domain.Card, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var prefix string
+	if len(existing) > 0 {
+		prefix = "\n"
+		if existing[len(existing)-1] != '\n' {
+			prefix = "\n\n"
+		}
+	}
+	block = prefix + strings.TrimSpace(block) + "\n"
+
+	content := append(existing[:len(existing):len(existing)], block...)
+	line := strings.Count(string(existing)+prefix, "\n") + 1
+	res := parser.ParseCards(path, content, cfg)
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("%s can't be parsed: %w", path, err)
+	}
+	for _, w := range res.Warnings {
+		if w.Line >= line {
+			return nil, fmt.Errorf("the cards would not be read back as written: %w", w)
+		}
+	}
+	var added []domain.Card
+	for _, c := range res.Cards {
+		if c.Line >= line {
+			added = append(added, c)
+		}
+	}
+	if len(added) == 0 {
+		return nil, fmt.Errorf("no cards would be read back from %s; check the text and the file", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := f.WriteString(block); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	return added, nil
+}
+
+// syncAdded syncs the file cards were added to and prints their hashes, one per
+// line.
+func syncAdded(db *storage.DB, path string, cards []domain.Card) error {
+	if _, err := sync.SyncFile(db, path); err != nil {
+		return fmt.Errorf("added the cards to %s but failed to sync them: %w", path, err)
+	}
+	for _, card := range cards {
+		slog.Info("Added card", "file", path, "line", card.Line, "hash", knol.Hash(card))
+		fmt.Fprintln(os.Stdout, knol.Hash(card))
+	}
+	return nil
+}
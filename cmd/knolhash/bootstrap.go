@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/registry"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// bootstrappedSetting records when the initial sources and decks were added.
+const bootstrappedSetting = "bootstrapped_at"
+
+// bootstrap adds the initial sources and decks of the configuration on the first
+// start against a database without sources, so a container comes up configured.
+// It runs once: sources deleted afterwards aren't added back. Sources and decks
+// that fail to be added are logged and skipped.
+func bootstrap(db *storage.DB, cfg *Config) error {
+	if len(cfg.InitialSources) == 0 && len(cfg.InitialDecks) == 0 {
+		return nil
+	}
+	settings, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	if settings[bootstrappedSetting] != "" {
+		return nil
+	}
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		for _, path := range cfg.InitialSources {
+			if err := addNewSource(db, path); err != nil {
+				slog.Error("Failed to add initial source", "path", path, "error", err)
+			}
+		}
+		for _, name := range cfg.InitialDecks {
+			d, err := registry.Install(db, cfg.DeckIndex, name)
+			if err != nil {
+				slog.Error("Failed to install initial deck", "deck", name, "error", err)
+				continue
+			}
+			slog.Info("Installed deck", "name", d.Name, "origin", d.Origin, "source_id", d.SourceID)
+		}
+	}
+	return db.UpdateSettings(map[string]string{bootstrappedSetting: time.Now().UTC().Format(time.RFC3339)})
+}
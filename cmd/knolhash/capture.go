@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/spf13/pflag"
+)
+
+// clipboardCommands read the clipboard on macOS, Wayland, X11 and Windows, tried
+// in order.
+var clipboardCommands = [][]string{
+	{"pbpaste"},
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+	{"xsel", "--clipboard", "--output"},
+	{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+}
+
+// runCapture captures cards written out in full, e.g. from an editor piping its
+// selection: `knolhash capture < card.md` reads Q: and A: lines from stdin, or
+// the clipboard with --clipboard, checks that they parse as cards without
+// warnings, appends them to inbox.md in the inbox, or the --file of a local
+// source, syncs it and prints the hashes of the new cards.
+func runCapture(db *storage.DB, cfg *Config, args []string) error {
+	flags := pflag.NewFlagSet("capture", pflag.ContinueOnError)
+	clipboard := flags.Bool("clipboard", false, "read the cards from the clipboard instead of stdin")
+	file := flags.String("file", "", "the Markdown file to append the cards to (default inbox.md in the inbox)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var text []byte
+	var err error
+	if *clipboard {
+		text, err = readClipboard()
+	} else {
+		text, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(text)) == "" {
+		return errors.New("nothing to capture: pipe Q: and A: lines to knolhash capture, or use --clipboard")
+	}
+
+	path, syntax, err := cardFile(db, cfg, *file)
+	if err != nil {
+		return err
+	}
+	cards, err := appendCards(path, syntax, string(text))
+	if err != nil {
+		return err
+	}
+	return syncAdded(db, path, cards)
+}
+
+// readClipboard returns the text on the clipboard, from the first of the
+// clipboardCommands installed.
+func readClipboard() ([]byte, error) {
+	for _, command := range clipboardCommands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		text, err := exec.Command(command[0], command[1:]...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the clipboard with %s: %w", command[0], err)
+		}
+		return text, nil
+	}
+	return nil, errors.New("no clipboard tool found: install wl-clipboard, xclip or xsel, or pipe the cards to stdin")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// runCard shows or changes a single card. `knolhash card show <hash>` prints
+// where the card is, as path:line for an editor to jump to, and its text.
+// `knolhash card due <hash> 2025-07-01` makes it due on a date, keeping its
+// scheduling state, and records the change in the review log as a manual entry
+// rather than a review.
+func runCard(db *storage.DB, args []string) error {
+	const usage = "usage: knolhash card show <hash> | knolhash card due <hash> <YYYY-MM-DD>"
+	switch {
+	case len(args) == 2 && args[0] == "show":
+		return showCard(db, args[1])
+	case len(args) == 3 && args[0] == "due":
+	default:
+		return errors.New(usage)
+	}
+	hash := args[1]
+	due, err := time.ParseInLocation(time.DateOnly, args[2], time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid due date %q: %s", args[2], usage)
+	}
+	found, err := db.SetCardDueDate(hash, due)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no card with hash %s", hash)
+	}
+	slog.Info("Set due date", "hash", hash, "due", due.Format(time.DateOnly))
+	return nil
+}
+
+// showCard prints the file and line a card was last found on, then its question
+// and answer.
+func showCard(db *storage.DB, hash string) error {
+	card, err := db.FindCardByHash(hash)
+	if err != nil {
+		return err
+	}
+	if card == nil {
+		return fmt.Errorf("no card with hash %s", hash)
+	}
+	location := "unknown file, not synced since it was added"
+	if card.File != "" {
+		location = card.File
+		if card.SourceID.Valid {
+			source, err := db.FindSourceByID(card.SourceID.Int64)
+			if err != nil {
+				return err
+			}
+			if source != nil {
+				root, err := sync.LocalPath(*source)
+				if err != nil {
+					return err
+				}
+				location = filepath.Join(root, card.File)
+			}
+		}
+		if card.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, card.Line)
+		}
+	}
+	fmt.Fprintln(os.Stdout, location)
+	fmt.Fprintf(os.Stdout, "Q: %s\n", card.Question)
+	if card.Answer != "" {
+		fmt.Fprintf(os.Stdout, "A: %s\n", card.Answer)
+	}
+	return nil
+}
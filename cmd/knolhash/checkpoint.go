@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// parseCheckpoint parses the checkpoint setting: auto, or empty, leaves WAL
+// checkpoints to SQLite, off leaves them to Litestream, and an interval, such as
+// 1m, has knolhash checkpoint on that interval instead of after commits.
+func parseCheckpoint(s string) (auto bool, interval time.Duration, err error) {
+	switch s {
+	case "", "auto":
+		return true, 0, nil
+	case "off":
+		return false, 0, nil
+	}
+	interval, err = time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		return false, 0, fmt.Errorf("invalid checkpoint %q: want auto, off or a positive interval", s)
+	}
+	return false, interval, nil
+}
+
+// startBackgroundCheckpoint starts a goroutine that periodically checkpoints the
+// write-ahead log into the database file.
+func startBackgroundCheckpoint(db *storage.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := db.Checkpoint(); err != nil {
+				slog.Error("Background checkpoint failed", "error", err)
+			}
+		}
+	}()
+	slog.Info("Background checkpoint started", "interval", interval)
+}
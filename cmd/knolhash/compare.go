@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/stats"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/spf13/pflag"
+)
+
+// runCompareSchedulers replays the review history under two schedulers and
+// prints the workload and retention each would have led to, to help decide
+// whether to change the scheduler's settings: `knolhash compare-schedulers --a
+// fsrs --b fsrs:retention=0.85`, or `--b sm2` against SM-2.
+func runCompareSchedulers(db *storage.DB, args []string) error {
+	flags := pflag.NewFlagSet("compare-schedulers", pflag.ContinueOnError)
+	specA := flags.String("a", "fsrs", "the first scheduler: fsrs, fsrs:retention=<0-1> or sm2")
+	specB := flags.String("b", "sm2", "the second scheduler, as --a")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	a, err := parseScheduler(*specA)
+	if err != nil {
+		return fmt.Errorf("invalid --a: %w", err)
+	}
+	b, err := parseScheduler(*specB)
+	if err != nil {
+		return fmt.Errorf("invalid --b: %w", err)
+	}
+
+	c, err := stats.CompareSchedulers(db, db.Now(), a, b)
+	if err != nil {
+		return fmt.Errorf("failed to replay reviews: %w", err)
+	}
+	if c.A.Cards == 0 {
+		fmt.Println("No reviews to replay.")
+		return nil
+	}
+	fmt.Printf("Replayed %d reviews of %d cards.\n\n", c.A.Reviews, c.A.Cards)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\tA: %s\tB: %s\tB-A\n", *specA, *specB)
+	rows := []struct {
+		name   string
+		a, b   float64
+		format string
+	}{
+		{"Mean interval (days)", c.A.MeanInterval, c.B.MeanInterval, "%.1f"},
+		{"Reviews per day", c.A.DailyReviews, c.B.DailyReviews, "%.1f"},
+		{"Due in 30 days", float64(c.A.DueSoon), float64(c.B.DueSoon), "%.0f"},
+		{"Predicted retention", c.A.Retention * 100, c.B.Retention * 100, "%.1f%%"},
+		{"Replayed retention", c.A.ReplayedRetention * 100, c.B.ReplayedRetention * 100, "%.1f%%"},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t"+row.format+"\t"+row.format+"\t%+"+row.format[1:]+"\n", row.name, row.a, row.b, row.b-row.a)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("\nRetention is predicted by the default FSRS memory model, at each card's next due date.")
+	return nil
+}
+
+// parseScheduler parses a scheduler of compare-schedulers: fsrs with the default
+// parameters, fsrs:retention=0.85 with another desired retention, or sm2.
+func parseScheduler(spec string) (stats.Scheduler, error) {
+	name, options, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch name {
+	case "sm2", "sm-2":
+		if options != "" {
+			return nil, fmt.Errorf("sm2 has no options, got %q", options)
+		}
+		return stats.SM2{}, nil
+	case "fsrs":
+		params := fsrs.DefaultParams()
+		for option := range strings.SplitSeq(options, ",") {
+			if option == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(option, "=")
+			if key != "retention" {
+				return nil, fmt.Errorf("unknown fsrs option %q", key)
+			}
+			retention, err := strconv.ParseFloat(value, 64)
+			if err != nil || retention <= 0 || retention >= 1 {
+				return nil, fmt.Errorf("retention must be between 0 and 1, got %q", value)
+			}
+			params.DesiredRetention = retention
+		}
+		return stats.FSRS{Params: params}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler %q; use fsrs or sm2", spec)
+	}
+}
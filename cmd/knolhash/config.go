@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v3"
+)
+
+// secretKeys are the configuration keys whose values config show redacts.
+var secretKeys = []string{"client_secret", "refresh_token", "token", "signing_key", "password", "webhook_url"}
+
+// runConfig prints the effective configuration, merged from config.yaml, the
+// environment and flags, as a config.yaml: `knolhash config show`.
+func runConfig(cfg *Config, args []string) error {
+	const usage = "usage: knolhash config show [--show-secrets]"
+	if len(args) == 0 || args[0] != "show" {
+		return errors.New(usage)
+	}
+	flags := pflag.NewFlagSet("config show", pflag.ContinueOnError)
+	showSecrets := flags.Bool("show-secrets", false, "print tokens and secrets instead of redacting them")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New(usage)
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(configMap(reflect.ValueOf(*cfg), !*showSecrets)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// configMap converts a configuration struct to a map keyed by the koanf tags of
+// its fields, writing durations as koanf reads them, e.g. 30m0s. With redact,
+// the values of secretKeys, the API's and the widgets' tokens and the password
+// of the proxy URL are hidden.
+func configMap(v reflect.Value, redact bool) map[string]any {
+	m := make(map[string]any)
+	for i := range v.NumField() {
+		key := v.Type().Field(i).Tag.Get("koanf")
+		if key == "" {
+			continue
+		}
+		switch field := v.Field(i); {
+		case field.Kind() == reflect.Struct:
+			m[key] = configMap(field, redact)
+		case field.Type() == reflect.TypeFor[time.Duration]():
+			m[key] = time.Duration(field.Int()).String()
+		case redact && key == "proxy_url" && field.String() != "":
+			if u, err := url.Parse(field.String()); err == nil {
+				m[key] = u.Redacted()
+			}
+		case redact && slices.Contains(secretKeys, key) && field.String() != "":
+			m[key] = "REDACTED"
+		case redact && (key == "tokens" || key == "widget_tokens") && field.Len() > 0:
+			// The API's and the widgets' tokens, by name
+			tokens := make(map[string]string, field.Len())
+			for _, name := range field.MapKeys() {
+				tokens[name.String()] = "REDACTED"
+			}
+			m[key] = tokens
+		default:
+			m[key] = field.Interface()
+		}
+	}
+	return m
+}
+
+// envVar maps a KNOLHASH_ environment variable to a configuration key and value:
+// KNOLHASH_DB_PATH to db_path and KNOLHASH_NOTION_TOKEN to notion.token. Names
+// matching no key, e.g. those of tenants, have every _ read as a dot. Lists,
+// such as KNOLHASH_INITIAL_SOURCES, are comma-separated.
+func envVar(name, value string) (string, any) {
+	name = strings.ToLower(strings.TrimPrefix(name, "KNOLHASH_"))
+	for key, v := range configValues(configMap(reflect.ValueOf(Config{}), false), "") {
+		if strings.ReplaceAll(key, ".", "_") != name {
+			continue
+		}
+		if _, ok := v.([]string); ok {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			return key, items
+		}
+		return key, value
+	}
+	return strings.ReplaceAll(name, "_", "."), value
+}
+
+// configValues flattens a configMap to its values by dotted key.
+func configValues(m map[string]any, prefix string) map[string]any {
+	values := make(map[string]any)
+	for key, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			maps.Copy(values, configValues(sub, prefix+key+"."))
+		} else {
+			values[prefix+key] = v
+		}
+	}
+	return values
+}
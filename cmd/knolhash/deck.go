@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/conorfennell/knolhash/internal/bundle"
+	"github.com/conorfennell/knolhash/internal/registry"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+	"github.com/spf13/pflag"
+)
+
+// runExportDeck packs the cards and media of a source into a .knol bundle, e.g.
+// `knolhash export-deck 3 --name "Go Basics" -o go-basics.knol`.
+func runExportDeck(db *storage.DB, args []string) error {
+	flags := pflag.NewFlagSet("export-deck", pflag.ContinueOnError)
+	name := flags.String("name", "", "name of the deck (default: the source directory's name)")
+	description := flags.String("description", "", "description of the deck")
+	out := flags.StringP("output", "o", "", "bundle file to write (default: the deck's name with "+bundle.Ext+")")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: knolhash export-deck <source ID> [--name NAME] [--description TEXT] [-o FILE]")
+	}
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid source ID %q", flags.Arg(0))
+	}
+	source, err := db.FindSourceByID(id)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return fmt.Errorf("no source with ID %d", id)
+	}
+	root, err := sync.LocalPath(*source)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name = filepath.Base(root)
+	}
+	if *out == "" {
+		*out = *name + bundle.Ext
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *out, err)
+	}
+	if err := bundle.Write(f, root, bundle.Manifest{Name: *name, Description: *description}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+	slog.Info("Exported deck", "source_id", id, "file", *out)
+	return nil
+}
+
+// runImportDeck unpacks a .knol bundle as a new local source and syncs it.
+func runImportDeck(db *storage.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: knolhash import-deck <file" + bundle.Ext + ">")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", args[0], err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+
+	m, dir, err := bundle.Import(db, f, info.Size(), args[0])
+	if err != nil {
+		return err
+	}
+	slog.Info("Imported deck", "name", m.Name, "cards", m.Cards, "path", dir)
+	sync.RunSync(db)
+	return nil
+}
+
+// runDeck manages shared decks: `knolhash deck install <URL or name>` installs a
+// .knol bundle or git deck, looking names up in the configured deck index;
+// `knolhash deck list` lists the installed decks and `knolhash deck available`
+// the decks in the index.
+func runDeck(db *storage.DB, cfg *Config, args []string) error {
+	const usage = "usage: knolhash deck install <URL or name> | list | available"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "install":
+		if len(args) != 2 {
+			return errors.New("usage: knolhash deck install <URL or name>")
+		}
+		d, err := registry.Install(db, cfg.DeckIndex, args[1])
+		if err != nil {
+			return err
+		}
+		slog.Info("Installed deck", "name", d.Name, "origin", d.Origin, "source_id", d.SourceID)
+		sync.RunSync(db)
+		return nil
+	case "list":
+		return listDecks(db)
+	case "available":
+		if cfg.DeckIndex == "" {
+			return errors.New("deck_index must be configured")
+		}
+		idx, err := registry.FetchIndex(cfg.DeckIndex)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tDESCRIPTION\tURL")
+		for _, e := range idx.Decks {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Name, e.Description, e.URL)
+		}
+		return tw.Flush()
+	default:
+		return errors.New(usage)
+	}
+}
+
+// listDecks prints the installed decks with their sources.
+func listDecks(db *storage.DB) error {
+	decks, err := db.GetDecks()
+	if err != nil {
+		return err
+	}
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSOURCE\tPATH\tINSTALLED\tORIGIN")
+	for _, source := range sources {
+		d, ok := decks[source.ID]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", d.Name, source.ID, source.Path, d.InstalledAt.Format("2006-01-02"), d.Origin)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// demoResetInterval is how often the reviews made on the demo are undone.
+const demoResetInterval = time.Hour
+
+// seedDemo adds the demo sources to the empty in-memory database and syncs them.
+// Sources that fail to sync are logged and left empty, as with a normal sync.
+func seedDemo(db *storage.DB, cfg *Config) {
+	for _, path := range cfg.DemoSources {
+		if err := addNewSource(db, path); err != nil {
+			slog.Error("Failed to add demo source", "path", path, "error", err)
+		}
+	}
+	sync.RunSync(db)
+}
+
+// startBackgroundDemoReset starts a goroutine that periodically returns every card
+// of the demo to the new state.
+func startBackgroundDemoReset(db *storage.DB) {
+	ticker := time.NewTicker(demoResetInterval)
+	go func() {
+		for range ticker.C {
+			if err := db.ResetReviews(); err != nil {
+				slog.Error("Failed to reset demo reviews", "error", err)
+				continue
+			}
+			slog.Info("Demo reviews reset")
+		}
+	}()
+	slog.Info("Background demo reset started", "interval", demoResetInterval)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// runDoctor checks the cards for problems with their sources and prints any
+// found, failing if there are some.
+func runDoctor(db *storage.DB) error {
+	broken, err := sync.CheckLinks(db)
+	if err != nil {
+		return fmt.Errorf("failed to check links: %w", err)
+	}
+	if len(broken) == 0 {
+		fmt.Println("No broken links or missing media found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tFILE\tKIND\tTARGET\tCARD")
+	for _, b := range broken {
+		kind := "link"
+		if b.Image {
+			kind = "image"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.SourcePath, b.File, kind, b.Target, b.CardHash[:12])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return fmt.Errorf("found %d broken links", len(broken))
+}
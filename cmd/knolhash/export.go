@@ -0,0 +1,18 @@
+package main
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/conorfennell/knolhash/internal/export"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// runExportReviews writes the whole review log to stdout as JSON Lines.
+func runExportReviews(db *storage.DB) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := export.ReviewsJSONL(w, db); err != nil {
+		return err
+	}
+	return w.Flush()
+}
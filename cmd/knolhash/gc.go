@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// runGC prunes the repos cache, shared with any tenants, and prints the disk
+// usage of every clone.
+func runGC(db *storage.DB, cfg *Config) error {
+	tenants, err := openTenants(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeTenants(tenants)
+	dbs := []*storage.DB{db}
+	for _, t := range tenants {
+		dbs = append(dbs, t.db)
+	}
+	usage, err := sync.CollectGarbage(dbs...)
+	if err != nil {
+		return fmt.Errorf("garbage collection failed: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLONE\tSOURCE\tBEFORE\tAFTER\tSTATUS")
+	var before, after int64
+	for _, u := range usage {
+		source := "-"
+		if u.SourceID != 0 {
+			source = fmt.Sprint(u.SourceID)
+		}
+		status := "ok"
+		switch {
+		case u.Err != nil:
+			status = "error: " + u.Err.Error()
+		case u.Removed:
+			status = "removed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.Path, source, formatBytes(u.BytesBefore), formatBytes(u.BytesAfter), status)
+		before += u.BytesBefore
+		after += u.BytesAfter
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%s\t%s\t\n", formatBytes(before), formatBytes(after))
+	return tw.Flush()
+}
+
+// startBackgroundGC starts a goroutine that periodically garbage collects the
+// repos cache, keeping the clones of sources in any of the databases.
+func startBackgroundGC(interval time.Duration, dbs ...*storage.DB) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			slog.Info("Background garbage collection triggered", "interval", interval)
+			usage, err := sync.CollectGarbage(dbs...)
+			if err != nil {
+				slog.Error("Background garbage collection failed", "error", err)
+				continue
+			}
+			for _, u := range usage {
+				slog.Info("Clone disk usage", "path", u.Path, "source_id", u.SourceID,
+					"bytes_before", u.BytesBefore, "bytes_after", u.BytesAfter, "removed", u.Removed)
+			}
+		}
+	}()
+	slog.Info("Background garbage collection started", "interval", interval)
+}
+
+// formatBytes renders a byte count with a binary unit suffix, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
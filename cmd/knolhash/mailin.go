@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/conorfennell/knolhash/internal/mailin"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// mailInPath is where the inbound email service posts the emails to turn into cards.
+const mailInPath = "/inbound/email"
+
+// withMailIn serves the webhook turning emails into cards at mailInPath, in front
+// of next, writing them to the inbox's email directory and syncing each straight
+// away. The inbox is added as a source if it isn't one yet.
+func withMailIn(db *storage.DB, cfg *Config, next http.Handler) http.Handler {
+	dir := filepath.Join(cfg.InboxDir, "email")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Error("Failed to create the inbox for emailed cards", "error", err)
+		return next
+	}
+	if err := addNewSource(db, cfg.InboxDir); err != nil {
+		slog.Error("Failed to add the inbox for emailed cards", "error", err)
+		return next
+	}
+	added := func(path string) {
+		go func() {
+			if _, err := sync.SyncFile(db, path); err != nil {
+				slog.Error("Failed to sync emailed card", "path", path, "error", err)
+			}
+		}()
+	}
+	mux := http.NewServeMux()
+	mux.Handle(mailInPath, mailin.Handler(cfg.MailIn, dir, added))
+	mux.Handle("/", next)
+	slog.Info("Accepting cards by email", "path", mailInPath, "senders", cfg.MailIn.Senders)
+	return mux
+}
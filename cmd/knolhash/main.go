@@ -1,14 +1,28 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/conorfennell/knolhash/internal/buildinfo"
+	"github.com/conorfennell/knolhash/internal/bundle"
+	"github.com/conorfennell/knolhash/internal/clock"
+	"github.com/conorfennell/knolhash/internal/cloudsource"
+	"github.com/conorfennell/knolhash/internal/hooks"
+	"github.com/conorfennell/knolhash/internal/netconf"
+	"github.com/conorfennell/knolhash/internal/mailin"
+	"github.com/conorfennell/knolhash/internal/notion"
+	"github.com/conorfennell/knolhash/internal/parser"
 	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/summary"
 	"github.com/conorfennell/knolhash/internal/sync"
 	"github.com/conorfennell/knolhash/internal/web"
 
@@ -21,27 +35,96 @@ import (
 	"github.com/spf13/pflag" // Using pflag for better flag parsing with koanf
 )
 
-var (
-	commit    = "dev"
-	buildDate = "unknown"
-)
-
 // Config holds the application's configuration.
 type Config struct {
-	DBPath       string        `koanf:"db_path" validate:"required"`
+	DBPath       string        `koanf:"db_path"` // Defaults to knolhash.db in DataDir
 	Serve        bool          `koanf:"serve"`
 	ListenAddr   string        `koanf:"listen_addr" validate:"required_if=Serve true"`
 	SyncInterval time.Duration `koanf:"sync_interval" validate:"required_if=Serve true,gt=0"`
+	GCInterval   time.Duration `koanf:"gc_interval" validate:"gte=0"` // Defaults to daily when unset
+	ProxyURL     string        `koanf:"proxy_url" validate:"omitempty,url"`
+	CABundle     string        `koanf:"ca_bundle" validate:"omitempty,file"`
+
+	// MaxLineSize and MaxFileSize, in bytes, limit the lines and files parsed for
+	// cards; files over them are skipped with an error, keeping their cards
+	MaxLineSize int `koanf:"max_line_size" validate:"gte=0"`
+	MaxFileSize int `koanf:"max_file_size" validate:"gte=0"`
+
+	// DataDir holds the database, the clones and downloads of sources, imported
+	// decks and the inbox; the working directory by default
+	DataDir string `koanf:"data_dir"`
+
+	// ReadOnly serves a replica of the database, e.g. restored by Litestream or
+	// mounted by LiteFS, without syncing or accepting changes
+	ReadOnly bool `koanf:"read_only"`
+	// Checkpoint controls WAL checkpoints: auto (SQLite's), off (left to
+	// Litestream) or an interval, such as 1m, to checkpoint on
+	Checkpoint string `koanf:"checkpoint"`
+
+	// InboxDir is a local source for cards created by knolhash itself, e.g. imported from Notion
+	InboxDir string        `koanf:"inbox_dir"`
+	Notion   notion.Config `koanf:"notion"`
+	// MailIn turns emails posted by an inbound email service into cards in the inbox
+	MailIn mailin.Config `koanf:"mail_in"`
+	// WeeklySummary emails and/or posts a summary of each study week once it is over
+	WeeklySummary summary.Config `koanf:"weekly_summary"`
+
+	// DeckIndex is the URL of a JSON index of shared decks, to install them by name
+	DeckIndex string `koanf:"deck_index" validate:"omitempty,url"`
+
+	// CheckUpdates makes the server log a notice when a newer release is published
+	CheckUpdates bool `koanf:"check_updates"`
+
+	// PluginsDir holds plugins, whose executables hook into parsing, reviews and syncs
+	PluginsDir string `koanf:"plugins_dir"`
+	// Hooks maps events, such as sync-finished, to shell commands run with a JSON payload on stdin
+	Hooks map[string]string `koanf:"hooks"`
+
+	// OAuth credentials for dropbox: and gdrive: sources
+	Dropbox     cloudsource.OAuthConfig `koanf:"dropbox"`
+	GoogleDrive cloudsource.OAuthConfig `koanf:"google_drive"`
+
+	// Tenants maps names to databases, each served to a study group on the
+	// subdomain of that name, e.g. biology.example.org, and kept in sync alongside
+	// the main one, which serves every other host
+	Tenants map[string]string `koanf:"tenants"`
+
+	// API opens the JSON API to frontends on other origins, with tokens and rate limits
+	API web.APIConfig `koanf:"api"`
+
+	// InitialSources and InitialDecks are added on the first start against an
+	// empty database, e.g. from KNOLHASH_INITIAL_SOURCES in a compose file
+	InitialSources []string `koanf:"initial_sources"`
+	InitialDecks   []string `koanf:"initial_decks"`
+
+	// Demo serves the demo sources from an in-memory database that only allows reviewing
+	Demo        bool     `koanf:"demo"`
+	DemoSources []string `koanf:"demo_sources" validate:"required_if=Demo true"`
+
+	// FakeNow starts the clock cards are scheduled by at a date, 2006-01-02, or an
+	// RFC 3339 time, from where it runs on, for demos and simulations
+	FakeNow string `koanf:"fake_now"`
 }
 
 var k = koanf.New(".") // Initialize koanf with a dot delimiter
 
 func main() {
-	// 1. Configure Logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// 1. Configure Logger; knolhash review speaks its protocol over stdout, the sources
+	// and config commands print YAML to it, add-card and capture the hashes of new cards
+	// and summary its report, so they log to stderr, and a Windows service has neither,
+	// so it logs to a file
+	logOut := io.Writer(os.Stdout)
+	if len(os.Args) > 1 && slices.Contains([]string{"review", "sources", "config", "add-card", "capture", "summary"}, os.Args[1]) {
+		logOut = os.Stderr
+	}
+	if serviceLog := enterService(); serviceLog != nil {
+		logOut = serviceLog
+	}
+	logger := slog.New(slog.NewJSONHandler(logOut, nil))
 	slog.SetDefault(logger)
 
-	slog.Info("KnolHash starting up", "commit", commit, "build_date", buildDate)
+	info := buildinfo.Get()
+	slog.Info("KnolHash starting up", "version", info.Version, "commit", info.Commit, "build_date", info.BuildDate)
 
 	// 2. Set up pflag
 	pflags := pflag.NewFlagSet("knolhash", pflag.ExitOnError)
@@ -49,6 +132,15 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		pflags.PrintDefaults()
 	}
+	pflags.Bool("demo", false, "serve a read-only demo of the demo_sources from an in-memory database")
+	pflags.Bool("read-only", false, "serve a replica of the database without syncing or accepting changes")
+	pflags.String("data-dir", "", "directory holding the database, source clones, imported decks and the inbox")
+	pflags.String("fake-now", "", "schedule cards as if it were this date or RFC 3339 time, for demos and simulations")
+
+	// Flags and subcommands are exclusive: `knolhash --demo` or `knolhash gc`
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "-") {
+		pflags.Parse(os.Args[1:])
+	}
 
 	// Load from config.yaml (lowest precedence)
 	// Check for a config file path flag first
@@ -62,12 +154,8 @@ func main() {
 	}
 
 	// Load from environment variables (higher precedence than file)
-	// KNOLHASH_DB_PATH, KNOLHASH_LISTEN_ADDR, etc.
-	k.Load(env.Provider("KNOLHASH_", ".", func(s string) string {
-		return strings.ReplaceAll(strings.ToLower(
-			strings.TrimPrefix(s, "KNOLHASH_")),
-			"_", ".")
-	}), nil)
+	// KNOLHASH_DB_PATH, KNOLHASH_LISTEN_ADDR, KNOLHASH_NOTION_TOKEN, etc.
+	k.Load(env.ProviderWithValue("KNOLHASH_", ".", envVar), nil)
 
 	// Load from command-line flags (highest precedence)
 	k.Load(posflag.Provider(pflags, ".", k), nil)
@@ -77,6 +165,32 @@ func main() {
 		slog.Error("Failed to unmarshal configuration", "error", err)
 		os.Exit(1)
 	}
+	if cfg.Demo {
+		cfg.DBPath = ":memory:"
+		cfg.Serve = true
+	}
+	// The flags are named read-only, data-dir and fake-now, but the config keys read_only,
+	// data_dir and fake_now
+	if readOnly, _ := pflags.GetBool("read-only"); readOnly {
+		cfg.ReadOnly = true
+	}
+	if dataDir, _ := pflags.GetString("data-dir"); dataDir != "" {
+		cfg.DataDir = dataDir
+	}
+	if fakeNow, _ := pflags.GetString("fake-now"); fakeNow != "" {
+		cfg.FakeNow = fakeNow
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = filepath.Join(cfg.DataDir, "knolhash.db")
+	}
+	if _, _, err := parseCheckpoint(cfg.Checkpoint); err != nil {
+		slog.Error("Configuration validation failed", "error", err)
+		os.Exit(1)
+	}
+	if _, err := parseScheduleTime(cfg.FakeNow); err != nil {
+		slog.Error("Configuration validation failed", "error", fmt.Errorf("invalid fake_now: %w", err))
+		os.Exit(1)
+	}
 
 	// Validate configuration
 	validate := validator.New()
@@ -85,66 +199,226 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Outbound connections (git, HTTP) share the proxy and CA configuration
+	if err := netconf.Configure(netconf.Config{ProxyURL: cfg.ProxyURL, CABundle: cfg.CABundle}); err != nil {
+		slog.Error("Failed to configure outbound networking", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.DataDir != "" {
+		if err := os.MkdirAll(cfg.DataDir, os.ModePerm); err != nil {
+			slog.Error("Failed to create data directory", "path", cfg.DataDir, "error", err)
+			os.Exit(1)
+		}
+	}
+	sync.SetDataDir(cfg.DataDir)
+	bundle.SetDataDir(cfg.DataDir)
+	parser.SetLimits(cfg.MaxLineSize, cfg.MaxFileSize)
+	if cfg.InboxDir == "" {
+		cfg.InboxDir = filepath.Join(cfg.DataDir, "inbox")
+	}
+	cloudsource.Configure(cloudsource.Config{Dropbox: cfg.Dropbox, GoogleDrive: cfg.GoogleDrive})
+	if cfg.PluginsDir == "" {
+		cfg.PluginsDir = "plugins"
+	}
+	if err := hooks.Configure(cfg.PluginsDir, cfg.Hooks); err != nil {
+		slog.Error("Failed to configure hooks", "error", err)
+		os.Exit(1)
+	}
+
 	// 3. Open DB
-	db, err := storage.Open(cfg.DBPath)
+	db, err := storage.OpenWith(cfg.DBPath, dbOptions(&cfg))
 	if err != nil {
 		slog.Error("Failed to open database", "error", err)
 		os.Exit(1)
 	}
-	defer db.Close() // 4. Dispatch based on flags (now using config values)
+	defer db.Close() // 4. Dispatch based on subcommand, then flags (now using config values)
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(db, &cfg, os.Args[1], os.Args[2:]); err != nil {
+			slog.Error("Command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !cfg.Demo && !cfg.ReadOnly {
+		if err := bootstrap(db, &cfg); err != nil {
+			slog.Error("Failed to bootstrap the database", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	if cfg.Serve {
-		runWebServer(db, cfg.ListenAddr, cfg.SyncInterval)
+		if cfg.Demo {
+			seedDemo(db, &cfg)
+		}
+		runWebServer(db, &cfg)
 		return
 	}
 
 	// Default action is to sync
+	if cfg.ReadOnly {
+		slog.Error("Cannot sync a read-only database; sync on the primary")
+		os.Exit(1)
+	}
 	sync.RunSync(db)
 }
 
-// addNewSource adds a new source to the database, determining its type.
+// addNewSource adds a new source to the database, determining its type. A source
+// that has already been added is left as it is.
 func addNewSource(db *storage.DB, path string) error {
-	// This logic could be moved to a shared package if it gets more complex
-	sourceType := "local"
-	if strings.HasSuffix(path, ".git") || strings.HasPrefix(path, "git@") || strings.HasPrefix(path, "https://") {
-		sourceType = "git"
+	source, err := sync.AddSource(db, path)
+	if errors.Is(err, sync.ErrSourceExists) {
+		slog.Info("Source with path already exists", "path", source.Path)
+		return nil
 	}
-
-	existing, err := db.FindSourceByPath(path)
 	if err != nil {
-		return fmt.Errorf("error checking for existing source: %w", err)
+		return fmt.Errorf("could not add new source: %w", err)
 	}
-	if existing != nil {
-		slog.Info("Source with path already exists", "path", path)
-		return nil
+	slog.Info("Successfully added new source", "path", source.Path, "type", source.Type)
+	return nil
+}
+
+// dbOptions returns the options the main and tenant databases are opened with.
+func dbOptions(cfg *Config) storage.Options {
+	autoCheckpoint, _, _ := parseCheckpoint(cfg.Checkpoint) // Validated at startup
+	opts := storage.Options{ReadOnly: cfg.ReadOnly, NoAutoCheckpoint: !autoCheckpoint}
+	if fakeNow, _ := parseScheduleTime(cfg.FakeNow); !fakeNow.IsZero() {
+		opts.Clock = clock.StartingAt(fakeNow)
 	}
+	return opts
+}
 
-	_, err = db.InsertSource(path, sourceType)
-	if err != nil {
-		return fmt.Errorf("could not insert new source: %w", err)
+// runCommand runs a single CLI subcommand, e.g. `knolhash gc`.
+func runCommand(db *storage.DB, cfg *Config, name string, args []string) error {
+	switch name {
+	case "gc":
+		return runGC(db, cfg)
+	case "doctor":
+		return runDoctor(db)
+	case "plugins":
+		return runPlugins()
+	case "review":
+		return runReview(db, args)
+	case "version":
+		return runVersion(args)
+	case "service":
+		return runService(db, cfg, args)
+	case "deck":
+		return runDeck(db, cfg, args)
+	case "sources":
+		return runSources(db, args)
+	case "config":
+		return runConfig(cfg, args)
+	case "export-deck":
+		return runExportDeck(db, args)
+	case "import-deck":
+		return runImportDeck(db, args)
+	case "export-reviews":
+		return runExportReviews(db)
+	case "import-notion":
+		return runImportNotion(db, cfg, args)
+	case "import-schedule":
+		return runImportSchedule(db, args)
+	case "seed":
+		return runSeed(db, cfg, args)
+	case "card":
+		return runCard(db, args)
+	case "add-card":
+		return runAddCard(db, cfg, args)
+	case "capture":
+		return runCapture(db, cfg, args)
+	case "summary":
+		return runSummary(db, cfg, args)
+	case "compare-schedulers":
+		return runCompareSchedulers(db, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
 	}
-	slog.Info("Successfully added new source", "path", path, "type", sourceType)
-	return nil
 }
 
-// runWebServer starts the HTTP server and a background sync ticker.
-func runWebServer(db *storage.DB, addr string, syncInterval time.Duration) {
-	startBackgroundSync(db, syncInterval)
+// runWebServer starts the HTTP server and the background sync and maintenance
+// tickers, none of which run on a read-only replica.
+func runWebServer(db *storage.DB, cfg *Config) {
+	_, checkpointInterval, _ := parseCheckpoint(cfg.Checkpoint) // Validated at startup
+	if cfg.GCInterval == 0 {
+		cfg.GCInterval = 24 * time.Hour
+	}
+	if cfg.CheckUpdates && !cfg.Demo {
+		go logUpdate()
+	}
+	var tenants []tenant
+	if !cfg.Demo {
+		var err error
+		if tenants, err = openTenants(cfg); err != nil {
+			slog.Error("Failed to open tenants", "error", err)
+			os.Exit(1)
+		}
+	}
+	dbs := []*storage.DB{db}
+	for _, t := range tenants {
+		dbs = append(dbs, t.db)
+	}
+	switch {
+	case cfg.ReadOnly:
+		slog.Info("Serving a read-only replica; syncs and maintenance are left to the primary")
+	case cfg.Demo:
+		// No GC: the demo database doesn't know the clones of the data
+		// directory, so they would all look unreferenced
+		startBackgroundSync(db, cfg, nil)
+		startBackgroundDemoReset(db)
+	default:
+		startBackgroundSync(db, cfg, tenants)
+		startBackgroundGC(cfg.GCInterval, dbs...)
+		if cfg.WeeklySummary.Enabled() {
+			startBackgroundSummary(db, cfg.WeeklySummary)
+		}
+		for _, db := range dbs {
+			startBackgroundNotifications(db)
+			if checkpointInterval > 0 {
+				startBackgroundCheckpoint(db, checkpointInterval)
+			}
+		}
+	}
 
-	server := web.NewServer(db)
-	slog.Info("Starting web server", "addr", addr)
-	if err := http.ListenAndServe(addr, server); err != nil {
+	var handler http.Handler = web.NewServer(db, cfg.Demo, cfg.ReadOnly)
+	if len(tenants) > 0 {
+		router := &tenantRouter{main: handler, tenants: make(map[string]http.Handler)}
+		for _, t := range tenants {
+			router.tenants[t.name] = web.NewServer(t.db, false, cfg.ReadOnly)
+			slog.Info("Serving tenant", "tenant", t.name)
+		}
+		handler = router
+	}
+	handler = web.APIHandler(handler, cfg.API)
+	if cfg.MailIn.Enabled() && !cfg.Demo && !cfg.ReadOnly {
+		handler = withMailIn(db, cfg, handler)
+	}
+	slog.Info("Starting web server", "addr", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
 		slog.Error("Failed to start web server", "error", err)
 		os.Exit(1)
 	}
 }
 
-// startBackgroundSync starts a goroutine that periodically calls sync.RunSync.
-func startBackgroundSync(db *storage.DB, interval time.Duration) {
+// startBackgroundSync starts a goroutine that periodically calls sync.RunSync,
+// after importing from Notion if it is configured, then syncs every tenant.
+func startBackgroundSync(db *storage.DB, cfg *Config, tenants []tenant) {
+	interval := cfg.SyncInterval
 	ticker := time.NewTicker(interval)
 	go func() {
 		for range ticker.C {
 			slog.Info("Background sync triggered", "interval", interval)
+			if cfg.Notion.Enabled() && !cfg.Demo {
+				if err := importNotion(db, cfg, false); err != nil {
+					slog.Error("Notion import failed", "error", err)
+				}
+			}
 			sync.RunSync(db)
+			for _, t := range tenants {
+				slog.Info("Syncing tenant", "tenant", t.name)
+				sync.RunSync(t.db)
+			}
 		}
 	}()
 	slog.Info("Background sync started", "interval", interval)
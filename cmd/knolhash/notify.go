@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/notify"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// notifyCheckInterval is how often the notification schedule is checked.
+const notifyCheckInterval = time.Minute
+
+// startBackgroundNotifications starts a goroutine that sends the daily due cards
+// notification configured on the settings page.
+func startBackgroundNotifications(db *storage.DB) {
+	ticker := time.NewTicker(notifyCheckInterval)
+	go func() {
+		for now := range ticker.C {
+			if err := notify.CheckDue(db, now); err != nil {
+				slog.Error("Due cards notification failed", "error", err)
+			}
+		}
+	}()
+	slog.Info("Background notifications started", "check_interval", notifyCheckInterval)
+}
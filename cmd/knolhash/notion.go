@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/conorfennell/knolhash/internal/notion"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+	"github.com/spf13/pflag"
+)
+
+// runImportNotion imports the configured Notion database into the inbox and syncs it.
+// With --full, cards of all pages are rewritten, which also removes deleted pages.
+func runImportNotion(db *storage.DB, cfg *Config, args []string) error {
+	flags := pflag.NewFlagSet("import-notion", pflag.ContinueOnError)
+	full := flags.Bool("full", false, "re-import every page instead of only pages edited since the last import")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !cfg.Notion.Enabled() {
+		return errors.New("notion.token and notion.database_id must be configured")
+	}
+
+	if err := importNotion(db, cfg, *full); err != nil {
+		return err
+	}
+	sync.RunSync(db)
+	return nil
+}
+
+// importNotion materializes the Notion database as cards in the inbox's notion
+// directory and makes sure the inbox is registered as a source.
+func importNotion(db *storage.DB, cfg *Config, full bool) error {
+	res, err := notion.Import(cfg.Notion, filepath.Join(cfg.InboxDir, "notion"), full)
+	if err != nil {
+		return err
+	}
+	slog.Info("Imported Notion database", "database_id", cfg.Notion.DatabaseID, "written", res.Written, "removed", res.Removed)
+	return addNewSource(db, cfg.InboxDir)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/conorfennell/knolhash/internal/hooks"
+)
+
+// runPlugins lists the hooks of the installed plugins and the configured commands.
+func runPlugins() error {
+	var found []hooks.Hook
+	for _, event := range hooks.Events {
+		eventHooks, err := hooks.Find(event)
+		if err != nil {
+			return err
+		}
+		found = append(found, eventHooks...)
+	}
+	if len(found) == 0 {
+		fmt.Println("No plugins installed and no hook commands configured.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tPLUGIN\tRUNS")
+	for _, h := range found {
+		plugin, runs := h.Plugin, h.Path
+		if h.Command != "" {
+			plugin, runs = "-", h.Command
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Event, plugin, runs)
+	}
+	return tw.Flush()
+}
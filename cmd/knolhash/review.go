@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/conorfennell/knolhash/internal/rpc"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/spf13/pflag"
+)
+
+// runReview reviews the due cards for an editor plugin, speaking line-delimited
+// JSON over stdin and stdout.
+func runReview(db *storage.DB, args []string) error {
+	flags := pflag.NewFlagSet("review", pflag.ContinueOnError)
+	jsonRPC := flags.Bool("json-rpc", false, "speak line-delimited JSON requests and responses over stdin and stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*jsonRPC {
+		return errors.New("usage: knolhash review --json-rpc")
+	}
+	return rpc.Serve(db, os.Stdin, os.Stdout)
+}
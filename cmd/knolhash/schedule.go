@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/spf13/pflag"
+)
+
+// scheduleColumns are the column names recognised in a schedule file's header,
+// with the names other tools export them under.
+var scheduleColumns = map[string]string{
+	"hash":        "hash",
+	"question":    "question",
+	"front":       "question",
+	"interval":    "interval",
+	"ivl":         "interval",
+	"ease":        "ease",
+	"factor":      "ease",
+	"easiness":    "ease",
+	"due":         "due",
+	"last_review": "last_review",
+}
+
+// runImportSchedule carries the scheduling state of cards over from another tool,
+// such as Anki or Mnemosyne, so that an imported collection isn't all due today.
+// It reads a CSV file, or a tab-separated one ending in .tsv or .txt, with a
+// header naming its columns: hash or question, to find the card, interval in
+// days, and optionally ease, due and last_review. The interval and ease become
+// the card's stability and difficulty with fsrs.FromInterval. Cards already
+// reviewed here are left alone unless --force is given.
+func runImportSchedule(db *storage.DB, args []string) error {
+	const usage = "usage: knolhash import-schedule <file.csv> [--force] [--dry-run]"
+	flags := pflag.NewFlagSet("import-schedule", pflag.ContinueOnError)
+	force := flags.Bool("force", false, "also replace the state of cards already reviewed")
+	dryRun := flags.Bool("dry-run", false, "count the cards that would be scheduled without changing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	if ext := strings.ToLower(filepath.Ext(flags.Arg(0))); ext == ".tsv" || ext == ".txt" {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header of %s: %w", flags.Arg(0), err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		if column, ok := scheduleColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[column] = i
+		}
+	}
+	if _, ok := columns["interval"]; !ok {
+		return fmt.Errorf("%s has no interval column", flags.Arg(0))
+	}
+	_, byHash := columns["hash"]
+	if _, ok := columns["question"]; !ok && !byHash {
+		return fmt.Errorf("%s has neither a hash nor a question column", flags.Arg(0))
+	}
+
+	cards, err := db.GetAllCardsSortedByDueDate()
+	if err != nil {
+		return err
+	}
+	hashes := make(map[string]string) // Hashes by question, for files without hashes
+	for _, card := range cards {
+		hashes[scheduleKey(card.Question)] = card.Hash
+	}
+
+	params := fsrs.DefaultParams()
+	now := db.Now()
+	var scheduled, skipped, missing int
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", flags.Arg(0), err)
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		hash := field("hash")
+		if !byHash {
+			hash = hashes[scheduleKey(field("question"))]
+		}
+		card, err := db.FindCardByHash(hash)
+		if err != nil {
+			return err
+		}
+		if card == nil {
+			missing++
+			continue
+		}
+		if card.LastReview.Valid && !*force {
+			skipped++
+			continue
+		}
+
+		interval, err := parseNumber(field("interval"))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid interval: %w", line, err)
+		}
+		if interval <= 0 { // A new card in the other tool too
+			continue
+		}
+		ease, err := parseNumber(field("ease"))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid ease: %w", line, err)
+		}
+		due, err := parseScheduleTime(field("due"))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid due date: %w", line, err)
+		}
+		lastReview, err := parseScheduleTime(field("last_review"))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid last review: %w", line, err)
+		}
+		days := time.Duration(interval * 24 * float64(time.Hour))
+		switch {
+		case due.IsZero() && lastReview.IsZero():
+			lastReview, due = now, now.Add(days)
+		case due.IsZero():
+			due = lastReview.Add(days)
+		case lastReview.IsZero():
+			lastReview = due.Add(-days)
+		}
+		if lastReview.After(now) {
+			lastReview = now
+		}
+
+		scheduled++
+		if *dryRun {
+			continue
+		}
+		state := params.FromInterval(interval, ease)
+		card.Stability = state.Stability
+		card.Difficulty = state.Difficulty
+		card.DueDate = due
+		card.LastReview = sql.NullTime{Time: lastReview, Valid: true}
+		card.State = 2 // In review, like a reviewed card
+		if err := db.UpdateCard(card); err != nil {
+			return err
+		}
+	}
+	slog.Info("Imported schedule", "file", flags.Arg(0), "scheduled", scheduled, "already_reviewed", skipped, "not_found", missing, "dry_run", *dryRun)
+	return nil
+}
+
+// scheduleKey normalizes a question for matching cards by it.
+func scheduleKey(question string) string {
+	return strings.ToLower(strings.Join(strings.Fields(question), " "))
+}
+
+// parseNumber parses a decimal number, an empty string being 0.
+func parseNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseScheduleTime parses a date, 2006-01-02, or an RFC 3339 time, an empty
+// string being the zero time.
+func parseScheduleTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
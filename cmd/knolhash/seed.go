@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/clock"
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+	"github.com/spf13/pflag"
+)
+
+// maxSeedCards limits `knolhash seed --cards`, below the number of distinct
+// cards the generators can make.
+const maxSeedCards = 5000
+
+// seedGenerators make the synthetic cards of `knolhash seed`, each from a random
+// number, by context.
+var seedGenerators = []struct {
+	context  string
+	generate func(r *rand.Rand) (question, answer string)
+}{
+	{"Math/Multiplication", func(r *rand.Rand) (string, string) {
+		a, b := 2+r.IntN(98), 2+r.IntN(98)
+		return fmt.Sprintf("What is %d × %d?", min(a, b), max(a, b)), strconv.Itoa(a * b)
+	}},
+	{"Math/Squares", func(r *rand.Rand) (string, string) {
+		n := 2 + r.IntN(998)
+		return fmt.Sprintf("What is %d²?", n), strconv.Itoa(n * n)
+	}},
+	{"Numbers/Hexadecimal", func(r *rand.Rand) (string, string) {
+		n := 16 + r.IntN(4080)
+		return fmt.Sprintf("What is %d in hexadecimal?", n), fmt.Sprintf("0x%X", n)
+	}},
+	{"Numbers/Binary", func(r *rand.Rand) (string, string) {
+		n := 2 + r.IntN(1022)
+		return fmt.Sprintf("What is %d in binary?", n), strconv.FormatInt(int64(n), 2)
+	}},
+	{"Numbers/Roman Numerals", func(r *rand.Rand) (string, string) {
+		n := 1 + r.IntN(3999)
+		return fmt.Sprintf("What is %d in Roman numerals?", n), roman(n)
+	}},
+}
+
+// runSeed generates a synthetic collection with a review history, for
+// screenshots, performance testing and developing the stats pages: `knolhash
+// seed --cards 500 --history 90d` writes the cards to the seed directory of the
+// data directory, adds it as a source, syncs it and simulates a learner
+// reviewing them over the history, recording every review.
+func runSeed(db *storage.DB, cfg *Config, args []string) error {
+	flags := pflag.NewFlagSet("seed", pflag.ContinueOnError)
+	cards := flags.Int("cards", 500, fmt.Sprintf("number of cards to generate, up to %d", maxSeedCards))
+	history := flags.String("history", "90d", "how far back the review history goes, in days, e.g. 90d, or as a duration")
+	seed := flags.Uint64("seed", 1, "random seed; the same seed generates the same collection")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *cards < 1 || *cards > maxSeedCards {
+		return fmt.Errorf("--cards must be between 1 and %d", maxSeedCards)
+	}
+	span, err := parseDays(*history)
+	if err != nil || span <= 0 {
+		return fmt.Errorf("invalid --history %q", *history)
+	}
+
+	dir := filepath.Join(cfg.DataDir, "seed")
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists; delete its source and the directory to seed again", dir)
+	}
+	r := rand.New(rand.NewPCG(*seed, *seed))
+	if err := writeSeedCards(dir, r, *cards); err != nil {
+		return err
+	}
+	source, err := sync.AddSource(db, dir)
+	if err != nil {
+		return fmt.Errorf("failed to add seed source: %w", err)
+	}
+	sync.RunSync(db)
+
+	seeded, err := db.GetCardsBySourceID(source.ID)
+	if err != nil {
+		return err
+	}
+	reviews := 0
+	for i := range seeded {
+		n, err := simulateReviews(db, r, &seeded[i], db.Now().Add(-span))
+		if err != nil {
+			return err
+		}
+		reviews += n
+	}
+	slog.Info("Seeded collection", "path", dir, "cards", len(seeded), "reviews", reviews)
+	return nil
+}
+
+// writeSeedCards writes n distinct generated cards to dir, a file per context.
+func writeSeedCards(dir string, r *rand.Rand, n int) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	files := make(map[string]*strings.Builder)
+	seen := make(map[string]bool)
+	for len(seen) < n {
+		g := seedGenerators[r.IntN(len(seedGenerators))]
+		question, answer := g.generate(r)
+		if seen[question] {
+			continue
+		}
+		seen[question] = true
+		b := files[g.context]
+		if b == nil {
+			b = &strings.Builder{}
+			files[g.context] = b
+		}
+		fmt.Fprintf(b, "Q: %s\nA: %s\nC: %s\n---\n", question, answer, g.context)
+	}
+	for context, b := range files {
+		name := strings.ToLower(strings.NewReplacer("/", "-", " ", "-").Replace(context)) + ".md"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o644); err != nil {
+			return fmt.Errorf("failed to write seed cards: %w", err)
+		}
+	}
+	return nil
+}
+
+// simulateReviews reviews card as a learner would from a random day after start
+// until now: on or soon after each due date, recalling it with a probability
+// falling as the time since the last review grows against its stability. It
+// returns the number of reviews recorded.
+func simulateReviews(db *storage.DB, r *rand.Rand, card *storage.Card, start time.Time) (int, error) {
+	now := db.Now()
+	params := fsrs.DefaultParams()
+	span := now.Sub(start)
+	t := start.Add(time.Duration(r.Int64N(int64(span))))
+	var state fsrs.CardState
+	var due time.Time
+	reviews := 0
+	for t.Before(now) {
+		rating := fsrs.Good
+		switch p := r.Float64(); {
+		case state.Stability > 0 && p > math.Pow(0.9, t.Sub(state.LastReview).Hours()/24/state.Stability):
+			rating = fsrs.Again
+		case state.Stability == 0 && p < 0.15:
+			rating = fsrs.Again
+		case p < 0.3:
+			rating = fsrs.Hard
+		case p > 0.9:
+			rating = fsrs.Easy
+		}
+		params.Clock = clock.Fixed(t)
+		next := params.NextState(state, rating)
+		due = params.DueDate(next.Stability)
+		if err := db.InsertReviewLog(storage.ReviewLog{
+			CardHash:         card.Hash,
+			ReviewedAt:       t,
+			Grade:            int(rating),
+			StabilityBefore:  state.Stability,
+			DifficultyBefore: state.Difficulty,
+			StabilityAfter:   next.Stability,
+			DifficultyAfter:  next.Difficulty,
+			DueDateAfter:     due,
+			Duration:         time.Duration(3+r.IntN(25)) * time.Second,
+		}); err != nil {
+			return reviews, err
+		}
+		reviews++
+		state = next
+		// Due cards are reviewed within half a day, or a few days after a break.
+		t = due.Add(time.Duration(r.IntN(12*60)) * time.Minute)
+		if r.IntN(20) == 0 {
+			t = t.AddDate(0, 0, 1+r.IntN(4))
+		}
+	}
+	if reviews == 0 {
+		return 0, nil
+	}
+	card.Stability = state.Stability
+	card.Difficulty = state.Difficulty
+	card.DueDate = due
+	card.LastReview.Time, card.LastReview.Valid = state.LastReview, true
+	card.State = 2 // In review
+	return reviews, db.UpdateCard(card)
+}
+
+// parseDays parses a number of days, such as 90d, or a Go duration.
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// roman writes n, from 1 to 3999, in Roman numerals.
+func roman(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// enterService returns nil: only Windows starts knolhash as a service itself.
+func enterService() io.Writer {
+	return nil
+}
+
+// runService fails: elsewhere, run knolhash under systemd, launchd or Docker.
+func runService(db *storage.DB, cfg *Config, args []string) error {
+	return errors.New("knolhash service is only supported on Windows; use systemd, launchd or Docker elsewhere")
+}
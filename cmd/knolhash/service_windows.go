@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	serviceName        = "knolhash"
+	serviceDisplayName = "KnolHash"
+	serviceDescription = "Spaced repetition server for Markdown notes"
+	// serviceLogFile is written in the service's directory, as services have no console.
+	serviceLogFile = "knolhash.log"
+)
+
+// enterService prepares the process when Windows started it as the service:
+// it changes into the directory the service was installed from, where the
+// configuration and data live, and returns the log file opened there. It
+// returns nil when not running as the service.
+func enterService() io.Writer {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService || len(os.Args) != 4 || os.Args[1] != "service" || os.Args[2] != "run" {
+		return nil
+	}
+	if err := os.Chdir(os.Args[3]); err != nil {
+		return nil
+	}
+	f, err := os.OpenFile(serviceLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// runService manages knolhash as a Windows service running the web server:
+// install registers it to start automatically from the current directory,
+// uninstall removes it, start and stop control it, and run is what Windows runs.
+func runService(db *storage.DB, cfg *Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: knolhash service install|uninstall|start|stop")
+	}
+	switch args[0] {
+	case "run":
+		return svc.Run(serviceName, &service{db: db, cfg: cfg})
+	case "install":
+		return installService()
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", serviceName, err)
+	}
+	defer s.Close()
+
+	switch args[0] {
+	case "uninstall":
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to remove service: %w", err)
+		}
+		slog.Info("Removed service", "name", serviceName)
+	case "start":
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("failed to start service: %w", err)
+		}
+		slog.Info("Started service", "name", serviceName)
+	case "stop":
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+		slog.Info("Stopped service", "name", serviceName)
+	default:
+		return fmt.Errorf("unknown service command %q", args[0])
+	}
+	return nil
+}
+
+// installService registers the service to run this executable in the current
+// directory whenever Windows starts.
+func installService() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run", dir)
+	if err != nil {
+		return fmt.Errorf("failed to install service: %w", err)
+	}
+	defer s.Close()
+	slog.Info("Installed service; start it with `knolhash service start`", "name", serviceName, "dir", dir)
+	return nil
+}
+
+// service runs the web server until Windows stops it.
+type service struct {
+	db  *storage.DB
+	cfg *Config
+}
+
+// Execute implements svc.Handler.
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	go runWebServer(s.db, s.cfg)
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			slog.Info("Service stopping")
+			status <- svc.Status{State: svc.StopPending, WaitHint: uint32(5 * time.Second / time.Millisecond)}
+			return false, 0
+		}
+	}
+	return false, 0
+}
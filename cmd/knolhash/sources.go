@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/pflag"
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// sourcesFile lists the sources a database should have, e.g.
+//
+//	sources:
+//	  - path: /srv/notes
+//	  - path: https://github.com/me/cards.git
+//	    submodules: true
+//	    mirrors: [git@backup.example.com:me/cards.git]
+//	  - path: dropbox:/Flashcards
+//	    archived: true
+//	  - path: /srv/shared-notes
+//	    question_prefix: "Front:"
+//	    answer_prefix: "Back:"
+//	  - path: /srv/docs
+//	    extensions: [.md, .mdx]
+//	    exclude: [node_modules, .docusaurus]
+type sourcesFile struct {
+	Sources []sourceSpec `koanf:"sources" yaml:"sources"`
+}
+
+// sourceSpec is a source of a sourcesFile with its options.
+type sourceSpec struct {
+	Path        string   `koanf:"path" yaml:"path"`
+	Archived    bool     `koanf:"archived" yaml:"archived,omitempty"`
+	Submodules  bool     `koanf:"submodules" yaml:"submodules,omitempty"`
+	Mirrors     []string `koanf:"mirrors" yaml:"mirrors,omitempty"`
+	TrustedKeys string   `koanf:"trusted_keys" yaml:"trusted_keys,omitempty"`
+
+	// The syntax of the source's Markdown cards; see parser.Config.
+	QuestionPrefix string `koanf:"question_prefix" yaml:"question_prefix,omitempty"`
+	AnswerPrefix   string `koanf:"answer_prefix" yaml:"answer_prefix,omitempty"`
+	ContextPrefix  string `koanf:"context_prefix" yaml:"context_prefix,omitempty"`
+	Separator      string `koanf:"separator" yaml:"separator,omitempty"`
+	IgnoreCase     bool   `koanf:"ignore_case" yaml:"ignore_case,omitempty"`
+
+	// The files scanned for cards; see storage.Source.
+	Extensions []string `koanf:"extensions" yaml:"extensions,omitempty"`
+	Exclude    []string `koanf:"exclude" yaml:"exclude,omitempty"`
+}
+
+// syntax returns the syntax of the spec's Markdown cards.
+func (spec sourceSpec) syntax() parser.Config {
+	return parser.Config{
+		Question:   spec.QuestionPrefix,
+		Answer:     spec.AnswerPrefix,
+		Context:    spec.ContextPrefix,
+		Separator:  spec.Separator,
+		IgnoreCase: spec.IgnoreCase,
+		Extensions: spec.Extensions,
+	}
+}
+
+// runSources manages the sources from the command line: `knolhash sources
+// export` prints them as a sources file, which `knolhash sources apply` applies.
+func runSources(db *storage.DB, args []string) error {
+	const usage = "usage: knolhash sources export | apply <sources.yaml> [--prune [--keep-cards]] [--dry-run]"
+	if len(args) == 1 && args[0] == "export" {
+		return exportSources(db)
+	}
+	if len(args) == 0 || args[0] != "apply" {
+		return errors.New(usage)
+	}
+	flags := pflag.NewFlagSet("sources apply", pflag.ContinueOnError)
+	prune := flags.Bool("prune", false, "delete sources missing from the file")
+	keepCards := flags.Bool("keep-cards", false, "keep the cards of pruned sources, without a source")
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(flags.Arg(0)), yaml.Parser()); err != nil {
+		return fmt.Errorf("failed to read %s: %w", flags.Arg(0), err)
+	}
+	var f sourcesFile
+	if err := k.Unmarshal("", &f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", flags.Arg(0), err)
+	}
+
+	added, err := applySources(db, f.Sources, *prune, *keepCards, *dryRun)
+	if err != nil {
+		return err
+	}
+	if added && !*dryRun {
+		sync.RunSync(db)
+	}
+	return nil
+}
+
+// exportSources prints the sources of the database as a sources file.
+func exportSources(db *storage.DB) error {
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return err
+	}
+	f := sourcesFile{Sources: []sourceSpec{}}
+	for _, source := range sources {
+		f.Sources = append(f.Sources, sourceSpec{
+			Path:        source.Path,
+			Archived:    source.Archived,
+			Submodules:  source.Submodules,
+			Mirrors:     source.Mirrors,
+			TrustedKeys: source.TrustedKeys,
+
+			QuestionPrefix: source.QuestionPrefix,
+			AnswerPrefix:   source.AnswerPrefix,
+			ContextPrefix:  source.ContextPrefix,
+			Separator:      source.Separator,
+			IgnoreCase:     source.IgnoreCase,
+
+			Extensions: source.Extensions,
+			Exclude:    source.Exclude,
+		})
+	}
+	enc := goyaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// applySources reconciles the sources of the database with specs: it adds the
+// missing sources, updates the options of those that differ and, with prune,
+// deletes those not listed. Every path is validated before anything changes.
+// Each change is printed, prefixed with +, ~ or -; with dryRun nothing else
+// happens. It reports whether sources were added.
+func applySources(db *storage.DB, specs []sourceSpec, prune, keepCards, dryRun bool) (bool, error) {
+	existing, err := db.GetAllSources()
+	if err != nil {
+		return false, err
+	}
+	byPath := make(map[string]storage.Source)
+	for _, source := range existing {
+		byPath[sync.NormalizePath(source.Path)] = source
+	}
+
+	listed := make(map[string]bool)
+	for i := range specs {
+		spec := &specs[i]
+		spec.Path = sync.NormalizePath(spec.Path)
+		if spec.Path == "" {
+			return false, fmt.Errorf("source %d has no path", i+1)
+		}
+		if listed[spec.Path] {
+			return false, fmt.Errorf("source %s is listed twice", spec.Path)
+		}
+		listed[spec.Path] = true
+		if err := spec.syntax().Validate(); err != nil {
+			return false, fmt.Errorf("invalid card syntax for source %s: %w", spec.Path, err)
+		}
+		if _, ok := byPath[spec.Path]; !ok {
+			if err := sync.ValidateSource(spec.Path, sync.SourceType(spec.Path)); err != nil {
+				return false, fmt.Errorf("invalid source: %w", err)
+			}
+		}
+	}
+
+	added := false
+	for _, spec := range specs {
+		source, ok := byPath[spec.Path]
+		if !ok {
+			fmt.Printf("+ %s\n", spec.Path)
+			added = true
+			if dryRun {
+				continue
+			}
+			if source, err = sync.AddSource(db, spec.Path); err != nil {
+				return added, fmt.Errorf("failed to add source %s: %w", spec.Path, err)
+			}
+		} else if source.Archived == spec.Archived && source.Submodules == spec.Submodules &&
+			slices.Equal(source.Mirrors, spec.Mirrors) && source.TrustedKeys == spec.TrustedKeys &&
+			sync.Syntax(source).Equal(spec.syntax()) && slices.Equal(source.Exclude, spec.Exclude) {
+			continue
+		} else {
+			fmt.Printf("~ %s\n", spec.Path)
+			if dryRun {
+				continue
+			}
+		}
+
+		source.Submodules = spec.Submodules
+		source.Mirrors = spec.Mirrors
+		source.TrustedKeys = spec.TrustedKeys
+		if err := db.UpdateSourceOptions(&source); err != nil {
+			return added, err
+		}
+		source.QuestionPrefix = spec.QuestionPrefix
+		source.AnswerPrefix = spec.AnswerPrefix
+		source.ContextPrefix = spec.ContextPrefix
+		source.Separator = spec.Separator
+		source.IgnoreCase = spec.IgnoreCase
+		if err := db.UpdateSourceSyntax(&source); err != nil {
+			return added, err
+		}
+		source.Extensions = spec.Extensions
+		source.Exclude = spec.Exclude
+		if err := db.UpdateSourceFiles(&source); err != nil {
+			return added, err
+		}
+		if err := db.SetSourceArchived(source.ID, spec.Archived); err != nil {
+			return added, err
+		}
+	}
+
+	if !prune {
+		return added, nil
+	}
+	for _, source := range existing {
+		if listed[sync.NormalizePath(source.Path)] {
+			continue
+		}
+		contents, err := db.GetSourceContents(source.ID)
+		if err != nil {
+			return added, err
+		}
+		fate := "deleted"
+		if keepCards {
+			fate = "kept"
+		}
+		fmt.Printf("- %s (%d cards with %d reviews %s)\n", source.Path, contents.Cards, contents.Reviews, fate)
+		if dryRun {
+			continue
+		}
+		if err := db.DeleteSource(source.ID, keepCards); err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
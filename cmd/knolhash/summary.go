@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/summary"
+	"github.com/spf13/pflag"
+)
+
+// summaryCheckInterval is how often the weekly summary's schedule is checked.
+const summaryCheckInterval = time.Minute
+
+// startBackgroundSummary starts a goroutine that sends the summary of each study
+// week once it is over, as configured under weekly_summary.
+func startBackgroundSummary(db *storage.DB, cfg summary.Config) {
+	ticker := time.NewTicker(summaryCheckInterval)
+	go func() {
+		for range ticker.C {
+			// By the database's clock, as when previewed, so fake_now applies
+			if err := summary.CheckDue(db, cfg, db.Now()); err != nil {
+				slog.Error("Weekly summary failed", "error", err)
+			}
+		}
+	}()
+	slog.Info("Weekly summary scheduled", "check_interval", summaryCheckInterval)
+}
+
+// runSummary previews the summary of the last study week: `knolhash summary`
+// prints its HTML email, --text its plain text, and --send delivers it now.
+func runSummary(db *storage.DB, cfg *Config, args []string) error {
+	flags := pflag.NewFlagSet("summary", pflag.ContinueOnError)
+	text := flags.Bool("text", false, "print the summary as plain text instead of HTML")
+	send := flags.Bool("send", false, "send the summary to the webhook and email recipients configured")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *send && !cfg.WeeklySummary.Enabled() {
+		return errors.New("no webhook_url or email recipients configured under weekly_summary")
+	}
+
+	p, err := prefs.Load(db)
+	if err != nil {
+		return err
+	}
+	now := db.Now()
+	s, err := summary.Build(db, p, p.WeekStart(now).AddDate(0, 0, -7), now)
+	if err != nil {
+		return err
+	}
+	if *send {
+		return summary.Send(cfg.WeeklySummary, s)
+	}
+	if *text {
+		_, err = fmt.Fprint(os.Stdout, summary.Text(s))
+		return err
+	}
+	html, err := summary.HTML(s)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(html)
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// tenantName matches a tenant's name, which is also the first label of the host
+// name its study group visits, e.g. biology in biology.example.org.
+var tenantName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// tenant is an isolated database served on its own subdomain.
+type tenant struct {
+	name string
+	db   *storage.DB
+}
+
+// openTenants opens the database of every configured tenant, like the main one.
+func openTenants(cfg *Config) ([]tenant, error) {
+	var tenants []tenant
+	for name, path := range cfg.Tenants {
+		if !tenantName.MatchString(name) {
+			closeTenants(tenants)
+			return nil, fmt.Errorf("invalid tenant name %q: use lowercase letters, digits and hyphens", name)
+		}
+		if path == cfg.DBPath {
+			closeTenants(tenants)
+			return nil, fmt.Errorf("tenant %q shares the main database %s", name, path)
+		}
+		db, err := storage.OpenWith(path, dbOptions(cfg))
+		if err != nil {
+			closeTenants(tenants)
+			return nil, fmt.Errorf("failed to open database of tenant %q: %w", name, err)
+		}
+		tenants = append(tenants, tenant{name: name, db: db})
+	}
+	return tenants, nil
+}
+
+// closeTenants closes the databases of the tenants.
+func closeTenants(tenants []tenant) {
+	for _, t := range tenants {
+		t.db.Close()
+	}
+}
+
+// tenantRouter serves each tenant on the subdomain named after it and the main
+// database on any other host. Tenants are told apart by host name rather than a
+// path prefix, as the pages link to absolute paths.
+type tenantRouter struct {
+	main    http.Handler
+	tenants map[string]http.Handler
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (t *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, _, _ := strings.Cut(strings.ToLower(host), ".")
+	if h, ok := t.tenants[label]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	t.main.ServeHTTP(w, r)
+}
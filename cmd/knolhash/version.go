@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/conorfennell/knolhash/internal/buildinfo"
+	"github.com/conorfennell/knolhash/internal/web"
+	"github.com/spf13/pflag"
+)
+
+// runVersion prints the build information, optionally with the checksum of every
+// embedded asset, and checks for a newer release when asked to.
+func runVersion(args []string) error {
+	flags := pflag.NewFlagSet("version", pflag.ContinueOnError)
+	assets := flags.Bool("assets", false, "list the checksum of every embedded asset")
+	check := flags.Bool("check", false, "check GitHub for a newer release")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	info := web.BuildInfo()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(tw, "Commit:\t%s\n", info.Commit)
+	fmt.Fprintf(tw, "Built:\t%s\n", info.BuildDate)
+	fmt.Fprintf(tw, "Go:\t%s %s\n", info.GoVersion, info.Platform)
+	fmt.Fprintf(tw, "Assets:\t%s\n", info.Assets)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if *assets {
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, p := range slices.Sorted(maps.Keys(info.AssetFiles)) {
+			fmt.Fprintf(tw, "  %s\t%s\n", p, info.AssetFiles[p])
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if *check {
+		rel, err := buildinfo.LatestRelease()
+		if err != nil {
+			return err
+		}
+		if buildinfo.Newer(rel.Version, info.Version) {
+			fmt.Printf("A newer release, %s, is available: %s\n", rel.Version, rel.URL)
+		} else {
+			fmt.Printf("The latest release is %s.\n", rel.Version)
+		}
+	}
+	return nil
+}
+
+// logUpdate logs a notice if a release newer than the running version is published.
+func logUpdate() {
+	rel, err := buildinfo.LatestRelease()
+	if err != nil {
+		slog.Warn("Failed to check for a newer release", "error", err)
+		return
+	}
+	if version := buildinfo.Get().Version; buildinfo.Newer(rel.Version, version) {
+		slog.Info("A newer release is available", "version", rel.Version, "running", version, "url", rel.URL)
+	}
+}
@@ -0,0 +1,26 @@
+package buildinfo
+
+import "testing"
+
+func TestNewer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected bool
+	}{
+		{name: "Higher patch", a: "v1.2.4", b: "v1.2.3", expected: true},
+		{name: "Lower minor", a: "v1.2.0", b: "v1.10.0", expected: false},
+		{name: "Same version", a: "v1.2.3", b: "1.2.3", expected: false},
+		{name: "Extra component", a: "v1.2.3.1", b: "v1.2.3", expected: true},
+		{name: "Pre-release suffix is ignored", a: "v2.0.0-rc1", b: "v1.9.9", expected: true},
+		{name: "Development build", a: "v1.0.0", b: "dev", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Newer(tc.a, tc.b); got != tc.expected {
+				t.Errorf("Expected Newer(%q, %q) to be %v, but got %v", tc.a, tc.b, tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/pathsafe"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+const (
+	// Ext is the file extension of a bundle.
+	Ext = ".knol"
+	// manifestName is the file in a bundle holding its Manifest.
+	manifestName = "knol.json"
+	// format is the version of the bundle layout written by Write.
+	format = 1
+	// maxFileSize limits the size of a single unpacked file.
+	maxFileSize = 64 << 20
+)
+
+// decksDir is the directory imported bundles are unpacked into, one directory
+// per deck, each synced as a local source.
+var decksDir = "decks"
+
+// SetDataDir places the directory imported bundles are unpacked into below dir,
+// instead of the working directory.
+func SetDataDir(dir string) {
+	decksDir = filepath.Join(dir, "decks")
+}
+
+// mediaExts are the extensions of the files bundled next to the Markdown files.
+var mediaExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+	".mp3": true, ".ogg": true, ".wav": true, ".mp4": true, ".webm": true, ".pdf": true,
+}
+
+// Manifest describes the deck in a bundle. Bundles carry the cards and their
+// media only, never scheduling state, so every importer starts the deck afresh.
+type Manifest struct {
+	Format      int       `json:"format"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+	Cards       int       `json:"cards"`
+}
+
+// bundled reports whether a file below a deck's root goes into its bundle.
+func bundled(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".md" || mediaExts[ext]
+}
+
+// Write packs the Markdown files below root and the media files next to them into
+// a bundle written to w, with a manifest counting the cards. Hidden files and
+// directories, such as .git, are left out.
+func Write(w io.Writer, root string, m Manifest) error {
+	m.Format = format
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !bundled(d.Name()) {
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(p), ".md") {
+			cards, err := parser.ParseFile(p)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", p, err)
+			}
+			m.Cards += len(cards)
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list files of %s: %w", root, err)
+	}
+
+	zw := zip.NewWriter(w)
+	manifest, err := zw.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: m.Created})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	for _, p := range files {
+		if err := addFile(zw, root, p); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return nil
+}
+
+// addFile adds the file at p to the bundle under its slash-separated path from root.
+func addFile(zw *zip.Writer, root, p string) error {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	dst, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     path.Join("deck", filepath.ToSlash(rel)),
+		Method:   zip.Deflate,
+		Modified: info.ModTime(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", rel, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to add %s: %w", rel, err)
+	}
+	return nil
+}
+
+// Read unpacks the deck in the bundle r of the given size into dir, which is
+// created, and returns its manifest. Entries outside the deck directory of the
+// bundle, unsafe paths and files that are neither Markdown nor media are rejected.
+func Read(r io.ReaderAt, size int64, dir string) (Manifest, error) {
+	var m Manifest
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return m, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	manifest, err := zr.Open(manifestName)
+	if err != nil {
+		return m, fmt.Errorf("bundle has no %s: %w", manifestName, err)
+	}
+	err = json.NewDecoder(manifest).Decode(&m)
+	manifest.Close()
+	if err != nil {
+		return m, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if m.Format != format {
+		return m, fmt.Errorf("unsupported bundle format %d", m.Format)
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		return m, errors.New("bundle has no name")
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return m, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	for _, f := range zr.File {
+		if f.Name == manifestName || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rel, ok := strings.CutPrefix(f.Name, "deck/")
+		if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) || !bundled(rel) {
+			return m, fmt.Errorf("unexpected file %q in bundle", f.Name)
+		}
+		if err := extractFile(f, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// extractFile writes a file of the bundle to dst, creating its directory.
+func extractFile(f *zip.File, dst string) error {
+	if f.UncompressedSize64 > maxFileSize {
+		return fmt.Errorf("file %s in bundle is too large", f.Name)
+	}
+	src, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from bundle: %w", f.Name, err)
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, io.LimitReader(src, maxFileSize)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return out.Close()
+}
+
+// unsafeChars are replaced when turning a deck name into a directory name.
+var unsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Import unpacks a bundle into its own directory under the decks directory and
+// registers that directory as a local source, recorded as a deck installed from
+// origin, e.g. the bundle's file name or URL. It returns the manifest and the
+// directory. Importing a deck of the same name again replaces its files; cards
+// that are unchanged keep their review history.
+func Import(db *storage.DB, r io.ReaderAt, size int64, origin string) (Manifest, string, error) {
+	if err := os.MkdirAll(decksDir, os.ModePerm); err != nil {
+		return Manifest{}, "", fmt.Errorf("failed to create decks directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(decksDir, ".import-")
+	if err != nil {
+		return Manifest{}, "", fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	m, err := Read(r, size, tmp)
+	if err != nil {
+		return m, "", err
+	}
+	name := strings.Trim(unsafeChars.ReplaceAllString(strings.ToLower(m.Name), "-"), "-")
+	if name == "" {
+		return m, "", fmt.Errorf("invalid deck name %q", m.Name)
+	}
+	dir := filepath.Join(decksDir, pathsafe.Segment(name))
+	if err := os.RemoveAll(dir); err != nil {
+		return m, "", fmt.Errorf("failed to replace deck %s: %w", dir, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return m, "", fmt.Errorf("failed to move deck into %s: %w", dir, err)
+	}
+
+	existing, err := db.FindSourceByPath(dir)
+	if err != nil {
+		return m, dir, err
+	}
+	var sourceID int64
+	if existing != nil {
+		sourceID = existing.ID
+	} else if sourceID, err = db.InsertSource(dir, "local"); err != nil {
+		return m, dir, err
+	}
+	err = db.RecordDeck(storage.Deck{SourceID: sourceID, Name: m.Name, Origin: origin, InstalledAt: time.Now()})
+	return m, dir, err
+}
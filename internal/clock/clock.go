@@ -0,0 +1,31 @@
+package clock
+
+import "time"
+
+// Clock tells the current time. Scheduling takes the time from a Clock rather
+// than time.Now, so tests can fix it and demos and simulations can move it.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the real clock.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Fixed is a clock stopped at a time, for reproducible tests.
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time { return time.Time(f) }
+
+// StartingAt returns a clock that reads start now and runs on from it as real
+// time passes, as for `knolhash --fake-now`.
+func StartingAt(start time.Time) Clock {
+	return offsetClock(time.Until(start))
+}
+
+type offsetClock time.Duration
+
+func (o offsetClock) Now() time.Time { return time.Now().Add(time.Duration(o)) }
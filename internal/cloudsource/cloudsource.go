@@ -0,0 +1,321 @@
+package cloudsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/netconf"
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/pathsafe"
+)
+
+const (
+	dropboxPrefix     = "dropbox:"
+	googleDrivePrefix = "gdrive:"
+
+	// stateFile stores the change token and file index of the last sync in the source's directory.
+	stateFile = ".state.json"
+
+	requestTimeout = 5 * time.Minute
+)
+
+// OAuthConfig holds the OAuth client and a long-lived refresh token for one provider.
+// Access tokens are obtained from the refresh token as needed.
+type OAuthConfig struct {
+	ClientID     string `koanf:"client_id"`
+	ClientSecret string `koanf:"client_secret"`
+	RefreshToken string `koanf:"refresh_token"`
+}
+
+// Config holds the OAuth configuration of each provider. Providers without a
+// refresh token cannot be synced.
+type Config struct {
+	Dropbox     OAuthConfig `koanf:"dropbox"`
+	GoogleDrive OAuthConfig `koanf:"google_drive"`
+}
+
+var (
+	dropbox     = &oauthClient{tokenURL: "https://api.dropboxapi.com/oauth2/token"}
+	googleDrive = &oauthClient{tokenURL: "https://oauth2.googleapis.com/token"}
+)
+
+// Configure sets the OAuth configuration used by Sync.
+func Configure(cfg Config) {
+	dropbox.configure(cfg.Dropbox)
+	googleDrive.configure(cfg.GoogleDrive)
+}
+
+// Type returns the source type of a cloud folder path, "dropbox" for
+// dropbox:/path/to/folder and "gdrive" for gdrive:<folder ID>, or "" for other paths.
+func Type(path string) string {
+	switch {
+	case strings.HasPrefix(path, dropboxPrefix):
+		return "dropbox"
+	case strings.HasPrefix(path, googleDrivePrefix):
+		return "gdrive"
+	}
+	return ""
+}
+
+// LocalPath returns the directory below baseDir that the cloud folder at path is downloaded into.
+func LocalPath(baseDir, path string) (string, error) {
+	switch Type(path) {
+	case "dropbox":
+		folder := strings.Trim(strings.TrimPrefix(path, dropboxPrefix), "/")
+		return pathsafe.Join(baseDir, "dropbox", folder), nil
+	case "gdrive":
+		folderID := strings.TrimPrefix(path, googleDrivePrefix)
+		if folderID == "" || strings.ContainsAny(folderID, `/\.`) {
+			return "", fmt.Errorf("invalid Google Drive folder ID in %s", path)
+		}
+		return filepath.Join(baseDir, "gdrive", folderID), nil
+	}
+	return "", fmt.Errorf("not a cloud folder: %s", path)
+}
+
+// Sync brings the markdown files of the cloud folder at path up to date in localDir.
+// The first sync downloads every file; later syncs only apply the changes reported
+// since the change token recorded by the previous one.
+func Sync(path, localDir string) error {
+	if err := os.MkdirAll(localDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", localDir, err)
+	}
+	st, err := loadState(localDir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	switch Type(path) {
+	case "dropbox":
+		err = syncDropbox(ctx, strings.TrimPrefix(path, dropboxPrefix), localDir, st)
+	case "gdrive":
+		err = syncGoogleDrive(ctx, strings.TrimPrefix(path, googleDrivePrefix), localDir, st)
+	default:
+		err = fmt.Errorf("not a cloud folder: %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	return st.save(localDir)
+}
+
+// state is persisted between syncs of a cloud folder.
+type state struct {
+	// Cursor is the provider's change token: a Dropbox list_folder cursor or a
+	// Google Drive changes page token.
+	Cursor string `json:"cursor"`
+	// Files maps provider file IDs to their path relative to the local directory,
+	// so renamed and deleted files can be found. Only used for Google Drive.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+func loadState(dir string) (*state, error) {
+	st := &state{Files: make(map[string]string)}
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state in %s: %w", dir, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		slog.Warn("Discarding corrupt sync state, doing a full sync", "dir", dir, "error", err)
+		return &state{Files: make(map[string]string)}, nil
+	}
+	if st.Files == nil {
+		st.Files = make(map[string]string)
+	}
+	return st, nil
+}
+
+func (st *state) save(dir string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, stateFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write sync state in %s: %w", dir, err)
+	}
+	return nil
+}
+
+// isCardFile reports whether name may hold cards, like a markdown file, the only
+// files that are downloaded.
+func isCardFile(name string) bool {
+	return parser.IsCardFile(name)
+}
+
+// writeFile writes a downloaded file to rel below dir, refusing paths that escape dir.
+func writeFile(dir, rel string, content []byte) error {
+	p, err := localFile(dir, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", rel, err)
+	}
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	return nil
+}
+
+// removeFile removes the file at rel below dir, if it exists.
+func removeFile(dir, rel string) error {
+	p, err := localFile(dir, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", rel, err)
+	}
+	return nil
+}
+
+// localFile returns the path of rel below dir. An empty rel refers to dir itself.
+func localFile(dir, rel string) (string, error) {
+	if rel == "" {
+		return dir, nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("refusing to use unsafe path %q", rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(rel)), nil
+}
+
+// removeMarkdown removes every markdown file below dir, if it exists.
+func removeMarkdown(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == dir {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isCardFile(d.Name()) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// oauthClient makes API requests authorized with an access token obtained from a refresh token.
+type oauthClient struct {
+	tokenURL string
+
+	mu          gosync.Mutex
+	cfg         OAuthConfig
+	accessToken string
+	expiry      time.Time
+}
+
+func (c *oauthClient) configure(cfg OAuthConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+	c.accessToken = ""
+}
+
+// token returns a valid access token, refreshing it when it is about to expire.
+func (c *oauthClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cfg.RefreshToken == "" {
+		return "", errors.New("no OAuth refresh token configured")
+	}
+	if c.accessToken != "" && time.Until(c.expiry) > time.Minute {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.cfg.RefreshToken},
+		"client_id":     {c.cfg.ClientID},
+		"client_secret": {c.cfg.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doJSON(req, &resp); err != nil {
+		return "", fmt.Errorf("failed to refresh OAuth access token: %w", err)
+	}
+	c.accessToken = resp.AccessToken
+	c.expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return c.accessToken, nil
+}
+
+// do sends an authorized request and returns the response body.
+func (c *oauthClient) do(ctx context.Context, method, url string, body []byte, header http.Header) ([]byte, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return send(req)
+}
+
+// doJSON sends req and decodes its JSON response into v.
+func doJSON(req *http.Request, v any) error {
+	body, err := send(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// apiError is returned for responses with a non-2xx status.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.Status, e.Body)
+}
+
+func send(req *http.Request) ([]byte, error) {
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &apiError{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
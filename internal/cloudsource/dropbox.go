@@ -0,0 +1,126 @@
+package cloudsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var (
+	dropboxAPI     = "https://api.dropboxapi.com/2"
+	dropboxContent = "https://content.dropboxapi.com/2"
+)
+
+// dropboxEntry is a file, folder or deletion in a Dropbox list_folder response.
+type dropboxEntry struct {
+	Tag         string `json:".tag"`
+	Name        string `json:"name"`
+	PathLower   string `json:"path_lower"`
+	PathDisplay string `json:"path_display"`
+}
+
+type dropboxListResult struct {
+	Entries []dropboxEntry `json:"entries"`
+	Cursor  string         `json:"cursor"`
+	HasMore bool           `json:"has_more"`
+}
+
+// syncDropbox lists the folder recursively, or only the changes since st.Cursor,
+// and downloads changed markdown files into localDir.
+func syncDropbox(ctx context.Context, folder, localDir string, st *state) error {
+	folder = "/" + strings.Trim(folder, "/")
+	if folder == "/" {
+		folder = "" // The Dropbox API addresses the root folder as ""
+	}
+
+	var result dropboxListResult
+	full := st.Cursor == ""
+	if !full {
+		err := dropboxCall(ctx, "/files/list_folder/continue", map[string]any{"cursor": st.Cursor}, &result)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+			slog.Info("Dropbox cursor expired, doing a full sync", "folder", folder)
+			full = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if full {
+		if err := removeMarkdown(localDir); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", localDir, err)
+		}
+		if err := dropboxCall(ctx, "/files/list_folder", map[string]any{"path": folder, "recursive": true}, &result); err != nil {
+			return err
+		}
+	}
+
+	for {
+		for _, e := range result.Entries {
+			if err := applyDropboxEntry(ctx, folder, localDir, e); err != nil {
+				return err
+			}
+		}
+		st.Cursor = result.Cursor
+		if !result.HasMore {
+			return nil
+		}
+		result = dropboxListResult{}
+		if err := dropboxCall(ctx, "/files/list_folder/continue", map[string]any{"cursor": st.Cursor}, &result); err != nil {
+			return err
+		}
+	}
+}
+
+// applyDropboxEntry downloads or removes the local copy of a changed entry.
+func applyDropboxEntry(ctx context.Context, folder, localDir string, e dropboxEntry) error {
+	// Paths are case-insensitive, so the folder is matched against the lower-cased path.
+	if !strings.HasPrefix(e.PathLower, strings.ToLower(folder)) {
+		return nil
+	}
+	rel := strings.TrimPrefix(e.PathDisplay[len(folder):], "/")
+	switch e.Tag {
+	case "file":
+		if !isCardFile(e.Name) {
+			return nil
+		}
+		arg, _ := json.Marshal(map[string]string{"path": e.PathLower})
+		content, err := dropbox.do(ctx, http.MethodPost, dropboxContent+"/files/download", nil, http.Header{
+			"Dropbox-API-Arg": {string(arg)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to download %s from Dropbox: %w", e.PathDisplay, err)
+		}
+		slog.Info("Downloaded file from Dropbox", "path", e.PathDisplay)
+		return writeFile(localDir, rel, content)
+	case "deleted":
+		// Deletions don't say whether a file or folder was removed.
+		if isCardFile(e.Name) {
+			return removeFile(localDir, rel)
+		}
+		p, err := localFile(localDir, rel)
+		if err != nil {
+			return err
+		}
+		return removeMarkdown(p)
+	}
+	return nil
+}
+
+// dropboxCall calls an RPC endpoint of the Dropbox API with a JSON argument and result.
+func dropboxCall(ctx context.Context, endpoint string, arg, result any) error {
+	body, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	resp, err := dropbox.do(ctx, http.MethodPost, dropboxAPI+endpoint, body, http.Header{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return fmt.Errorf("Dropbox %s failed: %w", endpoint, err)
+	}
+	return json.Unmarshal(resp, result)
+}
@@ -0,0 +1,151 @@
+package cloudsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+var googleDriveAPI = "https://www.googleapis.com/drive/v3"
+
+// driveFile is the subset of a Google Drive file resource used to sync it.
+type driveFile struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Parents []string `json:"parents"`
+	Trashed bool     `json:"trashed"`
+}
+
+// syncGoogleDrive downloads the markdown files directly inside the folder, or only
+// applies the changes since st.Cursor. Subfolders are not synced.
+func syncGoogleDrive(ctx context.Context, folderID, localDir string, st *state) error {
+	if st.Cursor != "" {
+		err := syncGoogleDriveChanges(ctx, folderID, localDir, st)
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || (apiErr.Status != http.StatusNotFound && apiErr.Status != http.StatusGone) {
+			return err
+		}
+		slog.Info("Google Drive page token expired, doing a full sync", "folder", folderID)
+	}
+
+	// Take the token before listing, so changes made while listing are picked up next time.
+	var start struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := driveCall(ctx, "/changes/startPageToken", nil, &start); err != nil {
+		return err
+	}
+
+	if err := removeMarkdown(localDir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", localDir, err)
+	}
+	clear(st.Files)
+
+	query := url.Values{
+		"q":        {fmt.Sprintf("'%s' in parents and trashed = false", folderID)},
+		"fields":   {"nextPageToken,files(id,name,parents,trashed)"},
+		"pageSize": {"1000"},
+	}
+	for {
+		var list struct {
+			Files         []driveFile `json:"files"`
+			NextPageToken string      `json:"nextPageToken"`
+		}
+		if err := driveCall(ctx, "/files", query, &list); err != nil {
+			return err
+		}
+		for _, f := range list.Files {
+			if err := downloadDriveFile(ctx, localDir, f, st); err != nil {
+				return err
+			}
+		}
+		if list.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", list.NextPageToken)
+	}
+
+	st.Cursor = start.StartPageToken
+	return nil
+}
+
+// syncGoogleDriveChanges applies the changes since st.Cursor to the files of the folder.
+func syncGoogleDriveChanges(ctx context.Context, folderID, localDir string, st *state) error {
+	query := url.Values{
+		"pageToken": {st.Cursor},
+		"fields":    {"nextPageToken,newStartPageToken,changes(fileId,removed,file(id,name,parents,trashed))"},
+		"pageSize":  {"1000"},
+	}
+	for {
+		var changes struct {
+			Changes []struct {
+				FileID  string     `json:"fileId"`
+				Removed bool       `json:"removed"`
+				File    *driveFile `json:"file"`
+			} `json:"changes"`
+			NextPageToken     string `json:"nextPageToken"`
+			NewStartPageToken string `json:"newStartPageToken"`
+		}
+		if err := driveCall(ctx, "/changes", query, &changes); err != nil {
+			return err
+		}
+
+		for _, c := range changes.Changes {
+			// Changes cover the whole drive; a file may have been renamed, moved
+			// out of the folder or trashed, so any previous copy is removed first.
+			if old, ok := st.Files[c.FileID]; ok {
+				if err := removeFile(localDir, old); err != nil {
+					return err
+				}
+				delete(st.Files, c.FileID)
+			}
+			if c.Removed || c.File == nil || c.File.Trashed || !slices.Contains(c.File.Parents, folderID) {
+				continue
+			}
+			if err := downloadDriveFile(ctx, localDir, *c.File, st); err != nil {
+				return err
+			}
+		}
+
+		if changes.NewStartPageToken != "" {
+			st.Cursor = changes.NewStartPageToken
+			return nil
+		}
+		query.Set("pageToken", changes.NextPageToken)
+	}
+}
+
+// downloadDriveFile downloads f into localDir if it is a markdown file and records it in st.
+func downloadDriveFile(ctx context.Context, localDir string, f driveFile, st *state) error {
+	if !isCardFile(f.Name) {
+		return nil
+	}
+	content, err := googleDrive.do(ctx, http.MethodGet, googleDriveAPI+"/files/"+url.PathEscape(f.ID)+"?alt=media", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download %s from Google Drive: %w", f.Name, err)
+	}
+	if err := writeFile(localDir, f.Name, content); err != nil {
+		return err
+	}
+	st.Files[f.ID] = f.Name
+	slog.Info("Downloaded file from Google Drive", "name", f.Name)
+	return nil
+}
+
+// driveCall makes a GET request to the Google Drive API and decodes the JSON result.
+func driveCall(ctx context.Context, endpoint string, query url.Values, result any) error {
+	u := googleDriveAPI + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := googleDrive.do(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Google Drive %s failed: %w", endpoint, err)
+	}
+	return json.Unmarshal(resp, result)
+}
@@ -0,0 +1,173 @@
+package cloze
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sentinels delimit the blanked or revealed parts of code in the rendered card.
+// They survive Markdown rendering and client-side syntax highlighting, after
+// which the page replaces them with highlighting marks.
+const (
+	MarkStart = "\uE000"
+	MarkEnd   = "\uE001"
+)
+
+// deletion matches a cloze deletion, {{c::text}} or numbered as {{c1::text}}.
+var deletion = regexp.MustCompile(`(?s)\{\{c\d*::(.*?)\}\}`)
+
+// textDeletion matches a cloze deletion outside code, capturing its number,
+// which may be empty, and its text, which may end in a ::hint.
+var textDeletion = regexp.MustCompile(`(?s)\{\{c(\d*)::(.*?)\}\}`)
+
+// split applies replaceCode to the text of the fenced code blocks in a Markdown
+// source and replaceText to the text around them.
+func split(source string, replaceCode, replaceText func(string) string) string {
+	lines := strings.SplitAfter(source, "\n")
+	var out, code, text strings.Builder
+	flushText := func() {
+		out.WriteString(replaceText(text.String()))
+		text.Reset()
+	}
+	fence := "" // The opening fence of the code block being read, if any
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+			text.WriteString(line)
+			flushText()
+		case fence != "" && strings.HasPrefix(trimmed, fence):
+			out.WriteString(replaceCode(code.String()))
+			code.Reset()
+			fence = ""
+			text.WriteString(line)
+		case fence != "":
+			code.WriteString(line)
+		default:
+			text.WriteString(line)
+		}
+	}
+	flushText()
+	out.WriteString(code.String()) // An unclosed block runs to the end
+	return out.String()
+}
+
+// inCode applies replace to the text of the fenced code blocks in a Markdown
+// source, leaving the rest untouched.
+func inCode(source string, replace func(code string) string) string {
+	return split(source, replace, func(text string) string { return text })
+}
+
+// inText applies replace to the text outside the fenced code blocks of a
+// Markdown source, leaving the code untouched.
+func inText(source string, replace func(text string) string) string {
+	return split(source, func(code string) string { return code }, replace)
+}
+
+// textDeletions applies replace to each cloze deletion outside the code blocks
+// of a Markdown source, given its number, text and hint.
+func textDeletions(source string, replace func(index int, text, hint string) string) string {
+	return inText(source, func(text string) string {
+		return textDeletion.ReplaceAllStringFunc(text, func(d string) string {
+			m := textDeletion.FindStringSubmatch(d)
+			index, err := strconv.Atoi(m[1])
+			if err != nil {
+				index = 1 // {{c::text}} counts as the first
+			}
+			text, hint, _ := strings.Cut(m[2], "::")
+			return replace(index, text, hint)
+		})
+	})
+}
+
+// Has reports whether the fenced code blocks of a Markdown source contain cloze deletions.
+func Has(source string) bool {
+	found := false
+	inCode(source, func(code string) string {
+		found = found || deletion.MatchString(code)
+		return code
+	})
+	return found
+}
+
+// Blank replaces the cloze deletions in code blocks with underscores of the same
+// length, keeping line breaks so indentation is preserved.
+func Blank(source string) string {
+	return inCode(source, func(code string) string {
+		return deletion.ReplaceAllStringFunc(code, func(d string) string {
+			text := deletion.FindStringSubmatch(d)[1]
+			blank := strings.Map(func(r rune) rune {
+				if r == '\n' || r == '\t' || r == ' ' {
+					return r
+				}
+				return '_'
+			}, text)
+			return MarkStart + blank + MarkEnd
+		})
+	})
+}
+
+// Reveal replaces the cloze deletions in code blocks with their text.
+func Reveal(source string) string {
+	return inCode(source, func(code string) string {
+		return deletion.ReplaceAllString(code, MarkStart+"$1"+MarkEnd)
+	})
+}
+
+// Indexes returns the numbers of the cloze deletions outside the code blocks of a
+// Markdown source, such as 1 and 2 for "{{c1::Paris}} is in {{c2::France}}", in
+// ascending order and without repeats. An unnumbered deletion counts as 1.
+func Indexes(source string) []int {
+	var indexes []int
+	textDeletions(source, func(index int, text, hint string) string {
+		if !slices.Contains(indexes, index) {
+			indexes = append(indexes, index)
+		}
+		return ""
+	})
+	slices.Sort(indexes)
+	return indexes
+}
+
+// Deleted returns the text of the deletions numbered index outside the code
+// blocks of a Markdown source, separated by commas.
+func Deleted(source string, index int) string {
+	var deleted []string
+	textDeletions(source, func(i int, text, hint string) string {
+		if i == index {
+			deleted = append(deleted, strings.TrimSpace(text))
+		}
+		return ""
+	})
+	return strings.Join(deleted, ", ")
+}
+
+// BlankText replaces the deletions numbered index outside code blocks with
+// [...], or with their hint in brackets if they have one, as in
+// {{c1::Paris::city}}. The other deletions are replaced with their text.
+func BlankText(source string, index int) string {
+	return textDeletions(source, func(i int, text, hint string) string {
+		switch {
+		case i != index:
+			return text
+		case hint != "":
+			return "[" + hint + "]"
+		default:
+			return "[...]"
+		}
+	})
+}
+
+// RevealText replaces the deletions numbered index outside code blocks with
+// their text in bold, and the other deletions with their text.
+func RevealText(source string, index int) string {
+	return textDeletions(source, func(i int, text, hint string) string {
+		if i != index {
+			return text
+		}
+		return "**" + text + "**"
+	})
+}
@@ -0,0 +1,98 @@
+package cloze
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestBlankAndReveal(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedHas    bool
+		expectedBlank  string
+		expectedReveal string
+	}{
+		{
+			name:           "Deletion in code keeps indentation",
+			input:          "Print:\n```go\nfunc main() {\n\t{{c::fmt.Println}}(\"hi\")\n}\n```",
+			expectedHas:    true,
+			expectedBlank:  "Print:\n```go\nfunc main() {\n\t" + MarkStart + "___________" + MarkEnd + "(\"hi\")\n}\n```",
+			expectedReveal: "Print:\n```go\nfunc main() {\n\t" + MarkStart + "fmt.Println" + MarkEnd + "(\"hi\")\n}\n```",
+		},
+		{
+			name:           "Numbered deletion across lines",
+			input:          "~~~\nif x {\n{{c1::  return 1\n  }}}\n~~~",
+			expectedHas:    true,
+			expectedBlank:  "~~~\nif x {\n" + MarkStart + "  ______ _\n  " + MarkEnd + "}\n~~~",
+			expectedReveal: "~~~\nif x {\n" + MarkStart + "  return 1\n  " + MarkEnd + "}\n~~~",
+		},
+		{
+			name:           "Deletion outside code is left alone",
+			input:          "What is {{c::this}}?\n```\nx := 1\n```",
+			expectedHas:    false,
+			expectedBlank:  "What is {{c::this}}?\n```\nx := 1\n```",
+			expectedReveal: "What is {{c::this}}?\n```\nx := 1\n```",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Has(tc.input); got != tc.expectedHas {
+				t.Errorf("Expected Has to be %v, but got %v", tc.expectedHas, got)
+			}
+			if got := Blank(tc.input); got != tc.expectedBlank {
+				t.Errorf("Expected Blank to be %q, but got %q", tc.expectedBlank, got)
+			}
+			if got := Reveal(tc.input); got != tc.expectedReveal {
+				t.Errorf("Expected Reveal to be %q, but got %q", tc.expectedReveal, got)
+			}
+		})
+	}
+}
+
+func TestTextDeletions(t *testing.T) {
+	input := "{{c1::Paris}} is the capital of {{c2::France::a country}}.\n```\n{{c3::code}}\n```\nIt lies on the {{c::Seine}}."
+
+	if got, expected := Indexes(input), []int{1, 2}; !slices.Equal(got, expected) {
+		t.Errorf("Expected Indexes to be %v, but got %v", expected, got)
+	}
+	if got := Indexes("```\n{{c1::code}}\n```"); len(got) != 0 {
+		t.Errorf("Expected no Indexes for deletions in code, but got %v", got)
+	}
+
+	testCases := []struct {
+		index          int
+		expectedText   string
+		expectedBlank  string
+		expectedReveal string
+	}{
+		{
+			index:          1,
+			expectedText:   "Paris, Seine",
+			expectedBlank:  "[...] is the capital of France.\n```\n{{c3::code}}\n```\nIt lies on the [...].",
+			expectedReveal: "**Paris** is the capital of France.\n```\n{{c3::code}}\n```\nIt lies on the **Seine**.",
+		},
+		{
+			index:          2,
+			expectedText:   "France",
+			expectedBlank:  "Paris is the capital of [a country].\n```\n{{c3::code}}\n```\nIt lies on the Seine.",
+			expectedReveal: "Paris is the capital of **France**.\n```\n{{c3::code}}\n```\nIt lies on the Seine.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("c%d", tc.index), func(t *testing.T) {
+			if got := Deleted(input, tc.index); got != tc.expectedText {
+				t.Errorf("Expected Deleted to be %q, but got %q", tc.expectedText, got)
+			}
+			if got := BlankText(input, tc.index); got != tc.expectedBlank {
+				t.Errorf("Expected BlankText to be %q, but got %q", tc.expectedBlank, got)
+			}
+			if got := RevealText(input, tc.index); got != tc.expectedReveal {
+				t.Errorf("Expected RevealText to be %q, but got %q", tc.expectedReveal, got)
+			}
+		})
+	}
+}
@@ -2,12 +2,60 @@ package domain
 
 import "time"
 
+// Kinds of cards.
+const (
+	KindBasic = "" // A question and its answer
+	// KindWriting is a free-recall writing prompt: the Question is a topic to
+	// write down everything remembered about, and the Answer the section of notes
+	// to grade the writing against.
+	KindWriting = "writing"
+	// KindChoice is a multiple choice card: the Answer is the right option and
+	// the Distractors the wrong ones.
+	KindChoice = "choice"
+	// KindSteps asks for the Steps of a procedure in order; the Answer is optional.
+	KindSteps = "steps"
+	// KindCloze blanks out the cloze deletions in the code blocks of the Question;
+	// the Answer is optional.
+	KindCloze = "cloze"
+	// KindTextCloze blanks out the cloze deletions numbered Cloze in the text of
+	// the Question; the Answer is the deleted text.
+	KindTextCloze = "text-cloze"
+	// KindNumbered asks for the numbered answer Cloze of the Question, one of
+	// several written A1:, A2:, ...; the Answer is that answer.
+	KindNumbered = "numbered"
+)
+
 // Card represents a single question-answer-context entry.
 type Card struct {
 	Question string
 	Answer   string
 	Context  string
-	Hash     string
+	Kind     string
+	// Distractors are the wrong options of a multiple choice card, one line each.
+	Distractors []string
+	// Steps are the steps of an ordered procedure, in order.
+	Steps []string
+	// Cloze is the number of the deletions a text cloze card blanks out, or of
+	// the answer a numbered answer card asks for, from 1.
+	Cloze int
+	// Tags categorise the card beyond its Context. Like the hint, they are left
+	// out of the Hash.
+	Tags []string
+	// Hint is shown on request before the answer. It is left out of the Hash.
+	Hint string
+	// Media are the images the card shows, by relative path: as written when
+	// parsed, and from the source's root once synced. Like the hint, they are
+	// left out of the Hash.
+	Media []string
+	// ID is the identity the author pinned the card to, with an ID: line or an
+	// <!-- id: ... --> comment, which stands in for its content in the Hash.
+	ID string
+	// File is the path of the file the card was parsed from, as given to
+	// ParseFile or ParseCards, and Line the line of it the card starts on,
+	// counting from 1. Like the hint, they are left out of the Hash.
+	File string
+	Line int
+	Hash string
 }
 
 // ReviewLog records a single review event for a card.
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// reviewRecord is one line of the JSON Lines review export. Field names are
+// snake_case and times RFC 3339, so the file loads directly into pandas or R.
+type reviewRecord struct {
+	CardHash         string    `json:"card_hash"`
+	ReviewedAt       time.Time `json:"reviewed_at"`
+	Grade            int       `json:"grade"`
+	StabilityBefore  float64   `json:"stability_before"`
+	DifficultyBefore float64   `json:"difficulty_before"`
+	StabilityAfter   float64   `json:"stability_after"`
+	DifficultyAfter  float64   `json:"difficulty_after"`
+	DueDateAfter     time.Time `json:"due_date_after"`
+	DurationMS       *int64    `json:"duration_ms"` // null when the duration was not recorded
+	Correct          *bool     `json:"correct"`     // null unless a multiple choice card was reviewed
+	Hinted           bool      `json:"hinted"`
+	Manual           bool      `json:"manual"` // A due date set by hand rather than a review, graded 0
+}
+
+// ReviewsJSONL writes the whole review log to w as JSON Lines, one review per
+// line, oldest first.
+func ReviewsJSONL(w io.Writer, db *storage.DB) error {
+	logs, err := db.GetAllReviewLogs()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, log := range logs {
+		rec := reviewRecord{
+			CardHash:         log.CardHash,
+			ReviewedAt:       log.ReviewedAt,
+			Grade:            log.Grade,
+			StabilityBefore:  log.StabilityBefore,
+			DifficultyBefore: log.DifficultyBefore,
+			StabilityAfter:   log.StabilityAfter,
+			DifficultyAfter:  log.DifficultyAfter,
+			DueDateAfter:     log.DueDateAfter,
+			Hinted:           log.Hinted,
+			Manual:           log.Manual,
+		}
+		if log.Duration > 0 {
+			ms := log.Duration.Milliseconds()
+			rec.DurationMS = &ms
+		}
+		if log.Correct.Valid {
+			rec.Correct = &log.Correct.Bool
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("failed to write review of card %s: %w", log.CardHash, err)
+		}
+	}
+	return nil
+}
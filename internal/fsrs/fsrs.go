@@ -3,6 +3,8 @@ package fsrs
 import (
 	"math"
 	"time"
+
+	"github.com/conorfennell/knolhash/internal/clock"
 )
 
 type Rating int
@@ -19,6 +21,9 @@ type Params struct {
 	// In the real FSRS, there are 17-19 weights.
 	W                []float64
 	DesiredRetention float64
+	// Clock tells the time of reviews and the due dates counted from it, the
+	// system clock if nil.
+	Clock clock.Clock
 }
 
 func DefaultParams() *Params {
@@ -48,7 +53,7 @@ func (p *Params) NextState(currentState CardState, rating Rating) CardState {
 		return CardState{
 			Stability:  newStability,
 			Difficulty: newDifficulty,
-			LastReview: time.Now(),
+			LastReview: p.now(),
 		}
 	}
 
@@ -74,7 +79,7 @@ func (p *Params) NextState(currentState CardState, rating Rating) CardState {
 	return CardState{
 		Stability:  newStability,
 		Difficulty: newDifficulty,
-		LastReview: time.Now(),
+		LastReview: p.now(),
 	}
 }
 
@@ -100,8 +105,52 @@ func (p *Params) calculateNewStability(s, d float64, r Rating) float64 {
 }
 
 func NextDueDate(newStability float64) time.Time {
+	return dueDate(time.Now(), newStability)
+}
+
+// DueDate is NextDueDate by the params' clock.
+func (p *Params) DueDate(newStability float64) time.Time {
+	return dueDate(p.now(), newStability)
+}
+
+func dueDate(now time.Time, newStability float64) time.Time {
 	// Instead of math.Round, we use the stability as the raw day count.
 	// We add a tiny bit of "fuzz" to prevent cards from grouping together perfectly.
 	hours := newStability * 24
-	return time.Now().Add(time.Duration(hours) * time.Hour)
+	return now.Add(time.Duration(hours) * time.Hour)
+}
+
+// now returns the time by the params' clock.
+func (p *Params) now() time.Time {
+	if p.Clock == nil {
+		return time.Now()
+	}
+	return p.Clock.Now()
+}
+
+// defaultEase is the ease factor Anki and Mnemosyne give new cards.
+const defaultEase = 2.5
+
+// FromInterval approximates the state of a card scheduled by another tool, such
+// as Anki or Mnemosyne, from its interval in days and its ease factor, so that
+// imported cards keep their spacing instead of all becoming due at once. As a
+// card is due its stability in days after a review, the interval becomes the
+// stability. The ease maps linearly onto difficulty, the default ease of 2.5
+// giving the difficulty of a new card graded Good and the minimum ease of 1.3 the
+// maximum difficulty. The ease may be given as a factor, 2.5, or in permille as
+// Anki stores it, 2500; 0 means unknown and counts as the default.
+func (p *Params) FromInterval(intervalDays, ease float64) CardState {
+	if ease > 100 {
+		ease /= 1000
+	}
+	if ease <= 0 {
+		ease = defaultEase
+	}
+	// 1.3 is the lowest ease of Anki and Mnemosyne, the hardest cards.
+	slope := (10 - p.W[4]) / (defaultEase - 1.3)
+	difficulty := p.W[4] - slope*(ease-defaultEase)
+	return CardState{
+		Stability:  math.Max(0.1, intervalDays),
+		Difficulty: math.Max(1, math.Min(10, difficulty)),
+	}
 }
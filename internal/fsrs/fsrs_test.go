@@ -4,6 +4,8 @@ import (
 	"math"
 	"testing"
 	"time"
+
+	"github.com/conorfennell/knolhash/internal/clock"
 )
 
 func TestCalculateNewStability(t *testing.T) {
@@ -11,15 +13,14 @@ func TestCalculateNewStability(t *testing.T) {
 	stability := 10.0
 	difficulty := 5.0
 	
-	// S' = 10 * (1 + 0.2 * 5^(-0.5) * 10^0.1 * (e^(4 * (1-0.9)) - 1))
-	// S' = 10 * (1 + 0.2 * 0.447 * 1.259 * (e^0.4 - 1))
-	// S' = 10 * (1 + 0.112 * (1.4918 - 1))
-	// S' = 10 * (1 + 0.112 * 0.4918)
-	// S' = 10 * (1 + 0.055)
-	// S' = 10 * 1.055 = 10.55
-	expected := 10.55
+	// S' = 10 * (1 + e^1.49 * (11 - 5) * 10^(-0.14) * (e^(0.94 * (1-0.9)) - 1))
+	// S' = 10 * (1 + 4.437 * 6 * 0.7244 * (1.0986 - 1))
+	// S' = 10 * (1 + 19.285 * 0.0986)
+	// S' = 10 * (1 + 1.901)
+	// S' = 10 * 2.901 = 29.01
+	expected := 29.01
 	
-	newStability := params.calculateNewStability(stability, difficulty)
+	newStability := params.calculateNewStability(stability, difficulty, Good)
 	
 	if math.Abs(newStability-expected) > 0.01 {
 		t.Errorf("Expected new stability to be around %.2f, but got %.2f", expected, newStability)
@@ -36,8 +37,9 @@ func TestNextState(t *testing.T) {
 
 	t.Run("Review with Again", func(t *testing.T) {
 		newState := params.NextState(initialState, Again)
-		if newState.Stability != 1 {
-			t.Errorf("Expected stability to be reset to 1, but got %.2f", newState.Stability)
+		// Stability is scaled down by w7 (10 * 0.01 = 0.1)
+		if math.Abs(newState.Stability-0.1) > 0.001 {
+			t.Errorf("Expected stability to drop to 0.1, but got %.2f", newState.Stability)
 		}
 		if newState.Difficulty <= initialState.Difficulty {
 			t.Errorf("Expected difficulty to increase, but it did not. Got %.2f", newState.Difficulty)
@@ -67,9 +69,9 @@ func TestNextState(t *testing.T) {
 
 func TestNextDueDate(t *testing.T) {
 	now := time.Now()
-	stability := 15.5 // Should round to 16 days
-	
-	expectedDate := now.Add(16 * 24 * time.Hour)
+	stability := 15.5 // Used as a raw day count, no rounding
+
+	expectedDate := now.Add(time.Duration(15.5 * 24 * float64(time.Hour)))
 	actualDate := NextDueDate(stability)
 
 	// Check if the dates are on the same day (ignoring time-of-day differences)
@@ -77,3 +79,46 @@ func TestNextDueDate(t *testing.T) {
 		t.Errorf("Expected due date to be around %v, but got %v", expectedDate, actualDate)
 	}
 }
+
+func TestFromInterval(t *testing.T) {
+	params := DefaultParams()
+	testCases := []struct {
+		name                  string
+		interval, ease        float64
+		stability, minD, maxD float64
+	}{
+		{"Default ease", 30, 2.5, 30, 4.93, 4.93},
+		{"Anki permille ease", 30, 2500, 30, 4.93, 4.93},
+		{"Unknown ease", 12, 0, 12, 4.93, 4.93},
+		{"Lowest ease is hardest", 3, 1.3, 3, 10, 10},
+		{"High ease is easier", 100, 3.0, 100, 1, 4},
+		{"Very high ease is clamped", 100, 10, 100, 1, 1},
+		{"Zero interval", 0, 2.5, 0.1, 4.93, 4.93},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := params.FromInterval(tc.interval, tc.ease)
+			if math.Abs(state.Stability-tc.stability) > 0.001 {
+				t.Errorf("Expected stability %.2f, but got %.2f", tc.stability, state.Stability)
+			}
+			if state.Difficulty < tc.minD-0.001 || state.Difficulty > tc.maxD+0.001 {
+				t.Errorf("Expected difficulty between %.2f and %.2f, but got %.2f", tc.minD, tc.maxD, state.Difficulty)
+			}
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	params := DefaultParams()
+	params.Clock = clock.Fixed(now)
+
+	state := params.NextState(CardState{}, Good)
+	if !state.LastReview.Equal(now) {
+		t.Errorf("Expected the review to be at %v, but got %v", now, state.LastReview)
+	}
+	expected := now.Add(time.Duration(state.Stability*24) * time.Hour)
+	if due := params.DueDate(state.Stability); !due.Equal(expected) {
+		t.Errorf("Expected due date %v, but got %v", expected, due)
+	}
+}
@@ -1,32 +1,65 @@
 package gitsource
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
 )
 
+// Options controls optional behaviour of Sync.
+type Options struct {
+	// RecurseSubmodules initializes and updates all submodules recursively
+	// after cloning or pulling, so cards inside them are scanned too.
+	RecurseSubmodules bool
+
+	// Mirrors are fallback URLs for the same repository. When cloning from or
+	// pulling from the primary URL fails, each mirror is tried in order.
+	Mirrors []string
+
+	// OnProgress, if set, receives the progress reported by the remote while cloning or pulling.
+	OnProgress func(Progress)
+}
+
 // Sync clones a git repository if it doesn't exist at the given path,
 // or pulls the latest changes if it does.
-func Sync(url, localPath string) error {
+func Sync(url, localPath string, opts Options) error {
+	urls := append([]string{url}, opts.Mirrors...)
+
+	// A nil *progressWriter must not be stored in the io.Writer fields, or go-git would call it.
+	var progress sideband.Progress
+	if w := newProgressWriter(opts.OnProgress); w != nil {
+		progress = w
+	}
+
+	var repo *git.Repository
 	_, err := os.Stat(localPath)
 	if os.IsNotExist(err) {
-		// Path does not exist, clone the repository
-		slog.Info("Cloning repository", "url", url, "path", localPath)
-		_, err := git.PlainClone(localPath, false, &git.CloneOptions{
-			URL:      url,
-			Progress: os.Stdout, // You can make this more sophisticated later
-		})
+		// Path does not exist, clone the repository from the first URL that works
+		var errs []error
+		for _, u := range urls {
+			slog.Info("Cloning repository", "url", u, "path", localPath)
+			repo, err = git.PlainClone(localPath, false, &git.CloneOptions{
+				URL:      u,
+				Progress: progress,
+			})
+			if err == nil {
+				break
+			}
+			slog.Warn("Clone failed, trying next URL", "url", u, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+		}
 		if err != nil {
-			return fmt.Errorf("failed to clone repo %s: %w", url, err)
+			return fmt.Errorf("failed to clone repo %s: %w", url, errors.Join(errs...))
 		}
 		slog.Info("Clone successful.")
 	} else if err == nil {
 		// Path exists, pull the latest changes
 		slog.Info("Pulling latest changes for repository", "path", localPath)
-		repo, err := git.PlainOpen(localPath)
+		repo, err = git.PlainOpen(localPath)
 		if err != nil {
 			return fmt.Errorf("failed to open existing repo at %s: %w", localPath, err)
 		}
@@ -36,12 +69,23 @@ func Sync(url, localPath string) error {
 			return fmt.Errorf("failed to get worktree for repo at %s: %w", localPath, err)
 		}
 
-		err = worktree.Pull(&git.PullOptions{
-			RemoteName: "origin",
-			Progress:   os.Stdout,
-		})
+		// Pull from the first URL that works. The remote's configured URL is the one
+		// the repository was cloned from, so the primary URL is passed explicitly.
+		var errs []error
+		for _, u := range urls {
+			err = worktree.Pull(&git.PullOptions{
+				RemoteName: "origin",
+				RemoteURL:  u,
+				Progress:   progress,
+			})
+			if err == nil || err == git.NoErrAlreadyUpToDate {
+				break
+			}
+			slog.Warn("Pull failed, trying next URL", "url", u, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+		}
 		if err != nil && err != git.NoErrAlreadyUpToDate {
-			return fmt.Errorf("failed to pull changes for repo at %s: %w", localPath, err)
+			return fmt.Errorf("failed to pull changes for repo at %s: %w", localPath, errors.Join(errs...))
 		}
 		slog.Info("Pull successful (or already up-to-date).")
 	} else {
@@ -49,5 +93,36 @@ func Sync(url, localPath string) error {
 		return fmt.Errorf("error checking path %s: %w", localPath, err)
 	}
 
+	if opts.RecurseSubmodules {
+		if err := updateSubmodules(repo); err != nil {
+			return fmt.Errorf("failed to update submodules for repo at %s: %w", localPath, err)
+		}
+	}
+
 	return nil
 }
+
+// updateSubmodules initializes and updates every submodule of the repository,
+// recursing into nested submodules. It is run after every clone or pull rather
+// than relying on the clone/pull options, so enabling submodules for an
+// existing, already up-to-date clone still checks them out.
+func updateSubmodules(repo *git.Repository) error {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+	if len(submodules) == 0 {
+		return nil
+	}
+
+	slog.Info("Updating submodules", "count", len(submodules))
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	})
+}
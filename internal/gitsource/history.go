@@ -0,0 +1,91 @@
+package gitsource
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// LastChanged returns, for every card file in the HEAD commit of the repository
+// at localPath, such as a Markdown file, the time of the last commit that changed
+// it, keyed by slash-separated path from the repository root. The modification
+// times of the files in a clone only tell when they were checked out.
+//
+// The history is walked from HEAD, comparing each commit with its first parent,
+// until every file has been seen, so changes merged from a branch count from the
+// merge commit.
+func LastChanged(localPath string) (map[string]time.Time, error) {
+	repo, err := git.PlainOpen(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repo at %s: %w", localPath, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD of repo at %s: %w", localPath, err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit of repo at %s: %w", localPath, err)
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of HEAD commit: %w", err)
+	}
+
+	wanted := make(map[string]bool)
+	err = headTree.Files().ForEach(func(f *object.File) error {
+		if parser.IsCardFile(f.Name) {
+			wanted[f.Name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of HEAD commit: %w", err)
+	}
+
+	changed := make(map[string]time.Time, len(wanted))
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log of repo at %s: %w", localPath, err)
+	}
+	err = commits.ForEach(func(c *object.Commit) error {
+		if len(changed) == len(wanted) {
+			return storer.ErrStop
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		var parentTree *object.Tree
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			name := change.To.Name
+			if wanted[name] {
+				if _, seen := changed[name]; !seen {
+					changed[name] = c.Committer.When
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk history of repo at %s: %w", localPath, err)
+	}
+	return changed, nil
+}
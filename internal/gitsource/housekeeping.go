@@ -0,0 +1,46 @@
+package gitsource
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// pruneGracePeriod mirrors git gc's default: unreachable objects younger than this are kept.
+const pruneGracePeriod = 14 * 24 * time.Hour
+
+// Housekeep performs `git gc` on the clone at localPath. The git binary is used
+// when it is installed, as it is in the Docker image. Otherwise go-git prunes
+// unreachable loose objects older than two weeks and repacks all reachable
+// objects, which fails for repositories containing submodules.
+func Housekeep(localPath string) error {
+	if gitBin, err := exec.LookPath("git"); err == nil {
+		out, err := exec.Command(gitBin, "-C", localPath, "gc", "--quiet").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git gc failed for repo at %s: %w: %s", localPath, err, out)
+		}
+		return nil
+	}
+
+	repo, err := git.PlainOpen(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repo at %s: %w", localPath, err)
+	}
+
+	cutoff := time.Now().Add(-pruneGracePeriod)
+	if err := repo.Prune(git.PruneOptions{
+		OnlyObjectsOlderThan: cutoff,
+		Handler:              repo.DeleteObject,
+	}); err != nil {
+		return fmt.Errorf("failed to prune repo at %s: %w", localPath, err)
+	}
+
+	if err := repo.RepackObjects(&git.RepackConfig{
+		OnlyDeletePacksOlderThan: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to repack repo at %s: %w", localPath, err)
+	}
+	return nil
+}
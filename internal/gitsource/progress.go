@@ -0,0 +1,61 @@
+package gitsource
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is a single progress update reported by the remote during a clone or pull,
+// e.g. "Receiving objects" at 40 percent.
+type Progress struct {
+	Phase   string
+	Percent int // -1 when the phase reports no percentage
+}
+
+// progressLine matches sideband progress such as "Counting objects:  40% (2/5)".
+var progressLine = regexp.MustCompile(`^(.+?):\s+(\d+)%`)
+
+// progressWriter turns the sideband progress stream written by go-git into Progress
+// callbacks. Updates of the same phase are separated by carriage returns, phases by newlines.
+type progressWriter struct {
+	onProgress func(Progress)
+	buf        []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.report(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) report(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if m := progressLine.FindStringSubmatch(line); m != nil {
+		percent, _ := strconv.Atoi(m[2])
+		w.onProgress(Progress{Phase: m[1], Percent: percent})
+		return
+	}
+	phase, _, _ := strings.Cut(line, ":")
+	w.onProgress(Progress{Phase: phase, Percent: -1})
+}
+
+// newProgressWriter returns a writer reporting to onProgress, or nil if onProgress is nil
+// so that go-git skips requesting progress altogether.
+func newProgressWriter(onProgress func(Progress)) *progressWriter {
+	if onProgress == nil {
+		return nil
+	}
+	return &progressWriter{onProgress: onProgress}
+}
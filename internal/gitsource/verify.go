@@ -0,0 +1,209 @@
+package gitsource
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash.New
+	_ "crypto/sha512" // Registers SHA-512 for crypto.Hash.New
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	pgpKeyBegin       = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpKeyEnd         = "-----END PGP PUBLIC KEY BLOCK-----"
+	pgpSignatureBegin = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureBegin = "-----BEGIN SSH SIGNATURE-----"
+
+	// sshSigNamespace is the namespace git uses when signing commits with SSH keys.
+	sshSigNamespace = "git"
+)
+
+// ErrUnsigned is returned by VerifyHead when the HEAD commit carries no signature.
+var ErrUnsigned = errors.New("commit is not signed")
+
+// VerifyHead checks that the HEAD commit of the repository at localPath is signed
+// by one of the trusted keys. trustedKeys may contain armored PGP public key blocks
+// and SSH public keys in authorized_keys format (one per line), in any mix.
+func VerifyHead(localPath, trustedKeys string) error {
+	repo, err := git.PlainOpen(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repo at %s: %w", localPath, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to resolve HEAD at %s: %w", localPath, err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD commit %s: %w", head.Hash(), err)
+	}
+
+	pgpKeys, sshKeys, err := parseTrustedKeys(trustedKeys)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case commit.PGPSignature == "":
+		return fmt.Errorf("HEAD commit %s: %w", commit.Hash, ErrUnsigned)
+	case strings.HasPrefix(commit.PGPSignature, pgpSignatureBegin):
+		for _, key := range pgpKeys {
+			if _, err := commit.Verify(key); err == nil {
+				return nil
+			}
+		}
+	case strings.HasPrefix(commit.PGPSignature, sshSignatureBegin):
+		payload, err := signedPayload(commit)
+		if err != nil {
+			return err
+		}
+		if err := verifySSHSignature(commit.PGPSignature, payload, sshKeys); err == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("HEAD commit %s has an unsupported signature format", commit.Hash)
+	}
+	return fmt.Errorf("HEAD commit %s is not signed by a trusted key", commit.Hash)
+}
+
+// parseTrustedKeys splits the trusted keys into armored PGP key blocks and parsed SSH keys.
+func parseTrustedKeys(trustedKeys string) (pgpKeys []string, sshKeys []ssh.PublicKey, err error) {
+	rest := trustedKeys
+	var other strings.Builder
+	for {
+		begin := strings.Index(rest, pgpKeyBegin)
+		if begin < 0 {
+			other.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[begin:], pgpKeyEnd)
+		if end < 0 {
+			return nil, nil, errors.New("unterminated PGP public key block in trusted keys")
+		}
+		end += begin + len(pgpKeyEnd)
+		other.WriteString(rest[:begin])
+		pgpKeys = append(pgpKeys, rest[begin:end])
+		rest = rest[end:]
+	}
+
+	for _, line := range strings.Split(other.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SSH public key in trusted keys %q: %w", line, err)
+		}
+		sshKeys = append(sshKeys, key)
+	}
+	return pgpKeys, sshKeys, nil
+}
+
+// signedPayload returns the commit object as it was signed, i.e. without its signature.
+func signedPayload(commit *object.Commit) ([]byte, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// sshSig is the wire format of an SSH signature, as specified by OpenSSH's PROTOCOL.sshsig,
+// following the "SSHSIG" magic preamble.
+type sshSig struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the structure that is actually signed by an SSH signature.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// verifySSHSignature verifies an armored SSH signature over payload against the trusted keys.
+func verifySSHSignature(armored string, payload []byte, trusted []ssh.PublicKey) error {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return errors.New("malformed SSH signature")
+	}
+	const magic = "SSHSIG"
+	if !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return errors.New("missing SSHSIG preamble")
+	}
+
+	var sig sshSig
+	if err := ssh.Unmarshal(block.Bytes[len(magic):], &sig); err != nil {
+		return fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshSigNamespace {
+		return fmt.Errorf("unexpected SSH signature namespace %q", sig.Namespace)
+	}
+
+	signer, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key in SSH signature: %w", err)
+	}
+	if !isTrusted(signer, trusted) {
+		return fmt.Errorf("signing key %s is not trusted", ssh.FingerprintSHA256(signer))
+	}
+
+	var h crypto.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = crypto.SHA256
+	case "sha512":
+		h = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported SSH signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	hasher := h.New()
+	hasher.Write(payload)
+
+	signed := append([]byte(magic), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          hasher.Sum(nil),
+	})...)
+
+	var signature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &signature); err != nil {
+		return fmt.Errorf("malformed SSH signature blob: %w", err)
+	}
+	return signer.Verify(signed, &signature)
+}
+
+// isTrusted reports whether key is one of the trusted keys.
+func isTrusted(key ssh.PublicKey, trusted []ssh.PublicKey) bool {
+	for _, t := range trusted {
+		if bytes.Equal(key.Marshal(), t.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
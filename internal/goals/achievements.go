@@ -0,0 +1,168 @@
+package goals
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/stats"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Every freezeEvery study days in a row earn a streak freeze, up to maxFreezes
+// banked. A freeze is used up automatically to keep the streak on a day without
+// reviews.
+const (
+	freezeEvery = 7
+	maxFreezes  = 2
+)
+
+// Streak is the run of study days with at least one review.
+type Streak struct {
+	Current    int // Days studied in the current streak; today counts once studied
+	Longest    int
+	Freezes    int // Banked streak freezes
+	FrozenDays int // Days without reviews in the current streak, kept by a freeze
+}
+
+// reached lists, for every length up to the longest streak, the study day on which
+// a streak first reached it: reached[n-1] is the day of the first n-day streak.
+type reached []time.Time
+
+// streak computes the study streak at now from the times of all reviews, oldest first.
+func streak(p prefs.Preferences, times []time.Time, now time.Time) (Streak, reached) {
+	var s Streak
+	var first reached
+	if len(times) == 0 {
+		return s, first
+	}
+	studied := make(map[int64]bool) // By Unix time of the day start
+	for _, t := range times {
+		studied[p.DayStart(t).Unix()] = true
+	}
+
+	today := p.DayStart(now)
+	for day := p.DayStart(times[0]); !day.After(today); day = day.AddDate(0, 0, 1) {
+		switch {
+		case studied[day.Unix()]:
+			s.Current++
+			if s.Current%freezeEvery == 0 && s.Freezes < maxFreezes {
+				s.Freezes++
+			}
+			if s.Current > s.Longest {
+				s.Longest = s.Current
+				first = append(first, day)
+			}
+		case day.Equal(today):
+			// Today isn't over yet, so the streak still stands.
+		case s.Freezes > 0:
+			s.Freezes--
+			s.FrozenDays++
+		default:
+			s.Current, s.FrozenDays = 0, 0
+		}
+	}
+	return s, first
+}
+
+// Badge is a milestone, earned once its progress reaches the target.
+type Badge struct {
+	ID          string
+	Name        string
+	Description string
+	Target      int
+	Progress    int
+	EarnedAt    time.Time // Zero until earned
+}
+
+// Earned reports whether the badge has been earned.
+func (b Badge) Earned() bool {
+	return !b.EarnedAt.IsZero()
+}
+
+// Kinds of progress badges are earned for.
+const (
+	byReviews = iota
+	byStreak
+	byMatureCards
+)
+
+// badges are all the badges that can be earned, in the order they are shown.
+var badges = []struct {
+	ID, Name, Description string
+	Kind, Target          int
+}{
+	{"reviews_1", "First Steps", "Review your first card", byReviews, 1},
+	{"reviews_100", "Getting Started", "Review 100 cards", byReviews, 100},
+	{"reviews_1000", "Dedicated", "Review 1,000 cards", byReviews, 1000},
+	{"reviews_10000", "Scholar", "Review 10,000 cards", byReviews, 10000},
+	{"streak_7", "One Week", "Study 7 days in a row", byStreak, 7},
+	{"streak_30", "One Month", "Study 30 days in a row", byStreak, 30},
+	{"streak_100", "Hundred Days", "Study 100 days in a row", byStreak, 100},
+	{"streak_365", "One Year", "Study 365 days in a row", byStreak, 365},
+	{"mature_100", "Deep Roots", "Have 100 mature cards at once", byMatureCards, 100},
+	{"mature_1000", "Well Read", "Have 1,000 mature cards at once", byMatureCards, 1000},
+}
+
+// Trophies are the study streak and badges.
+type Trophies struct {
+	Streak Streak
+	Badges []Badge
+}
+
+// Achievements computes the streak and badges at now from the review log, and
+// records newly earned badges with the time they were earned. Earned badges are
+// kept even if the reviews that earned them are later deleted.
+func Achievements(db *storage.DB, p prefs.Preferences, now time.Time) (Trophies, error) {
+	var t Trophies
+	times, err := db.GetReviewTimesSince(time.Time{})
+	if err != nil {
+		return t, err
+	}
+	_, _, mature, err := db.CountCardsByMaturity(stats.MatureDays)
+	if err != nil {
+		return t, err
+	}
+	earned, err := db.GetAchievements()
+	if err != nil {
+		return t, err
+	}
+
+	var first reached
+	t.Streak, first = streak(p, times, now)
+	for _, def := range badges {
+		b := Badge{ID: def.ID, Name: def.Name, Description: def.Description, Target: def.Target, EarnedAt: earned[def.ID]}
+		var earnedAt time.Time
+		switch def.Kind {
+		case byReviews:
+			b.Progress = len(times)
+			if b.Progress >= b.Target {
+				earnedAt = times[b.Target-1]
+			}
+		case byStreak:
+			b.Progress = t.Streak.Current
+			if len(first) >= b.Target {
+				earnedAt = first[b.Target-1]
+			}
+		case byMatureCards:
+			b.Progress = mature
+			if b.Progress >= b.Target {
+				earnedAt = now
+			}
+		}
+		if !b.Earned() && !earnedAt.IsZero() {
+			// A read-only replica shows it earned; the primary records it
+			if !db.ReadOnly() {
+				if err := db.RecordAchievement(b.ID, earnedAt); err != nil {
+					return t, err
+				}
+			}
+			b.EarnedAt = earnedAt
+		}
+		if b.Earned() {
+			b.Progress = b.Target
+		}
+		b.Progress = min(b.Progress, b.Target)
+		t.Badges = append(t.Badges, b)
+	}
+	return t, nil
+}
@@ -0,0 +1,67 @@
+package goals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+)
+
+func TestStreak(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone database unavailable: %v", err)
+	}
+	p := prefs.Preferences{Timezone: "America/New_York", DayCutoff: 4}
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, loc)
+	at := func(daysAgo, hour int) time.Time {
+		return time.Date(2026, 3, 20-daysAgo, hour, 0, 0, 0, loc)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		times []time.Time
+		now   time.Time // now if zero
+		want  Streak
+	}{
+		{"no reviews", nil, time.Time{}, Streak{}},
+		{"every day up to today", []time.Time{at(2, 10), at(1, 10), at(0, 10)}, time.Time{}, Streak{Current: 3, Longest: 3}},
+		{"today not studied yet", []time.Time{at(2, 10), at(1, 10)}, time.Time{}, Streak{Current: 2, Longest: 2}},
+		{"yesterday missed", []time.Time{at(3, 10), at(2, 10)}, time.Time{}, Streak{Longest: 2}},
+		{"a gap starts over", []time.Time{at(5, 10), at(4, 10), at(1, 10), at(0, 10)}, time.Time{}, Streak{Current: 2, Longest: 2}},
+		{
+			"a freeze keeps the streak",
+			[]time.Time{at(9, 10), at(8, 10), at(7, 10), at(6, 10), at(5, 10), at(4, 10), at(3, 10), at(1, 10)},
+			time.Time{},
+			Streak{Current: 8, Longest: 8, FrozenDays: 1},
+		},
+		{"before the cutoff counts for the day before", []time.Time{at(1, 10), at(0, 3)}, time.Time{}, Streak{Current: 1, Longest: 1}},
+		{
+			"days of the preferred timezone",
+			// 08:00 and 22:00 on March 19 in New York, but two days in UTC
+			[]time.Time{time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 20, 2, 0, 0, 0, time.UTC)},
+			time.Time{},
+			Streak{Current: 1, Longest: 1},
+		},
+		{
+			"across the change to daylight saving time",
+			[]time.Time{time.Date(2026, 3, 7, 10, 0, 0, 0, loc), time.Date(2026, 3, 8, 10, 0, 0, 0, loc), time.Date(2026, 3, 9, 10, 0, 0, 0, loc)},
+			time.Date(2026, 3, 9, 12, 0, 0, 0, loc),
+			Streak{Current: 3, Longest: 3},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			at := tc.now
+			if at.IsZero() {
+				at = now
+			}
+			got, first := streak(p, tc.times, at)
+			if got != tc.want {
+				t.Errorf("Expected %+v, but got %+v", tc.want, got)
+			}
+			if len(first) != got.Longest {
+				t.Errorf("Expected the day each length was first reached up to %d, but got %d", got.Longest, len(first))
+			}
+		})
+	}
+}
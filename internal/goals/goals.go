@@ -0,0 +1,107 @@
+package goals
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/stats"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Names of the goals, as recorded in goal_completions.
+const (
+	Reviews  = "reviews"
+	NewCards = "new_cards"
+	Minutes  = "minutes"
+)
+
+// historyDays is how far back completions are counted for each goal.
+const historyDays = 30
+
+// Goal is the progress towards a study goal in its current period, the study day
+// or, for new cards, the study week.
+type Goal struct {
+	Name        string
+	Label       string // e.g. "Reviews today"
+	Target      int
+	Done        int
+	PeriodStart time.Time
+	Completions int // Periods the goal was met in the last 30 days, including this one
+}
+
+// Met reports whether the goal has been reached in its current period.
+func (g Goal) Met() bool {
+	return g.Done >= g.Target
+}
+
+// current computes the progress at now towards every goal that is set, in the
+// order reviews, new cards, minutes.
+func current(db *storage.DB, p prefs.Preferences, now time.Time) ([]Goal, error) {
+	var goals []Goal
+	if p.ReviewsGoal == 0 && p.NewCardsGoal == 0 && p.MinutesGoal == 0 {
+		return goals, nil
+	}
+
+	day, week := p.DayStart(now), p.WeekStart(now)
+	if p.ReviewsGoal > 0 {
+		newCards, reviews, err := db.CountReviewsSince(day)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, Goal{Name: Reviews, Label: "Reviews today", Target: p.ReviewsGoal, Done: newCards + reviews, PeriodStart: day})
+	}
+	if p.NewCardsGoal > 0 {
+		newCards, _, err := db.CountReviewsSince(week)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, Goal{Name: NewCards, Label: "New cards this week", Target: p.NewCardsGoal, Done: newCards, PeriodStart: week})
+	}
+	if p.MinutesGoal > 0 {
+		// The whole study day, as [day, now) is empty at its very start
+		minutes, err := stats.MinutesPerDay(db, p, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		done := 0
+		if len(minutes) > 0 {
+			done = int(minutes[0].Value)
+		}
+		goals = append(goals, Goal{Name: Minutes, Label: "Minutes today", Target: p.MinutesGoal, Done: done, PeriodStart: day})
+	}
+	return goals, nil
+}
+
+// Progress returns the progress at now towards every goal that is set, with how
+// often each was met recently.
+func Progress(db *storage.DB, p prefs.Preferences, now time.Time) ([]Goal, error) {
+	goals, err := current(db, p, now)
+	if err != nil || len(goals) == 0 {
+		return goals, err
+	}
+	counts, err := db.CountGoalCompletionsSince(p.DayStart(now).AddDate(0, 0, -historyDays))
+	if err != nil {
+		return nil, err
+	}
+	for i := range goals {
+		goals[i].Completions = counts[goals[i].Name]
+	}
+	return goals, nil
+}
+
+// Record records the completion of every goal met at now, once per period.
+func Record(db *storage.DB, p prefs.Preferences, now time.Time) error {
+	goals, err := current(db, p, now)
+	if err != nil {
+		return err
+	}
+	for _, g := range goals {
+		if !g.Met() {
+			continue
+		}
+		if err := db.RecordGoalCompleted(g.Name, g.PeriodStart, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package goals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestProgressAtDayStart(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	p := prefs.Preferences{Timezone: "UTC", DayCutoff: 4, MinutesGoal: 10}
+	now := time.Date(2026, 3, 10, 4, 0, 0, 0, time.UTC)
+	if !now.Equal(p.DayStart(now)) {
+		t.Fatalf("Expected %v to be the start of a study day", now)
+	}
+
+	// Yesterday's study time doesn't count towards today's goal
+	log := storage.ReviewLog{CardHash: "abc", ReviewedAt: now.Add(-time.Minute), Grade: 3, Duration: 4 * time.Minute}
+	if err := db.InsertReviewLog(log); err != nil {
+		t.Fatal(err)
+	}
+	goals, err := Progress(db, p, now)
+	if err != nil {
+		t.Fatalf("Progress returned an unexpected error: %v", err)
+	}
+	if len(goals) != 1 || goals[0].Name != Minutes || goals[0].Done != 0 || !goals[0].PeriodStart.Equal(now) {
+		t.Fatalf("Expected no minutes yet today, but got %+v", goals)
+	}
+
+	log.ReviewedAt = now.Add(time.Minute)
+	if err := db.InsertReviewLog(log); err != nil {
+		t.Fatal(err)
+	}
+	goals, err = Progress(db, p, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Progress returned an unexpected error: %v", err)
+	}
+	if len(goals) != 1 || goals[0].Done != 4 {
+		t.Errorf("Expected 4 minutes today, but got %+v", goals)
+	}
+}
@@ -0,0 +1,253 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Event names a point at which hooks run. A plugin handles an event by
+// providing an executable of the same name; a shell command can also be
+// configured for it.
+type Event string
+
+const (
+	// PreParse hooks transform a Markdown file before its cards are parsed. They
+	// read the file on stdin and write the Markdown to parse on stdout.
+	PreParse Event = "pre-parse"
+	// PostReview hooks are told about each review, as JSON on stdin. They run in
+	// the background and cannot change the review.
+	PostReview Event = "post-review"
+	// PreSync hooks run before each sync, with the sources as JSON on stdin. A
+	// pre-sync hook that fails cancels the sync.
+	PreSync Event = "pre-sync"
+	// SyncFinished hooks run after each sync, with the synced sources as JSON on
+	// stdin, e.g. to commit changed files back to git.
+	SyncFinished Event = "sync-finished"
+	// SessionEnded hooks run in the background when the last card of a review
+	// session has been reviewed, with the Session as JSON on stdin.
+	SessionEnded Event = "session-ended"
+	// LeechDetected hooks run in the background when a card becomes a leech, with
+	// the Leech as JSON on stdin.
+	LeechDetected Event = "leech-detected"
+)
+
+// Events lists the events hooks can handle.
+var Events = []Event{PreParse, PostReview, PreSync, SyncFinished, SessionEnded, LeechDetected}
+
+// Review is the payload of post-review hooks.
+type Review struct {
+	Card       string    `json:"card"` // Hash of the card
+	Question   string    `json:"question"`
+	Grade      int       `json:"grade"` // 1 (again) to 4 (easy)
+	Correct    *bool     `json:"correct,omitempty"`
+	Hinted     bool      `json:"hinted"`
+	DurationMS int64     `json:"duration_ms"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+	DueDate    time.Time `json:"due_date"`
+}
+
+// Source is a source in the payload of pre-sync hooks.
+type Source struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// SyncedSource is a source in the payload of sync-finished hooks.
+type SyncedSource struct {
+	Source
+	Dir   string `json:"dir"`             // Local directory of the source's files
+	Error string `json:"error,omitempty"` // Why the sync of the source failed
+}
+
+// Session is the payload of session-ended hooks.
+type Session struct {
+	Context string `json:"context,omitempty"` // Set when only cards of a context were reviewed
+	Kind    string `json:"kind,omitempty"`    // Set when only cards of a kind were reviewed
+	Reviews int    `json:"reviews"`           // Reviews so far this study day
+}
+
+// Leech is the payload of leech-detected hooks: a card forgotten so many times
+// that it likely needs rewriting.
+type Leech struct {
+	Card     string `json:"card"` // Hash of the card
+	Question string `json:"question"`
+	SourceID int64  `json:"source_id"`
+	File     string `json:"file,omitempty"`
+	Lapses   int    `json:"lapses"`
+}
+
+// hookTimeout bounds how long a single hook may run.
+const hookTimeout = time.Minute
+
+var (
+	dir      string
+	commands map[Event]string
+)
+
+// Configure sets the plugins directory and the shell commands run on events.
+// Each directory in the plugins directory is a plugin, whose executables named
+// after an event, such as plugins/tidy/pre-parse (pre-parse.exe, .bat or .cmd
+// on Windows), are its hooks. Hooks can be scripts or compiled programs, and are
+// looked up on every event, so plugins are picked up without a restart.
+func Configure(pluginsDir string, eventCommands map[string]string) error {
+	dir = pluginsDir
+	commands = make(map[Event]string)
+	for name, command := range eventCommands {
+		event := Event(name)
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown hook event %q", name)
+		}
+		commands[event] = command
+	}
+	return nil
+}
+
+// Hook is an executable of a plugin, or a configured shell command, handling an event.
+type Hook struct {
+	Plugin  string // Empty for a configured command
+	Event   Event
+	Path    string
+	Command string // Run with sh -c, or cmd /C on Windows, instead of Path, when set
+}
+
+// Find returns the hooks for event: those of all plugins, ordered by plugin name,
+// then the configured command.
+func Find(event Event) ([]Hook, error) {
+	var hooks []Hook
+	if dir != "" {
+		var err error
+		if hooks, err = findPlugins(event); err != nil {
+			return nil, err
+		}
+	}
+	if command := commands[event]; command != "" {
+		hooks = append(hooks, Hook{Event: event, Command: command})
+	}
+	return hooks, nil
+}
+
+// findPlugins returns the hooks of all plugins for event, ordered by plugin name.
+func findPlugins(event Event) ([]Hook, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory %s: %w", dir, err)
+	}
+	var hooks []Hook
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if p, ok := executable(filepath.Join(dir, e.Name(), string(event))); ok {
+			hooks = append(hooks, Hook{Plugin: e.Name(), Event: event, Path: p})
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Plugin < hooks[j].Plugin })
+	return hooks, nil
+}
+
+// windowsExts are the extensions of the executables Windows runs. Windows has no
+// executable bit, so a hook there is a file named after the event with one of them.
+var windowsExts = []string{".exe", ".bat", ".cmd"}
+
+// executable returns the path of the executable file at p, or on Windows at p
+// with one of windowsExts, and reports whether there is one.
+func executable(p string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		for _, ext := range windowsExts {
+			if info, err := os.Stat(p + ext); err == nil && !info.IsDir() {
+				return p + ext, true
+			}
+		}
+		return "", false
+	}
+	info, err := os.Stat(p)
+	return p, err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
+
+// String describes the hook for errors and listings.
+func (h Hook) String() string {
+	if h.Plugin == "" {
+		return fmt.Sprintf("%s command %q", h.Event, h.Command)
+	}
+	return fmt.Sprintf("%s hook of plugin %s", h.Event, h.Plugin)
+}
+
+// run runs the hook with stdin as its input and returns its output. The hook
+// also gets the event in KNOLHASH_EVENT, along with env.
+func (h Hook) run(stdin []byte, env ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, h.Path)
+	if h.Command != "" && runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else if h.Command != "" {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), "KNOLHASH_EVENT="+string(h.Event))
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", h, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Transform passes the Markdown of the file at path through the pre-parse hooks
+// in turn, each given the path in KNOLHASH_FILE, and returns the result.
+func Transform(path string, markdown []byte) ([]byte, error) {
+	hooks, err := Find(PreParse)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		if markdown, err = h.run(markdown, "KNOLHASH_FILE="+path); err != nil {
+			return nil, err
+		}
+	}
+	return markdown, nil
+}
+
+// Notify runs the hooks for event with payload as JSON on stdin, one after
+// another, stopping at the first that fails.
+func Notify(event Event, payload any) error {
+	hooks, err := Find(event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", event, err)
+	}
+	for _, h := range hooks {
+		if _, err := h.run(stdin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyAsync runs Notify in the background, logging a failure.
+func NotifyAsync(event Event, payload any) {
+	go func() {
+		if err := Notify(event, payload); err != nil {
+			slog.Warn("Hook failed", "event", event, "error", err)
+		}
+	}()
+}
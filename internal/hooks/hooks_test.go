@@ -0,0 +1,66 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no executable bit")
+	}
+	plugins := t.TempDir()
+	for path, mode := range map[string]os.FileMode{
+		"tidy/pre-parse":     0o755,
+		"lint/pre-parse":     0o700,
+		"notes/pre-parse":    0o644, // Not executable
+		"other/post-review":  0o755, // Another event
+		"pre-parse":          0o755, // Not in a plugin
+		"nested/pre-parse/x": 0o755, // A directory named after the event
+	} {
+		p := filepath.Join(plugins, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("#!/bin/sh\ncat\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { Configure("", nil) })
+	if err := Configure(plugins, map[string]string{"pre-parse": "cat"}); err != nil {
+		t.Fatalf("Configure returned an unexpected error: %v", err)
+	}
+
+	hooks, err := Find(PreParse)
+	if err != nil {
+		t.Fatalf("Find returned an unexpected error: %v", err)
+	}
+	var found []string
+	for _, h := range hooks {
+		found = append(found, h.String())
+	}
+	want := []string{"pre-parse hook of plugin lint", "pre-parse hook of plugin tidy", `pre-parse command "cat"`}
+	if !slices.Equal(found, want) {
+		t.Errorf("Expected the executable hooks in plugin order, then the command: %q, but got %q", want, found)
+	}
+	if hooks[0].Path != filepath.Join(plugins, "lint", "pre-parse") {
+		t.Errorf("Expected the path of the plugin's executable, but got %s", hooks[0].Path)
+	}
+
+	if hooks, err := Find(SessionEnded); err != nil || len(hooks) != 0 {
+		t.Errorf("Expected no hooks for an event without any, but got %v, %v", hooks, err)
+	}
+
+	if err := Configure(filepath.Join(plugins, "missing"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, err := Find(PreParse); err != nil || len(hooks) != 0 {
+		t.Errorf("Expected a missing plugins directory to have no hooks, but got %v, %v", hooks, err)
+	}
+	if err := Configure(plugins, map[string]string{"post-parse": "cat"}); err == nil {
+		t.Errorf("Expected an unknown event to be an error")
+	}
+}
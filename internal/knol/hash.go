@@ -6,16 +6,31 @@ import (
 	"strings"
 
 	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/latex"
 )
 
 // Normalize concatenates the card's content after cleaning each part.
 // It trims whitespace, lowercases, and normalizes line endings for each field
-// before joining them.
+// before joining them, followed by the card's kind unless it is a basic card,
+// the distractors or steps of multiple choice and steps cards and the deletion
+// number of text cloze cards. The hint and tags are left out, so they can be
+// changed without losing the card's review history, and so is the ID. Spacing
+// inside LaTeX math, $...$ and $$...$$, is made canonical, so reflowing a
+// formula keeps its hash.
 func Normalize(card domain.Card) string {
+	return normalize(card, true)
+}
+
+// normalize implements Normalize, leaving the spacing inside math as it is
+// without math.
+func normalize(card domain.Card, math bool) string {
 	normalizePart := func(part string) string {
 		p := strings.ToLower(part)
 		p = strings.TrimSpace(p)
 		p = strings.ReplaceAll(p, "\r\n", "\n")
+		if math {
+			p = latex.Normalize(p)
+		}
 		return p
 	}
 
@@ -26,12 +41,50 @@ func Normalize(card domain.Card) string {
 	// We join with a newline to ensure separation between fields,
 	// preventing accidental joining of words. e.g. "question" and "answer"
 	// becoming "questionanswer".
-	return strings.Join([]string{q, a, c}, "\n")
+	parts := []string{q, a, c}
+	if card.Kind != domain.KindBasic {
+		// Only added for other kinds, so the hashes of basic cards are unchanged.
+		parts = append(parts, card.Kind)
+	}
+	for _, d := range card.Distractors {
+		parts = append(parts, normalizePart(d))
+	}
+	for _, step := range card.Steps {
+		parts = append(parts, normalizePart(step))
+	}
+	if card.Cloze > 0 {
+		parts = append(parts, fmt.Sprintf("c%d", card.Cloze))
+	}
+	return strings.Join(parts, "\n")
 }
 
-// Hash takes a card, normalizes it, and returns its SHA-256 hash as a hex string.
+// Hash returns the identity of a card: its ID when the author pinned one,
+// followed by -c and the number of a text cloze or numbered answer card, so
+// that its content can change without losing its review history, or else its
+// ContentHash.
 func Hash(card domain.Card) string {
+	if card.ID == "" {
+		return ContentHash(card)
+	}
+	if card.Cloze > 0 {
+		return fmt.Sprintf("%s-c%d", card.ID, card.Cloze)
+	}
+	return card.ID
+}
+
+// ContentHash takes a card, normalizes it, and returns its SHA-256 hash as a hex
+// string, ignoring any ID.
+func ContentHash(card domain.Card) string {
 	normalized := Normalize(card)
 	hashBytes := sha256.Sum256([]byte(normalized))
 	return fmt.Sprintf("%x", hashBytes)
 }
+
+// LegacyContentHash returns the content hash a card had before the spacing
+// inside math was made canonical, which differs from its ContentHash only for
+// cards with math. Cards stored under it are renamed when synced, keeping their
+// review history.
+func LegacyContentHash(card domain.Card) string {
+	hashBytes := sha256.Sum256([]byte(normalize(card, false)))
+	return fmt.Sprintf("%x", hashBytes)
+}
@@ -1,6 +1,8 @@
 package knol
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"testing"
 
 	"github.com/conorfennell/knolhash/internal/domain"
@@ -65,4 +67,72 @@ func TestHash(t *testing.T) {
 			t.Error("Expected hashes for different cards to be different")
 		}
 	})
+
+	t.Run("kind is part of the hash", func(t *testing.T) {
+		basic := domain.Card{Question: "Topic", Answer: "Notes"}
+		writing := domain.Card{Question: "Topic", Answer: "Notes", Kind: domain.KindWriting}
+		if Hash(basic) == Hash(writing) {
+			t.Error("Expected a writing prompt to hash differently from a basic card with the same content")
+		}
+	})
+	t.Run("cloze number is part of the hash", func(t *testing.T) {
+		c1 := domain.Card{Question: "{{c1::Go}} and {{c2::Go}}", Answer: "Go", Kind: domain.KindTextCloze, Cloze: 1}
+		c2 := domain.Card{Question: "{{c1::Go}} and {{c2::Go}}", Answer: "Go", Kind: domain.KindTextCloze, Cloze: 2}
+		if Hash(c1) == Hash(c2) {
+			t.Error("Expected the cards of each cloze number to hash differently")
+		}
+	})
+	t.Run("tags are not part of the hash", func(t *testing.T) {
+		card := domain.Card{Question: "Capital of Australia?", Answer: "Canberra"}
+		tagged := domain.Card{Question: "Capital of Australia?", Answer: "Canberra", Tags: []string{"geography"}}
+		if Hash(card) != Hash(tagged) {
+			t.Error("Expected adding tags to leave the hash unchanged")
+		}
+	})
+	t.Run("hint is not part of the hash", func(t *testing.T) {
+		card := domain.Card{Question: "Capital of Australia?", Answer: "Canberra"}
+		hinted := domain.Card{Question: "Capital of Australia?", Answer: "Canberra", Hint: "Not Sydney"}
+		if Hash(card) != Hash(hinted) {
+			t.Error("Expected adding a hint to leave the hash unchanged")
+		}
+	})
+	t.Run("spacing inside math is not part of the hash", func(t *testing.T) {
+		card := domain.Card{Question: "Quadratic formula?", Answer: "$$x = \\frac{-b \\pm \\sqrt{b^2-4ac}}{2a}$$"}
+		reflowed := domain.Card{Question: "Quadratic formula?", Answer: "$$\nx=\\frac{-b\\pm\\sqrt{b^2 - 4ac}}{2a}\n$$"}
+		if Hash(card) != Hash(reflowed) {
+			t.Error("Expected reflowing a formula to leave the hash unchanged")
+		}
+	})
+	t.Run("the legacy hash leaves math as it is", func(t *testing.T) {
+		plain := domain.Card{Question: "Capital of Australia?", Answer: "Canberra"}
+		if LegacyContentHash(plain) != ContentHash(plain) {
+			t.Error("Expected the legacy hash of a card without math to be its content hash")
+		}
+		math := domain.Card{Question: "Euler's identity?", Answer: "$e^{i \\pi} + 1 = 0$"}
+		if LegacyContentHash(math) == ContentHash(math) {
+			t.Error("Expected the legacy hash to keep the spacing inside math")
+		}
+		// SHA-256 of "euler's identity?\n$e^{i \\pi} + 1 = 0$\n", as hashed before
+		if got := LegacyContentHash(math); got != sha256Hex("euler's identity?\n$e^{i \\pi} + 1 = 0$\n") {
+			t.Errorf("Expected the legacy hash of the unnormalized math, but got '%s'", got)
+		}
+	})
+	t.Run("an ID overrides the hash", func(t *testing.T) {
+		card := domain.Card{Question: "Capital of Australia?", Answer: "Canberra", ID: "australia-capital"}
+		if Hash(card) != "australia-capital" {
+			t.Errorf("Expected the ID as the hash, but got '%s'", Hash(card))
+		}
+		if ContentHash(card) != Hash(domain.Card{Question: "Capital of Australia?", Answer: "Canberra"}) {
+			t.Error("Expected the content hash to ignore the ID")
+		}
+		cloze := domain.Card{Question: "{{c1::Go}} and {{c2::Go}}", Answer: "Go", Kind: domain.KindTextCloze, Cloze: 2, ID: "go"}
+		if Hash(cloze) != "go-c2" {
+			t.Errorf("Expected the cloze number after the ID, but got '%s'", Hash(cloze))
+		}
+	})
+}
+
+// sha256Hex returns the SHA-256 of s as a hex string.
+func sha256Hex(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
 }
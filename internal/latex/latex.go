@@ -0,0 +1,231 @@
+package latex
+
+import (
+	"strings"
+)
+
+// Span is a math span of a Markdown text, from Start to End, delimiters
+// included: inline math, $...$, or display math, $$...$$.
+type Span struct {
+	Start, End int
+	Display    bool
+}
+
+// TeX returns the math of the span in text, without its delimiters.
+func (s Span) TeX(text string) string {
+	d := 1
+	if s.Display {
+		d = 2
+	}
+	return text[s.Start+d : s.End-d]
+}
+
+// textCommands are the commands whose argument is text, in which spaces count.
+var textCommands = []string{`\text`, `\textrm`, `\textbf`, `\textit`, `\textsf`, `\texttt`, `\mbox`, `\hbox`}
+
+// Spans returns the math spans of a Markdown text. Display math may run over
+// several lines; inline math ends on the line it starts. As in Pandoc, an
+// opening $ is followed by a non-space, a closing $ follows one and isn't
+// followed by a digit, so "$5 and $10" is no math; \$ is a dollar sign. Code
+// spans and fenced code blocks are left out.
+func Spans(text string) []Span {
+	var spans []Span
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case (i == 0 || text[i-1] == '\n') && isFence(text[i:]):
+			i = skipFence(text, i) - 1
+		case c == '\\':
+			i++ // An escaped character, such as \$
+		case c == '`':
+			i = skipCode(text, i) - 1
+		case strings.HasPrefix(text[i:], "$$"):
+			if end := Close(text[i+2:], "$$"); end >= 0 {
+				spans = append(spans, Span{Start: i, End: i + 2 + end + 2, Display: true})
+				i += 2 + end + 1
+			} else {
+				i++
+			}
+		case c == '$':
+			if end := Close(text[i+1:], "$"); end >= 0 {
+				spans = append(spans, Span{Start: i, End: i + 1 + end + 1})
+				i += end + 1
+			}
+		}
+	}
+	return spans
+}
+
+// Close returns the index in text of the delimiter closing math opened with
+// delim, $ or $$, text starting right after the opening delimiter, or -1 if the
+// math isn't closed.
+func Close(text, delim string) int {
+	if delim == "$" && (text == "" || isSpace(text[0])) {
+		return -1
+	}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case delim == "$" && text[i] == '\n':
+			return -1
+		case delim == "$$" && strings.HasPrefix(text[i:], "$$"):
+			return i
+		case delim == "$" && text[i] == '$':
+			if i == 0 || isSpace(text[i-1]) || i+1 < len(text) && isDigit(text[i+1]) {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// Normalize rewrites the math spans of a Markdown text with their spacing made
+// canonical, so that formulas only differing in spacing, which TeX ignores in
+// math, normalize alike. Spaces are dropped, except a single one where it ends
+// a command before a letter, as in \alpha x, and in the text of commands such
+// as \text{...}. Line breaks count as spaces. Text outside math is unchanged.
+func Normalize(text string) string {
+	spans := Spans(text)
+	if len(spans) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(text[last:s.Start])
+		d := "$"
+		if s.Display {
+			d = "$$"
+		}
+		b.WriteString(d + normalizeTeX(s.TeX(text)) + d)
+		last = s.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// normalizeTeX makes the spacing of math canonical; see Normalize.
+func normalizeTeX(tex string) string {
+	var b strings.Builder
+	textDepth := 0 // Braces open in the argument of a text command, if in one
+	depth := 0     // Braces open
+	for i := 0; i < len(tex); i++ {
+		c := tex[i]
+		switch {
+		case isSpace(c):
+			j := i
+			for j < len(tex) && isSpace(tex[j]) {
+				j++
+			}
+			out := b.String()
+			if textDepth > 0 || j < len(tex) && isLetter(tex[j]) && endsWithCommand(out) {
+				b.WriteByte(' ')
+			}
+			i = j - 1
+		case c == '\\' && i+1 < len(tex):
+			switch next := tex[i+1]; {
+			case isLetter(next): // A control word, such as \alpha
+				b.WriteByte(c)
+			case isSpace(next): // A control space counts
+				b.WriteString(`\ `)
+				i++
+			default: // A control symbol, such as \\ or \{
+				b.WriteString(tex[i : i+2])
+				i++
+			}
+		case c == '{':
+			depth++
+			if textDepth == 0 && isTextCommand(b.String()) {
+				textDepth = depth
+			}
+			b.WriteByte(c)
+		case c == '}':
+			if depth == textDepth {
+				textDepth = 0
+			}
+			depth--
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// endsWithCommand reports whether tex ends with a control word, such as \alpha.
+func endsWithCommand(tex string) bool {
+	i := len(tex)
+	for i > 0 && isLetter(tex[i-1]) {
+		i--
+	}
+	return i < len(tex) && i > 0 && tex[i-1] == '\\' && (i < 2 || tex[i-2] != '\\')
+}
+
+// isTextCommand reports whether tex ends with a command taking text.
+func isTextCommand(tex string) bool {
+	for _, command := range textCommands {
+		if strings.HasSuffix(tex, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFence reports whether a line, the start of text, opens a fenced code block.
+func isFence(text string) bool {
+	trimmed := strings.TrimLeft(text, " ")
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// skipFence returns the index after the fenced code block starting at i, or
+// the end of text if it isn't closed.
+func skipFence(text string, i int) int {
+	open, _, _ := strings.Cut(text[i:], "\n")
+	marker := strings.TrimLeft(open, " ")[:3]
+	offset := i + len(open) + 1
+	for offset < len(text) {
+		line, _, _ := strings.Cut(text[offset:], "\n")
+		offset += len(line) + 1
+		if strings.HasPrefix(strings.TrimLeft(line, " "), marker) {
+			return min(offset, len(text))
+		}
+	}
+	return len(text)
+}
+
+// skipCode returns the index after the code span starting at i, or after its
+// opening backticks if it isn't closed.
+func skipCode(text string, i int) int {
+	n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+	ticks := text[i : i+n]
+	for j := i + n; j < len(text); {
+		k := strings.Index(text[j:], ticks)
+		if k < 0 {
+			break
+		}
+		j += k
+		end := j + n
+		for end < len(text) && text[end] == '`' {
+			end++
+		}
+		if end-j == n {
+			return end
+		}
+		j = end
+	}
+	return i + n
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
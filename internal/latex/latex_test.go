@@ -0,0 +1,56 @@
+package latex
+
+import (
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		wants []string
+	}{
+		{"inline", `Euler: $e^{i\pi} + 1 = 0$.`, []string{`$e^{i\pi} + 1 = 0$`}},
+		{"display over lines", "Sum:\n$$\n\\sum_{i=1}^n i\n$$\nDone", []string{"$$\n\\sum_{i=1}^n i\n$$"}},
+		{"prices are no math", "It costs $5 and $10.", nil},
+		{"escaped dollar", `A \$ sign and $x$`, []string{`$x$`}},
+		{"inline ends on its line", "$a\nb$", nil},
+		{"code span", "Use `$x$` or $y$", []string{`$y$`}},
+		{"fenced code", "```sh\necho $HOME$\n```\n$z$", []string{`$z$`}},
+		{"two spans", `$a$ and $$b$$`, []string{`$a$`, `$$b$$`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := Spans(tt.text)
+			if len(spans) != len(tt.wants) {
+				t.Fatalf("Spans() = %v, want %d spans", spans, len(tt.wants))
+			}
+			for i, s := range spans {
+				if got := tt.text[s.Start:s.End]; got != tt.wants[i] {
+					t.Errorf("span %d = %q, want %q", i, got, tt.wants[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"spaces dropped", `$a + b = c$`, `$a+b=c$`},
+		{"line breaks", "$$\n  \\frac{a}{b}\n  + c\n$$", `$$\frac{a}{b}+c$$`},
+		{"command before letter", `$\alpha  x + \beta(y)$`, `$\alpha x+\beta(y)$`},
+		{"text command", `$x \text{if  and only if} y$`, `$x\text{if and only if}y$`},
+		{"control symbols", `$a \\ b \{ c \ d$`, `$a\\b\{c\ d$`},
+		{"outside math unchanged", `Spaced  out $x = 1$  text`, `Spaced  out $x=1$  text`},
+		{"no math", `It costs $5 and $10.`, `It costs $5 and $10.`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.text); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
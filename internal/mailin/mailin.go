@@ -0,0 +1,142 @@
+// Package mailin turns emails into cards in the inbox: an inbound email service
+// such as Mailgun posts each email sent to a dedicated address to a webhook,
+// whose subject becomes the question and whose body the answer.
+package mailin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+)
+
+const (
+	// maxEmailSize limits the emails accepted, attachments included, which are dropped.
+	maxEmailSize = 10 << 20
+	// maxAge is how old the signature of a webhook may be, limiting replays.
+	maxAge = 15 * time.Minute
+)
+
+// Config enables the webhook, signed as Mailgun signs its inbound routes, and
+// lists the addresses cards may be sent from; emails from others are refused.
+type Config struct {
+	SigningKey string   `koanf:"signing_key"`
+	Senders    []string `koanf:"senders"`
+}
+
+// Enabled reports whether emails are turned into cards.
+func (c Config) Enabled() bool {
+	return c.SigningKey != "" && len(c.Senders) > 0
+}
+
+// Handler returns the webhook, which writes each email it accepts as a card in
+// a file of its own in dir, then calls added with the file, e.g. to sync it. An
+// email that can't be a card is refused with 406, so the service doesn't retry
+// it; one delivered twice is written once.
+func Handler(cfg Config, dir string, added func(path string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxEmailSize)
+		if err := r.ParseMultipartForm(maxEmailSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+		if err := verify(cfg.SigningKey, r.PostFormValue("timestamp"), r.PostFormValue("token"), r.PostFormValue("signature"), time.Now()); err != nil {
+			slog.Warn("Refused inbound email", "error", err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if from := sender(r.PostFormValue("from"), r.PostFormValue("sender")); !slices.ContainsFunc(cfg.Senders, func(s string) bool { return strings.EqualFold(s, from) }) {
+			slog.Warn("Refused inbound email from unknown sender", "from", from)
+			http.Error(w, "Unknown sender", http.StatusNotAcceptable)
+			return
+		}
+
+		body := r.PostFormValue("stripped-text") // Without quoted replies and the signature
+		if strings.TrimSpace(body) == "" {
+			body = r.PostFormValue("body-plain")
+		}
+		content, err := card(r.PostFormValue("subject"), body)
+		if err != nil {
+			slog.Warn("Refused inbound email", "subject", r.PostFormValue("subject"), "error", err)
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+			return
+		}
+
+		// The service's token names the file, so a retried delivery overwrites it
+		sum := sha256.Sum256([]byte(r.PostFormValue("token")))
+		path := filepath.Join(dir, hex.EncodeToString(sum[:8])+".md")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			slog.Error("Failed to create email inbox", "dir", dir, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			slog.Error("Failed to write card of inbound email", "path", path, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Card added by email", "path", path)
+		added(path)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// verify checks that a webhook was signed with key: the signature is the hex
+// HMAC-SHA256 of its timestamp and token, and the timestamp within maxAge of now.
+func verify(key, timestamp, token, signature string, now time.Time) error {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(secs, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("signature from %s is too old", time.Unix(secs, 0).Format(time.RFC3339))
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + token))
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// sender returns the address an email is from, going by its From header, or
+// else the envelope sender.
+func sender(from, envelope string) string {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		return addr.Address
+	}
+	return strings.TrimSpace(envelope)
+}
+
+// card returns the file of a card asking the subject of an email, answered by
+// its body, refusing an email that would not parse back as that one card.
+func card(subject, body string) ([]byte, error) {
+	question := strings.Join(strings.Fields(subject), " ")
+	answer := strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if question == "" || answer == "" {
+		return nil, errors.New("an email needs a subject, the question, and a body, the answer")
+	}
+	content := []byte(parser.Config{}.Format(question, answer, ""))
+	res := parser.ParseCards("email.md", content, parser.Config{})
+	if len(res.Cards) != 1 || len(res.Warnings) > 0 || res.Err() != nil {
+		return nil, errors.New("the email would not be read back as one card; check for lines starting with Q: or ---")
+	}
+	return content, nil
+}
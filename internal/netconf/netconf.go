@@ -0,0 +1,70 @@
+package netconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Config holds the settings for outbound connections, shared by git and HTTP clients.
+type Config struct {
+	// ProxyURL overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables, which are honoured when it is empty.
+	ProxyURL string
+	// CABundle is the path to a PEM file of certificates trusted in addition to
+	// the system roots, e.g. for networks with TLS interception.
+	CABundle string
+}
+
+var client = http.DefaultClient
+
+// Configure builds the shared HTTP client from cfg and installs it as the transport
+// for git operations over HTTP(S). It must be called before any outbound connection is made.
+func Configure(cfg Config) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL %q: %w", cfg.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		slog.Info("Using outbound proxy", "proxy", proxyURL.Redacted())
+	}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle %s: %w", cfg.CABundle, err)
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+		slog.Info("Using custom CA bundle", "path", cfg.CABundle)
+	}
+
+	client = &http.Client{Transport: transport}
+
+	gitHTTP := githttp.NewClient(client)
+	gitclient.InstallProtocol("http", gitHTTP)
+	gitclient.InstallProtocol("https", gitHTTP)
+	return nil
+}
+
+// Client returns the shared HTTP client for outbound requests.
+// Callers should bound requests with a context, as the client has no timeout.
+func Client() *http.Client {
+	return client
+}
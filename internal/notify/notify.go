@@ -0,0 +1,271 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/netconf"
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Keys of the notification settings in the settings table.
+const (
+	keyProvider      = "notify.provider"
+	keyNtfyServer    = "notify.ntfy_server"
+	keyNtfyTopic     = "notify.ntfy_topic"
+	keyNtfyToken     = "notify.ntfy_token"
+	keyPushoverToken = "notify.pushover_token"
+	keyPushoverUser  = "notify.pushover_user"
+	keyTime          = "notify.time"
+	keySmart         = "notify.smart"
+	keyQuietStart    = "notify.quiet_start"
+	keyQuietEnd      = "notify.quiet_end"
+	keyLastSent      = "notify.last_sent"
+)
+
+const (
+	defaultNtfyServer = "https://ntfy.sh"
+	defaultTime       = "09:00"
+
+	pushoverURL = "https://api.pushover.net/1/messages.json"
+
+	// clockLayout is the format of times of day in the settings.
+	clockLayout = "15:04"
+
+	sendTimeout = 30 * time.Second
+)
+
+// Settings configure the daily "cards due" push notification.
+type Settings struct {
+	Provider string // "" (disabled), "ntfy" or "pushover"
+
+	NtfyServer string
+	NtfyTopic  string
+	NtfyToken  string // Optional access token for protected topics
+
+	PushoverToken string // Application API token
+	PushoverUser  string // User or group key
+
+	Time string // Time of day (HH:MM, in the preferred timezone) the notification is sent
+
+	// Smart sends the notification shortly before the time of day reviews usually
+	// start, learned from the review log, instead of at Time. Time is still used
+	// until there is enough history.
+	Smart bool
+
+	// Notifications are held back between QuietStart and QuietEnd (HH:MM, may
+	// span midnight) and sent when quiet hours end. Both empty disables quiet hours.
+	QuietStart string
+	QuietEnd   string
+}
+
+// LoadSettings reads the notification settings, filling in defaults.
+func LoadSettings(db *storage.DB) (Settings, error) {
+	values, err := db.GetSettings()
+	if err != nil {
+		return Settings{}, err
+	}
+	s := Settings{
+		Provider:      values[keyProvider],
+		NtfyServer:    values[keyNtfyServer],
+		NtfyTopic:     values[keyNtfyTopic],
+		NtfyToken:     values[keyNtfyToken],
+		PushoverToken: values[keyPushoverToken],
+		PushoverUser:  values[keyPushoverUser],
+		Time:          values[keyTime],
+		Smart:         values[keySmart] == "true",
+		QuietStart:    values[keyQuietStart],
+		QuietEnd:      values[keyQuietEnd],
+	}
+	if s.NtfyServer == "" {
+		s.NtfyServer = defaultNtfyServer
+	}
+	if s.Time == "" {
+		s.Time = defaultTime
+	}
+	return s, nil
+}
+
+// Save validates and stores the notification settings.
+func (s Settings) Save(db *storage.DB) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return db.UpdateSettings(map[string]string{
+		keyProvider:      s.Provider,
+		keyNtfyServer:    s.NtfyServer,
+		keyNtfyTopic:     s.NtfyTopic,
+		keyNtfyToken:     s.NtfyToken,
+		keyPushoverToken: s.PushoverToken,
+		keyPushoverUser:  s.PushoverUser,
+		keyTime:          s.Time,
+		keySmart:         strconv.FormatBool(s.Smart),
+		keyQuietStart:    s.QuietStart,
+		keyQuietEnd:      s.QuietEnd,
+	})
+}
+
+// Validate checks that the selected provider is fully configured and that times are well-formed.
+func (s Settings) Validate() error {
+	switch s.Provider {
+	case "":
+	case "ntfy":
+		if u, err := url.Parse(s.NtfyServer); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("invalid ntfy server URL %q", s.NtfyServer)
+		}
+		if s.NtfyTopic == "" {
+			return errors.New("an ntfy topic is required")
+		}
+	case "pushover":
+		if s.PushoverToken == "" || s.PushoverUser == "" {
+			return errors.New("a Pushover API token and user key are required")
+		}
+	default:
+		return fmt.Errorf("unknown notification provider %q", s.Provider)
+	}
+
+	if _, err := time.Parse(clockLayout, s.Time); err != nil {
+		return fmt.Errorf("invalid notification time %q, expected HH:MM", s.Time)
+	}
+	if (s.QuietStart == "") != (s.QuietEnd == "") {
+		return errors.New("quiet hours need both a start and an end")
+	}
+	for _, t := range []string{s.QuietStart, s.QuietEnd} {
+		if _, err := time.Parse(clockLayout, t); t != "" && err != nil {
+			return fmt.Errorf("invalid quiet hours time %q, expected HH:MM", t)
+		}
+	}
+	return nil
+}
+
+// Send pushes a notification through the configured provider.
+func (s Settings) Send(title, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	var req *http.Request
+	var err error
+	switch s.Provider {
+	case "ntfy":
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+			strings.TrimSuffix(s.NtfyServer, "/")+"/"+url.PathEscape(s.NtfyTopic), strings.NewReader(message))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Title", title)
+		if s.NtfyToken != "" {
+			req.Header.Set("Authorization", "Bearer "+s.NtfyToken)
+		}
+	case "pushover":
+		form := url.Values{
+			"token":   {s.PushoverToken},
+			"user":    {s.PushoverUser},
+			"title":   {title},
+			"message": {message},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	default:
+		return errors.New("no notification provider configured")
+	}
+
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", s.Provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send %s notification: unexpected status %s: %s", s.Provider, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// quiet reports whether t falls within the quiet hours.
+func (s Settings) quiet(t time.Time) bool {
+	if s.QuietStart == "" {
+		return false
+	}
+	now := t.Format(clockLayout)
+	if s.QuietStart <= s.QuietEnd {
+		return now >= s.QuietStart && now < s.QuietEnd
+	}
+	// Quiet hours span midnight, e.g. 22:00 to 07:00.
+	return now >= s.QuietStart || now < s.QuietEnd
+}
+
+// lastScheduled returns the most recent time at or before now that a notification
+// sent daily at clock (HH:MM) was scheduled for.
+func lastScheduled(now time.Time, clock string) time.Time {
+	c, _ := time.Parse(clockLayout, clock)
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), c.Hour(), c.Minute(), 0, 0, now.Location())
+	if scheduled.After(now) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	return scheduled
+}
+
+// CheckDue sends the daily notification if it is due at now and has not been sent
+// since it was last scheduled. A notification held back by quiet hours is sent
+// once they end. Nothing is sent when no cards are due.
+func CheckDue(db *storage.DB, now time.Time) error {
+	s, err := LoadSettings(db)
+	if err != nil || s.Provider == "" {
+		return err
+	}
+	p, err := prefs.Load(db)
+	if err != nil {
+		return err
+	}
+	now = now.In(p.Location())
+
+	values, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	clock := s.Time
+	if s.Smart {
+		learned, ok, err := LearnedTime(db, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			clock = learned
+		}
+	}
+
+	lastSent, _ := time.Parse(time.RFC3339, values[keyLastSent])
+	if !lastSent.Before(lastScheduled(now, clock)) || s.quiet(now) {
+		return nil
+	}
+
+	due, err := p.DueQueue(db)
+	if err != nil {
+		return err
+	}
+	if len(due) > 0 {
+		message := fmt.Sprintf("You have %d cards due for review.", len(due))
+		if len(due) == 1 {
+			message = "You have 1 card due for review."
+		}
+		if err := s.Send("Knolhash", message); err != nil {
+			return err
+		}
+		slog.Info("Sent due cards notification", "provider", s.Provider, "due", len(due))
+	}
+	// Also recorded when nothing was due, so cards becoming due later that day
+	// don't trigger a notification at an unexpected time.
+	return db.UpdateSettings(map[string]string{keyLastSent: now.Format(time.RFC3339)})
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"sort"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+const (
+	// learningWindow is how far back reviews are considered when learning the usual review time.
+	learningWindow = 28 * 24 * time.Hour
+	// minStudyDays is the number of days with reviews needed before the learned time is used.
+	minStudyDays = 5
+	// reminderLead is how long before the usual review time the notification is sent.
+	reminderLead = 15 * time.Minute
+)
+
+// LearnedTime returns the time of day (HH:MM) to send the notification: shortly
+// before the median time the first review of the day took place over the last
+// four weeks. ok is false when fewer than minStudyDays days had reviews.
+func LearnedTime(db *storage.DB, now time.Time) (clock string, ok bool, err error) {
+	times, err := db.GetReviewTimesSince(now.Add(-learningWindow))
+	if err != nil {
+		return "", false, err
+	}
+
+	// Times are ordered, so the first one seen per day is that day's first review.
+	firstOfDay := make(map[string]int) // date -> minutes after midnight
+	for _, t := range times {
+		t = t.In(now.Location())
+		day := t.Format(time.DateOnly)
+		if _, seen := firstOfDay[day]; !seen {
+			firstOfDay[day] = t.Hour()*60 + t.Minute()
+		}
+	}
+	if len(firstOfDay) < minStudyDays {
+		return "", false, nil
+	}
+
+	minutes := make([]int, 0, len(firstOfDay))
+	for _, m := range firstOfDay {
+		minutes = append(minutes, m)
+	}
+	sort.Ints(minutes)
+	median := minutes[len(minutes)/2]
+
+	midnight := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	send := midnight.Add(time.Duration(median)*time.Minute - reminderLead)
+	return send.Format(clockLayout), true, nil
+}
@@ -0,0 +1,274 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/netconf"
+)
+
+var apiBase = "https://api.notion.com/v1"
+
+const (
+	apiVersion = "2022-06-28"
+
+	// stateFile records the last_edited_time of the newest page imported so far.
+	stateFile = ".state.json"
+
+	importTimeout = 5 * time.Minute
+)
+
+// Config selects the Notion database to import and maps its properties to card fields.
+type Config struct {
+	Token      string     `koanf:"token"`
+	DatabaseID string     `koanf:"database_id"`
+	Properties Properties `koanf:"properties"`
+}
+
+// Properties names the database properties holding each card field.
+// Question is required; the others may be left empty.
+type Properties struct {
+	Question string `koanf:"question"`
+	Answer   string `koanf:"answer"`
+	Context  string `koanf:"context"`
+	Tags     string `koanf:"tags"`
+}
+
+// Enabled reports whether an import is configured.
+func (c Config) Enabled() bool {
+	return c.Token != "" && c.DatabaseID != ""
+}
+
+// Result summarizes an import run.
+type Result struct {
+	Written int // Pages written as cards
+	Removed int // Pages whose cards were removed because they were archived or emptied
+}
+
+// Import materializes the pages of the database as markdown cards in dir, one
+// file per page. Only pages edited since the previous import are fetched, unless
+// full is set, in which case dir is rebuilt from scratch. Pages deleted from the
+// database are only noticed by a full import.
+func Import(cfg Config, dir string, full bool) (Result, error) {
+	var res Result
+	if cfg.Properties.Question == "" {
+		return res, errors.New("no Notion property configured for the question")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return res, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	var st struct {
+		LastEditedTime time.Time `json:"last_edited_time"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, stateFile)); err == nil && !full {
+		if err := json.Unmarshal(data, &st); err != nil {
+			slog.Warn("Discarding corrupt Notion import state", "dir", dir, "error", err)
+		}
+	}
+	if full || st.LastEditedTime.IsZero() {
+		if err := removePages(dir); err != nil {
+			return res, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	query := map[string]any{"page_size": 100}
+	if !st.LastEditedTime.IsZero() {
+		// Notion truncates edit times to the minute, so pages edited in the same
+		// minute as the newest one are fetched again; rewriting them is harmless.
+		query["filter"] = map[string]any{
+			"timestamp":        "last_edited_time",
+			"last_edited_time": map[string]any{"on_or_after": st.LastEditedTime.Format(time.RFC3339)},
+		}
+	}
+
+	for {
+		var resp struct {
+			Results    []page `json:"results"`
+			HasMore    bool   `json:"has_more"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := call(ctx, cfg.Token, "/databases/"+cfg.DatabaseID+"/query", query, &resp); err != nil {
+			return res, err
+		}
+
+		for _, p := range resp.Results {
+			if p.LastEditedTime.After(st.LastEditedTime) {
+				st.LastEditedTime = p.LastEditedTime
+			}
+			written, err := writePage(dir, p, cfg.Properties)
+			if err != nil {
+				return res, err
+			}
+			if written {
+				res.Written++
+			} else {
+				res.Removed++
+			}
+		}
+
+		if !resp.HasMore {
+			break
+		}
+		query["start_cursor"] = resp.NextCursor
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return res, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, stateFile), data, 0o644); err != nil {
+		return res, fmt.Errorf("failed to write Notion import state: %w", err)
+	}
+	return res, nil
+}
+
+// page is a row of a Notion database.
+type page struct {
+	ID             string              `json:"id"`
+	Archived       bool                `json:"archived"`
+	InTrash        bool                `json:"in_trash"`
+	LastEditedTime time.Time           `json:"last_edited_time"`
+	Properties     map[string]property `json:"properties"`
+}
+
+// property is a page property value. Only the property types that can hold
+// card text or tags are decoded.
+type property struct {
+	Type     string     `json:"type"`
+	Title    []richText `json:"title"`
+	RichText []richText `json:"rich_text"`
+	Select   *struct {
+		Name string `json:"name"`
+	} `json:"select"`
+	MultiSelect []struct {
+		Name string `json:"name"`
+	} `json:"multi_select"`
+}
+
+type richText struct {
+	PlainText string `json:"plain_text"`
+}
+
+// text returns the property value as plain text, joining multi-select options with ", ".
+func (p property) text() string {
+	var parts []string
+	switch p.Type {
+	case "title":
+		for _, t := range p.Title {
+			parts = append(parts, t.PlainText)
+		}
+		return strings.Join(parts, "")
+	case "rich_text":
+		for _, t := range p.RichText {
+			parts = append(parts, t.PlainText)
+		}
+		return strings.Join(parts, "")
+	case "select":
+		if p.Select != nil {
+			return p.Select.Name
+		}
+	case "multi_select":
+		for _, o := range p.MultiSelect {
+			parts = append(parts, o.Name)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// writePage writes the card of a page to its file, or removes the file if the page
+// is archived or has no question. It reports whether a card was written.
+func writePage(dir string, p page, props Properties) (bool, error) {
+	if strings.ContainsAny(p.ID, `/\.`) {
+		return false, fmt.Errorf("unexpected Notion page ID %q", p.ID)
+	}
+	name := filepath.Join(dir, strings.ReplaceAll(p.ID, "-", "")+".md")
+
+	field := func(property string) string {
+		if property == "" {
+			return ""
+		}
+		return strings.TrimSpace(p.Properties[property].text())
+	}
+	question := field(props.Question)
+
+	if p.Archived || p.InTrash || question == "" {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove card of Notion page %s: %w", p.ID, err)
+		}
+		return false, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Q: %s\n", question)
+	fmt.Fprintf(&b, "A: %s\n", field(props.Answer))
+	if c := field(props.Context); c != "" {
+		fmt.Fprintf(&b, "C: %s\n", c)
+	}
+	if tags := field(props.Tags); tags != "" {
+		fmt.Fprintf(&b, "T: %s\n", tags)
+	}
+
+	if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+		return false, fmt.Errorf("failed to write card of Notion page %s: %w", p.ID, err)
+	}
+	return true, nil
+}
+
+// removePages removes the cards of all previously imported pages.
+func removePages(dir string) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := os.Remove(m); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// call POSTs a JSON request to the Notion API and decodes the JSON response into result.
+func call(ctx context.Context, token, endpoint string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBase+endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Notion-Version", apiVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return fmt.Errorf("Notion request %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Notion response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Notion request %s failed: unexpected status %s: %s", endpoint, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return json.Unmarshal(respBody, result)
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/cloze"
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+// ankiSeparators are the field separators an Anki export may declare in its
+// #separator header.
+var ankiSeparators = map[string]rune{
+	"tab": '\t', "comma": ',', "semicolon": ';', "pipe": '|', "space": ' ', "colon": ':',
+}
+
+// ankiBreak matches the HTML tags of an Anki field that end a line.
+var ankiBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(?:div|p|li)>`)
+
+// ankiTag matches any other HTML tag of an Anki field.
+var ankiTag = regexp.MustCompile(`<[^>]*>`)
+
+// ParseAnki reads the cards of a file exported from Anki as "Notes in Plain
+// Text": one note per line, front, back and tags separated by tabs, with fields
+// holding tabs or line breaks quoted. Anki's headers, such as #separator:comma,
+// #html:true, #tags column:3 and #deck column:1, say how to read the lines; the
+// note type and GUID columns are skipped and the deck, Anki's Spanish::Verbs,
+// becomes the context, Spanish/Verbs. Tags are separated by spaces. HTML in the
+// fields is reduced to text, its line breaks kept, and a front with cloze
+// deletions makes one text cloze card per number.
+func ParseAnki(r io.Reader) ([]domain.Card, error) {
+	br := bufio.NewReader(r)
+	comma, htmlFields := '\t', true
+	columns := make(map[string]int) // Columns from the headers, from 0
+	headerLines := 0
+	for {
+		peek, _ := br.Peek(1)
+		if len(peek) == 0 || peek[0] != '#' {
+			break
+		}
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		headerLines++
+		key, value, ok := strings.Cut(strings.TrimSpace(line[1:]), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "separator":
+			if sep, ok := ankiSeparators[strings.ToLower(value)]; ok {
+				comma = sep
+			} else if len(value) == 1 {
+				comma = rune(value[0])
+			}
+		case "html":
+			htmlFields = value == "true"
+		case "tags column", "deck column", "notetype column", "guid column":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				columns[strings.TrimSuffix(key, " column")] = n - 1
+			}
+		}
+	}
+
+	skip := make(map[int]bool) // The columns that aren't front or back
+	for _, i := range columns {
+		skip[i] = true
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	var cards []domain.Card
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cards, fmt.Errorf("invalid Anki export: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		tagsColumn, hasTags := columns["tags"]
+		if headerLines == 0 && len(record) > 2 {
+			// Exports without headers have the tags in their last column.
+			tagsColumn, hasTags = len(record)-1, true
+		}
+		var fields []string
+		for i, field := range record {
+			if skip[i] || hasTags && i == tagsColumn {
+				continue
+			}
+			if htmlFields {
+				field = ankiText(field)
+			}
+			fields = append(fields, strings.TrimSpace(field))
+		}
+		if len(fields) == 0 || fields[0] == "" {
+			continue
+		}
+
+		card := domain.Card{Question: fields[0], Line: headerLines + line}
+		if len(fields) > 1 {
+			card.Answer = fields[1]
+		}
+		if i, ok := columns["deck"]; ok && i < len(record) {
+			card.Context = strings.ReplaceAll(strings.TrimSpace(record[i]), "::", "/")
+		}
+		if hasTags && tagsColumn < len(record) {
+			card.Tags = appendTags(nil, strings.Join(strings.Fields(record[tagsColumn]), ","))
+		}
+		if indexes := cloze.Indexes(card.Question); len(indexes) > 0 {
+			cards = append(cards, textClozes(card, indexes)...)
+		} else if card.Answer != "" {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
+
+// ankiText reduces the HTML of an Anki field to text, keeping its line breaks.
+func ankiText(field string) string {
+	field = ankiBreak.ReplaceAllString(field, "\n")
+	field = ankiTag.ReplaceAllString(field, "")
+	return html.UnescapeString(strings.ReplaceAll(field, "&nbsp;", " "))
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+func TestParseAnki(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []domain.Card
+	}{
+		{
+			name:  "Headers with deck and tags columns",
+			input: "#separator:tab\n#html:true\n#deck column:1\n#tags column:4\nSpanish::Verbs\tto eat\tcomer<br>(regular)\tverbs food\n",
+			expected: []domain.Card{
+				{Question: "to eat", Answer: "comer\n(regular)", Context: "Spanish/Verbs", Tags: []string{"verbs", "food"}, Line: 5},
+			},
+		},
+		{
+			name:  "Without headers the tags are last",
+			input: "hola\thello\tgreetings\n\"two\nlines\"\t&lt;b&gt; &amp;\t\n",
+			expected: []domain.Card{
+				{Question: "hola", Answer: "hello", Tags: []string{"greetings"}, Line: 1},
+				{Question: "two\nlines", Answer: "<b> &", Line: 2},
+			},
+		},
+		{
+			name:  "Cloze note",
+			input: "#separator:comma\n#html:false\n\"{{c1::Canberra}} is the capital of {{c2::Australia}}\",\n",
+			expected: []domain.Card{
+				{Question: "{{c1::Canberra}} is the capital of {{c2::Australia}}", Answer: "Canberra", Kind: domain.KindTextCloze, Cloze: 1, Line: 3},
+				{Question: "{{c1::Canberra}} is the capital of {{c2::Australia}}", Answer: "Australia", Kind: domain.KindTextCloze, Cloze: 2, Line: 3},
+			},
+		},
+		{
+			name:  "Notes without a back are skipped",
+			input: "#separator:tab\nfront only\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cards, err := ParseAnki(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("ParseAnki() returned an unexpected error: %v", err)
+			}
+			if len(cards) != len(tc.expected) {
+				t.Fatalf("Expected %d cards, but got %d: %+v", len(tc.expected), len(cards), cards)
+			}
+			for i, card := range cards {
+				expected := tc.expected[i]
+				if card.Question != expected.Question || card.Answer != expected.Answer || card.Context != expected.Context || card.Line != expected.Line {
+					t.Errorf("Expected card %d to be %q/%q in %q on line %d, but got %q/%q in %q on line %d", i, expected.Question, expected.Answer, expected.Context, expected.Line, card.Question, card.Answer, card.Context, card.Line)
+				}
+				if card.Kind != expected.Kind || card.Cloze != expected.Cloze || !slices.Equal(card.Tags, expected.Tags) {
+					t.Errorf("Expected card %d to be %q c%d tagged %q, but got %q c%d tagged %q", i, expected.Kind, expected.Cloze, expected.Tags, card.Kind, card.Cloze, card.Tags)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCards(t *testing.T) {
+	for name, expected := range map[string]int{
+		"cards.tsv":  1,
+		"notes.txt":  0,
+		"export.txt": 1,
+		"cards.md":   0,
+	} {
+		content := "hola\thello\n"
+		if name == "export.txt" {
+			content = "#separator:tab\n" + content
+		}
+		res := ParseCards(name, []byte(content), Config{})
+		if err := res.Err(); err != nil {
+			t.Fatalf("ParseCards(%q) returned an unexpected error: %v", name, err)
+		}
+		if len(res.Cards) != expected {
+			t.Errorf("Expected %d cards from %s, but got %d", expected, name, len(res.Cards))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config is the syntax of the Markdown cards of a source, for notes shared with
+// other tools, e.g. Front: and Back: cards, and the files holding them. The zero
+// value is Knolhash's own syntax; empty fields keep their default.
+type Config struct {
+	Question  string // Prefix of a question, Q: by default
+	Answer    string // Prefix of an answer, A: by default
+	Context   string // Prefix of a context or writing prompt, C: by default
+	Separator string // Line separating entries, --- by default
+	// IgnoreCase matches the prefixes in any case, e.g. front: for Front:.
+	IgnoreCase bool
+	// Extensions are those of the Markdown files, e.g. .mdx, in any case; .md by
+	// default. Anki exports and org files are read whatever they are.
+	Extensions []string
+	// Defaults are the frontmatter of files without their own, e.g. from the
+	// DirConfigFile of their directory. A file's frontmatter overrides them,
+	// except for tags, which are added to them.
+	Defaults Frontmatter
+}
+
+// defaultExtensions are the extensions of Markdown files by default.
+var defaultExtensions = []string{".md"}
+
+// Equal reports whether two configs are the same syntax.
+func (c Config) Equal(o Config) bool {
+	return c.Question == o.Question && c.Answer == o.Answer && c.Context == o.Context &&
+		c.Separator == o.Separator && c.IgnoreCase == o.IgnoreCase && slices.Equal(c.Extensions, o.Extensions) &&
+		c.Defaults.Deck == o.Defaults.Deck && slices.Equal(c.Defaults.Tags, o.Defaults.Tags) &&
+		c.Defaults.Context == o.Defaults.Context && c.Defaults.Disabled == o.Defaults.Disabled
+}
+
+// IsMarkdown reports whether a file is a Markdown file, going by its extension.
+func (c Config) IsMarkdown(name string) bool {
+	extensions := c.Extensions
+	if len(extensions) == 0 {
+		extensions = defaultExtensions
+	}
+	ext := filepath.Ext(name)
+	return slices.ContainsFunc(extensions, func(e string) bool { return strings.EqualFold(e, ext) })
+}
+
+// IsCardFile reports whether a file may hold cards, going by its name: Markdown
+// files, Anki exports ending in .tsv or .txt and org files.
+func (c Config) IsCardFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tsv", ".txt", ".org":
+		return true
+	}
+	return c.IsMarkdown(name)
+}
+
+// Validate checks that the prefixes and separator are single lines, and that no
+// prefix starts another, which would make it ambiguous.
+func (c Config) Validate() error {
+	fields := map[string]string{"question prefix": c.Question, "answer prefix": c.Answer, "context prefix": c.Context, "separator": c.Separator}
+	for name, value := range fields {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s must be a single line, got %q", name, value)
+		}
+		if value != "" && strings.TrimSpace(value) != value {
+			return fmt.Errorf("%s must not start or end with spaces, got %q", name, value)
+		}
+	}
+	for _, ext := range c.Extensions {
+		if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext[1:], "./\\ \t\r\n") {
+			return fmt.Errorf("extension %q must be a dot and a name, e.g. .mdx", ext)
+		}
+		if lower := strings.ToLower(ext); lower == ".org" || lower == ".tsv" {
+			return fmt.Errorf("extension %s is read as its own format, not Markdown", ext)
+		}
+	}
+	prefixes := c.fieldPrefixes()
+	for i, a := range prefixes {
+		for j, b := range prefixes {
+			if i != j && c.hasPrefix(a, b) {
+				return fmt.Errorf("prefix %q starts with prefix %q", a, b)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Config) question() string {
+	return cmp.Or(c.Question, questionPrefix)
+}
+
+func (c Config) answer() string {
+	return cmp.Or(c.Answer, answerPrefix)
+}
+
+func (c Config) context() string {
+	return cmp.Or(c.Context, contextPrefix)
+}
+
+func (c Config) separator() string {
+	return cmp.Or(c.Separator, "---")
+}
+
+// fieldPrefixes are the prefixes of the lines starting a card's fields.
+func (c Config) fieldPrefixes() []string {
+	return []string{c.question(), c.answer(), c.context(), optionPrefix, stepPrefix, hintPrefix, tagsPrefix, idPrefix}
+}
+
+// hasPrefix reports whether a line starts with prefix, in any case with IgnoreCase.
+func (c Config) hasPrefix(line, prefix string) bool {
+	if c.IgnoreCase {
+		return len(line) >= len(prefix) && strings.EqualFold(line[:len(prefix)], prefix)
+	}
+	return strings.HasPrefix(line, prefix)
+}
+
+// Format returns the lines of a card in the syntax of c, e.g. to append to a
+// file; context may be empty.
+func (c Config) Format(question, answer, context string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n%s %s\n", c.question(), question, c.answer(), answer)
+	if context != "" {
+		fmt.Fprintf(&b, "%s %s\n", c.context(), context)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWithConfig(t *testing.T) {
+	cfg := Config{Question: "Front:", Answer: "Back:", Separator: "%%", IgnoreCase: true}
+	input := "Front: What is 1+1?\nback: 2\nQ: stays in the answer\n%%\nFRONT: Capital of France?\nBack: Paris\nC: Geography"
+	cards, err := ParseWith(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("ParseWith() returned an unexpected error: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, but got %d", len(cards))
+	}
+	if cards[0].Question != "What is 1+1?" || cards[0].Answer != "2\nQ: stays in the answer" {
+		t.Errorf("Expected the first card to be answered 2, but got %+v", cards[0])
+	}
+	if cards[1].Question != "Capital of France?" || cards[1].Answer != "Paris" || cards[1].Context != "Geography" {
+		t.Errorf("Expected the second card to be about Paris, but got %+v", cards[1])
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"default", Config{}, false},
+		{"front and back", Config{Question: "Front:", Answer: "Back:"}, false},
+		{"same prefix twice", Config{Question: "A:"}, true},
+		{"prefix starting another", Config{Question: "Card:", Answer: "Card: back:"}, true},
+		{"clash in any case", Config{Answer: "q:", IgnoreCase: true}, true},
+		{"multi-line separator", Config{Separator: "--\n--"}, true},
+		{"surrounding spaces", Config{Question: " Front:"}, true},
+		{"extensions", Config{Extensions: []string{".md", ".MDX", ".txt"}}, false},
+		{"extension without a dot", Config{Extensions: []string{"mdx"}}, true},
+		{"extension of another format", Config{Extensions: []string{".org"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigFormat(t *testing.T) {
+	for _, cfg := range []Config{{}, {Question: "Front:", Answer: "Back:", Context: "Deck:"}} {
+		input := "Q: Existing?\nA: Yes\n\n" + cfg.Format("What is 1+1?", "2", "Math")
+		cards, err := ParseWith(strings.NewReader(input), cfg)
+		if err != nil {
+			t.Fatalf("ParseWith() returned an unexpected error: %v", err)
+		}
+		last := cards[len(cards)-1]
+		if last.Question != "What is 1+1?" || last.Answer != "2" || last.Context != "Math" || last.Line != 4 {
+			t.Errorf("Expected the formatted card to parse back on line 4 with %+v, but got %+v", cfg, last)
+		}
+	}
+}
+
+func TestConfigExtensions(t *testing.T) {
+	cfg := Config{Extensions: []string{".mdx", ".txt"}}
+	for name, want := range map[string]bool{"a.mdx": true, "a.MDX": true, "a.md": false, "a.org": true, "a.tsv": true, "a.go": false} {
+		if got := cfg.IsCardFile(name); got != want {
+			t.Errorf("IsCardFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !IsCardFile("a.md") || !IsCardFile("a.mdx") || IsCardFile("a.go") {
+		t.Errorf("Expected IsCardFile to allow the Markdown extensions sources often add")
+	}
+
+	note := []byte("Q: Is this a note?\nA: Yes")
+	if res := ParseCards("notes.txt", note, cfg); len(res.Cards) != 1 {
+		t.Errorf("Expected a .txt file to be Markdown with the extension, but got %+v", res)
+	}
+	if res := ParseCards("notes.txt", note, Config{}); len(res.Cards) != 0 {
+		t.Errorf("Expected a .txt file to have no cards without the extension, but got %+v", res)
+	}
+	anki := []byte("#separator:tab\nWhat is 1+1?\t2")
+	if res := ParseCards("export.txt", anki, cfg); len(res.Cards) != 1 || res.Cards[0].Answer != "2" {
+		t.Errorf("Expected a .txt Anki export to stay one with the extension, but got %+v", res)
+	}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// DirConfigFile names the file of settings for a directory of a source and
+// everything below it.
+const DirConfigFile = "_knolhash.yaml"
+
+// DirConfig is the settings of a DirConfigFile: defaults for the frontmatter of
+// the files below it, and the syntax of their cards, overriding the source's.
+// Each directory inherits the settings of those above it, overriding them.
+type DirConfig struct {
+	Deck     string  `yaml:"deck"`
+	Tags     tagList `yaml:"tags"` // Added to those of the directories above
+	Context  string  `yaml:"context"`
+	Disabled *bool   `yaml:"disabled"` // Set to false to enable a directory below a disabled one
+
+	QuestionPrefix string `yaml:"question_prefix"`
+	AnswerPrefix   string `yaml:"answer_prefix"`
+	ContextPrefix  string `yaml:"context_prefix"`
+	Separator      string `yaml:"separator"`
+	IgnoreCase     *bool  `yaml:"ignore_case"`
+}
+
+// ReadDirConfig reads the DirConfigFile of dir, or returns the zero DirConfig if
+// it has none.
+func ReadDirConfig(dir string) (DirConfig, error) {
+	var d DirConfig
+	data, err := os.ReadFile(filepath.Join(dir, DirConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&d); err != nil && !errors.Is(err, io.EOF) {
+		return DirConfig{}, fmt.Errorf("invalid %s: %w", DirConfigFile, err)
+	}
+	return d, nil
+}
+
+// Under returns the settings of d in a directory below one with parent's.
+func (d DirConfig) Under(parent DirConfig) DirConfig {
+	tags := slices.Clone(parent.Tags)
+	for _, tag := range d.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return DirConfig{
+		Deck:           cmp.Or(d.Deck, parent.Deck),
+		Tags:           tags,
+		Context:        cmp.Or(d.Context, parent.Context),
+		Disabled:       cmp.Or(d.Disabled, parent.Disabled),
+		QuestionPrefix: cmp.Or(d.QuestionPrefix, parent.QuestionPrefix),
+		AnswerPrefix:   cmp.Or(d.AnswerPrefix, parent.AnswerPrefix),
+		ContextPrefix:  cmp.Or(d.ContextPrefix, parent.ContextPrefix),
+		Separator:      cmp.Or(d.Separator, parent.Separator),
+		IgnoreCase:     cmp.Or(d.IgnoreCase, parent.IgnoreCase),
+	}
+}
+
+// Apply returns cfg, the syntax of a source, with the settings of d for a file
+// in its directory.
+func (d DirConfig) Apply(cfg Config) Config {
+	cfg.Question = cmp.Or(d.QuestionPrefix, cfg.Question)
+	cfg.Answer = cmp.Or(d.AnswerPrefix, cfg.Answer)
+	cfg.Context = cmp.Or(d.ContextPrefix, cfg.Context)
+	cfg.Separator = cmp.Or(d.Separator, cfg.Separator)
+	if d.IgnoreCase != nil {
+		cfg.IgnoreCase = *d.IgnoreCase
+	}
+	cfg.Defaults = Frontmatter{
+		Tags:     d.Tags,
+		Deck:     d.Deck,
+		Context:  d.Context,
+		Disabled: d.Disabled != nil && *d.Disabled,
+	}
+	return cfg
+}
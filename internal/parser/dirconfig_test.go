@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDirConfig(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "tcp")
+	off := filepath.Join(sub, "drafts")
+	on := filepath.Join(off, "final")
+	for dir, settings := range map[string]string{
+		root: "deck: Networking\ntags: [net]\ncontext: Networking\n",
+		sub:  "tags: [tcp, net]\nquestion_prefix: \"Front:\"\nanswer_prefix: \"Back:\"\n",
+		off:  "disabled: true\n",
+		on:   "disabled: false\n",
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, DirConfigFile), []byte(settings), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var settings DirConfig
+	for _, dir := range []string{root, sub} {
+		own, err := ReadDirConfig(dir)
+		if err != nil {
+			t.Fatalf("ReadDirConfig(%s) returned an unexpected error: %v", dir, err)
+		}
+		settings = own.Under(settings)
+	}
+	cfg := settings.Apply(Config{})
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected the inherited settings to be valid, but got %v", err)
+	}
+
+	res := ParseCards("tcp/handshake.md", []byte("Front: How many steps are in the TCP handshake?\nBack: Three."), cfg)
+	if len(res.Cards) != 1 {
+		t.Fatalf("Expected the directory's prefixes to read 1 card, but got %+v", res)
+	}
+	card := res.Cards[0]
+	if card.Context != "Networking" || !slices.Equal(card.Tags, []string{"Networking", "net", "tcp"}) {
+		t.Errorf("Expected the inherited deck, tags and context, but got %q and %q", card.Context, card.Tags)
+	}
+
+	res = ParseCards("tcp/udp.md", []byte("---\ndeck: Transport\ntags: [udp]\ncontext: UDP\n---\nFront: Is UDP reliable?\nBack: No."), cfg)
+	if len(res.Cards) != 1 || res.Cards[0].Context != "UDP" || !slices.Equal(res.Cards[0].Tags, []string{"Transport", "net", "tcp", "udp"}) {
+		t.Errorf("Expected the frontmatter to override the deck and context and add tags, but got %+v", res.Cards)
+	}
+
+	for dir, disabled := range map[string]bool{off: true, on: false} {
+		below := settings
+		for _, d := range []string{off, on} {
+			own, err := ReadDirConfig(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			below = own.Under(below)
+			if d == dir {
+				break
+			}
+		}
+		res := ParseCards("note.md", []byte("Front: Q?\nBack: A."), below.Apply(Config{}))
+		if got := len(res.Cards) == 0; got != disabled {
+			t.Errorf("Expected the cards below %s to be disabled: %v, but got %+v", dir, disabled, res.Cards)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, DirConfigFile), []byte("dek: Typo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadDirConfig(root); err == nil {
+		t.Errorf("Expected an unknown setting to be an error")
+	}
+	if d, err := ReadDirConfig(t.TempDir()); err != nil || d.Deck != "" {
+		t.Errorf("Expected a directory without settings to have none, but got %+v, %v", d, err)
+	}
+}
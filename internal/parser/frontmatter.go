@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"go.yaml.in/yaml/v3"
+)
+
+// Frontmatter is the metadata of a file, from a YAML block between --- lines at
+// its top. Keys other than these, such as those of Obsidian, are ignored.
+type Frontmatter struct {
+	// Tags are added to every card of the file. They may be a list or a
+	// comma-separated string.
+	Tags tagList `yaml:"tags"`
+	// Deck names the deck the file's cards belong to, added to their tags.
+	Deck string `yaml:"deck"`
+	// Context is the context of the cards without a C: line.
+	Context string `yaml:"context"`
+	// Disabled files have no cards.
+	Disabled bool `yaml:"disabled"`
+}
+
+// tagList reads tags from a YAML list or a comma-separated string.
+type tagList []string
+
+func (t *tagList) UnmarshalYAML(node *yaml.Node) error {
+	var tags []string
+	if node.Kind == yaml.ScalarNode {
+		tags = strings.Split(node.Value, ",")
+	} else if err := node.Decode(&tags); err != nil {
+		return err
+	}
+	*t = nil
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// readFrontmatter reads the frontmatter at the top of a file's lines, returning
+// it with the number of lines it takes up. A block that isn't a YAML mapping,
+// or has card lines in it, is a card separator rather than frontmatter, and 0
+// lines are returned.
+func readFrontmatter(lines []string, cfg Config) (Frontmatter, int, error) {
+	var fm Frontmatter
+	if len(lines) == 0 || lines[0] != "---" {
+		return fm, 0, nil
+	}
+	end := slices.Index(lines[1:], "---") + 1
+	if end == 0 {
+		return fm, 0, nil
+	}
+	for _, line := range lines[1:end] {
+		for _, prefix := range cfg.fieldPrefixes() {
+			if cfg.hasPrefix(line, prefix) {
+				return fm, 0, nil
+			}
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "\n")), &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fm, 0, nil
+	}
+	if err := doc.Content[0].Decode(&fm); err != nil {
+		return Frontmatter{}, end + 1, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	return fm, end + 1, nil
+}
+
+// over returns the frontmatter of a file with defaults, which its own overrides,
+// except for tags, added to those of defaults.
+func (fm Frontmatter) over(defaults Frontmatter) Frontmatter {
+	tags := slices.Clone(defaults.Tags)
+	for _, tag := range fm.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return Frontmatter{
+		Tags:     tags,
+		Deck:     cmp.Or(fm.Deck, defaults.Deck),
+		Context:  cmp.Or(fm.Context, defaults.Context),
+		Disabled: fm.Disabled || defaults.Disabled,
+	}
+}
+
+// apply sets the frontmatter's tags and context default on a card.
+func (fm Frontmatter) apply(card *domain.Card) {
+	if strings.TrimSpace(card.Context) == "" && fm.Context != "" {
+		card.Context = fm.Context
+	}
+	tags := slices.Clone(card.Tags)
+	for _, tag := range append([]string{fm.Deck}, fm.Tags...) {
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	card.Tags = tags
+}
+
+// applyAll applies the defaults of a file without frontmatter, such as an Anki
+// export, to the cards of its result, dropping them if it is disabled.
+func (fm Frontmatter) applyAll(res ParseResult) ParseResult {
+	if fm.Disabled {
+		res.Cards = nil
+	}
+	for i := range res.Cards {
+		fm.apply(&res.Cards[i])
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/latex"
+)
+
+const (
+	// inlineSeparator splits an inline card, Question::Answer, as written for
+	// the Obsidian Spaced Repetition plugin.
+	inlineSeparator = "::"
+	// reversedSeparator splits an inline card that is also asked in reverse.
+	reversedSeparator = ":::"
+	// flashcardsTag marks the notes the Spaced Repetition plugin reads cards from.
+	flashcardsTag = "flashcards"
+)
+
+// flashcardsTagPattern matches #flashcards in a note, capturing the deck of a
+// nested tag such as #flashcards/spanish/verbs.
+var flashcardsTagPattern = regexp.MustCompile(`(?:^|\s)#` + flashcardsTag + `((?:/[\w-]+)*)(?:\s|$)`)
+
+// inlineDeck reports whether a file is tagged #flashcards, in its text or its
+// frontmatter tags, and so has inline cards, returning the deck of its first
+// nested tag with slashes, e.g. spanish/verbs.
+func inlineDeck(lines []string, fm Frontmatter) (string, bool) {
+	for _, line := range lines {
+		if m := flashcardsTagPattern.FindStringSubmatch(line); m != nil {
+			return strings.TrimPrefix(m[1], "/"), true
+		}
+	}
+	for _, tag := range fm.Tags {
+		if tag = strings.TrimPrefix(tag, "#"); tag == flashcardsTag || strings.HasPrefix(tag, flashcardsTag+"/") {
+			return strings.TrimPrefix(strings.TrimPrefix(tag, flashcardsTag), "/"), true
+		}
+	}
+	return "", false
+}
+
+// inlineCards reads an inline card from a line, Question::Answer, or two for a
+// reversed one, Question:::Answer. Separators in inline code or cloze deletions,
+// as in `std::vector` or {{c1::text}}, don't count.
+func inlineCards(line string, lineNo int, deck string) []domain.Card {
+	i, reversed := inlineSeparatorIndex(line)
+	if i < 0 {
+		return nil
+	}
+	sep := inlineSeparator
+	if reversed {
+		sep = reversedSeparator
+	}
+	question := strings.TrimSpace(line[:i])
+	answer := strings.TrimSpace(line[i+len(sep):])
+	if question == "" || answer == "" {
+		return nil
+	}
+	cards := []domain.Card{{Question: question, Answer: answer, Context: deck, Line: lineNo}}
+	if reversed {
+		cards = append(cards, domain.Card{Question: answer, Answer: question, Context: deck, Line: lineNo})
+	}
+	return cards
+}
+
+// inlineSeparatorIndex returns the index of the first separator of a line outside
+// inline code, math and cloze deletions, or -1, and whether it is a reversed one.
+func inlineSeparatorIndex(line string) (int, bool) {
+	code := false  // Inside `inline code`
+	cloze := false // Inside {{...}}
+	spans := latex.Spans(line)
+	for i := 0; i < len(line); i++ {
+		if len(spans) > 0 && i >= spans[0].Start {
+			i, spans = spans[0].End-1, spans[1:] // Math is no separator
+			continue
+		}
+		switch {
+		case line[i] == '`':
+			code = !code
+		case code:
+		case strings.HasPrefix(line[i:], "{{"):
+			cloze = true
+			i++
+		case strings.HasPrefix(line[i:], "}}"):
+			cloze = false
+			i++
+		case !cloze && strings.HasPrefix(line[i:], inlineSeparator):
+			reversed := strings.HasPrefix(line[i:], reversedSeparator)
+			// Four or more colons are no separator
+			if n := len(line[i:]) - len(strings.TrimLeft(line[i:], ":")); n > len(reversedSeparator) {
+				return -1, false
+			}
+			return i, reversed
+		}
+	}
+	return -1, false
+}
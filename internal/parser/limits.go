@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Default limits on what is parsed, well above any handwritten notes, so that a
+// generated file can't exhaust the memory of a sync.
+const (
+	DefaultMaxLineSize = 4 << 20  // 4 MiB
+	DefaultMaxFileSize = 32 << 20 // 32 MiB
+)
+
+// ErrTooLarge is returned for a file, or a line of it, over the limits set by
+// SetLimits. Its cards are not parsed at all, rather than cut short.
+var ErrTooLarge = errors.New("over the size limit")
+
+var (
+	maxLineSize = DefaultMaxLineSize
+	maxFileSize = DefaultMaxFileSize
+)
+
+// SetLimits sets the largest line and file parsed, in bytes, e.g. from the
+// max_line_size and max_file_size settings; zero keeps the default. It must be
+// called before any parsing.
+func SetLimits(line, file int) {
+	maxLineSize = DefaultMaxLineSize
+	if line > 0 {
+		maxLineSize = line
+	}
+	maxFileSize = DefaultMaxFileSize
+	if file > 0 {
+		maxFileSize = file
+	}
+}
+
+// CheckFileSize returns an error wrapping ErrTooLarge if a file of size bytes is
+// over the limit.
+func CheckFileSize(size int64) error {
+	if size > int64(maxFileSize) {
+		return fmt.Errorf("file of %d bytes is larger than %d bytes (max_file_size): %w", size, maxFileSize, ErrTooLarge)
+	}
+	return nil
+}
+
+// readLines reads the lines of r, returning an error wrapping ErrTooLarge for
+// a line over the limit, with its number.
+func readLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize+1) // Room for the line's newline
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		return nil, fmt.Errorf("line %d is longer than %d bytes (max_line_size): %w", len(lines)+1, maxLineSize, ErrTooLarge)
+	} else if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
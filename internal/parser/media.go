@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+// imageRef matches a Markdown image, ![alt](path) or ![alt](<path> "title"),
+// capturing its destination.
+var imageRef = regexp.MustCompile(`!\[[^\]]*\]\(\s*(?:<([^>]+)>|([^)\s]+))(?:\s+["'(][^)]*)?\)`)
+
+// Images returns the relative paths of the images in Markdown, as written but
+// unescaped and without any query or fragment, skipping URLs and absolute paths.
+func Images(markdown string) []string {
+	var images []string
+	for _, m := range imageRef.FindAllStringSubmatch(markdown, -1) {
+		if p, ok := ImagePath(m[1] + m[2]); ok && !slices.Contains(images, p) {
+			images = append(images, p)
+		}
+	}
+	return images
+}
+
+// ImagePath returns the path of an image's destination, unescaped and without
+// any query or fragment, reporting false for URLs and absolute paths.
+func ImagePath(destination string) (string, bool) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// ResolveMedia resolves the path of an image, relative to the file it is in,
+// from the root of the file's source. It reports false for paths leaving the
+// source.
+func ResolveMedia(file, image string) (string, bool) {
+	p := path.Join(path.Dir(file), image)
+	return p, filepath.IsLocal(filepath.FromSlash(p))
+}
+
+// cardImages returns the images in the fields of a card.
+func cardImages(card domain.Card) []string {
+	var images []string
+	for _, field := range append([]string{card.Question, card.Answer, card.Hint}, card.Steps...) {
+		for _, image := range Images(field) {
+			if !slices.Contains(images, image) {
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImages(t *testing.T) {
+	markdown := `![A cell](img/cell.png) and ![](<diagrams/two words.svg> "Title")
+![again](img/cell.png) ![remote](https://example.com/a.png) ![abs](/etc/a.png)
+![escaped](img/a%20b.png?v=2#top) [not an image](doc.md)`
+	want := []string{"img/cell.png", "diagrams/two words.svg", "img/a b.png"}
+	if got := Images(markdown); !slices.Equal(got, want) {
+		t.Errorf("Images() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveMedia(t *testing.T) {
+	tests := []struct {
+		file, image, want string
+		ok                bool
+	}{
+		{"notes.md", "img/a.png", "img/a.png", true},
+		{"bio/cells.md", "img/a.png", "bio/img/a.png", true},
+		{"bio/cells.md", "../shared/a.png", "shared/a.png", true},
+		{"cells.md", "../a.png", "../a.png", false},
+	}
+	for _, tt := range tests {
+		got, ok := ResolveMedia(tt.file, tt.image)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveMedia(%q, %q) = %q, %v, want %q, %v", tt.file, tt.image, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseMedia(t *testing.T) {
+	input := `Q: Which organelle is this? ![](img/mito.png)
+A: A mitochondrion, see ![diagram](img/mito-diagram.svg).
+---
+Q: No images here
+A: None
+`
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Parse() returned %d cards, want 2", len(cards))
+	}
+	if want := []string{"img/mito.png", "img/mito-diagram.svg"}; !slices.Equal(cards[0].Media, want) {
+		t.Errorf("cards[0].Media = %q, want %q", cards[0].Media, want)
+	}
+	if cards[1].Media != nil {
+		t.Errorf("cards[1].Media = %q, want none", cards[1].Media)
+	}
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/latex"
+)
+
+// drillTag marks the org headings that are org-drill items.
+const drillTag = "drill"
+
+// orgHeading matches an org heading: its stars, its title and its tags, as in
+// "** Capital of France :drill:geo:".
+var orgHeading = regexp.MustCompile(`^(\*+)\s+(.*?)(?:\s+:([\w@#%:]+):)?\s*$`)
+
+// orgProperty matches a property line in a :PROPERTIES: drawer.
+var orgProperty = regexp.MustCompile(`^\s*:([\w-]+):\s*(.*)$`)
+
+// orgDrawer matches the line opening a drawer, such as :PROPERTIES: or :LOGBOOK:.
+var orgDrawer = regexp.MustCompile(`^:[A-Z_]+:$`)
+
+// orgCloze matches the cloze deletions of an org-drill item, [text] or
+// [text||hint], along with org links, [[...]], which are left alone.
+var orgCloze = regexp.MustCompile(`\[\[.*?\]\]|\[([^\[\]|]+?)(?:\|\|([^\[\]]+))?\]`)
+
+// orgNotCloze matches bracketed text that isn't a cloze deletion: checkboxes,
+// inactive timestamps, footnotes and statistics cookies.
+var orgNotCloze = regexp.MustCompile(`^(?:[ X-]|\d{4}-\d{2}-\d{2}.*|fn:.*|\d*/\d*|\d*%)$`)
+
+// orgSection is an org heading with the lines under it up to the next heading.
+type orgSection struct {
+	level int
+	title string
+	tags  []string
+	line  int
+	body  []string
+}
+
+// ParseOrg reads the cards of an org file in the style of org-drill: each
+// heading tagged :drill: is an item, whose text is the question and whose
+// "Answer" subheading, if any, is the answer. The item's title becomes the
+// context, or the question if the item has no text, and its other tags the
+// card's tags. An item without an answer whose text has cloze deletions, [text]
+// or [text||hint], is a text cloze card blanking them all. A twosided item,
+// with the property DRILL_CARD_TYPE: twosided, makes a card asking each of its
+// first two subheadings for the other. Source blocks become fenced code blocks.
+func ParseOrg(r io.Reader) ([]domain.Card, error) {
+	var sections []orgSection
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range lines {
+		lineNo := i + 1
+		if m := orgHeading.FindStringSubmatch(line); m != nil {
+			section := orgSection{level: len(m[1]), title: m[2], line: lineNo}
+			if m[3] != "" {
+				section.tags = strings.Split(m[3], ":")
+			}
+			sections = append(sections, section)
+		} else if len(sections) > 0 {
+			sections[len(sections)-1].body = append(sections[len(sections)-1].body, line)
+		}
+	}
+
+	var cards []domain.Card
+	for i, item := range sections {
+		if !slices.Contains(item.tags, drillTag) {
+			continue
+		}
+		var children []orgSection
+		for _, child := range sections[i+1:] {
+			if child.level <= item.level {
+				break
+			}
+			if child.level == item.level+1 {
+				children = append(children, child)
+			}
+		}
+		body, props := orgBody(item.body)
+		card := domain.Card{Question: body, Context: item.title, Line: item.line}
+		if card.Question == "" {
+			card.Question, card.Context = item.title, ""
+		}
+		for _, tag := range item.tags {
+			if tag != drillTag {
+				card.Tags = append(card.Tags, tag)
+			}
+		}
+
+		if strings.EqualFold(props["DRILL_CARD_TYPE"], "twosided") && len(children) >= 2 {
+			front, _ := orgBody(children[0].body)
+			back, _ := orgBody(children[1].body)
+			if front == "" || back == "" {
+				continue
+			}
+			card.Context = item.title
+			reversed := card
+			card.Question, card.Answer = front, back
+			reversed.Question, reversed.Answer = back, front
+			cards = append(cards, card, reversed)
+			continue
+		}
+
+		for _, child := range children {
+			if strings.EqualFold(child.title, "answer") {
+				card.Answer, _ = orgBody(child.body)
+				break
+			}
+		}
+		if card.Answer == "" {
+			if question, ok := orgClozes(card.Question); ok {
+				card.Question = question
+				cards = append(cards, textClozes(card, []int{1})...)
+			}
+			continue
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// orgBody turns the lines under an org heading into Markdown, returning it with
+// the properties of its :PROPERTIES: drawer. Drawers, planning lines and other
+// #+ keywords are left out.
+func orgBody(lines []string) (string, map[string]string) {
+	props := make(map[string]string)
+	var out []string
+	drawer := "" // The drawer being read, e.g. :PROPERTIES:
+	code := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		upper := strings.ToUpper(trimmed)
+		switch {
+		case strings.HasPrefix(upper, "#+END_SRC"), strings.HasPrefix(upper, "#+END_EXAMPLE"):
+			code = false
+			out = append(out, "```")
+		case code:
+			out = append(out, line)
+		case drawer != "":
+			if upper == ":END:" {
+				drawer = ""
+			} else if m := orgProperty.FindStringSubmatch(line); m != nil && drawer == ":PROPERTIES:" {
+				props[strings.ToUpper(m[1])] = strings.TrimSpace(m[2])
+			}
+		case orgDrawer.MatchString(upper):
+			drawer = upper
+		case strings.HasPrefix(upper, "SCHEDULED:"), strings.HasPrefix(upper, "DEADLINE:"), strings.HasPrefix(upper, "CLOSED:"):
+		case strings.HasPrefix(upper, "#+BEGIN_SRC"):
+			lang, _, _ := strings.Cut(strings.TrimSpace(trimmed[len("#+BEGIN_SRC"):]), " ")
+			code = true
+			out = append(out, "```"+lang)
+		case strings.HasPrefix(upper, "#+BEGIN_EXAMPLE"):
+			code = true
+			out = append(out, "```")
+		case strings.HasPrefix(trimmed, "#+"):
+		default:
+			out = append(out, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(out, "\n")), props
+}
+
+// orgClozes rewrites the org-drill cloze deletions of text as {{c1::...}}
+// deletions, reporting whether there were any. Code blocks and math, such as
+// $[0, 1]$, are left alone.
+func orgClozes(text string) (string, bool) {
+	found := false
+	lines := strings.Split(text, "\n")
+	code := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			code = !code
+		}
+		if code {
+			continue
+		}
+		spans := latex.Spans(line)
+		var b strings.Builder
+		last := 0
+		for _, m := range orgCloze.FindAllStringSubmatchIndex(line, -1) {
+			if m[2] < 0 || orgNotCloze.MatchString(line[m[2]:m[3]]) || inMath(spans, m[0]) {
+				continue
+			}
+			found = true
+			b.WriteString(line[last:m[0]])
+			b.WriteString("{{c1::" + line[m[2]:m[3]])
+			if m[4] >= 0 {
+				b.WriteString("::" + line[m[4]:m[5]])
+			}
+			b.WriteString("}}")
+			last = m[1]
+		}
+		lines[i] = b.String() + line[last:]
+	}
+	return strings.Join(lines, "\n"), found
+}
+
+// inMath reports whether the index i of a text is in one of its math spans.
+func inMath(spans []latex.Span, i int) bool {
+	for _, s := range spans {
+		if i >= s.Start && i < s.End {
+			return true
+		}
+	}
+	return false
+}
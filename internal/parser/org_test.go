@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+func TestParseOrg(t *testing.T) {
+	input := `#+TITLE: Geography
+* Europe
+** Capital of France                                    :drill:geo:
+SCHEDULED: <2025-01-01 Wed>
+:PROPERTIES:
+:ID: 1234
+:END:
+What is the capital of France?
+*** Answer
+Paris
+** Not a drill item
+Some notes [[https://example.com][a link]].
+** Rivers :drill:
+The [Danube||river] flows into the [Black Sea], see [2024-05-01 Wed], $[0, 1]$ and [[link]].
+** Lookup :drill:
+#+BEGIN_SRC go
+m := map[string]int{}
+v := m[key]
+#+END_SRC
+*** Answer
+A map index.
+** Hello :drill:
+:PROPERTIES:
+:DRILL_CARD_TYPE: twosided
+:END:
+*** English
+hello
+*** Spanish
+hola
+** No answer and no cloze :drill:
+`
+	cards, err := ParseOrg(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrg() returned an unexpected error: %v", err)
+	}
+	expected := []domain.Card{
+		{Question: "What is the capital of France?", Answer: "Paris", Context: "Capital of France", Tags: []string{"geo"}, Line: 3},
+		{Question: "The {{c1::Danube::river}} flows into the {{c1::Black Sea}}, see [2024-05-01 Wed], $[0, 1]$ and [[link]].", Answer: "Danube, Black Sea", Context: "Rivers", Kind: domain.KindTextCloze, Cloze: 1, Line: 13},
+		{Question: "```go\nm := map[string]int{}\nv := m[key]\n```", Answer: "A map index.", Context: "Lookup", Line: 15},
+		{Question: "hello", Answer: "hola", Context: "Hello", Line: 22},
+		{Question: "hola", Answer: "hello", Context: "Hello", Line: 22},
+	}
+	if len(cards) != len(expected) {
+		t.Fatalf("Expected %d cards, but got %d: %+v", len(expected), len(cards), cards)
+	}
+	for i, card := range cards {
+		e := expected[i]
+		if card.Question != e.Question || card.Answer != e.Answer || card.Context != e.Context || card.Line != e.Line {
+			t.Errorf("Expected card %d to be %q/%q in %q on line %d, but got %q/%q in %q on line %d", i, e.Question, e.Answer, e.Context, e.Line, card.Question, card.Answer, card.Context, card.Line)
+		}
+		if card.Kind != e.Kind || card.Cloze != e.Cloze || !slices.Equal(card.Tags, e.Tags) {
+			t.Errorf("Expected card %d to be %q c%d tagged %q, but got %q c%d tagged %q", i, e.Kind, e.Cloze, e.Tags, card.Kind, card.Cloze, card.Tags)
+		}
+	}
+}
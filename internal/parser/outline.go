@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// outlineTag marks the bullets that are cards in an outline, as Logseq does:
+// #card or #[[card]].
+var outlineTag = regexp.MustCompile(`(?:^|\s)#(?:card|\[\[card\]\])(?:\s|$)`)
+
+// outlineProperty matches a block property of an outliner, e.g. Logseq's own
+// card-repeats:: 2 or collapsed:: true, which is no part of an answer.
+var outlineProperty = regexp.MustCompile(`^\s*[A-Za-z][\w-]*:: `)
+
+// outlineQuestion reads the question of an outline card from a top-level bullet
+// tagged #card, e.g. "- What is TCP? #card", without the tag.
+func outlineQuestion(line string) (string, bool) {
+	if len(line) < 2 || !strings.ContainsRune("-*+", rune(line[0])) || line[1] != ' ' {
+		return "", false
+	}
+	text := line[2:]
+	if !outlineTag.MatchString(text) {
+		return "", false
+	}
+	question := strings.Join(strings.Fields(outlineTag.ReplaceAllString(text, " ")), " ")
+	return question, question != ""
+}
+
+// indented reports whether a line belongs to the bullet above it in an outline:
+// it is blank or indented.
+func indented(line string) bool {
+	return strings.TrimSpace(line) == "" || line[0] == ' ' || line[0] == '\t'
+}
+
+// outlineAnswer makes the answer of an outline card from the lines indented
+// below its bullet, without block properties and with the indentation they
+// share removed, so that nested bullets stay a list.
+func outlineAnswer(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		if !outlineProperty.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	indent := -1
+	for _, line := range kept {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range kept {
+		if len(line) >= indent && indent > 0 {
+			kept[i] = line[indent:]
+		} else {
+			kept[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
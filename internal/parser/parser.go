@@ -1,20 +1,47 @@
 package parser
 
 import (
-	"bufio"
+	"bytes"
+	"fmt"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/conorfennell/knolhash/internal/cloze"
 	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/latex"
 )
 
 const (
 	questionPrefix = "Q:"
 	answerPrefix   = "A:"
 	contextPrefix  = "C:"
+	optionPrefix   = "O:"
+	stepPrefix     = "S:"
+	hintPrefix     = "H:"
+	tagsPrefix     = "T:"
+	idPrefix       = "ID:"
 )
 
+// idComment matches an HTML comment pinning the ID of a card, <!-- id: ... -->.
+var idComment = regexp.MustCompile(`^\s*<!--\s*(?i:id):\s*(\S+)\s*-->\s*$`)
+
+// regionMarker matches the comments turning parsing off and back on around
+// parts of a file, <!-- knolhash:off --> and <!-- knolhash:on -->.
+var regionMarker = regexp.MustCompile(`^\s*<!--\s*knolhash:(off|on)\s*-->\s*$`)
+
+// validID matches the IDs authors may pin cards to, which stand in for hashes
+// in URLs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// mathMarker delimits display math, whose lines are content like code's.
+const mathMarker = "$$"
+
 type state int
 
 const (
@@ -22,26 +49,193 @@ const (
 	readingQuestion
 	readingAnswer
 	readingContext
+	readingOptions
+	readingSteps
+	readingHint
+	readingTags
+	readingNumberedAnswer
+	readingID
 )
 
-// ParseFile reads a file from the given path and extracts all cards.
+// ParseFile reads a file from the given path and extracts all cards, whose
+// File is the path, in Knolhash's own syntax.
 func ParseFile(path string) ([]domain.Card, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	if info, err := file.Stat(); err != nil {
+		return nil, err
+	} else if err := CheckFileSize(info.Size()); err != nil {
+		return nil, err
+	}
+
+	cards, err := Parse(file)
+	return withFile(cards, path), err
+}
+
+// IsCardFile reports whether a file may hold cards in any source, going by its
+// name: Markdown files with the .md extension or the .markdown and .mdx sources
+// often add, Anki exports ending in .tsv or .txt and org files. Sources choose
+// theirs with Config.IsCardFile.
+func IsCardFile(name string) bool {
+	return Config{Extensions: []string{".md", ".markdown", ".mdx"}}.IsCardFile(name)
+}
+
+// ParseCards parses the cards of a file by its name: an Anki export for a .tsv
+// file or a .txt file starting with Anki's # headers, org-drill items for an
+// .org file, Markdown otherwise, in the syntax of cfg. Other .txt files are
+// Markdown if cfg has the extension, else have no cards. The File of the cards
+// is the name. Only Markdown files get warnings.
+func ParseCards(name string, content []byte, cfg Config) ParseResult {
+	if err := CheckFileSize(int64(len(content))); err != nil {
+		return failed(err)
+	}
+	var res ParseResult
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".org":
+		res = cfg.Defaults.applyAll(result(ParseOrg(bytes.NewReader(content))))
+	case ".tsv":
+		res = cfg.Defaults.applyAll(result(ParseAnki(bytes.NewReader(content))))
+	case ".txt":
+		if bytes.HasPrefix(content, []byte("#separator:")) || bytes.HasPrefix(content, []byte("#html:")) {
+			res = cfg.Defaults.applyAll(result(ParseAnki(bytes.NewReader(content))))
+		} else if cfg.IsMarkdown(name) {
+			res = parseMarkdown(bytes.NewReader(content), cfg)
+		} else {
+			return ParseResult{}
+		}
+	default:
+		res = parseMarkdown(bytes.NewReader(content), cfg)
+	}
+	res.Cards = withFile(res.Cards, name)
+	return res
+}
 
-	return Parse(file)
+// withFile sets the File of cards to path.
+func withFile(cards []domain.Card, path string) []domain.Card {
+	for i := range cards {
+		cards[i].File = path
+	}
+	return cards
 }
 
-// Parse reads from an io.Reader and extracts all cards.
+// Parse reads from an io.Reader and extracts all cards, in Knolhash's own
+// syntax described below.
+//
+// An entry starting with C: instead of Q:, at the start of the file or after a
+// --- separator, is a writing prompt: the C: line is the topic and the lines up
+// to the next separator or Q: the notes to recall. A C: line without a topic
+// takes the heading above it.
+//
+// O: lines add wrong options to a card, making it a multiple choice card with
+// the A: line as the right option. An O: block may also list one option per line.
+//
+// S: lines are the steps of an ordered procedure, making the card a steps card.
+// Like options, they may be listed one per line, numbered or not.
+//
+// An H: line is a hint, which can be shown before the answer. It is not part of
+// the card's hash, so it can be reworded without losing the review history.
+//
+// A T: line lists comma-separated tags. Like the hint, they are not part of the hash.
+//
+// An ID: line, or an <!-- id: ... --> comment in or before the entry, pins the identity of
+// its cards to an ID of letters, digits, - and _, which the hash is then made of
+// instead of the content, so that the card can be edited without losing its
+// review history. Invalid and repeated IDs are dropped, with an error.
+//
+// The lines between <!-- knolhash:off --> and <!-- knolhash:on --> comments,
+// or after an off comment to the end of the file, are skipped, e.g. drafts or
+// example cards. An off comment ends the entry it is in; in code blocks, the
+// comments are content.
+//
+// A file may start with YAML frontmatter between --- lines, whose tags, deck
+// and context apply to all its cards; see Frontmatter. A file with invalid
+// frontmatter is parsed without it, and its cards returned with the error.
+//
+// A question with cloze deletions, {{c::text}}, in its fenced code blocks is a
+// cloze card. Outside code blocks, numbered deletions such as
+// {{c1::Paris}} or {{c2::France::a country}} make one text cloze card per
+// number, whose answer is the deleted text followed by any A: notes.
+//
+// Numbered answers, A1:, A2: and so on, make one card per number asking for
+// that answer to the question, followed by any A: notes, instead of a card
+// answered by them all.
+//
+// The relative paths of the images a card shows, ![alt](path), are its Media.
+//
+// Lines in fenced code blocks, between ``` or ~~~ lines, are content: a Q:, A:
+// or --- line in a code snippet starts no field or card. So are the lines of
+// display math, from a line starting with $$ to the next $$.
+//
+// In a file tagged #flashcards, as read by the Obsidian Spaced Repetition
+// plugin, a line Question::Answer outside an entry is a card of its own, and
+// Question:::Answer makes a second card asking the answer. A nested tag such as
+// #flashcards/spanish sets their context.
+//
+// Outside an entry, a top-level bullet tagged #card, as in a Logseq outline, is
+// a card whose answer is the lines indented below it, without their outliner
+// properties such as card-repeats:: 2.
+//
+// A <!-- knolhash:table --> comment makes a card of each row of the Markdown
+// table below it, asking the first column and answered by the second, with the
+// context "First header → Second header". Its question, answer and context
+// attributes pick the columns by header and set the context, e.g.
+// <!-- knolhash:table question=English answer=Spanish context=Vocabulary -->.
+// The comment ends the entry it is in.
 func Parse(r io.Reader) ([]domain.Card, error) {
-	scanner := bufio.NewScanner(r)
+	return ParseWith(r, Config{})
+}
+
+// ParseWith is Parse with the question, answer and context prefixes and the
+// separator of cfg.
+func ParseWith(r io.Reader, cfg Config) ([]domain.Card, error) {
+	res := parseMarkdown(r, cfg)
+	return res.Cards, res.Err()
+}
+
+// parseMarkdown parses like ParseWith, with warnings about the entries that
+// were skipped or look unfinished: an entry without a question, a question
+// without an answer, options without a right answer, a writing prompt without
+// notes and invalid or repeated IDs.
+func parseMarkdown(r io.Reader, cfg Config) ParseResult {
+	lines, err := readLines(r)
+	if err != nil {
+		return failed(err)
+	}
+	var res ParseResult
+	fm, lineNo, fmErr := readFrontmatter(lines, cfg)
+	if fmErr != nil {
+		res.Errors = append(res.Errors, Issue{Line: 1, Err: fmErr})
+	}
+	fm = fm.over(cfg.Defaults)
+	question, answer, context := cfg.question(), cfg.answer(), cfg.context()
+	if fm.Disabled {
+		return res
+	}
+	warn := func(line int, format string, args ...any) {
+		res.Warnings = append(res.Warnings, Issue{Line: line, Err: fmt.Errorf(format, args...)})
+	}
+
 	var cards []domain.Card
 	var currentCard domain.Card
 	var currentBlock []string
 	currentState := seeking
+	writing := false            // The current entry started with C: rather than Q:
+	lastHeading := ""           // Text of the last Markdown heading outside of an entry
+	fence := ""                 // The marker of the fenced code block or display math the line is in, e.g. ```
+	answers := map[int]string{} // The numbered answers of the current entry, by number
+	number := 0                 // The number of the numbered answer being read
+	idLines := map[string]int{} // The lines of the entries with each ID
+	off := false                // Whether the line is in a region turned off by a marker
+	entryLine := 0              // The line the current entry starts on
+	outlining := false          // Whether the lines are those below an outline card's bullet
+	var outlineBlock []string   // The lines below the outline card's bullet
+	var cardTable *table        // The table of cards below a table marker, if any
+	var tableRows []string      // The rows of the table read so far
+	tableLine := 0              // The line the table's rows start on
+	deck, inline := inlineDeck(lines[lineNo:], fm)
 
 	finishCard := func() {
 		if len(currentBlock) > 0 {
@@ -53,31 +247,173 @@ func Parse(r io.Reader) ([]domain.Card, error) {
 				currentCard.Answer = content
 			case readingContext:
 				currentCard.Context = content
+			case readingOptions:
+				currentCard.Distractors = append(currentCard.Distractors, listItems(currentBlock)...)
+			case readingSteps:
+				currentCard.Steps = append(currentCard.Steps, listItems(currentBlock)...)
+			case readingHint:
+				currentCard.Hint = content
+			case readingTags:
+				currentCard.Tags = appendTags(currentCard.Tags, content)
+			case readingNumberedAnswer:
+				answers[number] = content
+			case readingID:
+				currentCard.ID = strings.TrimSpace(content)
 			}
 			currentBlock = nil
 		}
 
-		if currentCard.Question != "" {
-			cards = append(cards, currentCard)
+		if id := currentCard.ID; id != "" {
+			if !validID.MatchString(id) {
+				warn(entryLine, "invalid ID %q: use up to 64 letters, digits, - and _", id)
+				currentCard.ID = ""
+			} else if line, ok := idLines[id]; ok {
+				warn(entryLine, "ID %q is already used on line %d", id, line)
+				currentCard.ID = ""
+			} else {
+				idLines[id] = entryLine
+			}
+		}
+
+		if writing {
+			if prompt, ok := writingPrompt(currentCard, lastHeading); ok {
+				cards = append(cards, prompt)
+			} else {
+				warn(entryLine, "writing prompt without a topic or notes, skipped")
+			}
+		} else if currentCard.Question != "" {
+			switch {
+			case len(currentCard.Steps) > 0:
+				currentCard.Kind = domain.KindSteps
+			case len(currentCard.Distractors) > 0 && currentCard.Answer != "":
+				currentCard.Kind = domain.KindChoice
+			case cloze.Has(currentCard.Question):
+				currentCard.Kind = domain.KindCloze
+			}
+			if indexes := cloze.Indexes(currentCard.Question); currentCard.Kind == domain.KindBasic && len(indexes) > 0 {
+				cards = append(cards, textClozes(currentCard, indexes)...)
+			} else if currentCard.Kind == domain.KindBasic && len(answers) > 0 {
+				cards = append(cards, numberedAnswers(currentCard, answers)...)
+			} else {
+				if currentCard.Kind == domain.KindBasic && len(currentCard.Distractors) > 0 {
+					warn(entryLine, "options without a right answer, %s", answer)
+				} else if currentCard.Kind == domain.KindBasic && strings.TrimSpace(currentCard.Answer) == "" {
+					warn(entryLine, "question without an answer")
+				}
+				cards = append(cards, currentCard)
+			}
+		} else if currentCard.Answer != "" || len(currentCard.Distractors) > 0 || len(currentCard.Steps) > 0 || len(answers) > 0 || currentCard.Hint != "" {
+			warn(entryLine, "entry without a question, %s, skipped", question)
 		}
 		currentCard = domain.Card{}
 		currentState = seeking
+		writing = false
+		clear(answers)
+		entryLine = 0
+	}
+
+	// finishOutline ends an outline card, answered by the lines below its bullet.
+	finishOutline := func() {
+		currentCard.Answer = outlineAnswer(outlineBlock)
+		outlineBlock, outlining = nil, false
+		finishCard()
+	}
+
+	// finishTable ends a table of cards, making a card of each row.
+	finishTable := func() {
+		tableCards, warnings := cardTable.cards(tableRows, tableLine)
+		cards = append(cards, tableCards...)
+		res.Warnings = append(res.Warnings, warnings...)
+		cardTable, tableRows = nil, nil
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	for _, line := range lines[lineNo:] {
+		lineNo++
+
+		if cardTable != nil {
+			if isTableRow(line) {
+				if len(tableRows) == 0 {
+					tableLine = lineNo
+				}
+				tableRows = append(tableRows, line)
+				continue
+			}
+			if len(tableRows) > 0 || strings.TrimSpace(line) != "" {
+				finishTable()
+			} else {
+				continue // Blank lines between the marker and the table
+			}
+		}
+		if outlining {
+			if indented(line) {
+				outlineBlock = append(outlineBlock, line)
+				continue
+			}
+			finishOutline()
+		}
+
+		isQ := cfg.hasPrefix(line, question)
+		isA := cfg.hasPrefix(line, answer)
+		isC := cfg.hasPrefix(line, context)
+		isO := cfg.hasPrefix(line, optionPrefix)
+		isS := cfg.hasPrefix(line, stepPrefix)
+		isH := cfg.hasPrefix(line, hintPrefix)
+		isT := cfg.hasPrefix(line, tagsPrefix)
+		isID := cfg.hasPrefix(line, idPrefix)
+		n, numbered, isAN := cfg.numberedAnswer(line)
+		isSeparator := line == cfg.separator()
 
-		isQ := strings.HasPrefix(line, questionPrefix)
-		isA := strings.HasPrefix(line, answerPrefix)
-		isC := strings.HasPrefix(line, contextPrefix)
-		isSeparator := line == "---"
+		if fence != "" { // Code is content, even if it looks like a field or separator
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			if currentState != seeking {
+				currentBlock = append(currentBlock, line)
+			}
+			continue
+		}
+		if m := regionMarker.FindStringSubmatch(line); m != nil {
+			if m[1] == "off" && !off {
+				finishCard()
+			}
+			off = m[1] == "off"
+			continue
+		}
+		if m := idComment.FindStringSubmatch(line); m != nil && !off {
+			currentCard.ID = m[1]
+			continue
+		}
+		fence = opensFence(line)
+		for _, prefix := range cfg.fieldPrefixes() {
+			if cfg.hasPrefix(line, prefix) { // e.g. A: ```go
+				fence = opensFence(strings.TrimPrefix(line[len(prefix):], " "))
+			}
+		}
+		if isAN {
+			fence = opensFence(strings.TrimPrefix(numbered, " "))
+		}
+		if off { // Only its code blocks are followed, so markers in them don't count
+			continue
+		}
+		if t, ok := readTableMarker(line); ok {
+			finishCard()
+			cardTable, tableLine = &t, lineNo
+			continue
+		}
 
 		if isSeparator {
 			finishCard()
 			continue
 		}
 
-		if isQ || isA || isC {
+		if currentState == seeking && strings.HasPrefix(line, "#") {
+			lastHeading = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		}
+
+		if isQ || isA || isC || isO || isS || isH || isT || isAN || isID {
+			if currentState == seeking && entryLine == 0 {
+				entryLine = lineNo
+			}
 			if len(currentBlock) > 0 {
 				content := strings.Join(currentBlock, "\n")
 				switch currentState {
@@ -87,6 +423,18 @@ func Parse(r io.Reader) ([]domain.Card, error) {
 					currentCard.Answer = content
 				case readingContext:
 					currentCard.Context = content
+				case readingOptions:
+					currentCard.Distractors = append(currentCard.Distractors, listItems(currentBlock)...)
+				case readingSteps:
+					currentCard.Steps = append(currentCard.Steps, listItems(currentBlock)...)
+				case readingHint:
+					currentCard.Hint = content
+				case readingTags:
+					currentCard.Tags = appendTags(currentCard.Tags, content)
+				case readingNumberedAnswer:
+					answers[number] = content
+				case readingID:
+					currentCard.ID = strings.TrimSpace(content)
 				}
 				currentBlock = nil
 			}
@@ -94,23 +442,52 @@ func Parse(r io.Reader) ([]domain.Card, error) {
 			if isQ {
 				if currentState != seeking { // A new question always starts a new card
 					finishCard()
+					entryLine = lineNo
 				}
+				currentCard.Line = lineNo
 				currentState = readingQuestion
-				lineContent := line[len(questionPrefix):]
+				lineContent := line[len(question):]
 				if strings.HasPrefix(lineContent, " ") {
 					lineContent = lineContent[1:]
 				}
 				currentBlock = append(currentBlock, lineContent)
 			} else if isA {
 				currentState = readingAnswer
-				lineContent := line[len(answerPrefix):]
+				lineContent := line[len(answer):]
+				if strings.HasPrefix(lineContent, " ") {
+					lineContent = lineContent[1:]
+				}
+				currentBlock = append(currentBlock, lineContent)
+			} else if isAN {
+				currentState = readingNumberedAnswer
+				number = n
+				currentBlock = append(currentBlock, strings.TrimPrefix(numbered, " "))
+			} else if isO {
+				currentState = readingOptions
+				currentBlock = append(currentBlock, line[len(optionPrefix):])
+			} else if isS {
+				currentState = readingSteps
+				currentBlock = append(currentBlock, line[len(stepPrefix):])
+			} else if isH {
+				currentState = readingHint
+				lineContent := line[len(hintPrefix):]
 				if strings.HasPrefix(lineContent, " ") {
 					lineContent = lineContent[1:]
 				}
 				currentBlock = append(currentBlock, lineContent)
+			} else if isT {
+				currentState = readingTags
+				currentBlock = append(currentBlock, line[len(tagsPrefix):])
+			} else if isID {
+				currentState = readingID
+				currentBlock = append(currentBlock, line[len(idPrefix):])
 			} else if isC {
+				if currentState == seeking {
+					writing = true
+					currentCard.Line = lineNo
+				}
 				currentState = readingContext
-				lineContent := line[len(contextPrefix):]
+				lineContent := line[len(context):]
 				if strings.HasPrefix(lineContent, " ") {
 					lineContent = lineContent[1:]
 				}
@@ -118,14 +495,163 @@ func Parse(r io.Reader) ([]domain.Card, error) {
 			}
 		} else if currentState != seeking {
 			currentBlock = append(currentBlock, line)
+		} else if q, ok := outlineQuestion(line); ok && fence == "" {
+			entryLine, currentCard.Line, currentCard.Question = lineNo, lineNo, q
+			outlining = true
+		} else if inline && fence == "" && !strings.HasPrefix(line, "#") {
+			cards = append(cards, inlineCards(line, lineNo, deck)...)
 		}
 	}
 
+	if outlining {
+		finishOutline()
+	}
+	if cardTable != nil {
+		finishTable()
+	}
 	finishCard() // Finish the very last card in the file
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	for i := range cards {
+		fm.apply(&cards[i])
+		cards[i].Media = cardImages(cards[i])
+	}
+	res.Cards = cards
+	return res
+}
+
+// writingPrompt turns an entry that started with C: into a writing prompt. It
+// reports false if the entry has no topic or nothing to recall.
+func writingPrompt(entry domain.Card, heading string) (domain.Card, bool) {
+	topic, notes, _ := strings.Cut(entry.Context, "\n")
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		topic = heading
+	}
+	notes = strings.TrimSpace(notes)
+	if entry.Answer != "" {
+		notes = strings.TrimSpace(notes + "\n" + entry.Answer)
+	}
+	if topic == "" || notes == "" {
+		return domain.Card{}, false
+	}
+	return domain.Card{Question: topic, Answer: notes, Context: topic, Kind: domain.KindWriting, Hint: entry.Hint, Tags: entry.Tags, ID: entry.ID, Line: entry.Line}, true
+}
+
+// appendTags appends the comma-separated tags of a T: block to tags, skipping
+// empty and repeated ones.
+func appendTags(tags []string, block string) []string {
+	for _, tag := range strings.FieldsFunc(block, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// listItems reads the items of an O: or S: block, one per non-empty line,
+// dropping list markers ("- ", "* ", "1. " or "1) ").
+func listItems(block []string) []string {
+	var items []string
+	for _, line := range block {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "- "); ok {
+			line = rest
+		} else if rest, ok := strings.CutPrefix(line, "* "); ok {
+			line = rest
+		} else if i := strings.IndexAny(line, ".)"); i > 0 && strings.Trim(line[:i], "0123456789") == "" && strings.HasPrefix(line[i+1:], " ") {
+			line = line[i+1:]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+// textClozes expands a card with cloze deletions in its question into a text
+// cloze card per deletion number, each answered by the text it blanks out.
+func textClozes(card domain.Card, indexes []int) []domain.Card {
+	var cards []domain.Card
+	for _, index := range indexes {
+		c := card
+		c.Kind = domain.KindTextCloze
+		c.Cloze = index
+		c.Answer = cloze.Deleted(card.Question, index)
+		if notes := strings.TrimSpace(card.Answer); notes != "" {
+			c.Answer += "\n\n" + notes
+		}
+		cards = append(cards, c)
+	}
+	return cards
+}
+
+// numberedAnswer reports whether a line starts a numbered answer: the answer
+// prefix with a number before its colon, such as A1: or Back1:, in any case with
+// IgnoreCase. It returns the number and the rest of the line. An answer prefix
+// without a trailing colon has no numbered form.
+func (c Config) numberedAnswer(line string) (int, string, bool) {
+	base, ok := strings.CutSuffix(c.answer(), ":")
+	if !ok || !c.hasPrefix(line, base) {
+		return 0, "", false
+	}
+	rest := line[len(base):]
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits == 0 || !strings.HasPrefix(rest[digits:], ":") {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(rest[:digits])
+	if err != nil || n == 0 {
+		return 0, "", false
 	}
+	return n, rest[digits+1:], true
+}
+
+// numberedAnswers expands a card with numbered answers into a numbered answer
+// card per number, in order, each followed by the card's A: notes.
+func numberedAnswers(card domain.Card, answers map[int]string) []domain.Card {
+	var cards []domain.Card
+	for _, n := range slices.Sorted(maps.Keys(answers)) {
+		answer := strings.TrimSpace(answers[n])
+		if answer == "" {
+			continue
+		}
+		c := card
+		c.Kind = domain.KindNumbered
+		c.Cloze = n
+		c.Answer = answer
+		if notes := strings.TrimSpace(card.Answer); notes != "" {
+			c.Answer += "\n\n" + notes
+		}
+		cards = append(cards, c)
+	}
+	return cards
+}
 
-	return cards, nil
+// opensFence returns the marker of the fenced code block a line opens, three or
+// more backticks or tildes, or "" if it opens none. A line starting display
+// math, $$, that it doesn't close opens a block ending with the next $$.
+func opensFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if rest, ok := strings.CutPrefix(trimmed, mathMarker); ok && latex.Close(rest, mathMarker) < 0 {
+		return mathMarker
+	}
+	if len(line)-len(trimmed) > 3 || trimmed == "" || trimmed[0] != '`' && trimmed[0] != '~' {
+		return ""
+	}
+	marker := trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+	if len(marker) < 3 || marker[0] == '`' && strings.Contains(trimmed[len(marker):], "`") {
+		return ""
+	}
+	return marker
+}
+
+// closesFence reports whether a line closes the fenced code block opened with
+// marker: a run of at least as many of its characters, and nothing else. Any
+// line with $$ closes display math.
+func closesFence(line, marker string) bool {
+	if marker == mathMarker {
+		return strings.Contains(line, mathMarker)
+	}
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, marker) && strings.Trim(trimmed, marker[:1]) == ""
 }
@@ -1,8 +1,13 @@
 package parser
 
 import (
+	"errors"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/knol"
 )
 
 func TestParse(t *testing.T) {
@@ -13,6 +18,11 @@ func TestParse(t *testing.T) {
 		expectedQ     string
 		expectedA     string
 		expectedC     string
+		expectedKind  string
+		expectedO     []string
+		expectedS     []string
+		expectedH     string
+		expectedT     []string
 	}{
 		{
 			name:          "Simple Q&A",
@@ -79,6 +89,177 @@ C: Programming Languages
             expectedQ: "Question",
             expectedA: "Answer",
         },
+		{
+			name: "Writing prompt",
+			input: `
+C: The TCP handshake
+The client sends SYN,
+the server SYN-ACK
+and the client ACK.
+`,
+			expectedCards: 1,
+			expectedQ:     "The TCP handshake",
+			expectedA:     "The client sends SYN,\nthe server SYN-ACK\nand the client ACK.",
+			expectedC:     "The TCP handshake",
+			expectedKind:  "writing",
+		},
+		{
+			name: "Writing prompt under a heading",
+			input: `
+## The TCP handshake
+C:
+SYN, SYN-ACK, ACK.
+`,
+			expectedCards: 1,
+			expectedQ:     "The TCP handshake",
+			expectedA:     "SYN, SYN-ACK, ACK.",
+			expectedC:     "The TCP handshake",
+			expectedKind:  "writing",
+		},
+		{
+			name: "Writing prompt ends at a question",
+			input: `
+C: Go
+A statically typed language.
+Q: Who designed Go?
+A: Google
+`,
+			expectedCards: 2,
+		},
+		{
+			name:          "Writing prompt without notes",
+			input:         "C: Nothing to recall",
+			expectedCards: 0,
+		},
+		{
+			name:          "Multiple choice",
+			input:         "Q: What is pi to two decimal places?\nA: 3.14\nO: 3.41\nO: 3.16",
+			expectedCards: 1,
+			expectedQ:     "What is pi to two decimal places?",
+			expectedA:     "3.14",
+			expectedKind:  "choice",
+			expectedO:     []string{"3.41", "3.16"},
+		},
+		{
+			name: "Multiple choice with an option list",
+			input: `
+Q: Which keyword starts a goroutine?
+A: go
+O:
+- defer
+- async
+C: Go
+`,
+			expectedCards: 1,
+			expectedQ:     "Which keyword starts a goroutine?",
+			expectedA:     "go",
+			expectedC:     "Go",
+			expectedKind:  "choice",
+			expectedO:     []string{"defer", "async"},
+		},
+		{
+			name:          "Options without an answer",
+			input:         "Q: Which keyword starts a goroutine?\nO: defer",
+			expectedCards: 1,
+			expectedQ:     "Which keyword starts a goroutine?",
+			expectedO:     []string{"defer"},
+		},
+		{
+			name: "Ordered steps",
+			input: `
+Q: How do you make a git commit?
+S:
+1. Edit the files
+2. git add
+3) git commit
+`,
+			expectedCards: 1,
+			expectedQ:     "How do you make a git commit?",
+			expectedKind:  "steps",
+			expectedS:     []string{"Edit the files", "git add", "git commit"},
+		},
+		{
+			name:          "Ordered steps on S: lines",
+			input:         "Q: Boil an egg\nS: Boil water\nS: Add the egg\nS: Wait 7 minutes\nA: Cool it in cold water.",
+			expectedCards: 1,
+			expectedQ:     "Boil an egg",
+			expectedA:     "Cool it in cold water.",
+			expectedKind:  "steps",
+			expectedS:     []string{"Boil water", "Add the egg", "Wait 7 minutes"},
+		},
+		{
+			name:          "Cloze deletion in code",
+			input:         "Q: Print a line\n```go\nfunc main() {\n\t{{c::fmt.Println}}(\"hi\")\n}\n```\nA: From package fmt.",
+			expectedCards: 1,
+			expectedQ:     "Print a line\n```go\nfunc main() {\n\t{{c::fmt.Println}}(\"hi\")\n}\n```",
+			expectedA:     "From package fmt.",
+			expectedKind:  "cloze",
+		},
+		{
+			name:          "Cloze deletion in text",
+			input:         "Q: What does {{c::this}} mean?\nA: Nothing here.",
+			expectedCards: 1,
+			expectedQ:     "What does {{c::this}} mean?",
+			expectedA:     "this\n\nNothing here.",
+			expectedKind:  "text-cloze",
+		},
+		{
+			name:          "Hint",
+			input:         "Q: Capital of Australia?\nH: Not Sydney\nA: Canberra",
+			expectedCards: 1,
+			expectedQ:     "Capital of Australia?",
+			expectedA:     "Canberra",
+			expectedH:     "Not Sydney",
+		},
+		{
+			name:          "Tags",
+			input:         "Q: Capital of Australia?\nA: Canberra\nT: geography, capitals,\nT: geography",
+			expectedCards: 1,
+			expectedQ:     "Capital of Australia?",
+			expectedA:     "Canberra",
+			expectedT:     []string{"geography", "capitals"},
+		},
+		{
+			name: "Context after a card is not a writing prompt",
+			input: `
+Q: What is Go?
+A: A language.
+
+C: Programming Languages
+`,
+			expectedCards: 1,
+			expectedQ:     "What is Go?",
+			expectedA:     "A language.\n",
+			expectedC:     "Programming Languages",
+		},
+		{
+			name:          "Separator and fields inside a code fence",
+			input:         "Q: What does this YAML hold?\nA:\n```yaml\n---\nQ: 1\nA: 2\n---\n```\nC: YAML",
+			expectedCards: 1,
+			expectedQ:     "What does this YAML hold?",
+			expectedA:     "\n```yaml\n---\nQ: 1\nA: 2\n---\n```",
+			expectedC:     "YAML",
+		},
+		{
+			name:          "Longer fence around a shorter one",
+			input:         "Q: How is a fence written?\nA: ````\n```\n---\n```\n````",
+			expectedCards: 1,
+			expectedQ:     "How is a fence written?",
+			expectedA:     "````\n```\n---\n```\n````",
+		},
+		{
+			name:          "Tilde fence",
+			input:         "Q: Front matter?\nA:\n~~~\n---\ntitle: x\n---\n~~~\n---\nQ: Second\nA: card",
+			expectedCards: 2,
+		},
+		{
+			name:          "Display math block",
+			input:         "Q: Binomial theorem?\nA: $$\n(x+y)^n =\n\\sum_{k=0}^n \\binom{n}{k} x^k y^{n-k}\n---\n$$\nC: Algebra",
+			expectedCards: 1,
+			expectedQ:     "Binomial theorem?",
+			expectedA:     "$$\n(x+y)^n =\n\\sum_{k=0}^n \\binom{n}{k} x^k y^{n-k}\n---\n$$",
+			expectedC:     "Algebra",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -104,6 +285,389 @@ C: Programming Languages
 				if card.Context != tc.expectedC {
 					t.Errorf("Expected Context to be '%s', but got '%s'", tc.expectedC, card.Context)
 				}
+				if card.Kind != tc.expectedKind {
+					t.Errorf("Expected Kind to be '%s', but got '%s'", tc.expectedKind, card.Kind)
+				}
+				if !slices.Equal(card.Distractors, tc.expectedO) {
+					t.Errorf("Expected Distractors to be %q, but got %q", tc.expectedO, card.Distractors)
+				}
+				if !slices.Equal(card.Steps, tc.expectedS) {
+					t.Errorf("Expected Steps to be %q, but got %q", tc.expectedS, card.Steps)
+				}
+				if card.Hint != tc.expectedH {
+					t.Errorf("Expected Hint to be %q, but got %q", tc.expectedH, card.Hint)
+				}
+				if !slices.Equal(card.Tags, tc.expectedT) {
+					t.Errorf("Expected Tags to be %q, but got %q", tc.expectedT, card.Tags)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	input := `# Networking
+
+C: TCP
+A connection-oriented protocol.
+---
+Q: First question
+A: First answer
+
+Q: Second question
+A: Second answer
+`
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() returned an unexpected error: %v", err)
+	}
+	var lines []int
+	for _, card := range cards {
+		lines = append(lines, card.Line)
+	}
+	if expected := []int{3, 6, 9}; !slices.Equal(lines, expected) {
+		t.Errorf("Expected cards to start on lines %v, but got %v", expected, lines)
+	}
+}
+
+func TestParseTextClozes(t *testing.T) {
+	input := "Q: The capital of {{c2::France}} is {{c1::Paris}}.\nC: Geography"
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() returned an unexpected error: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, but got %d", len(cards))
+	}
+	for i, expected := range []struct {
+		cloze  int
+		answer string
+	}{{1, "Paris"}, {2, "France"}} {
+		card := cards[i]
+		if card.Kind != domain.KindTextCloze || card.Cloze != expected.cloze || card.Answer != expected.answer {
+			t.Errorf("Expected card %d to be text cloze c%d answered %q, but got %q c%d answered %q", i, expected.cloze, expected.answer, card.Kind, card.Cloze, card.Answer)
+		}
+		if card.Context != "Geography" {
+			t.Errorf("Expected Context to be 'Geography', but got '%s'", card.Context)
+		}
+	}
+}
+
+func TestParseNumberedAnswers(t *testing.T) {
+	input := "Q: Name the primary colours of paint.\nA2: Yellow\nA1: Red\nA3:\nBlue\n\nA: Mixing all three makes brown.\nC: Art"
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() returned an unexpected error: %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("Expected 3 cards, but got %d", len(cards))
+	}
+	hashes := map[string]bool{}
+	for i, expected := range []struct {
+		number int
+		answer string
+	}{{1, "Red"}, {2, "Yellow"}, {3, "Blue"}} {
+		card := cards[i]
+		answer := expected.answer + "\n\nMixing all three makes brown."
+		if card.Kind != domain.KindNumbered || card.Cloze != expected.number || card.Answer != answer {
+			t.Errorf("Expected card %d to ask for answer %d, %q, but got %q %d answered %q", i, expected.number, answer, card.Kind, card.Cloze, card.Answer)
+		}
+		if card.Question != "Name the primary colours of paint." || card.Context != "Art" || card.Line != 1 {
+			t.Errorf("Expected card %d to keep the question, context and line, but got %+v", i, card)
+		}
+		hashes[knol.Hash(card)] = true
+	}
+	if len(hashes) != 3 {
+		t.Errorf("Expected 3 distinct hashes, but got %d", len(hashes))
+	}
+}
+
+func TestParseNumberedAnswersCustomPrefix(t *testing.T) {
+	cfg := Config{Question: "Front:", Answer: "Back:", IgnoreCase: true}
+	res := ParseCards("paint.md", []byte("Front: Name the primary colours of paint.\nback1: Red\nBACK2: Yellow"), cfg)
+	if len(res.Cards) != 2 {
+		t.Fatalf("Expected the answer prefix to number 2 answers, but got %+v", res.Cards)
+	}
+	for i, answer := range []string{"Red", "Yellow"} {
+		if card := res.Cards[i]; card.Kind != domain.KindNumbered || card.Cloze != i+1 || card.Answer != answer {
+			t.Errorf("Expected card %d to ask for answer %d, %q, but got %q %d answered %q", i, i+1, answer, card.Kind, card.Cloze, card.Answer)
+		}
+	}
+
+	res = ParseCards("paint.md", []byte("Front: Name the primary colours of paint.\nBack: Three.\nA1: Red"), cfg)
+	if len(res.Cards) != 1 || res.Cards[0].Kind == domain.KindNumbered || res.Cards[0].Answer != "Three.\nA1: Red" {
+		t.Errorf("Expected A1: to be part of the answer with another answer prefix, but got %+v", res.Cards)
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	input := "Q: Capital of Australia?\nA: Canberra\nID: australia-capital\n---\n<!-- id: gold -->\nQ: Symbol for gold?\nA: Au\n---\nQ: Bad?\nA: Yes\nID: not valid!\n---\nQ: Again?\nA: Yes\nID: gold"
+	res := ParseCards("ids.md", []byte(input), Config{})
+	if err := res.Err(); err != nil {
+		t.Fatalf("ParseCards() returned an unexpected error: %v", err)
+	}
+	if len(res.Warnings) != 2 || res.Warnings[0].Line != 9 || res.Warnings[1].Line != 13 {
+		t.Errorf("Expected warnings for the invalid ID on line 9 and the duplicate on line 13, but got %v", res.Warnings)
+	}
+	cards := res.Cards
+	if len(cards) != 4 {
+		t.Fatalf("Expected 4 cards, but got %d", len(cards))
+	}
+	if cards[0].ID != "australia-capital" || cards[0].Answer != "Canberra" {
+		t.Errorf("Expected the ID: line to set the ID, but got %+v", cards[0])
+	}
+	if cards[1].ID != "gold" || cards[1].Question != "Symbol for gold?" {
+		t.Errorf("Expected the comment to set the ID, but got %+v", cards[1])
+	}
+	if cards[2].ID != "" || cards[3].ID != "" {
+		t.Errorf("Expected the invalid and duplicate IDs to be dropped, but got %q and %q", cards[2].ID, cards[3].ID)
+	}
+
+	clozes, err := Parse(strings.NewReader("Q: {{c1::Go}} was made at {{c2::Google}}.\nID: go-origin"))
+	if err != nil {
+		t.Fatalf("Parse() returned an unexpected error: %v", err)
+	}
+	if len(clozes) != 2 || knol.Hash(clozes[0]) != "go-origin-c1" || knol.Hash(clozes[1]) != "go-origin-c2" {
+		t.Errorf("Expected each deletion to hash to the ID and its number, but got %+v", clozes)
+	}
+}
+
+func TestParseOffRegions(t *testing.T) {
+	input := "Q: Kept?\nA: Yes\n<!-- knolhash:off -->\nQ: Example?\nA: Skipped\n```\n<!-- knolhash:on -->\n```\nQ: Still skipped?\nA: Yes\n<!-- knolhash:on -->\nQ: Back on?\nA: Yes\n```\n<!-- knolhash:off -->\n```\n---\nQ: Last?\nA: Yes\n<!-- knolhash:off -->\nQ: Draft?\nA: Skipped"
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() returned an unexpected error: %v", err)
+	}
+	var questions []string
+	for _, card := range cards {
+		questions = append(questions, card.Question)
+	}
+	if !slices.Equal(questions, []string{"Kept?", "Back on?", "Last?"}) {
+		t.Errorf("Expected the cards outside the off regions, but got %q", questions)
+	}
+	if cards[0].Answer != "Yes" || cards[1].Line != 12 || !strings.Contains(cards[1].Answer, "knolhash:off") {
+		t.Errorf("Expected markers in code to be content, but got %+v", cards[1])
+	}
+}
+
+func TestParseOutline(t *testing.T) {
+	input := "# Networking\n\n- Plain bullet\n  - not a card\n- What does TCP stand for? #card\n  card-repeats:: 2\n  card-ease-factor:: 2.5\n  - Transmission\n    - Control Protocol\n\n- Last step of the handshake #[[card]] #net\n\tACK\nQ: Mixed with Q/A?\nA: Yes\n---\n- Unanswered #card"
+	res := ParseCards("notes.md", []byte(input), Config{})
+	expected := []domain.Card{
+		{Question: "What does TCP stand for?", Answer: "- Transmission\n  - Control Protocol", Line: 5},
+		{Question: "Last step of the handshake #net", Answer: "ACK", Line: 11},
+		{Question: "Mixed with Q/A?", Answer: "Yes", Line: 13},
+		{Question: "Unanswered", Line: 16},
+	}
+	if len(res.Cards) != len(expected) {
+		t.Fatalf("Expected %d cards, but got %+v", len(expected), res.Cards)
+	}
+	for i, e := range expected {
+		if c := res.Cards[i]; c.Question != e.Question || c.Answer != e.Answer || c.Line != e.Line {
+			t.Errorf("Expected card %d to be %+v, but got %+v", i, e, c)
+		}
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Line != 16 {
+		t.Errorf("Expected a warning about the unanswered card on line 16, but got %v", res.Warnings)
+	}
+}
+
+func TestParseTables(t *testing.T) {
+	input := "Q: Before?\nA: Yes\n<!-- knolhash:table -->\n\n| Spanish | English |\n|---|:---|\n| hola | hello |\n| gato |  |\n| a \\| b | pipe |\n\n| Not | Cards |\n|---|---|\n| x | y |\n\n<!-- knolhash:table question=English answer=\"Spanish\" context=\"Vocabulary\" -->\n| Spanish | English |\n|---|---|\n| perro | dog |\n<!-- knolhash:table answer=French -->\n| Spanish | English |\n|---|---|\n| sí | yes |"
+	res := ParseCards("notes.md", []byte(input), Config{})
+	expected := []domain.Card{
+		{Question: "Before?", Answer: "Yes", Line: 1},
+		{Question: "hola", Answer: "hello", Context: "Spanish → English", Line: 7},
+		{Question: "a | b", Answer: "pipe", Context: "Spanish → English", Line: 9},
+		{Question: "dog", Answer: "perro", Context: "Vocabulary", Line: 18},
+	}
+	if len(res.Cards) != len(expected) {
+		t.Fatalf("Expected %d cards, but got %+v", len(expected), res.Cards)
+	}
+	for i, e := range expected {
+		if c := res.Cards[i]; c.Question != e.Question || c.Answer != e.Answer || c.Context != e.Context || c.Line != e.Line {
+			t.Errorf("Expected card %d to be %+v, but got %+v", i, e, c)
+		}
+	}
+	var warned []int
+	for _, w := range res.Warnings {
+		warned = append(warned, w.Line)
+	}
+	if !slices.Equal(warned, []int{8, 20}) {
+		t.Errorf("Expected warnings about the row without an answer and the missing column, but got %v", res.Warnings)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	SetLimits(16, 64)
+	defer SetLimits(0, 0)
+
+	if _, err := Parse(strings.NewReader("Q: Short?\nA: Yes")); err != nil {
+		t.Errorf("Expected lines under the limit to parse, but got %v", err)
+	}
+	_, err := Parse(strings.NewReader("Q: Short?\nA: " + strings.Repeat("long ", 10)))
+	if !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected ErrTooLarge for line 2, but got %v", err)
+	}
+	err = ParseCards("notes.md", []byte(strings.Repeat("Q: Short?\nA: Yes\n---\n", 5)), Config{}).Err()
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for the file, but got %v", err)
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	input := "Q: Fine?\nA: Yes\n---\nA: No question\n---\nQ: No answer?\n---\nQ: Which?\nO: This\nO: That\n---\nC: Topic\n---\nQ: {{c1::Cloze}} needs no answer\n---\n---\nQ: Last?\nA: Yes"
+	res := ParseCards("notes.md", []byte(input), Config{})
+	if err := res.Err(); err != nil {
+		t.Fatalf("ParseCards() returned an unexpected error: %v", err)
+	}
+	expected := []struct {
+		line    int
+		message string
+	}{
+		{4, "entry without a question"},
+		{6, "question without an answer"},
+		{8, "options without a right answer"},
+		{12, "writing prompt without a topic or notes"},
+	}
+	if len(res.Warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, but got %v", len(expected), res.Warnings)
+	}
+	for i, e := range expected {
+		if w := res.Warnings[i]; w.Line != e.line || !strings.Contains(w.Error(), e.message) {
+			t.Errorf("Expected warning %q on line %d, but got %v", e.message, e.line, w)
+		}
+	}
+	if len(res.Cards) != 5 {
+		t.Errorf("Expected the cards with warnings but a question to be kept, 5 in all, but got %d", len(res.Cards))
+	}
+}
+
+func TestParseFrontmatter(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expectedErr  bool
+		expectedC    []string
+		expectedTags []string
+		expectedLine int
+	}{
+		{
+			name:         "Tags and context default",
+			input:        "---\ntags: [go, basics]\ncontext: Go\naliases: [Golang]\n---\nQ: What is Go?\nA: A language.\n\nQ: Who made Go?\nA: Google.\nC: History",
+			expectedC:    []string{"Go", "History"},
+			expectedTags: []string{"go", "basics"},
+			expectedLine: 6,
+		},
+		{
+			name:         "Deck and comma-separated tags",
+			input:        "---\ndeck: Go Basics\ntags: go, go\n---\nQ: What is Go?\nA: A language.",
+			expectedC:    []string{""},
+			expectedTags: []string{"Go Basics", "go"},
+			expectedLine: 5,
+		},
+		{
+			name:  "Disabled",
+			input: "---\ndisabled: true\n---\nQ: What is Go?\nA: A language.",
+		},
+		{
+			name:         "Separator before a writing prompt is not frontmatter",
+			input:        "---\nC: TCP\nA connection-oriented protocol.\n---\n",
+			expectedC:    []string{"TCP"},
+			expectedLine: 2,
+		},
+		{
+			name:         "Invalid frontmatter",
+			input:        "---\ndisabled: maybe\n---\nQ: What is Go?\nA: A language.",
+			expectedErr:  true,
+			expectedC:    []string{""},
+			expectedLine: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cards, err := Parse(strings.NewReader(tc.input))
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("Expected an error to be %v, but got %v", tc.expectedErr, err)
+			}
+			if len(cards) != len(tc.expectedC) {
+				t.Fatalf("Expected %d cards, but got %d", len(tc.expectedC), len(cards))
+			}
+			for i, card := range cards {
+				if card.Context != tc.expectedC[i] {
+					t.Errorf("Expected Context of card %d to be '%s', but got '%s'", i, tc.expectedC[i], card.Context)
+				}
+				if !slices.Equal(card.Tags, tc.expectedTags) {
+					t.Errorf("Expected Tags of card %d to be %q, but got %q", i, tc.expectedTags, card.Tags)
+				}
+			}
+			if len(cards) > 0 && cards[0].Line != tc.expectedLine {
+				t.Errorf("Expected the first card to start on line %d, but got %d", tc.expectedLine, cards[0].Line)
+			}
+		})
+	}
+}
+
+func TestParseInlineCards(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []domain.Card
+	}{
+		{
+			name:  "Basic and reversed",
+			input: "# Spanish #flashcards/spanish\n\nhola::hello\ngato:::cat",
+			expected: []domain.Card{
+				{Question: "hola", Answer: "hello", Context: "spanish", Line: 3},
+				{Question: "gato", Answer: "cat", Context: "spanish", Line: 4},
+				{Question: "cat", Answer: "gato", Context: "spanish", Line: 4},
+			},
+		},
+		{
+			name:  "Tag in frontmatter",
+			input: "---\ntags: [flashcards]\n---\nCapital of France::Paris",
+			expected: []domain.Card{
+				{Question: "Capital of France", Answer: "Paris", Line: 4, Tags: []string{"flashcards"}},
+			},
+		},
+		{
+			name:  "Code, clozes and entries are not inline cards",
+			input: "#flashcards\n`std::vector` is a container\n```cpp\nstd::string s;\n```\nQ: The {{c1::vector}} grows\nA: std::vector\n---\nno::::card",
+			expected: []domain.Card{
+				{Question: "The {{c1::vector}} grows", Answer: "vector\n\nstd::vector", Kind: domain.KindTextCloze, Cloze: 1, Line: 6},
+			},
+		},
+		{
+			name:  "Math is not split",
+			input: "#flashcards\nRatio $a::b$ of a to b::a over b",
+			expected: []domain.Card{
+				{Question: "Ratio $a::b$ of a to b", Answer: "a over b", Line: 2},
+			},
+		},
+		{
+			name:  "Untagged file",
+			input: "author:: Ada Lovelace",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cards, err := Parse(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("Parse() returned an unexpected error: %v", err)
+			}
+			if len(cards) != len(tc.expected) {
+				t.Fatalf("Expected %d cards, but got %d: %+v", len(tc.expected), len(cards), cards)
+			}
+			for i, card := range cards {
+				expected := tc.expected[i]
+				if card.Question != expected.Question || card.Answer != expected.Answer || card.Context != expected.Context || card.Line != expected.Line {
+					t.Errorf("Expected card %d to be %q::%q in %q on line %d, but got %q::%q in %q on line %d", i, expected.Question, expected.Answer, expected.Context, expected.Line, card.Question, card.Answer, card.Context, card.Line)
+				}
+				if card.Kind != expected.Kind || !slices.Equal(card.Tags, expected.Tags) {
+					t.Errorf("Expected card %d to be %q tagged %q, but got %q tagged %q", i, expected.Kind, expected.Tags, card.Kind, card.Tags)
+				}
 			}
 		})
 	}
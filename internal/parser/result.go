@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+// Issue is a problem found parsing a file, at a line of it or, with Line 0, with
+// the file as a whole.
+type Issue struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface.
+func (i Issue) Error() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d: %v", i.Line, i.Err)
+	}
+	return i.Err.Error()
+}
+
+// Unwrap returns the underlying error, e.g. ErrTooLarge.
+func (i Issue) Unwrap() error {
+	return i.Err
+}
+
+// ParseResult is what parsing a file found: its cards, warnings about entries
+// that were skipped or look unfinished, such as an answer without a question,
+// and errors, which lose more than an entry, such as invalid frontmatter or a
+// file too large to parse at all.
+type ParseResult struct {
+	Cards    []domain.Card
+	Warnings []Issue
+	Errors   []Issue
+}
+
+// Err joins the errors of a result, or is nil if it has none.
+func (r ParseResult) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i, issue := range r.Errors {
+		errs[i] = issue
+	}
+	return errors.Join(errs...)
+}
+
+// failed is the result of a file that couldn't be parsed.
+func failed(err error) ParseResult {
+	return ParseResult{Errors: []Issue{{Err: err}}}
+}
+
+// result is the result of a parser returning cards and an error.
+func result(cards []domain.Card, err error) ParseResult {
+	if err != nil {
+		return ParseResult{Cards: cards, Errors: []Issue{{Err: err}}}
+	}
+	return ParseResult{Cards: cards}
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+)
+
+// tableMarker matches the comment turning the Markdown table below it into
+// cards, with optional attributes, e.g. <!-- knolhash:table answer=English -->.
+var tableMarker = regexp.MustCompile(`^\s*<!--\s*knolhash:table((?:\s+\w+=(?:"[^"]*"|[^\s"]+))*)\s*-->\s*$`)
+
+// tableAttr matches an attribute of a table marker, quoted or not.
+var tableAttr = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^\s"]+))`)
+
+// table is a Markdown table of cards, a row each: the headers of the question
+// and answer columns, the first and second by default, and the context of its
+// cards, "Question header → Answer header" by default.
+type table struct {
+	question, answer string
+	context          *string
+}
+
+// readTableMarker reads the comment above a table of cards.
+func readTableMarker(line string) (table, bool) {
+	m := tableMarker.FindStringSubmatch(line)
+	if m == nil {
+		return table{}, false
+	}
+	var t table
+	for _, attr := range tableAttr.FindAllStringSubmatch(m[1], -1) {
+		value := attr[2] + attr[3]
+		switch strings.ToLower(attr[1]) {
+		case "question":
+			t.question = value
+		case "answer":
+			t.answer = value
+		case "context":
+			t.context = &value
+		}
+	}
+	return t, true
+}
+
+// isTableRow reports whether a line is a row of a Markdown table.
+func isTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+// tableCells splits a row of a Markdown table into its trimmed cells, at pipes
+// not escaped as \|.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// isDelimiterRow reports whether the cells are those of the row below a
+// table's header, such as |---|:---:|.
+func isDelimiterRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, ":-") != "" || !strings.Contains(cell, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// cards returns a card for each row of the table, whose rows start on line
+// first, and warnings about the table or rows that were skipped.
+func (t table) cards(rows []string, first int) ([]domain.Card, []Issue) {
+	if len(rows) < 2 || !isDelimiterRow(tableCells(rows[1])) {
+		return nil, []Issue{{Line: first, Err: fmt.Errorf("table without a header row, skipped")}}
+	}
+	header := tableCells(rows[0])
+	q, a := 0, 1
+	for name, col := range map[string]*int{t.question: &q, t.answer: &a} {
+		if name == "" {
+			continue
+		}
+		*col = -1
+		for i, h := range header {
+			if strings.EqualFold(h, name) {
+				*col = i
+			}
+		}
+		if *col < 0 {
+			return nil, []Issue{{Line: first, Err: fmt.Errorf("table without a %q column, skipped", name)}}
+		}
+	}
+	if a >= len(header) {
+		return nil, []Issue{{Line: first, Err: fmt.Errorf("table with one column, skipped")}}
+	}
+	context := strings.TrimSpace(header[q] + " → " + header[a])
+	if header[q] == "" || header[a] == "" {
+		context = ""
+	}
+	if t.context != nil {
+		context = *t.context
+	}
+
+	var cards []domain.Card
+	var warnings []Issue
+	for i, row := range rows[2:] {
+		line := first + 2 + i
+		cells := tableCells(row)
+		if max(q, a) >= len(cells) || cells[q] == "" || cells[a] == "" {
+			warnings = append(warnings, Issue{Line: line, Err: fmt.Errorf("table row without a question or answer, skipped")})
+			continue
+		}
+		cards = append(cards, domain.Card{Question: cells[q], Answer: cells[a], Context: context, Line: line})
+	}
+	return cards, warnings
+}
@@ -0,0 +1,56 @@
+package pathsafe
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// reserved are the device names Windows reserves in every directory, with or
+// without an extension.
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Segment makes name, e.g. a part of a URL, usable as a file or directory name
+// on every platform: characters Windows forbids are replaced with underscores,
+// trailing dots and spaces, which Windows drops, are removed, and reserved
+// device names get an underscore appended. "." and ".." become "_", so a
+// segment can never leave its directory.
+func Segment(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	stem, ext, _ := strings.Cut(name, ".")
+	if reserved[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = stem + "_"
+		if ext != "" {
+			name += "." + ext
+		}
+	}
+	return name
+}
+
+// Join joins base and the segments of the slash-separated paths, each made
+// safe with Segment. Empty segments are skipped.
+func Join(base string, paths ...string) string {
+	parts := []string{base}
+	for _, p := range paths {
+		for _, seg := range strings.Split(p, "/") {
+			if seg != "" {
+				parts = append(parts, Segment(seg))
+			}
+		}
+	}
+	return filepath.Join(parts...)
+}
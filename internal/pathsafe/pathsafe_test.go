@@ -0,0 +1,40 @@
+package pathsafe
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSegment(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Plain name is kept", input: "knolhash", expected: "knolhash"},
+		{name: "Host with a port", input: "git.example.org:8443", expected: "git.example.org_8443"},
+		{name: "Forbidden characters", input: `a<b>c"d|e?f*g\h`, expected: "a_b_c_d_e_f_g_h"},
+		{name: "Trailing dots and spaces", input: "notes. .", expected: "notes"},
+		{name: "Reserved name", input: "con", expected: "con_"},
+		{name: "Reserved name with an extension", input: "NUL.txt", expected: "NUL_.txt"},
+		{name: "Reserved name as a prefix only", input: "console", expected: "console"},
+		{name: "Parent directory", input: "..", expected: "_"},
+		{name: "Empty", input: "", expected: "_"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Segment(tc.input); got != tc.expected {
+				t.Errorf("Expected Segment(%q) to be %q, but got %q", tc.input, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	got := Join("repos", "host:22", "/user/../aux/repo/")
+	expected := filepath.Join("repos", "host_22", "user", "_", "aux_", "repo")
+	if got != expected {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+}
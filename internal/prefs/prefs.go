@@ -0,0 +1,199 @@
+package prefs
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Keys of the preferences in the settings table.
+const (
+	keyTimezone       = "prefs.timezone"
+	keyDayCutoff      = "prefs.day_cutoff"
+	keyNewCardsPerDay = "prefs.new_cards_per_day"
+	keyReviewsPerDay  = "prefs.reviews_per_day"
+	keyTheme          = "prefs.theme"
+	keyMinutesGoal    = "prefs.minutes_goal"
+	keyReviewsGoal    = "prefs.reviews_goal"
+	keyNewCardsGoal   = "prefs.new_cards_goal"
+	keyAchievements   = "prefs.achievements"
+	keyAutoReveal     = "prefs.auto_reveal_seconds"
+	keyAutoAgain      = "prefs.auto_again_seconds"
+)
+
+const (
+	defaultDayCutoff      = 4 // 4am, so late-night reviews count towards the previous day
+	defaultNewCardsPerDay = 20
+)
+
+// Preferences control how the study day is defined and how much is studied in it.
+// They apply to the whole instance.
+type Preferences struct {
+	Timezone       string // IANA name, e.g. "Europe/Dublin"; empty uses the server's timezone
+	DayCutoff      int    // Hour (0-23) at which a new study day starts
+	NewCardsPerDay int    // Maximum new cards introduced per study day; 0 is unlimited
+	ReviewsPerDay  int    // Maximum reviews of learned cards per study day; 0 is unlimited
+	Theme          string // "" (follow the device), "light", "dark" or "high-contrast"
+
+	// Goals are shown with their progress on the deck page; 0 is no goal.
+	MinutesGoal  int // Minutes studied per study day
+	ReviewsGoal  int // Reviews (of any card) per study day
+	NewCardsGoal int // New cards learned per study week, which starts on Monday
+
+	// Achievements shows the study streak, streak freezes and badges.
+	Achievements bool
+
+	// Timers for hands-free reviews; 0 is off. AutoReveal shows the answer that
+	// many seconds after the question, and AutoAgain grades the card Again that
+	// many seconds after the answer if it hasn't been graded.
+	AutoReveal int
+	AutoAgain  int
+}
+
+// Load reads the preferences, filling in defaults.
+func Load(db *storage.DB) (Preferences, error) {
+	values, err := db.GetSettings()
+	if err != nil {
+		return Preferences{}, err
+	}
+	p := Preferences{
+		Timezone:       values[keyTimezone],
+		DayCutoff:      defaultDayCutoff,
+		NewCardsPerDay: defaultNewCardsPerDay,
+		Theme:          values[keyTheme],
+		Achievements:   values[keyAchievements] == "true",
+	}
+	intValue := func(key string, v *int) {
+		if n, err := strconv.Atoi(values[key]); err == nil {
+			*v = n
+		}
+	}
+	intValue(keyDayCutoff, &p.DayCutoff)
+	intValue(keyNewCardsPerDay, &p.NewCardsPerDay)
+	intValue(keyReviewsPerDay, &p.ReviewsPerDay)
+	intValue(keyMinutesGoal, &p.MinutesGoal)
+	intValue(keyReviewsGoal, &p.ReviewsGoal)
+	intValue(keyNewCardsGoal, &p.NewCardsGoal)
+	intValue(keyAutoReveal, &p.AutoReveal)
+	intValue(keyAutoAgain, &p.AutoAgain)
+	return p, nil
+}
+
+// Save validates and stores the preferences.
+func (p Preferences) Save(db *storage.DB) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return db.UpdateSettings(map[string]string{
+		keyTimezone:       p.Timezone,
+		keyDayCutoff:      strconv.Itoa(p.DayCutoff),
+		keyNewCardsPerDay: strconv.Itoa(p.NewCardsPerDay),
+		keyReviewsPerDay:  strconv.Itoa(p.ReviewsPerDay),
+		keyTheme:          p.Theme,
+		keyMinutesGoal:    strconv.Itoa(p.MinutesGoal),
+		keyReviewsGoal:    strconv.Itoa(p.ReviewsGoal),
+		keyNewCardsGoal:   strconv.Itoa(p.NewCardsGoal),
+		keyAchievements:   strconv.FormatBool(p.Achievements),
+		keyAutoReveal:     strconv.Itoa(p.AutoReveal),
+		keyAutoAgain:      strconv.Itoa(p.AutoAgain),
+	})
+}
+
+// Validate checks that the preferences are within range.
+func (p Preferences) Validate() error {
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", p.Timezone)
+	}
+	if p.DayCutoff < 0 || p.DayCutoff > 23 {
+		return fmt.Errorf("day cutoff must be an hour between 0 and 23, got %d", p.DayCutoff)
+	}
+	if p.NewCardsPerDay < 0 || p.ReviewsPerDay < 0 {
+		return fmt.Errorf("daily limits cannot be negative")
+	}
+	if p.MinutesGoal < 0 || p.ReviewsGoal < 0 || p.NewCardsGoal < 0 {
+		return fmt.Errorf("goals cannot be negative")
+	}
+	if p.AutoReveal < 0 || p.AutoAgain < 0 {
+		return fmt.Errorf("timers cannot be negative")
+	}
+	switch p.Theme {
+	case "", "light", "dark", "high-contrast":
+	default:
+		return fmt.Errorf("unknown theme %q", p.Theme)
+	}
+	return nil
+}
+
+// locations caches loaded timezones by name, as loading one reads the tz database.
+var locations sync.Map
+
+// Location returns the preferred timezone, falling back to the server's.
+func (p Preferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.Local
+	}
+	if loc, ok := locations.Load(p.Timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	locations.Store(p.Timezone, loc)
+	return loc
+}
+
+// DayStart returns the start of the study day containing t, in the preferred timezone.
+func (p Preferences) DayStart(t time.Time) time.Time {
+	t = t.In(p.Location())
+	start := time.Date(t.Year(), t.Month(), t.Day(), p.DayCutoff, 0, 0, 0, t.Location())
+	if start.After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// WeekStart returns the start of the study week containing t: the start of its
+// Monday's study day, in the preferred timezone.
+func (p Preferences) WeekStart(t time.Time) time.Time {
+	day := p.DayStart(t)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// DueQueue returns the cards to study now, in due order, within what is left of
+// the current study day's limits.
+func (p Preferences) DueQueue(db *storage.DB) ([]storage.Card, error) {
+	cards, err := db.GetDueCards()
+	if err != nil {
+		return nil, err
+	}
+	if p.NewCardsPerDay == 0 && p.ReviewsPerDay == 0 {
+		return cards, nil
+	}
+
+	newToday, reviewsToday, err := db.CountReviewsSince(p.DayStart(db.Now()))
+	if err != nil {
+		return nil, err
+	}
+	newLeft, reviewsLeft := p.NewCardsPerDay-newToday, p.ReviewsPerDay-reviewsToday
+
+	queue := cards[:0]
+	for _, c := range cards {
+		switch {
+		case c.Stability == 0: // Never reviewed
+			if p.NewCardsPerDay == 0 || newLeft > 0 {
+				queue = append(queue, c)
+				newLeft--
+			}
+		default:
+			if p.ReviewsPerDay == 0 || reviewsLeft > 0 {
+				queue = append(queue, c)
+				reviewsLeft--
+			}
+		}
+	}
+	return queue, nil
+}
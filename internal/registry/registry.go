@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/bundle"
+	"github.com/conorfennell/knolhash/internal/netconf"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+const (
+	fetchTimeout = 5 * time.Minute
+	// maxIndexSize limits the size of an index file.
+	maxIndexSize = 4 << 20
+)
+
+// Entry is a deck listed in an index.
+type Entry struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// URL is a .knol bundle or a git repository ending in .git.
+	URL string `json:"url"`
+}
+
+// Index is a list of shared decks, published as a JSON file such as
+//
+//	{"decks": [{"name": "go-basics", "description": "The Go tour", "url": "https://example.org/go-basics.knol"}]}
+type Index struct {
+	Decks []Entry `json:"decks"`
+}
+
+// Find returns the entry of the deck with the given name, ignoring case.
+func (idx Index) Find(name string) (Entry, bool) {
+	for _, e := range idx.Decks {
+		if strings.EqualFold(e.Name, name) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// FetchIndex downloads and decodes the index at indexURL.
+func FetchIndex(indexURL string) (Index, error) {
+	var idx Index
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	body, err := get(ctx, indexURL)
+	if err != nil {
+		return idx, err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(io.LimitReader(body, maxIndexSize)).Decode(&idx); err != nil {
+		return idx, fmt.Errorf("failed to read deck index %s: %w", indexURL, err)
+	}
+	return idx, nil
+}
+
+// Install installs a shared deck and records it as a deck. ref is the URL of a
+// .knol bundle or of a git repository ending in .git, or else the name of a deck
+// in the index at indexURL. Bundles are unpacked into a local source; git decks
+// are added as git sources, cloned on the next sync.
+func Install(db *storage.DB, indexURL, ref string) (storage.Deck, error) {
+	entry := Entry{URL: ref}
+	if !isURL(ref) {
+		if indexURL == "" {
+			return storage.Deck{}, fmt.Errorf("%q is not a URL and no deck index is configured", ref)
+		}
+		idx, err := FetchIndex(indexURL)
+		if err != nil {
+			return storage.Deck{}, err
+		}
+		var ok bool
+		if entry, ok = idx.Find(ref); !ok {
+			return storage.Deck{}, fmt.Errorf("no deck named %q in the index %s", ref, indexURL)
+		}
+	}
+
+	urlPath := entry.URL // git@host:path URLs don't parse
+	if u, err := url.Parse(entry.URL); err == nil {
+		urlPath = u.Path
+	}
+	switch {
+	case strings.HasSuffix(strings.ToLower(urlPath), bundle.Ext):
+		return installBundle(db, entry.URL)
+	case strings.HasSuffix(urlPath, ".git"):
+		if entry.Name == "" {
+			entry.Name = strings.TrimSuffix(path.Base(urlPath), ".git")
+		}
+		return installGit(db, entry)
+	default:
+		return storage.Deck{}, fmt.Errorf("%s is neither a %s bundle nor a .git repository", entry.URL, bundle.Ext)
+	}
+}
+
+// installBundle downloads the bundle at bundleURL and imports it.
+func installBundle(db *storage.DB, bundleURL string) (storage.Deck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	body, err := get(ctx, bundleURL)
+	if err != nil {
+		return storage.Deck{}, err
+	}
+	defer body.Close()
+
+	// A bundle is a zip archive, which is read from its end, so it is downloaded first.
+	tmp, err := os.CreateTemp("", "knolhash-*"+bundle.Ext)
+	if err != nil {
+		return storage.Deck{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		return storage.Deck{}, fmt.Errorf("failed to download %s: %w", bundleURL, err)
+	}
+
+	m, dir, err := bundle.Import(db, tmp, size, bundleURL)
+	if err != nil {
+		return storage.Deck{}, err
+	}
+	source, err := db.FindSourceByPath(dir)
+	if err != nil || source == nil {
+		return storage.Deck{}, errors.Join(errors.New("imported deck has no source"), err)
+	}
+	return storage.Deck{SourceID: source.ID, Name: m.Name, Origin: bundleURL, InstalledAt: time.Now()}, nil
+}
+
+// installGit adds the git repository of a deck as a source, unless it already is one.
+func installGit(db *storage.DB, entry Entry) (storage.Deck, error) {
+	d := storage.Deck{Name: entry.Name, Origin: entry.URL, InstalledAt: time.Now()}
+	source, err := sync.AddSource(db, entry.URL)
+	if err != nil && !errors.Is(err, sync.ErrSourceExists) {
+		return d, err
+	}
+	d.SourceID = source.ID
+	return d, db.RecordDeck(d)
+}
+
+// get starts downloading rawURL, failing unless the response is 200 OK.
+func get(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", rawURL, err)
+	}
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// isURL reports whether ref is an HTTP(S) or git URL rather than a deck name.
+func isURL(ref string) bool {
+	return strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "git@")
+}
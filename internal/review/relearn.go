@@ -0,0 +1,42 @@
+package review
+
+import (
+	"slices"
+
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Relearn is the relearn queue of a review session: the hashes of the cards
+// graded Again in it, in order, which come back at the end of the session
+// instead of waiting for their due date, as in Anki's learning queue. A card
+// leaves the queue once it is graded Hard or better.
+type Relearn []string
+
+// Graded updates the queue after the card hash was graded: a card graded Again
+// goes to the back of the queue, any other grade takes it out.
+func (q Relearn) Graded(hash string, rating fsrs.Rating) Relearn {
+	q = slices.DeleteFunc(slices.Clone(q), func(h string) bool { return h == hash })
+	if rating == fsrs.Again {
+		q = append(q, hash)
+	}
+	return q
+}
+
+// Append appends the cards of the queue that aren't in cards already, or
+// suspended or deleted since, to cards, the session's due cards.
+func (q Relearn) Append(db *storage.DB, cards []storage.Card) ([]storage.Card, error) {
+	for _, hash := range q {
+		if slices.ContainsFunc(cards, func(c storage.Card) bool { return c.Hash == hash }) {
+			continue
+		}
+		card, err := db.FindCardByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if card != nil && !card.Suspended {
+			cards = append(cards, *card)
+		}
+	}
+	return cards, nil
+}
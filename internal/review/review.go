@@ -0,0 +1,125 @@
+package review
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/goals"
+	"github.com/conorfennell/knolhash/internal/hooks"
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// leechLapses is the number of lapses at which a card becomes a leech.
+const leechLapses = 8
+
+// Grade is the outcome of reviewing a card.
+type Grade struct {
+	Rating   fsrs.Rating
+	Correct  sql.NullBool  // Whether the right option was chosen, for multiple choice cards
+	Hinted   bool          // Whether the hint was shown before the answer
+	Duration time.Duration // Time from showing the card to grading it, if known
+}
+
+// Choice grades a review of a multiple choice card from the index of the option
+// chosen, the right option being 0: Good if it was right and Again otherwise.
+func Choice(chosen int) Grade {
+	if chosen == 0 {
+		return Grade{Rating: fsrs.Good, Correct: sql.NullBool{Bool: true, Valid: true}}
+	}
+	return Grade{Rating: fsrs.Again, Correct: sql.NullBool{Valid: true}}
+}
+
+// Valid reports whether the rating is one of Again, Hard, Good or Easy.
+func (g Grade) Valid() bool {
+	return g.Rating >= fsrs.Again && g.Rating <= fsrs.Easy
+}
+
+// Record schedules card by the grade, saves it and logs the review. It then
+// records any goals completed and runs the post-review hooks, and the
+// leech-detected hooks if the card has just become a leech; failures of these
+// are logged rather than returned.
+func Record(db *storage.DB, params *fsrs.Params, card *storage.Card, g Grade) error {
+	current := fsrs.CardState{
+		Stability:  card.Stability,
+		Difficulty: card.Difficulty,
+		LastReview: card.LastReview.Time,
+	}
+	next := params.NextState(current, g.Rating)
+	dueDate := params.DueDate(next.Stability)
+
+	card.Stability = next.Stability
+	card.Difficulty = next.Difficulty
+	card.DueDate = dueDate
+	card.LastReview = sql.NullTime{Time: next.LastReview, Valid: true}
+	card.State = 2 // Mark as in-review
+
+	if err := db.UpdateCard(card); err != nil {
+		return fmt.Errorf("failed to update card state: %w", err)
+	}
+
+	if err := db.InsertReviewLog(storage.ReviewLog{
+		CardHash:         card.Hash,
+		ReviewedAt:       next.LastReview,
+		Grade:            int(g.Rating),
+		StabilityBefore:  current.Stability,
+		DifficultyBefore: current.Difficulty,
+		StabilityAfter:   next.Stability,
+		DifficultyAfter:  next.Difficulty,
+		DueDateAfter:     dueDate,
+		Duration:         g.Duration,
+		Correct:          g.Correct,
+		Hinted:           g.Hinted,
+	}); err != nil {
+		slog.Warn("Failed to record review log", "hash", card.Hash, "error", err)
+	}
+
+	p, err := prefs.Load(db)
+	if err == nil {
+		err = goals.Record(db, p, db.Now())
+	}
+	if err != nil {
+		slog.Warn("Failed to record goal completions", "error", err)
+	}
+
+	payload := hooks.Review{
+		Card:       card.Hash,
+		Question:   card.Question,
+		Grade:      int(g.Rating),
+		Hinted:     g.Hinted,
+		DurationMS: g.Duration.Milliseconds(),
+		ReviewedAt: next.LastReview,
+		DueDate:    dueDate,
+	}
+	if g.Correct.Valid {
+		payload.Correct = &g.Correct.Bool
+	}
+	hooks.NotifyAsync(hooks.PostReview, payload)
+	if g.Rating == fsrs.Again {
+		notifyLeech(db, card)
+	}
+	return nil
+}
+
+// notifyLeech runs the leech-detected hooks when a card just forgotten has
+// become a leech.
+func notifyLeech(db *storage.DB, card *storage.Card) {
+	lapses, err := db.CountLapses(card.Hash)
+	if err != nil {
+		slog.Warn("Failed to count lapses", "hash", card.Hash, "error", err)
+		return
+	}
+	if lapses != leechLapses {
+		return
+	}
+	hooks.NotifyAsync(hooks.LeechDetected, hooks.Leech{
+		Card:     card.Hash,
+		Question: card.Question,
+		SourceID: card.SourceID.Int64,
+		File:     card.File,
+		Lapses:   lapses,
+	})
+}
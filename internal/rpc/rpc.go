@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/cloze"
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/review"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// maxLine limits the size of a request.
+const maxLine = 1 << 20
+
+// Request is a line sent by the client, e.g.
+//
+//	{"id": 1, "method": "next"}
+//	{"id": 2, "method": "reveal", "params": {"card": "9c8e2a..."}}
+//	{"id": 3, "method": "grade", "params": {"card": "9c8e2a...", "grade": 3}}
+type Request struct {
+	ID     json.RawMessage `json:"id,omitempty"` // Echoed in the response
+	Method string          `json:"method"`
+	Params Params          `json:"params"`
+}
+
+// Params are the parameters of a request.
+type Params struct {
+	Card   string `json:"card"`   // Hash of the card to reveal or grade
+	Grade  int    `json:"grade"`  // 1 (again) to 4 (easy)
+	Choice *int   `json:"choice"` // Option chosen on a multiple choice card, graded instead of Grade
+	Hinted bool   `json:"hinted"` // Whether the hint was shown before grading
+}
+
+// Response is the line written for each request, with either a result or an error.
+type Response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Front is the result of next: the front of the next due card, or no card when
+// none are due.
+type Front struct {
+	Card *FrontCard `json:"card"`
+	Due  int        `json:"due"` // Cards due, including this one and those to relearn
+}
+
+// FrontCard is the front of a card.
+type FrontCard struct {
+	Hash     string   `json:"hash"`
+	Kind     string   `json:"kind"`
+	Question string   `json:"question"` // Markdown; cloze deletions are blanked
+	Context  string   `json:"context,omitempty"`
+	Hint     string   `json:"hint,omitempty"`
+	Options  []Option `json:"options,omitempty"` // Shuffled options of a multiple choice card
+	Number   int      `json:"number,omitempty"`  // The numbered answer a numbered answer card asks for
+}
+
+// Option is an option of a multiple choice card, chosen by its index.
+type Option struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+}
+
+// Back is the result of reveal.
+type Back struct {
+	Hash     string   `json:"hash"`
+	Question string   `json:"question"` // Markdown; cloze deletions are filled in
+	Answer   string   `json:"answer"`
+	Context  string   `json:"context,omitempty"`
+	Steps    []string `json:"steps,omitempty"`
+	Right    *int     `json:"right,omitempty"` // Index of the right option of a multiple choice card
+	File     string   `json:"file,omitempty"`  // The file the card was last found in, from its source's root
+	Line     int      `json:"line,omitempty"`  // The line of File the card starts on
+}
+
+// Graded is the result of grade.
+type Graded struct {
+	NextReview time.Time `json:"next_review"`
+	Due        int       `json:"due"` // Cards still due
+}
+
+// unmark removes the cloze sentinels, which only the web page highlights.
+var unmark = strings.NewReplacer(cloze.MarkStart, "", cloze.MarkEnd, "")
+
+// Serve answers the requests read from r, one JSON object per line, with a
+// response line each on w, until r is exhausted. A request that fails gets an
+// error response; only failing to read or write ends the loop.
+//
+// The methods are next, for the front of the next due card, reveal, for the back
+// of a card, and grade, which reviews a card and schedules it. Cards graded Again
+// come back once the due cards are done, until graded Hard or better.
+func Serve(db *storage.DB, r io.Reader, w io.Writer) error {
+	s := NewSession(db)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req Request
+		var resp Response
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			resp = s.Handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	return nil
+}
+
+// Session is the state of a review between requests, kept by Serve for its
+// client, and by the web server for the clients of its JSON API. It is not
+// safe for concurrent use.
+type Session struct {
+	db      *storage.DB
+	params  *fsrs.Params
+	shown   map[string]time.Time // When each card's front was sent, to time the review
+	relearn review.Relearn       // Cards graded Again, which come back once the due cards are done
+}
+
+// NewSession starts a review of the cards of db.
+func NewSession(db *storage.DB) *Session {
+	s := &Session{db: db, params: fsrs.DefaultParams(), shown: make(map[string]time.Time)}
+	s.params.Clock = db
+	return s
+}
+
+// Handle answers a request, with an error response if it fails.
+func (s *Session) Handle(req Request) Response {
+	resp := Response{ID: req.ID}
+	result, err := s.handle(req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+func (s *Session) handle(req Request) (any, error) {
+	switch req.Method {
+	case "next":
+		return s.next()
+	case "reveal":
+		return s.reveal(req.Params)
+	case "grade":
+		return s.grade(req.Params)
+	default:
+		return nil, fmt.Errorf("unknown method %q", req.Method)
+	}
+}
+
+func (s *Session) dueQueue() ([]storage.Card, error) {
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := p.DueQueue(s.db)
+	if err != nil {
+		return nil, err
+	}
+	return s.relearn.Append(s.db, cards)
+}
+
+func (s *Session) next() (Front, error) {
+	cards, err := s.dueQueue()
+	if err != nil || len(cards) == 0 {
+		return Front{}, err
+	}
+	card := cards[0]
+	front := &FrontCard{Hash: card.Hash, Kind: card.Kind, Question: card.Question, Context: card.Context, Hint: card.Hint}
+	switch card.Kind {
+	case domain.KindCloze:
+		front.Question = unmark.Replace(cloze.Blank(card.Question))
+	case domain.KindTextCloze:
+		front.Question = cloze.BlankText(card.Question, card.Cloze)
+	case domain.KindNumbered:
+		front.Number = card.Cloze
+	case domain.KindChoice:
+		for i, text := range card.Options() {
+			front.Options = append(front.Options, Option{Index: i, Text: text})
+		}
+		rand.Shuffle(len(front.Options), func(i, j int) { front.Options[i], front.Options[j] = front.Options[j], front.Options[i] })
+	}
+	s.shown[card.Hash] = time.Now()
+	return Front{Card: front, Due: len(cards)}, nil
+}
+
+func (s *Session) card(hash string) (*storage.Card, error) {
+	if hash == "" {
+		return nil, errors.New("missing card")
+	}
+	card, err := s.db.FindCardByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if card == nil {
+		return nil, fmt.Errorf("no card %s", hash)
+	}
+	return card, nil
+}
+
+func (s *Session) reveal(params Params) (Back, error) {
+	card, err := s.card(params.Card)
+	if err != nil {
+		return Back{}, err
+	}
+	back := Back{Hash: card.Hash, Question: card.Question, Answer: card.Answer, Context: card.Context, Steps: card.Steps, File: card.File, Line: card.Line}
+	switch card.Kind {
+	case domain.KindCloze:
+		back.Question = unmark.Replace(cloze.Reveal(card.Question))
+	case domain.KindTextCloze:
+		back.Question = cloze.RevealText(card.Question, card.Cloze)
+	case domain.KindChoice:
+		right := 0
+		back.Right = &right
+	}
+	return back, nil
+}
+
+func (s *Session) grade(params Params) (Graded, error) {
+	card, err := s.card(params.Card)
+	if err != nil {
+		return Graded{}, err
+	}
+	g := review.Grade{Rating: fsrs.Rating(params.Grade)}
+	if card.Kind == domain.KindChoice && params.Choice != nil {
+		g = review.Choice(*params.Choice)
+	}
+	if !g.Valid() {
+		return Graded{}, fmt.Errorf("invalid grade %d", g.Rating)
+	}
+	g.Hinted = params.Hinted
+	if shownAt, ok := s.shown[card.Hash]; ok {
+		g.Duration = time.Since(shownAt)
+		delete(s.shown, card.Hash)
+	}
+	if err := review.Record(s.db, s.params, card, g); err != nil {
+		return Graded{}, err
+	}
+	s.relearn = s.relearn.Graded(card.Hash, g.Rating)
+	cards, err := s.dueQueue()
+	if err != nil {
+		return Graded{}, err
+	}
+	return Graded{NextReview: card.DueDate, Due: len(cards)}, nil
+}
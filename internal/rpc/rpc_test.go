@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// openDeck opens an in-memory database with new cards of the given hashes and kinds.
+func openDeck(t *testing.T, kinds map[string]string) *storage.DB {
+	t.Helper()
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	sourceID, err := db.InsertSource("/notes", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, kind := range kinds {
+		card := domain.Card{Hash: hash, Question: "Q " + hash, Answer: "A " + hash, Kind: kind}
+		if kind == domain.KindChoice {
+			card.Distractors = []string{"wrong"}
+		}
+		if err := db.InsertCard(card, sourceID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSessionGradeValidation(t *testing.T) {
+	db := openDeck(t, map[string]string{"basic": domain.KindBasic, "choice": domain.KindChoice})
+	s := NewSession(db)
+	choice := func(n int) *int { return &n }
+
+	for _, tc := range []struct {
+		name   string
+		params Params
+		valid  bool
+	}{
+		{"grade 0", Params{Card: "basic", Grade: 0}, false},
+		{"grade 5", Params{Card: "basic", Grade: 5}, false},
+		{"grade -1", Params{Card: "basic", Grade: -1}, false},
+		{"no card", Params{Grade: 3}, false},
+		{"unknown card", Params{Card: "missing", Grade: 3}, false},
+		{"the right option", Params{Card: "choice", Grade: 9, Choice: choice(0)}, true},
+		{"grade 3", Params{Card: "basic", Grade: 3}, true},
+	} {
+		resp := s.Handle(Request{Method: "grade", Params: tc.params})
+		if valid := resp.Error == ""; valid != tc.valid {
+			t.Errorf("Expected grading with %s to be valid: %v, but got %+v", tc.name, tc.valid, resp)
+		}
+	}
+	for hash, want := range map[string]int{"basic": 1, "choice": 1} {
+		if logs, err := db.GetReviewLogsByCard(hash); err != nil || len(logs) != want {
+			t.Errorf("Expected %d review of %s, but got %d, %v", want, hash, len(logs), err)
+		}
+	}
+}
+
+func TestSessionRelearnAcrossCalls(t *testing.T) {
+	db := openDeck(t, map[string]string{"one": domain.KindBasic, "two": domain.KindBasic})
+	s := NewSession(db)
+	next := func() *FrontCard {
+		t.Helper()
+		resp := s.Handle(Request{Method: "next"})
+		if resp.Error != "" {
+			t.Fatalf("next returned an unexpected error: %s", resp.Error)
+		}
+		return resp.Result.(Front).Card
+	}
+	grade := func(hash string, grade int) Graded {
+		t.Helper()
+		resp := s.Handle(Request{Method: "grade", Params: Params{Card: hash, Grade: grade}})
+		if resp.Error != "" {
+			t.Fatalf("grade returned an unexpected error: %s", resp.Error)
+		}
+		return resp.Result.(Graded)
+	}
+
+	first := next()
+	if first == nil {
+		t.Fatal("Expected a card due")
+	}
+	// Forgotten, it comes back after the other card, until remembered
+	if g := grade(first.Hash, 1); g.Due != 2 {
+		t.Errorf("Expected the forgotten card to stay in the queue, but %d are due", g.Due)
+	}
+	second := next()
+	if second == nil || second.Hash == first.Hash {
+		t.Fatalf("Expected the other card before the forgotten one, but got %+v", second)
+	}
+	if g := grade(second.Hash, 3); g.Due != 1 {
+		t.Errorf("Expected the forgotten card left, but %d are due", g.Due)
+	}
+	if again := next(); again == nil || again.Hash != first.Hash {
+		t.Fatalf("Expected the forgotten card to come back, but got %+v", again)
+	}
+	if g := grade(first.Hash, 3); g.Due != 0 {
+		t.Errorf("Expected no cards left, but %d are due", g.Due)
+	}
+	if card := next(); card != nil {
+		t.Errorf("Expected no more cards, but got %+v", card)
+	}
+
+	if resp := s.Handle(Request{Method: "unknown"}); !strings.Contains(resp.Error, "unknown method") {
+		t.Errorf("Expected an unknown method to be an error, but got %+v", resp)
+	}
+}
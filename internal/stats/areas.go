@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+const (
+	// minAreaReviews is how many reviews of learned cards a context needs before
+	// its lapse rate is ranked.
+	minAreaReviews = 5
+	// weakAreas is how many of the worst ranked contexts are flagged as weak.
+	weakAreas = 3
+)
+
+// Area is a topic, the cards sharing a context, ranked by how poorly it is remembered.
+type Area struct {
+	storage.ContextStats
+	LapseRate float64 // Share of reviews of learned cards graded Again
+	Ranked    bool    // Enough reviews to rank by lapse rate
+	Weak      bool    // Among the worst ranked areas
+}
+
+// Areas returns every context, worst first: contexts with enough reviews by lapse
+// rate and then average difficulty, followed by the rest by average difficulty.
+func Areas(db *storage.DB) ([]Area, error) {
+	contexts, err := db.GetContextStats()
+	if err != nil {
+		return nil, err
+	}
+
+	areas := make([]Area, len(contexts))
+	for i, c := range contexts {
+		areas[i] = Area{ContextStats: c, Ranked: c.Reviews >= minAreaReviews}
+		if c.Reviews > 0 {
+			areas[i].LapseRate = float64(c.Lapses) / float64(c.Reviews)
+		}
+	}
+	slices.SortFunc(areas, func(a, b Area) int {
+		if a.Ranked != b.Ranked {
+			if a.Ranked {
+				return -1
+			}
+			return 1
+		}
+		if a.Ranked {
+			if c := cmp.Compare(b.LapseRate, a.LapseRate); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(b.AvgDifficulty.Float64, a.AvgDifficulty.Float64)
+	})
+	for i := 0; i < len(areas) && i < weakAreas; i++ {
+		areas[i].Weak = areas[i].Ranked && areas[i].Lapses > 0
+	}
+	return areas, nil
+}
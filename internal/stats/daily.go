@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Point is the value of a daily series on the study day starting at Time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// days returns the start of every study day overlapping [from, to), in order.
+func days(p prefs.Preferences, from, to time.Time) []time.Time {
+	var starts []time.Time
+	for day := p.DayStart(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		starts = append(starts, day)
+	}
+	return starts
+}
+
+// ReviewsPerDay counts the reviews of every study day overlapping [from, to).
+// Days without reviews are included with a count of zero.
+func ReviewsPerDay(db *storage.DB, p prefs.Preferences, from, to time.Time) ([]Point, error) {
+	starts := days(p, from, to)
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	logs, err := db.GetReviewLogsBetween(starts[0], to)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int) // By Unix time of the day start
+	for _, log := range logs {
+		counts[p.DayStart(log.ReviewedAt).Unix()]++
+	}
+	points := make([]Point, len(starts))
+	for i, day := range starts {
+		points[i] = Point{Time: day, Value: float64(counts[day.Unix()])}
+	}
+	return points, nil
+}
+
+// RetentionPerDay returns the share of reviews of already learned cards that were
+// recalled (graded Hard or better) on every study day overlapping [from, to).
+// First reviews of new cards don't count, and days without any reviews of learned
+// cards are left out.
+func RetentionPerDay(db *storage.DB, p prefs.Preferences, from, to time.Time) ([]Point, error) {
+	starts := days(p, from, to)
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	logs, err := db.GetReviewLogsBetween(starts[0], to)
+	if err != nil {
+		return nil, err
+	}
+
+	type tally struct{ recalled, total int }
+	tallies := make(map[int64]*tally) // By Unix time of the day start
+	for _, log := range logs {
+		if log.StabilityBefore == 0 {
+			continue
+		}
+		day := p.DayStart(log.ReviewedAt).Unix()
+		t := tallies[day]
+		if t == nil {
+			t = &tally{}
+			tallies[day] = t
+		}
+		t.total++
+		if log.Grade > 1 {
+			t.recalled++
+		}
+	}
+	var points []Point
+	for _, day := range starts {
+		if t := tallies[day.Unix()]; t != nil {
+			points = append(points, Point{Time: day, Value: float64(t.recalled) / float64(t.total)})
+		}
+	}
+	return points, nil
+}
+
+// DueForecast counts the cards falling due on every study day overlapping [now, to).
+// Overdue cards are counted on the current day. Days before now are not forecast.
+func DueForecast(db *storage.DB, p prefs.Preferences, now, to time.Time) ([]Point, error) {
+	starts := days(p, now, to)
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	dueDates, err := db.GetDueDatesBefore(to)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int) // By Unix time of the day start
+	for _, due := range dueDates {
+		day := p.DayStart(due)
+		if day.Before(starts[0]) {
+			day = starts[0]
+		}
+		counts[day.Unix()]++
+	}
+	points := make([]Point, len(starts))
+	for i, day := range starts {
+		points[i] = Point{Time: day, Value: float64(counts[day.Unix()])}
+	}
+	return points, nil
+}
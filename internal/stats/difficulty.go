@@ -0,0 +1,30 @@
+package stats
+
+import (
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Bin is a bar of the difficulty histogram, counting cards with a difficulty
+// from Low up to Low+1.
+type Bin struct {
+	Low   int
+	Cards int
+}
+
+// DifficultyHistogram bins the reviewed cards by FSRS difficulty, which ranges
+// from 1 to 10. Cards with a difficulty of exactly 10 fall into the last bin.
+func DifficultyHistogram(db *storage.DB) ([]Bin, error) {
+	counts, err := db.CountCardsByDifficulty()
+	if err != nil {
+		return nil, err
+	}
+	bins := make([]Bin, 9)
+	for i := range bins {
+		bins[i].Low = i + 1
+	}
+	for difficulty, n := range counts {
+		i := min(max(difficulty, 1), 9) - 1
+		bins[i].Cards += n
+	}
+	return bins, nil
+}
@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Recall counts reviews and how many of them were recalled, graded anything but Again.
+type Recall struct {
+	Reviews  int
+	Recalled int
+}
+
+// Rate is the share of reviews recalled, 0 without reviews.
+func (r Recall) Rate() float64 {
+	if r.Reviews == 0 {
+		return 0
+	}
+	return float64(r.Recalled) / float64(r.Reviews)
+}
+
+// HintUse compares the reviews of cards that have a hint made with the hint shown
+// to those made without it.
+type HintUse struct {
+	Shown    Recall
+	NotShown Recall
+}
+
+// Hints computes how often hints are used and how well cards are recalled with
+// and without them.
+func Hints(db *storage.DB) (HintUse, error) {
+	var h HintUse
+	var err error
+	h.Shown.Reviews, h.Shown.Recalled, h.NotShown.Reviews, h.NotShown.Recalled, err = db.CountReviewsOfHintedCards()
+	return h, err
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// MatureDays is the interval from which a card counts as mature. Intervals are
+// the card's stability in days.
+const MatureDays = 21
+
+// maturityWindow is how far back reviews count towards the retention of each class.
+const maturityWindow = 30 * 24 * time.Hour
+
+// Retention tallies the reviews of a class of cards.
+type Retention struct {
+	Reviews  int     `json:"reviews"`
+	Recalled int     `json:"recalled"` // Graded Hard or better
+	Rate     float64 `json:"rate"`     // Share of reviews recalled; 0 without reviews
+}
+
+// add counts a review with the given grade.
+func (r *Retention) add(grade int) {
+	r.Reviews++
+	if grade > 1 {
+		r.Recalled++
+	}
+	r.Rate = float64(r.Recalled) / float64(r.Reviews)
+}
+
+// Maturity breaks the collection down into new, young and mature cards, with the
+// retention of young and mature cards over the last 30 days. Mature retention is
+// the usual measure of whether scheduling works: it should stay close to the
+// retention the scheduler targets.
+type Maturity struct {
+	New             int       `json:"new"`
+	Young           int       `json:"young"`
+	Mature          int       `json:"mature"`
+	YoungRetention  Retention `json:"young_retention"`
+	MatureRetention Retention `json:"mature_retention"`
+}
+
+// CardMaturity computes the maturity breakdown at now. A review counts towards the
+// class of the card's interval at the time of the review.
+func CardMaturity(db *storage.DB, now time.Time) (Maturity, error) {
+	var m Maturity
+	var err error
+	m.New, m.Young, m.Mature, err = db.CountCardsByMaturity(MatureDays)
+	if err != nil {
+		return m, err
+	}
+
+	logs, err := db.GetReviewLogsBetween(now.Add(-maturityWindow), now)
+	if err != nil {
+		return m, err
+	}
+	for _, log := range logs {
+		var r *Retention
+		switch {
+		case log.StabilityBefore == 0: // First review of a new card
+			continue
+		case log.StabilityBefore < MatureDays:
+			r = &m.YoungRetention
+		default:
+			r = &m.MatureRetention
+		}
+		r.add(log.Grade)
+	}
+	return m, nil
+}
@@ -0,0 +1,171 @@
+package stats
+
+import (
+	"math"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/clock"
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// replayHorizon is how far ahead a replay counts the reviews that fall due.
+const replayHorizon = 30 * 24 * time.Hour
+
+// A Scheduler replays the reviews of a card, in order, returning the interval in
+// days it would have scheduled after each.
+type Scheduler interface {
+	Intervals(reviews []storage.ReviewLog) []float64
+}
+
+// FSRS schedules with a set of FSRS parameters.
+type FSRS struct {
+	Params *fsrs.Params
+}
+
+// Intervals implements Scheduler.
+func (s FSRS) Intervals(reviews []storage.ReviewLog) []float64 {
+	params := *s.Params
+	var state fsrs.CardState
+	intervals := make([]float64, len(reviews))
+	for i, review := range reviews {
+		params.Clock = clock.Fixed(review.ReviewedAt)
+		state = params.NextState(state, fsrs.Rating(review.Grade))
+		intervals[i] = state.Stability
+	}
+	return intervals
+}
+
+// SM2 schedules with SuperMemo's SM-2, as Anki and Mnemosyne did: intervals of 1
+// and 6 days, then each the last times an ease factor, starting at 2.5 and
+// adjusted by every grade. Again starts the card over. The grades Hard, Good and
+// Easy are SM-2's 3, 4 and 5.
+type SM2 struct{}
+
+// Intervals implements Scheduler.
+func (SM2) Intervals(reviews []storage.ReviewLog) []float64 {
+	ease, interval, reps := 2.5, 0.0, 0
+	intervals := make([]float64, len(reviews))
+	for i, review := range reviews {
+		q := float64(review.Grade + 1)
+		switch {
+		case review.Grade <= int(fsrs.Again):
+			reps, interval = 0, 1
+		case reps == 0:
+			interval = 1
+		case reps == 1:
+			interval = 6
+		default:
+			interval = math.Round(interval * ease)
+		}
+		if review.Grade > int(fsrs.Again) {
+			reps++
+		}
+		ease = math.Max(1.3, ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+		intervals[i] = interval
+	}
+	return intervals
+}
+
+// Replay is what a scheduler would have made of the review history.
+type Replay struct {
+	Cards        int     // Cards with reviews
+	Reviews      int     // Reviews replayed
+	MeanInterval float64 // Mean interval in days after each card's last review
+	// DailyReviews is the reviews a day the cards would need at their current
+	// intervals, once the collection settles.
+	DailyReviews float64
+	// DueSoon is the cards that would fall due from now to 30 days ahead.
+	DueSoon int
+	// Retention is the predicted share of the cards recalled when they next fall
+	// due, by the memory model of the default FSRS parameters.
+	Retention float64
+	// ReplayedRetention is the predicted recall at each replayed review after the
+	// first, had the reviews happened when the scheduler made them due.
+	ReplayedRetention float64
+}
+
+// Comparison replays the review history under two schedulers.
+type Comparison struct {
+	A, B Replay
+}
+
+// CompareSchedulers replays every card's review history, without manual due
+// date changes, under schedulers a and b at now, reporting the workload and
+// retention each would have led to. The schedulers are judged by the same memory
+// model, the default FSRS parameters' stability of the card after each actual
+// review: the chance of recalling a card after t days of stability S is
+// 0.9^(t/S). Longer intervals mean fewer reviews and more forgetting, so neither
+// number means much without the other.
+func CompareSchedulers(db *storage.DB, now time.Time, a, b Scheduler) (Comparison, error) {
+	logs, err := db.GetAllReviewLogs()
+	if err != nil {
+		return Comparison{}, err
+	}
+	var order []string
+	byCard := make(map[string][]storage.ReviewLog)
+	for _, log := range logs {
+		if log.Manual {
+			continue
+		}
+		if _, ok := byCard[log.CardHash]; !ok {
+			order = append(order, log.CardHash)
+		}
+		byCard[log.CardHash] = append(byCard[log.CardHash], log)
+	}
+
+	memory := FSRS{Params: fsrs.DefaultParams()}
+	var c Comparison
+	var sumA, sumB replaySums
+	for _, hash := range order {
+		reviews := byCard[hash]
+		stability := memory.Intervals(reviews)
+		sumA.add(&c.A, a.Intervals(reviews), stability, reviews, now)
+		sumB.add(&c.B, b.Intervals(reviews), stability, reviews, now)
+	}
+	sumA.finish(&c.A)
+	sumB.finish(&c.B)
+	return c, nil
+}
+
+// replaySums sums the predicted recall of a replay.
+type replaySums struct {
+	recall, replayed float64
+}
+
+// add adds a card's replay under a scheduler, which scheduled the given
+// intervals where the memory model had the given stability, to r.
+func (s *replaySums) add(r *Replay, intervals, stability []float64, reviews []storage.ReviewLog, now time.Time) {
+	last := len(reviews) - 1
+	r.Cards++
+	r.Reviews += len(reviews)
+	interval := math.Max(intervals[last], 0.1)
+	r.MeanInterval += interval
+	r.DailyReviews += 1 / interval
+	due := reviews[last].ReviewedAt.Add(time.Duration(interval * 24 * float64(time.Hour)))
+	if due.Before(now.Add(replayHorizon)) {
+		r.DueSoon++
+	}
+	s.recall += recall(interval, stability[last])
+	for i := range last {
+		s.replayed += recall(intervals[i], stability[i])
+	}
+}
+
+// finish turns the sums of r into means.
+func (s *replaySums) finish(r *Replay) {
+	if r.Cards == 0 {
+		return
+	}
+	r.MeanInterval /= float64(r.Cards)
+	r.Retention = s.recall / float64(r.Cards)
+	if n := r.Reviews - r.Cards; n > 0 {
+		r.ReplayedRetention = s.replayed / float64(n)
+	}
+}
+
+// recall is the chance of recalling a card of the given stability after the
+// given days.
+func recall(days, stability float64) float64 {
+	return math.Pow(0.9, days/math.Max(stability, 0.1))
+}
@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// maxReviewTime caps the time counted for a single review, so a card left open
+// while away from the screen doesn't count as an hour of study.
+const maxReviewTime = 5 * time.Minute
+
+// MinutesPerDay sums the time spent answering reviews on every study day
+// overlapping [from, to), in minutes. Days without reviews are included with zero.
+// Reviews recorded before answer times were tracked count as no time.
+func MinutesPerDay(db *storage.DB, p prefs.Preferences, from, to time.Time) ([]Point, error) {
+	starts := days(p, from, to)
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	logs, err := db.GetReviewLogsBetween(starts[0], to)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make(map[int64]time.Duration) // By Unix time of the day start
+	for _, log := range logs {
+		spent[p.DayStart(log.ReviewedAt).Unix()] += min(log.Duration, maxReviewTime)
+	}
+	points := make([]Point, len(starts))
+	for i, day := range starts {
+		points[i] = Point{Time: day, Value: spent[day.Unix()].Minutes()}
+	}
+	return points, nil
+}
+
+// Weekly sums a daily series into consecutive weeks of seven days, starting with
+// its first day. The last week may be shorter.
+func Weekly(daily []Point) []Point {
+	var weeks []Point
+	for i, pt := range daily {
+		if i%7 == 0 {
+			weeks = append(weeks, Point{Time: pt.Time})
+		}
+		weeks[len(weeks)-1].Value += pt.Value
+	}
+	return weeks
+}
+
+// Streak is a run of consecutive study days meeting a daily goal.
+type Streak struct {
+	Current int // Days up to today; today only counts once the goal is met
+	Longest int
+}
+
+// GoalStreak computes the streaks of days in a daily series, oldest first and
+// ending with today, whose value reached goal. A goal of 0 has no streaks.
+func GoalStreak(daily []Point, goal float64) Streak {
+	var s Streak
+	if goal <= 0 {
+		return s
+	}
+	run := 0
+	for _, pt := range daily {
+		if pt.Value >= goal {
+			run++
+		} else {
+			run = 0
+		}
+		s.Longest = max(s.Longest, run)
+	}
+	s.Current = run
+	if n := len(daily); run == 0 && n > 1 {
+		// Today isn't over yet, so the streak up to yesterday still stands.
+		for i := n - 2; i >= 0 && daily[i].Value >= goal; i-- {
+			s.Current++
+		}
+	}
+	return s
+}
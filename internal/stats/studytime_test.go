@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestGoalStreak(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		values []float64 // Oldest first, ending with today
+		goal   float64
+		want   Streak
+	}{
+		{"no goal", []float64{20, 20}, 0, Streak{}},
+		{"no days", nil, 10, Streak{}},
+		{"met every day", []float64{10, 15, 10}, 10, Streak{Current: 3, Longest: 3}},
+		{"today not met yet", []float64{10, 10, 0}, 10, Streak{Current: 2, Longest: 2}},
+		{"only today, not met yet", []float64{5}, 10, Streak{}},
+		{"yesterday missed", []float64{10, 10, 10, 0, 0}, 10, Streak{Longest: 3}},
+		{"a gap starts over", []float64{10, 10, 10, 9, 10, 10}, 10, Streak{Current: 2, Longest: 3}},
+		{"a gap, today not met yet", []float64{10, 0, 10, 10, 5}, 10, Streak{Current: 2, Longest: 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			daily := make([]Point, len(tc.values))
+			for i, v := range tc.values {
+				daily[i] = Point{Value: v}
+			}
+			if got := GoalStreak(daily, tc.goal); got != tc.want {
+				t.Errorf("Expected %+v, but got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMinutesPerDayTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone database unavailable: %v", err)
+	}
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	p := prefs.Preferences{Timezone: "America/New_York", DayCutoff: 4}
+
+	for _, at := range []time.Time{
+		time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC), // 08:00 on the 18th in New York
+		time.Date(2026, 3, 19, 2, 0, 0, 0, time.UTC),  // 22:00 on the 18th
+		time.Date(2026, 3, 19, 7, 30, 0, 0, time.UTC), // 03:30 on the 19th, before the cutoff
+		time.Date(2026, 3, 19, 9, 0, 0, 0, time.UTC),  // 05:00 on the 19th
+	} {
+		if err := db.InsertReviewLog(storage.ReviewLog{CardHash: "abc", ReviewedAt: at, Grade: 3, Duration: 10 * time.Minute}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := time.Date(2026, 3, 18, 4, 0, 0, 0, loc)
+	minutes, err := MinutesPerDay(db, p, from, from.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("MinutesPerDay returned an unexpected error: %v", err)
+	}
+	// Each review counts for at most maxReviewTime
+	want := []float64{15, 5, 0}
+	if len(minutes) != len(want) {
+		t.Fatalf("Expected %d days, but got %+v", len(want), minutes)
+	}
+	for i, w := range want {
+		if day := from.AddDate(0, 0, i); !minutes[i].Time.Equal(day) || minutes[i].Value != w {
+			t.Errorf("Expected %v minutes on the day starting %v, but got %+v", w, day, minutes[i])
+		}
+	}
+	if got := GoalStreak(minutes, 5); got != (Streak{Current: 2, Longest: 2}) {
+		t.Errorf("Expected a streak of the two days studied, but got %+v", got)
+	}
+}
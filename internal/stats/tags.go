@@ -0,0 +1,38 @@
+package stats
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Tag is a tag ranked by how poorly its cards were remembered over a period.
+type Tag struct {
+	storage.TagStats
+	LapseRate float64 // Share of reviews of learned cards graded Again
+}
+
+// WeakTags returns up to n tags whose cards were forgotten in [from, to), worst
+// lapse rate first, among those with enough reviews to rank.
+func WeakTags(db *storage.DB, from, to time.Time, n int) ([]Tag, error) {
+	stats, err := db.GetTagStatsBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	for _, s := range stats {
+		if s.Reviews >= minAreaReviews && s.Lapses > 0 {
+			tags = append(tags, Tag{TagStats: s, LapseRate: float64(s.Lapses) / float64(s.Reviews)})
+		}
+	}
+	slices.SortFunc(tags, func(a, b Tag) int {
+		if c := cmp.Compare(b.LapseRate, a.LapseRate); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return tags[:min(n, len(tags))], nil
+}
@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Totals sums up the reviews of a period.
+type Totals struct {
+	Reviews  int           // All reviews, first reviews of new cards included
+	NewCards int           // First reviews of new cards
+	Learned  int           // Reviews of cards already learned
+	Recalled int           // Of those, graded Hard or better
+	Time     time.Duration // Spent answering, capped per review like MinutesPerDay
+}
+
+// Retention returns the share of reviews of learned cards that were recalled, or
+// 0 if there were none.
+func (t Totals) Retention() float64 {
+	if t.Learned == 0 {
+		return 0
+	}
+	return float64(t.Recalled) / float64(t.Learned)
+}
+
+// TotalsBetween sums up the reviews in [from, to).
+func TotalsBetween(db *storage.DB, from, to time.Time) (Totals, error) {
+	var t Totals
+	logs, err := db.GetReviewLogsBetween(from, to)
+	if err != nil {
+		return t, err
+	}
+	for _, log := range logs {
+		t.Reviews++
+		t.Time += min(log.Duration, maxReviewTime)
+		if log.StabilityBefore == 0 {
+			t.NewCards++
+			continue
+		}
+		t.Learned++
+		if log.Grade > 1 {
+			t.Recalled++
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ContextStats aggregates the cards sharing a context and their review history.
+type ContextStats struct {
+	Context string
+	Cards   int
+	// Reviews and Lapses only count reviews of cards that had already been
+	// learned; a lapse is such a review graded Again.
+	Reviews       int
+	Lapses        int
+	AvgDifficulty sql.NullFloat64 // Over reviewed cards; null if none has been reviewed
+}
+
+// GetContextStats aggregates cards and their reviews by context.
+func (db *DB) GetContextStats() ([]ContextStats, error) {
+	rows, err := db.conn.Query(`
+		SELECT c.context, COUNT(*), COALESCE(SUM(r.reviews), 0), COALESCE(SUM(r.lapses), 0),
+			AVG(CASE WHEN c.stability > 0 THEN c.difficulty END)
+		FROM cards c
+		LEFT JOIN (
+			SELECT card_hash, COUNT(*) AS reviews, SUM(CASE WHEN grade = 1 THEN 1 ELSE 0 END) AS lapses
+			FROM review_logs
+			WHERE stability_before > 0 AND manual = 0
+			GROUP BY card_hash
+		) r ON r.card_hash = c.hash
+		GROUP BY c.context
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ContextStats
+	for rows.Next() {
+		var s ContextStats
+		if err := rows.Scan(&s.Context, &s.Cards, &s.Reviews, &s.Lapses, &s.AvgDifficulty); err != nil {
+			return nil, fmt.Errorf("failed to scan context stats row: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// CountCardsByMaturity counts the cards that are new (never reviewed), young
+// (stability below matureStability days) and mature.
+func (db *DB) CountCardsByMaturity(matureStability float64) (newCards, young, mature int, err error) {
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN stability = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN stability > 0 AND stability < ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN stability >= ? THEN 1 ELSE 0 END), 0)
+		FROM cards
+	`, matureStability, matureStability).Scan(&newCards, &young, &mature)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count cards by maturity: %w", err)
+	}
+	return newCards, young, mature, nil
+}
+
+// CountCardsByDifficulty counts the reviewed cards by whole difficulty, e.g. cards
+// with a difficulty from 3 up to 4 are counted under 3.
+func (db *DB) CountCardsByDifficulty() (map[int]int, error) {
+	rows, err := db.conn.Query(`
+		SELECT CAST(difficulty AS INTEGER), COUNT(*)
+		FROM cards
+		WHERE stability > 0
+		GROUP BY 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count cards by difficulty: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var difficulty, count int
+		if err := rows.Scan(&difficulty, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan difficulty count: %w", err)
+		}
+		counts[difficulty] = count
+	}
+	return counts, rows.Err()
+}
+
+// TagStats aggregates the reviews of the cards sharing a tag; like those of
+// ContextStats, only reviews of cards that had already been learned count.
+type TagStats struct {
+	Tag     string
+	Reviews int
+	Lapses  int
+}
+
+// GetTagStatsBetween aggregates the reviews in [from, to) by the current tags of
+// their cards, in no particular order.
+func (db *DB) GetTagStatsBetween(from, to time.Time) ([]TagStats, error) {
+	rows, err := db.conn.Query(`
+		SELECT c.tags, COUNT(*), SUM(CASE WHEN r.grade = 1 THEN 1 ELSE 0 END)
+		FROM review_logs r
+		JOIN cards c ON c.hash = r.card_hash
+		WHERE r.reviewed_at >= ? AND r.reviewed_at < ? AND r.stability_before > 0 AND r.manual = 0 AND c.tags != ''
+		GROUP BY c.tags
+	`, from.Local(), to.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag stats: %w", err)
+	}
+	defer rows.Close()
+
+	byTag := make(map[string]*TagStats)
+	var stats []*TagStats
+	for rows.Next() {
+		var tags string
+		var reviews, lapses int
+		if err := rows.Scan(&tags, &reviews, &lapses); err != nil {
+			return nil, fmt.Errorf("failed to scan tag stats row: %w", err)
+		}
+		for _, tag := range strings.Split(tags, "\n") {
+			s := byTag[tag]
+			if s == nil {
+				s = &TagStats{Tag: tag}
+				byTag[tag] = s
+				stats = append(stats, s)
+			}
+			s.Reviews += reviews
+			s.Lapses += lapses
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]TagStats, len(stats))
+	for i, s := range stats {
+		result[i] = *s
+	}
+	return result, nil
+}
@@ -3,42 +3,157 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/conorfennell/knolhash/internal/clock"
 	"github.com/conorfennell/knolhash/internal/domain"
 	_ "modernc.org/sqlite" // Registers the sqlite driver
 )
 
 // DB represents a wrapper around the SQL database connection.
 type DB struct {
-	conn *sql.DB
+	conn     *sql.DB
+	readOnly bool
+	clock    clock.Clock
 }
 
+// Options tune how the database is opened, e.g. when it is replicated by
+// Litestream or LiteFS.
+type Options struct {
+	// ReadOnly opens a replica: nothing is written, not even migrations, so the
+	// primary must already have migrated it to this version's schema.
+	ReadOnly bool
+	// NoAutoCheckpoint stops SQLite from checkpointing the write-ahead log after
+	// commits, leaving it to Checkpoint or to Litestream, which replicates the
+	// WAL and prefers to checkpoint it itself.
+	NoAutoCheckpoint bool
+	// Clock sets the time cards are scheduled and found due by, the system clock
+	// if nil.
+	Clock clock.Clock
+}
+
+// busyTimeoutMS is how long a connection waits for a lock, e.g. one held by
+// Litestream while it copies the WAL, before failing with SQLITE_BUSY.
+const busyTimeoutMS = 5000
+
 // Open creates a new database connection and ensures the schema is up to date.
 func Open(dsn string) (*DB, error) {
-	// Ensure the directory for the database file exists.
-	dir := filepath.Dir(dsn)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	return OpenWith(dsn, Options{})
+}
+
+// OpenWith is Open with options. File databases use the write-ahead log, which
+// Litestream and LiteFS need to replicate them.
+func OpenWith(dsn string, opts Options) (*DB, error) {
+	pragmas := []string{fmt.Sprintf("busy_timeout(%d)", busyTimeoutMS)}
+	if opts.ReadOnly {
+		pragmas = append(pragmas, "query_only(1)")
+	} else {
+		// Ensure the directory for the database file exists.
+		dir := filepath.Dir(dsn)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+		pragmas = append(pragmas, "journal_mode(WAL)", "synchronous(NORMAL)")
+		if opts.NoAutoCheckpoint {
+			pragmas = append(pragmas, "wal_autocheckpoint(0)")
+		}
 	}
 
-	db, err := sql.Open("sqlite", dsn)
+	source := dsn
+	if dsn != ":memory:" {
+		// The driver runs every _pragma on each new connection.
+		query := url.Values{"_pragma": pragmas}
+		source = dsn + "?" + query.Encode()
+	}
+	db, err := sql.Open("sqlite", source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if dsn == ":memory:" {
+		// Every connection to ":memory:" gets its own empty database, so share a single one.
+		db.SetMaxOpenConns(1)
+	}
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if opts.ReadOnly {
+		if err := checkVersion(db); err != nil {
+			return nil, err
+		}
+		return &DB{conn: db, readOnly: true, clock: opts.Clock}, nil
+	}
+
 	// Execute the schema to create tables if they don't exist.
 	if _, err := db.Exec(schema); err != nil {
 		return nil, fmt.Errorf("failed to apply schema: %w", err)
 	}
 
-	return &DB{conn: db}, nil
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
+	return &DB{conn: db, clock: opts.Clock}, nil
+}
+
+// checkVersion returns an error if a read-only database lacks migrations this
+// version relies on. A newer schema is fine: migrations only add to it.
+func checkVersion(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version < len(migrations) {
+		return fmt.Errorf("read-only database is at schema version %d, but this version of knolhash needs %d: upgrade the primary first", version, len(migrations))
+	}
+	return nil
+}
+
+// ReadOnly reports whether the database was opened read-only, as a replica.
+func (db *DB) ReadOnly() bool {
+	return db.readOnly
+}
+
+// Checkpoint copies the write-ahead log back into the database file without
+// waiting for readers, so it is safe to run alongside Litestream.
+func (db *DB) Checkpoint() error {
+	if _, err := db.conn.Exec(`PRAGMA wal_checkpoint(PASSIVE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return nil
+}
+
+// migrate applies any migrations that have not yet been run against the database.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		// PRAGMA statements cannot take bound parameters.
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
 }
 
 // Close closes the database connection.
@@ -51,27 +166,110 @@ type Card struct {
 	Hash       string
 	Question   string
 	Answer     string
+	Context    string // Without surrounding whitespace
 	Stability  float64
 	Difficulty float64
 	DueDate    time.Time
 	LastReview sql.NullTime // Use NullTime for nullable last_review
 	State      int          // 0: New, 1: Learning, 2: Review
 	SourceID   sql.NullInt64 // Use NullInt64 for nullable source_id
+	Suspended  bool          // Suspended cards are never due
+	Kind       string        // One of the domain.Kind constants
+	// Distractors are the wrong options of a multiple choice card.
+	Distractors []string
+	// Steps are the steps of a steps card, in order.
+	Steps []string
+	Hint  string // Without surrounding whitespace
+	Cloze int    // Number of the deletions a text cloze card blanks out
+	Tags  []string
+	Media []string // Paths of the images the card shows, from the source's root
+	// File is the path of the file the card was last found in, from the source's root.
+	File         string
+	Line         int          // Line of File the card starts on, from 1; 0 until the next sync
+	FileModified sql.NullTime // When File last changed; unset until the next sync
+}
+
+// Options returns the options of a multiple choice card: its answer, followed by
+// the distractors.
+func (c Card) Options() []string {
+	return append([]string{strings.TrimSpace(c.Answer)}, c.Distractors...)
+}
+
+// cardColumns lists the columns scanned by scanCard, in order.
+const cardColumns = `hash, question, answer, context, stability, difficulty, due_date, last_review, state, source_id, suspended, kind, distractors, steps, hint, file, file_modified, line, cloze, tags, media`
+
+// scanCard scans a row selected with cardColumns into a Card.
+func scanCard(row interface{ Scan(...any) error }) (Card, error) {
+	var cs Card
+	var distractors, steps, tags, media string
+	err := row.Scan(
+		&cs.Hash,
+		&cs.Question,
+		&cs.Answer,
+		&cs.Context,
+		&cs.Stability,
+		&cs.Difficulty,
+		&cs.DueDate,
+		&cs.LastReview,
+		&cs.State,
+		&cs.SourceID,
+		&cs.Suspended,
+		&cs.Kind,
+		&distractors,
+		&steps,
+		&cs.Hint,
+		&cs.File,
+		&cs.FileModified,
+		&cs.Line,
+		&cs.Cloze,
+		&tags,
+		&media,
+	)
+	if distractors != "" {
+		cs.Distractors = strings.Split(distractors, "\n")
+	}
+	if steps != "" {
+		cs.Steps = strings.Split(steps, "\n")
+	}
+	if tags != "" {
+		cs.Tags = strings.Split(tags, "\n")
+	}
+	if media != "" {
+		cs.Media = strings.Split(media, "\n")
+	}
+	return cs, err
+}
+
+// Now returns the current time by the database's clock, which cards are
+// scheduled and found due by.
+func (db *DB) Now() time.Time {
+	if db.clock == nil {
+		return time.Now()
+	}
+	return db.clock.Now()
 }
 
 // InsertCard inserts a new card into the database.
 // It also sets initial FSRS values for new cards.
 func (db *DB) InsertCard(card domain.Card, sourceID int64) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO cards (hash, question, answer, stability, difficulty, due_date, state, source_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO cards (hash, question, answer, context, kind, distractors, steps, hint, cloze, tags, media, stability, difficulty, due_date, state, source_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		card.Hash,
 		card.Question,
 		card.Answer,
+		strings.TrimSpace(card.Context),
+		card.Kind,
+		strings.Join(card.Distractors, "\n"),
+		strings.Join(card.Steps, "\n"),
+		strings.TrimSpace(card.Hint),
+		card.Cloze,
+		strings.Join(card.Tags, "\n"),
+		strings.Join(card.Media, "\n"),
 		0.0, // Initial stability
 		0.0, // Initial difficulty
-		time.Now(), // Initial due date (today)
+		db.Now(), // Initial due date (today)
 		0,   // Initial state: New
 		sourceID,
 	)
@@ -83,23 +281,11 @@ func (db *DB) InsertCard(card domain.Card, sourceID int64) error {
 
 // FindCardByHash retrieves a card's state from the database by its hash.
 func (db *DB) FindCardByHash(hash string) (*Card, error) {
-	var cs Card
 	row := db.conn.QueryRow(`
-		SELECT hash, question, answer, stability, difficulty, due_date, last_review, state, source_id
+		SELECT `+cardColumns+`
 		FROM cards WHERE hash = ?
 	`, hash)
-
-	err := row.Scan(
-		&cs.Hash,
-		&cs.Question,
-		&cs.Answer,
-		&cs.Stability,
-		&cs.Difficulty,
-		&cs.DueDate,
-		&cs.LastReview,
-		&cs.State,
-		&cs.SourceID,
-	)
+	cs, err := scanCard(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Card not found
@@ -129,12 +315,55 @@ func (db *DB) UpdateCard(cs *Card) error {
 	return nil
 }
 
-// Source represents a card source, either a local path or a Git URL.
+// Source represents a card source: a local path, a Git URL or a file/gist URL.
 type Source struct {
 	ID          int64
 	Path        string
-	Type        string // 'local' or 'git'
+	Type        string // 'local', 'git' or 'url'
 	LastScanned sql.NullTime
+	Archived    bool // Archived sources are skipped by sync but keep their cards
+
+	// Options only relevant to git sources.
+	Submodules bool     // Recursively init and update submodules on sync
+	Mirrors    []string // Fallback URLs tried in order when Path is unreachable
+	// TrustedKeys are PGP and/or SSH public keys. When set, the synced HEAD commit
+	// must be signed by one of them before the source's cards are ingested.
+	TrustedKeys string
+
+	// The syntax of the source's Markdown cards, for notes shared with other
+	// tools; empty prefixes and separator are the defaults, Q:, A:, C: and ---.
+	QuestionPrefix string
+	AnswerPrefix   string
+	ContextPrefix  string
+	Separator      string
+	IgnoreCase     bool // Prefixes match in any case
+
+	// The files scanned for cards: the extensions of Markdown files, .md if
+	// empty, and the directories skipped, by name or glob from the source's root,
+	// node_modules, .git and build if empty.
+	Extensions []string
+	Exclude    []string
+}
+
+// sourceColumns lists the columns scanned by scanSource, in order.
+const sourceColumns = `id, path, type, last_scanned, archived, submodules, mirrors, trusted_keys, question_prefix, answer_prefix, context_prefix, separator, ignore_case, extensions, exclude`
+
+// scanSource scans a row selected with sourceColumns into a Source.
+func scanSource(row interface{ Scan(...any) error }) (Source, error) {
+	var s Source
+	var mirrors, extensions, exclude string
+	err := row.Scan(&s.ID, &s.Path, &s.Type, &s.LastScanned, &s.Archived, &s.Submodules, &mirrors, &s.TrustedKeys,
+		&s.QuestionPrefix, &s.AnswerPrefix, &s.ContextPrefix, &s.Separator, &s.IgnoreCase, &extensions, &exclude)
+	if mirrors != "" {
+		s.Mirrors = strings.Split(mirrors, "\n")
+	}
+	if extensions != "" {
+		s.Extensions = strings.Split(extensions, "\n")
+	}
+	if exclude != "" {
+		s.Exclude = strings.Split(exclude, "\n")
+	}
+	return s, err
 }
 
 // InsertSource inserts a new source path into the database and returns its ID.
@@ -142,7 +371,7 @@ func (db *DB) InsertSource(path, sourceType string) (int64, error) {
 	res, err := db.conn.Exec(`
 		INSERT INTO sources (path, type, last_scanned)
 		VALUES (?, ?, ?)
-	`, path, sourceType, time.Now())
+	`, path, sourceType, db.Now())
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert source %s: %w", path, err)
 	}
@@ -155,13 +384,12 @@ func (db *DB) InsertSource(path, sourceType string) (int64, error) {
 
 // FindSourceByPath retrieves a source from the database by its path.
 func (db *DB) FindSourceByPath(path string) (*Source, error) {
-	var s Source
 	row := db.conn.QueryRow(`
-		SELECT id, path, type, last_scanned
+		SELECT `+sourceColumns+`
 		FROM sources WHERE path = ?
 	`, path)
 
-	err := row.Scan(&s.ID, &s.Path, &s.Type, &s.LastScanned)
+	s, err := scanSource(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Source not found
@@ -171,10 +399,27 @@ func (db *DB) FindSourceByPath(path string) (*Source, error) {
 	return &s, nil
 }
 
+// FindSourceByID retrieves a source from the database by its ID.
+func (db *DB) FindSourceByID(id int64) (*Source, error) {
+	row := db.conn.QueryRow(`
+		SELECT `+sourceColumns+`
+		FROM sources WHERE id = ?
+	`, id)
+
+	s, err := scanSource(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Source not found
+		}
+		return nil, fmt.Errorf("failed to find source by ID %d: %w", id, err)
+	}
+	return &s, nil
+}
+
 // GetAllSources retrieves all stored sources from the database.
 func (db *DB) GetAllSources() ([]Source, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, path, type, last_scanned
+		SELECT ` + sourceColumns + `
 		FROM sources
 	`)
 	if err != nil {
@@ -184,8 +429,8 @@ func (db *DB) GetAllSources() ([]Source, error) {
 
 	var sources []Source
 	for rows.Next() {
-		var s Source
-		if err := rows.Scan(&s.ID, &s.Path, &s.Type, &s.LastScanned); err != nil {
+		s, err := scanSource(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan source row: %w", err)
 		}
 		sources = append(sources, s)
@@ -199,17 +444,84 @@ func (db *DB) UpdateSourceLastScanned(sourceID int64) error {
 		UPDATE sources
 		SET last_scanned = ?
 		WHERE id = ?
-	`, time.Now(), sourceID)
+	`, db.Now(), sourceID)
 	if err != nil {
 		return fmt.Errorf("failed to update last scanned for source ID %d: %w", sourceID, err)
 	}
 	return nil
 }
 
+// UpdateSourcePath changes the path and type of an existing source, keeping its ID
+// and therefore all of its cards.
+func (db *DB) UpdateSourcePath(sourceID int64, path, sourceType string) error {
+	_, err := db.conn.Exec(`
+		UPDATE sources
+		SET path = ?, type = ?
+		WHERE id = ?
+	`, path, sourceType, sourceID)
+	if err != nil {
+		return fmt.Errorf("failed to update path for source ID %d: %w", sourceID, err)
+	}
+	return nil
+}
+
+// UpdateSourceOptions persists the per-source sync options of a source.
+func (db *DB) UpdateSourceOptions(s *Source) error {
+	_, err := db.conn.Exec(`
+		UPDATE sources
+		SET submodules = ?, mirrors = ?, trusted_keys = ?
+		WHERE id = ?
+	`, s.Submodules, strings.Join(s.Mirrors, "\n"), s.TrustedKeys, s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update options for source ID %d: %w", s.ID, err)
+	}
+	return nil
+}
+
+// UpdateSourceSyntax persists the syntax of a source's Markdown cards.
+func (db *DB) UpdateSourceSyntax(s *Source) error {
+	_, err := db.conn.Exec(`
+		UPDATE sources
+		SET question_prefix = ?, answer_prefix = ?, context_prefix = ?, separator = ?, ignore_case = ?
+		WHERE id = ?
+	`, s.QuestionPrefix, s.AnswerPrefix, s.ContextPrefix, s.Separator, s.IgnoreCase, s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update syntax for source ID %d: %w", s.ID, err)
+	}
+	return nil
+}
+
+// UpdateSourceFiles persists which files of a source are scanned for cards.
+func (db *DB) UpdateSourceFiles(s *Source) error {
+	_, err := db.conn.Exec(`
+		UPDATE sources
+		SET extensions = ?, exclude = ?
+		WHERE id = ?
+	`, strings.Join(s.Extensions, "\n"), strings.Join(s.Exclude, "\n"), s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update files for source ID %d: %w", s.ID, err)
+	}
+	return nil
+}
+
+// SetSourceArchived archives or unarchives a source. Cards of archived sources
+// remain reviewable, but the source is no longer synced.
+func (db *DB) SetSourceArchived(sourceID int64, archived bool) error {
+	_, err := db.conn.Exec(`
+		UPDATE sources
+		SET archived = ?
+		WHERE id = ?
+	`, archived, sourceID)
+	if err != nil {
+		return fmt.Errorf("failed to set archived for source ID %d: %w", sourceID, err)
+	}
+	return nil
+}
+
 // GetCardsBySourceID retrieves all card states associated with a specific source ID.
 func (db *DB) GetCardsBySourceID(sourceID int64) ([]Card, error) {
 	rows, err := db.conn.Query(`
-		SELECT hash, question, answer, stability, difficulty, due_date, last_review, state, source_id
+		SELECT `+cardColumns+`
 		FROM cards WHERE source_id = ?
 	`, sourceID)
 	if err != nil {
@@ -219,18 +531,8 @@ func (db *DB) GetCardsBySourceID(sourceID int64) ([]Card, error) {
 
 	var cards []Card
 	for rows.Next() {
-		var cs Card
-		if err := rows.Scan(
-			&cs.Hash,
-			&cs.Question,
-			&cs.Answer,
-			&cs.Stability,
-			&cs.Difficulty,
-			&cs.DueDate,
-			&cs.LastReview,
-			&cs.State,
-			&cs.SourceID,
-		); err != nil {
+		cs, err := scanCard(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan card row for source ID %d: %w", sourceID, err)
 		}
 		cards = append(cards, cs)
@@ -238,6 +540,149 @@ func (db *DB) GetCardsBySourceID(sourceID int64) ([]Card, error) {
 	return cards, nil
 }
 
+// SetCardSuspended suspends or unsuspends a card.
+func (db *DB) SetCardSuspended(hash string, suspended bool) error {
+	_, err := db.conn.Exec(`UPDATE cards SET suspended = ? WHERE hash = ?`, suspended, hash)
+	if err != nil {
+		return fmt.Errorf("failed to set suspended for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// ResetCard returns a card to the new state, due now. Its review log is kept.
+func (db *DB) ResetCard(hash string) error {
+	_, err := db.conn.Exec(`
+		UPDATE cards SET stability = 0, difficulty = 0, due_date = ?, last_review = NULL, state = 0
+		WHERE hash = ?
+	`, db.Now(), hash)
+	if err != nil {
+		return fmt.Errorf("failed to reset card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// SetCardDueDate makes a card due at the given time, keeping its stability and
+// difficulty, and records the change in the review log as a manual entry, so
+// that it isn't taken for a review. It reports false if there is no such card.
+func (db *DB) SetCardDueDate(hash string, due time.Time) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stability, difficulty float64
+	err = tx.QueryRow(`SELECT stability, difficulty FROM cards WHERE hash = ?`, hash).Scan(&stability, &difficulty)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get card %s: %w", hash, err)
+	}
+	if _, err := tx.Exec(`UPDATE cards SET due_date = ? WHERE hash = ?`, due, hash); err != nil {
+		return false, fmt.Errorf("failed to set due date for card %s: %w", hash, err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO review_logs (card_hash, reviewed_at, grade, stability_before, difficulty_before, stability_after, difficulty_after, due_date_after, manual)
+		VALUES (?, ?, 0, ?, ?, ?, ?, ?, 1)
+	`, hash, db.Now(), stability, difficulty, stability, difficulty, due)
+	if err != nil {
+		return false, fmt.Errorf("failed to record due date change for card %s: %w", hash, err)
+	}
+	return true, tx.Commit()
+}
+
+// UpdateCardContent sets the question, answer, kind, options, steps and cloze
+// number of an existing card, which only change under the same hash for cards
+// with an ID pinned by their author.
+func (db *DB) UpdateCardContent(card domain.Card) error {
+	_, err := db.conn.Exec(`
+		UPDATE cards
+		SET question = ?, answer = ?, kind = ?, distractors = ?, steps = ?, cloze = ?
+		WHERE hash = ?
+	`, card.Question, card.Answer, card.Kind, strings.Join(card.Distractors, "\n"), strings.Join(card.Steps, "\n"), card.Cloze, card.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to update content for card %s: %w", card.Hash, err)
+	}
+	return nil
+}
+
+// RenameCard changes the hash of a card, with its review history, e.g. to the
+// ID its author pinned it to.
+func (db *DB) RenameCard(oldHash, newHash string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin renaming card %s: %w", oldHash, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE cards SET hash = ? WHERE hash = ?`, newHash, oldHash); err != nil {
+		return fmt.Errorf("failed to rename card %s: %w", oldHash, err)
+	}
+	if _, err := tx.Exec(`UPDATE review_logs SET card_hash = ? WHERE card_hash = ?`, newHash, oldHash); err != nil {
+		return fmt.Errorf("failed to rename the reviews of card %s: %w", oldHash, err)
+	}
+	return tx.Commit()
+}
+
+// UpdateCardContext sets the context of an existing card.
+func (db *DB) UpdateCardContext(hash, context string) error {
+	_, err := db.conn.Exec(`UPDATE cards SET context = ? WHERE hash = ?`, strings.TrimSpace(context), hash)
+	if err != nil {
+		return fmt.Errorf("failed to update context for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// UpdateCardHint sets the hint of an existing card.
+func (db *DB) UpdateCardHint(hash, hint string) error {
+	_, err := db.conn.Exec(`UPDATE cards SET hint = ? WHERE hash = ?`, strings.TrimSpace(hint), hash)
+	if err != nil {
+		return fmt.Errorf("failed to update hint for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// UpdateCardTags sets the tags of an existing card.
+func (db *DB) UpdateCardTags(hash string, tags []string) error {
+	_, err := db.conn.Exec(`UPDATE cards SET tags = ? WHERE hash = ?`, strings.Join(tags, "\n"), hash)
+	if err != nil {
+		return fmt.Errorf("failed to update tags for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// UpdateCardMedia sets the paths of the images an existing card shows.
+func (db *DB) UpdateCardMedia(hash string, media []string) error {
+	_, err := db.conn.Exec(`UPDATE cards SET media = ? WHERE hash = ?`, strings.Join(media, "\n"), hash)
+	if err != nil {
+		return fmt.Errorf("failed to update media for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// UpdateCardFile records the file a card was found in, the line it starts on and
+// when the file last changed.
+func (db *DB) UpdateCardFile(hash, file string, line int, modified time.Time) error {
+	_, err := db.conn.Exec(`UPDATE cards SET file = ?, line = ?, file_modified = ? WHERE hash = ?`, file, line, modified, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update file for card %s: %w", hash, err)
+	}
+	return nil
+}
+
+// UpdateCardSource links an existing card to a different source.
+func (db *DB) UpdateCardSource(hash string, sourceID int64) error {
+	_, err := db.conn.Exec(`
+		UPDATE cards
+		SET source_id = ?
+		WHERE hash = ?
+	`, sourceID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update source for card %s: %w", hash, err)
+	}
+	return nil
+}
+
 // DeleteCardByHash removes a card from the database by its hash.
 func (db *DB) DeleteCardByHash(hash string) error {
 	_, err := db.conn.Exec(`
@@ -250,14 +695,14 @@ func (db *DB) DeleteCardByHash(hash string) error {
 	return nil
 }
 
-// GetDueCards retrieves all cards that are due for review, sorted by due date.
+// GetDueCards retrieves all unsuspended cards that are due for review, sorted by due date.
 func (db *DB) GetDueCards() ([]Card, error) {
 	rows, err := db.conn.Query(`
-		SELECT hash, question, answer, stability, difficulty, due_date, last_review, state, source_id
+		SELECT `+cardColumns+`
 		FROM cards
-		WHERE due_date <= ?
+		WHERE due_date <= ? AND suspended = 0
 		ORDER BY due_date ASC
-	`, time.Now())
+	`, db.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get due cards: %w", err)
 	}
@@ -265,18 +710,8 @@ func (db *DB) GetDueCards() ([]Card, error) {
 
 	var cards []Card
 	for rows.Next() {
-		var cs Card
-		if err := rows.Scan(
-			&cs.Hash,
-			&cs.Question,
-			&cs.Answer,
-			&cs.Stability,
-			&cs.Difficulty,
-			&cs.DueDate,
-			&cs.LastReview,
-			&cs.State,
-			&cs.SourceID,
-		); err != nil {
+		cs, err := scanCard(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan due card row: %w", err)
 		}
 		cards = append(cards, cs)
@@ -284,18 +719,159 @@ func (db *DB) GetDueCards() ([]Card, error) {
 	return cards, nil
 }
 
-// DeleteSource deletes a source and all its associated cards from the database.
-func (db *DB) DeleteSource(id int64) error {
+// GetCardsByContextNotReviewedSince retrieves the unsuspended cards with the given
+// context that have not been reviewed since the given time, sorted by due date.
+func (db *DB) GetCardsByContextNotReviewedSince(context string, since time.Time) ([]Card, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE context = ? AND suspended = 0 AND (last_review IS NULL OR last_review < ?)
+		ORDER BY due_date ASC
+	`, context, since.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards for context %q: %w", context, err)
+	}
+	defer rows.Close()
+
+	var cards []Card
+	for rows.Next() {
+		cs, err := scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan card row for context %q: %w", context, err)
+		}
+		cards = append(cards, cs)
+	}
+	return cards, rows.Err()
+}
+
+// GetCardsByFile retrieves the cards found in a file, by its path from the root of
+// its source, in the order they appear.
+func (db *DB) GetCardsByFile(file string) ([]Card, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+cardColumns+`
+		FROM cards
+		WHERE file = ?
+		ORDER BY source_id, line
+	`, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards of file %s: %w", file, err)
+	}
+	defer rows.Close()
+
+	var cards []Card
+	for rows.Next() {
+		cs, err := scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan card row of file %s: %w", file, err)
+		}
+		cards = append(cards, cs)
+	}
+	return cards, rows.Err()
+}
+
+// GetDueDatesBefore retrieves the due dates of all unsuspended cards due before the given time, earliest first.
+func (db *DB) GetDueDatesBefore(until time.Time) ([]time.Time, error) {
+	rows, err := db.conn.Query(`
+		SELECT due_date
+		FROM cards
+		WHERE due_date < ? AND suspended = 0
+		ORDER BY due_date ASC
+	`, until.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due dates: %w", err)
+	}
+	defer rows.Close()
+
+	var dates []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan due date: %w", err)
+		}
+		dates = append(dates, t)
+	}
+	return dates, rows.Err()
+}
+
+// GetNextDueDate retrieves the earliest due date after the given time among
+// unsuspended cards, or the zero time if no card falls due after it.
+func (db *DB) GetNextDueDate(after time.Time) (time.Time, error) {
+	var t time.Time
+	err := db.conn.QueryRow(`
+		SELECT due_date
+		FROM cards
+		WHERE due_date > ? AND suspended = 0
+		ORDER BY due_date ASC
+		LIMIT 1
+	`, after.Local()).Scan(&t)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("failed to get next due date: %w", err)
+	}
+	return t, nil
+}
+
+// SourceContents counts what deleting a source removes along with it.
+type SourceContents struct {
+	Cards   int
+	Reviews int // Review log entries of those cards
+}
+
+// GetSourceContents counts the cards of a source and their reviews.
+func (db *DB) GetSourceContents(id int64) (SourceContents, error) {
+	var sc SourceContents
+	err := db.conn.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM cards WHERE source_id = ?),
+			(SELECT COUNT(*) FROM review_logs WHERE manual = 0 AND card_hash IN (SELECT hash FROM cards WHERE source_id = ?))
+	`, id, id).Scan(&sc.Cards, &sc.Reviews)
+	if err != nil {
+		return sc, fmt.Errorf("failed to count contents of source %d: %w", id, err)
+	}
+	return sc, nil
+}
+
+// DeleteSource deletes a source from the database, along with all its cards
+// and their review history unless keepCards is set. Kept cards are orphaned:
+// they stay reviewable without a source, and are linked to a new one if a
+// source with them is added again.
+func (db *DB) DeleteSource(id int64, keepCards bool) error {
 	tx, err := db.conn.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback() // Rollback on error or if not committed
 
-	// Delete associated cards first
-	_, err = tx.Exec(`DELETE FROM cards WHERE source_id = ?`, id)
+	if keepCards {
+		_, err = tx.Exec(`UPDATE cards SET source_id = NULL WHERE source_id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("failed to orphan cards of source %d: %w", id, err)
+		}
+	} else {
+		// Delete the review history of its cards, then the cards themselves
+		_, err = tx.Exec(`DELETE FROM review_logs WHERE card_hash IN (SELECT hash FROM cards WHERE source_id = ?)`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete review logs for source %d: %w", id, err)
+		}
+
+		_, err = tx.Exec(`DELETE FROM cards WHERE source_id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete cards for source %d: %w", id, err)
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM source_syncs WHERE source_id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete sync history for source %d: %w", id, err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM parse_issues WHERE source_id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete parse issues of source %d: %w", id, err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM decks WHERE source_id = ?`, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete cards for source %d: %w", id, err)
+		return fmt.Errorf("failed to delete deck of source %d: %w", id, err)
 	}
 
 	// Delete the source itself
@@ -312,6 +888,7 @@ type CardWithSource struct {
 	Hash       string
 	Question   string
 	Answer     string
+	Context    string
 	Stability  float64
 	Difficulty float64
 	DueDate    time.Time
@@ -319,18 +896,45 @@ type CardWithSource struct {
 	State      int
 	SourceID   sql.NullInt64
 	SourcePath sql.NullString
+	Suspended  bool
+	Kind       string
+	Cloze      int
+	// ReadOnly is set for cards of archived sources, which are no longer synced.
+	ReadOnly bool
+	// File is the path of the file the card was last found in, from the source's root.
+	File         string
+	FileModified sql.NullTime
 }
 
 // GetAllCardsSortedByDueDate retrieves all cards from the database, sorted by due date.
 func (db *DB) GetAllCardsSortedByDueDate() ([]CardWithSource, error) {
+	return db.queryCardsWithSource(`ORDER BY c.due_date ASC`)
+}
+
+// GetHardestCards retrieves up to limit reviewed cards with the highest difficulty, hardest first.
+func (db *DB) GetHardestCards(limit int) ([]CardWithSource, error) {
+	return db.queryCardsWithSource(`WHERE c.stability > 0 ORDER BY c.difficulty DESC, c.due_date ASC LIMIT ?`, limit)
+}
+
+// GetStaleCards retrieves reviewed cards at least as difficult as minDifficulty whose
+// file last changed before the given time, the longest unchanged first.
+func (db *DB) GetStaleCards(changedBefore time.Time, minDifficulty float64) ([]CardWithSource, error) {
+	return db.queryCardsWithSource(`
+		WHERE c.stability > 0 AND c.difficulty >= ? AND c.file_modified < ?
+		ORDER BY c.file_modified ASC, c.difficulty DESC
+	`, minDifficulty, changedBefore.Local())
+}
+
+// queryCardsWithSource selects cards joined with their source, filtered and
+// ordered by the given clauses on the cards aliased c.
+func (db *DB) queryCardsWithSource(clauses string, args ...any) ([]CardWithSource, error) {
 	rows, err := db.conn.Query(`
-		SELECT c.hash, c.question, c.answer, c.stability, c.difficulty, c.due_date, c.last_review, c.state, c.source_id, s.path
+		SELECT c.hash, c.question, c.answer, c.context, c.stability, c.difficulty, c.due_date, c.last_review, c.state, c.source_id, c.suspended, c.kind, c.cloze, s.path, COALESCE(s.archived, 0), c.file, c.file_modified
 		FROM cards c
 		LEFT JOIN sources s ON c.source_id = s.id
-		ORDER BY c.due_date ASC
-	`)
+		`+clauses, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all cards sorted by due date: %w", err)
+		return nil, fmt.Errorf("failed to get cards: %w", err)
 	}
 	defer rows.Close()
 
@@ -341,17 +945,24 @@ func (db *DB) GetAllCardsSortedByDueDate() ([]CardWithSource, error) {
 			&cs.Hash,
 			&cs.Question,
 			&cs.Answer,
+			&cs.Context,
 			&cs.Stability,
 			&cs.Difficulty,
 			&cs.DueDate,
 			&cs.LastReview,
 			&cs.State,
 			&cs.SourceID,
+			&cs.Suspended,
+			&cs.Kind,
+			&cs.Cloze,
 			&cs.SourcePath,
+			&cs.ReadOnly,
+			&cs.File,
+			&cs.FileModified,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan card row: %w", err)
 		}
 		cards = append(cards, cs)
 	}
-	return cards, nil
+	return cards, rows.Err()
 }
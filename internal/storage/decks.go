@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Deck is a source installed as a shared deck.
+type Deck struct {
+	SourceID    int64
+	Name        string
+	Origin      string // The bundle or git URL it was installed from
+	InstalledAt time.Time
+}
+
+// RecordDeck records a source as an installed deck, replacing an earlier install.
+func (db *DB) RecordDeck(d Deck) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO decks (source_id, name, origin, installed_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(source_id) DO UPDATE SET name = excluded.name, origin = excluded.origin, installed_at = excluded.installed_at
+	`, d.SourceID, d.Name, d.Origin, d.InstalledAt)
+	if err != nil {
+		return fmt.Errorf("failed to record deck %s: %w", d.Name, err)
+	}
+	return nil
+}
+
+// GetDecks retrieves the installed decks by source ID.
+func (db *DB) GetDecks() (map[int64]Deck, error) {
+	rows, err := db.conn.Query(`SELECT source_id, name, origin, installed_at FROM decks`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get decks: %w", err)
+	}
+	defer rows.Close()
+
+	decks := make(map[int64]Deck)
+	for rows.Next() {
+		var d Deck
+		if err := rows.Scan(&d.SourceID, &d.Name, &d.Origin, &d.InstalledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deck row: %w", err)
+		}
+		decks[d.SourceID] = d
+	}
+	return decks, rows.Err()
+}
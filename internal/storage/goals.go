@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordGoalCompleted records that a goal was met in the study day or week starting
+// at periodStart. Recording the same period again keeps the first completion.
+func (db *DB) RecordGoalCompleted(goal string, periodStart, completedAt time.Time) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO goal_completions (goal, period_start, completed_at) VALUES (?, ?, ?)
+		ON CONFLICT(goal, period_start) DO NOTHING
+	`, goal, periodStart.Local(), completedAt.Local())
+	if err != nil {
+		return fmt.Errorf("failed to record completion of goal %s: %w", goal, err)
+	}
+	return nil
+}
+
+// CountGoalCompletionsSince counts, per goal, the periods starting at or after since
+// in which the goal was met.
+func (db *DB) CountGoalCompletionsSince(since time.Time) (map[string]int, error) {
+	rows, err := db.conn.Query(`
+		SELECT goal, COUNT(*) FROM goal_completions
+		WHERE period_start >= ?
+		GROUP BY goal
+	`, since.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count goal completions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var goal string
+		var n int
+		if err := rows.Scan(&goal, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan goal completion row: %w", err)
+		}
+		counts[goal] = n
+	}
+	return counts, rows.Err()
+}
+
+// RecordAchievement records that the badge with the given ID was earned at
+// earnedAt. Recording an earned badge again keeps the first time.
+func (db *DB) RecordAchievement(id string, earnedAt time.Time) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO achievements (id, earned_at) VALUES (?, ?)
+		ON CONFLICT(id) DO NOTHING
+	`, id, earnedAt.Local())
+	if err != nil {
+		return fmt.Errorf("failed to record achievement %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetAchievements retrieves the time every earned badge was earned, by badge ID.
+func (db *DB) GetAchievements() (map[string]time.Time, error) {
+	rows, err := db.conn.Query(`SELECT id, earned_at FROM achievements`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	}
+	defer rows.Close()
+
+	earned := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, fmt.Errorf("failed to scan achievement row: %w", err)
+		}
+		earned[id] = t
+	}
+	return earned, rows.Err()
+}
@@ -0,0 +1,66 @@
+package storage
+
+import "fmt"
+
+// Severities of a ParseIssue.
+const (
+	SeverityWarning = "warning" // An entry was skipped or looks unfinished
+	SeverityError   = "error"   // More than an entry was lost, e.g. a whole file
+)
+
+// ParseIssue is a warning or error found parsing a file of a source.
+type ParseIssue struct {
+	File     string // Slash-separated path from the source's root
+	Line     int    // 0 for the file as a whole
+	Severity string // SeverityWarning or SeverityError
+	Message  string
+}
+
+// ReplaceParseIssues replaces the parse issues recorded for a source with those
+// of its latest reconciliation.
+func (db *DB) ReplaceParseIssues(sourceID int64, issues []ParseIssue) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM parse_issues WHERE source_id = ?`, sourceID); err != nil {
+		return fmt.Errorf("failed to clear parse issues of source %d: %w", sourceID, err)
+	}
+	for _, issue := range issues {
+		_, err := tx.Exec(`
+			INSERT INTO parse_issues (source_id, file, line, severity, message)
+			VALUES (?, ?, ?, ?, ?)
+		`, sourceID, issue.File, issue.Line, issue.Severity, issue.Message)
+		if err != nil {
+			return fmt.Errorf("failed to insert parse issue of source %d: %w", sourceID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetParseIssues retrieves the parse issues of a source, errors first, then by
+// file and line.
+func (db *DB) GetParseIssues(sourceID int64) ([]ParseIssue, error) {
+	rows, err := db.conn.Query(`
+		SELECT file, line, severity, message
+		FROM parse_issues
+		WHERE source_id = ?
+		ORDER BY severity = 'warning', file, line, id
+	`, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parse issues of source %d: %w", sourceID, err)
+	}
+	defer rows.Close()
+
+	var issues []ParseIssue
+	for rows.Next() {
+		var issue ParseIssue
+		if err := rows.Scan(&issue.File, &issue.Line, &issue.Severity, &issue.Message); err != nil {
+			return nil, fmt.Errorf("failed to scan parse issue: %w", err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
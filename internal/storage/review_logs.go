@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ReviewLog records a single review of a card and its scheduling state before and after it.
+type ReviewLog struct {
+	CardHash         string
+	ReviewedAt       time.Time
+	Grade            int // 1: Again, 2: Hard, 3: Good, 4: Easy
+	StabilityBefore  float64
+	DifficultyBefore float64
+	StabilityAfter   float64
+	DifficultyAfter  float64
+	DueDateAfter     time.Time
+	Duration         time.Duration // Time taken to answer; 0 when unknown
+	Correct          sql.NullBool  // Whether the chosen option was right, for multiple choice cards
+	Hinted           bool          // Whether the hint was shown before grading
+	// Manual marks a due date set by hand, with a Grade of 0, rather than a
+	// review. Manual entries leave the card's stability and difficulty as they
+	// were and count as no review in the stats.
+	Manual bool
+}
+
+// InsertReviewLog records a review.
+func (db *DB) InsertReviewLog(log ReviewLog) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO review_logs (card_hash, reviewed_at, grade, stability_before, difficulty_before, stability_after, difficulty_after, due_date_after, duration_ms, correct, hinted, manual)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		log.CardHash,
+		log.ReviewedAt,
+		log.Grade,
+		log.StabilityBefore,
+		log.DifficultyBefore,
+		log.StabilityAfter,
+		log.DifficultyAfter,
+		log.DueDateAfter,
+		log.Duration.Milliseconds(),
+		log.Correct,
+		log.Hinted,
+		log.Manual,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert review log for card %s: %w", log.CardHash, err)
+	}
+	return nil
+}
+
+// ResetReviews returns every card to the new state, due now, and deletes the review
+// log with the goal completions and achievements recorded from it.
+func (db *DB) ResetReviews() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE cards SET stability = 0, difficulty = 0, due_date = ?, last_review = NULL, state = 0
+	`, db.Now())
+	if err != nil {
+		return fmt.Errorf("failed to reset cards: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM review_logs`); err != nil {
+		return fmt.Errorf("failed to delete review logs: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM goal_completions`); err != nil {
+		return fmt.Errorf("failed to delete goal completions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM achievements`); err != nil {
+		return fmt.Errorf("failed to delete achievements: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetAllReviewLogs retrieves the whole review log, oldest first, with the manual
+// due date changes.
+func (db *DB) GetAllReviewLogs() ([]ReviewLog, error) {
+	return db.queryReviewLogs(`SELECT ` + reviewLogColumns + ` FROM review_logs ORDER BY reviewed_at ASC, id ASC`)
+}
+
+// GetReviewLogsBetween retrieves the reviews in [from, to), oldest first,
+// without the manual due date changes.
+func (db *DB) GetReviewLogsBetween(from, to time.Time) ([]ReviewLog, error) {
+	return db.queryReviewLogs(`
+		SELECT `+reviewLogColumns+`
+		FROM review_logs
+		WHERE reviewed_at >= ? AND reviewed_at < ? AND manual = 0
+		ORDER BY reviewed_at ASC, id ASC
+	`, from.Local(), to.Local())
+}
+
+// GetReviewLogsByCard retrieves the reviews of a card, oldest first, with its
+// manual due date changes.
+func (db *DB) GetReviewLogsByCard(hash string) ([]ReviewLog, error) {
+	return db.queryReviewLogs(`
+		SELECT `+reviewLogColumns+`
+		FROM review_logs
+		WHERE card_hash = ?
+		ORDER BY reviewed_at ASC, id ASC
+	`, hash)
+}
+
+// reviewLogColumns are the columns scanned by queryReviewLogs, in order.
+const reviewLogColumns = `card_hash, reviewed_at, grade, stability_before, difficulty_before, stability_after, difficulty_after, due_date_after, duration_ms, correct, hinted, manual`
+
+// queryReviewLogs runs a query selecting reviewLogColumns and scans the reviews.
+func (db *DB) queryReviewLogs(query string, args ...any) ([]ReviewLog, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []ReviewLog
+	for rows.Next() {
+		var log ReviewLog
+		var durationMS int64
+		if err := rows.Scan(
+			&log.CardHash,
+			&log.ReviewedAt,
+			&log.Grade,
+			&log.StabilityBefore,
+			&log.DifficultyBefore,
+			&log.StabilityAfter,
+			&log.DifficultyAfter,
+			&log.DueDateAfter,
+			&durationMS,
+			&log.Correct,
+			&log.Hinted,
+			&log.Manual,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan review log row: %w", err)
+		}
+		log.Duration = time.Duration(durationMS) * time.Millisecond
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+// GetReviewTimesSince retrieves the time of every review since the given time, oldest first.
+func (db *DB) GetReviewTimesSince(since time.Time) ([]time.Time, error) {
+	rows, err := db.conn.Query(`
+		SELECT reviewed_at
+		FROM review_logs
+		WHERE reviewed_at >= ? AND manual = 0
+		ORDER BY reviewed_at ASC
+	`, since.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review times: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan review time: %w", err)
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// CountReviewsSince counts the reviews since the given time, separating first reviews
+// of new cards from reviews of cards already being learned.
+func (db *DB) CountReviewsSince(since time.Time) (newCards, reviews int, err error) {
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN stability_before = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN stability_before > 0 THEN 1 ELSE 0 END), 0)
+		FROM review_logs
+		WHERE reviewed_at >= ? AND manual = 0
+	`, since.Local()).Scan(&newCards, &reviews)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count reviews: %w", err)
+	}
+	return newCards, reviews, nil
+}
+
+// CountLapses counts the times a card was forgotten: graded Again after it was
+// first learned.
+func (db *DB) CountLapses(hash string) (int, error) {
+	var lapses int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM review_logs
+		WHERE card_hash = ? AND grade = 1 AND stability_before > 0
+	`, hash).Scan(&lapses)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count lapses: %w", err)
+	}
+	return lapses, nil
+}
+
+// CountReviewsOfHintedCards counts the reviews of cards that have a hint, split by
+// whether the hint was shown, and how many of each were not graded Again.
+func (db *DB) CountReviewsOfHintedCards() (shown, shownRecalled, notShown, notShownRecalled int, err error) {
+	rows, err := db.conn.Query(`
+		SELECT r.hinted, COUNT(*), COALESCE(SUM(CASE WHEN r.grade > 1 THEN 1 ELSE 0 END), 0)
+		FROM review_logs r
+		JOIN cards c ON c.hash = r.card_hash
+		WHERE c.hint != '' AND r.manual = 0
+		GROUP BY r.hinted
+	`)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("failed to count reviews of hinted cards: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hinted bool
+		var reviews, recalled int
+		if err := rows.Scan(&hinted, &reviews, &recalled); err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("failed to scan hinted review count: %w", err)
+		}
+		if hinted {
+			shown, shownRecalled = reviews, recalled
+		} else {
+			notShown, notShownRecalled = reviews, recalled
+		}
+	}
+	return shown, shownRecalled, notShown, notShownRecalled, rows.Err()
+}
@@ -16,11 +16,140 @@ CREATE TABLE IF NOT EXISTS cards (
     FOREIGN KEY(source_id) REFERENCES sources(id)
 );
 
--- The 'sources' table tracks the origin of the cards, either a local directory or a git repository.
+-- The 'sources' table tracks the origin of the cards: a local directory, a git repository or a file/gist URL.
 CREATE TABLE IF NOT EXISTS sources (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     path TEXT NOT NULL UNIQUE,
-    type TEXT NOT NULL, -- 'local' or 'git'
+    type TEXT NOT NULL, -- 'local', 'git' or 'url'
     last_scanned DATETIME
 );
+
+-- The 'source_syncs' table records the card delta of every reconciliation run per source.
+CREATE TABLE IF NOT EXISTS source_syncs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER NOT NULL,
+    synced_at DATETIME NOT NULL,
+    cards_added INTEGER NOT NULL,
+    cards_removed INTEGER NOT NULL,
+    total_cards INTEGER NOT NULL,
+
+    FOREIGN KEY(source_id) REFERENCES sources(id)
+);
+
+-- The 'parse_issues' table holds the warnings and errors found parsing the
+-- files of each source in its last reconciliation, replaced by every run.
+CREATE TABLE IF NOT EXISTS parse_issues (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER NOT NULL,
+    file TEXT NOT NULL, -- Slash-separated path from the source's root
+    line INTEGER NOT NULL, -- 0 for the file as a whole
+    severity TEXT NOT NULL, -- 'warning' or 'error'
+    message TEXT NOT NULL,
+
+    FOREIGN KEY(source_id) REFERENCES sources(id)
+);
+
+-- The 'review_logs' table records every review with the card's scheduling state before and after it.
+CREATE TABLE IF NOT EXISTS review_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    card_hash TEXT NOT NULL,
+    reviewed_at DATETIME NOT NULL,
+    grade INTEGER NOT NULL, -- 1: Again, 2: Hard, 3: Good, 4: Easy
+    stability_before REAL NOT NULL,
+    difficulty_before REAL NOT NULL,
+    stability_after REAL NOT NULL,
+    difficulty_after REAL NOT NULL,
+    due_date_after DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_review_logs_reviewed_at ON review_logs(reviewed_at);
+CREATE INDEX IF NOT EXISTS idx_review_logs_card_hash ON review_logs(card_hash);
+
+-- The 'goal_completions' table records every study day or week in which a goal was met.
+CREATE TABLE IF NOT EXISTS goal_completions (
+    goal TEXT NOT NULL, -- 'reviews', 'new_cards' or 'minutes'
+    period_start DATETIME NOT NULL, -- Start of the study day or week
+    completed_at DATETIME NOT NULL,
+
+    PRIMARY KEY (goal, period_start)
+);
+
+-- The 'achievements' table records the badges earned and when. They are kept when
+-- the reviews that earned them are deleted with their source.
+CREATE TABLE IF NOT EXISTS achievements (
+    id TEXT PRIMARY KEY,
+    earned_at DATETIME NOT NULL
+);
+
+-- The 'decks' table tracks the sources installed as shared decks, from a .knol
+-- bundle or a curated git repository, apart from personal notes.
+CREATE TABLE IF NOT EXISTS decks (
+    source_id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    origin TEXT NOT NULL, -- The bundle or git URL the deck was installed from
+    installed_at DATETIME NOT NULL,
+
+    FOREIGN KEY(source_id) REFERENCES sources(id)
+);
+
+-- The 'settings' table holds instance-wide preferences edited on the settings page.
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
 `
+
+// migrations alter tables created by older versions of the schema above.
+// They are applied in order and the number applied is tracked in PRAGMA user_version,
+// so new entries must only ever be appended.
+var migrations = []string{
+	// 1: Archived sources are kept (with their cards) but no longer synced.
+	`ALTER TABLE sources ADD COLUMN archived INTEGER NOT NULL DEFAULT 0`,
+	// 2: Git sources can opt in to recursively updating their submodules.
+	`ALTER TABLE sources ADD COLUMN submodules INTEGER NOT NULL DEFAULT 0`,
+	// 3: Newline-separated fallback URLs tried when a git source's primary URL fails.
+	`ALTER TABLE sources ADD COLUMN mirrors TEXT NOT NULL DEFAULT ''`,
+	// 4: PGP/SSH public keys that must have signed HEAD before a git source's cards are ingested.
+	`ALTER TABLE sources ADD COLUMN trusted_keys TEXT NOT NULL DEFAULT ''`,
+	// 5: Milliseconds from showing a card's question to grading it; 0 when unknown.
+	`ALTER TABLE review_logs ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0`,
+	// 6: The card's C: line, its topic; filled in for existing cards on their next sync.
+	`ALTER TABLE cards ADD COLUMN context TEXT NOT NULL DEFAULT ''`,
+	// 7: Suspended cards are kept with their scheduling state but never due.
+	`ALTER TABLE cards ADD COLUMN suspended INTEGER NOT NULL DEFAULT 0`,
+	// 8: The kind of card: '' for question and answer cards, 'writing', 'choice', 'steps', 'cloze', 'text-cloze' or 'numbered'.
+	`ALTER TABLE cards ADD COLUMN kind TEXT NOT NULL DEFAULT ''`,
+	// 9: Newline-separated wrong options of a multiple choice card.
+	`ALTER TABLE cards ADD COLUMN distractors TEXT NOT NULL DEFAULT ''`,
+	// 10: Whether the option chosen in a review of a multiple choice card was right; NULL for other cards.
+	`ALTER TABLE review_logs ADD COLUMN correct INTEGER`,
+	// 11: Newline-separated steps of a steps card, in order.
+	`ALTER TABLE cards ADD COLUMN steps TEXT NOT NULL DEFAULT ''`,
+	// 12: The card's H: line, shown on request before the answer; not part of the hash.
+	`ALTER TABLE cards ADD COLUMN hint TEXT NOT NULL DEFAULT ''`,
+	// 13: Whether the hint was shown before the card was graded.
+	`ALTER TABLE review_logs ADD COLUMN hinted INTEGER NOT NULL DEFAULT 0`,
+	// 14: Slash-separated path of the file the card was last found in, from the source's root.
+	`ALTER TABLE cards ADD COLUMN file TEXT NOT NULL DEFAULT ''`,
+	// 15: When that file last changed: its last commit for git sources, else its modification time.
+	`ALTER TABLE cards ADD COLUMN file_modified DATETIME`,
+	// 16: The line of that file the card starts on, counting from 1; 0 until the next sync.
+	`ALTER TABLE cards ADD COLUMN line INTEGER NOT NULL DEFAULT 0`,
+	// 17: The number of the deletions a text cloze card blanks out; 0 for other cards.
+	`ALTER TABLE cards ADD COLUMN cloze INTEGER NOT NULL DEFAULT 0`,
+	// 18: Newline-separated tags of the card; not part of the hash.
+	`ALTER TABLE cards ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+	// 19: Newline-separated paths of the images a card shows, from its source's root.
+	`ALTER TABLE cards ADD COLUMN media TEXT NOT NULL DEFAULT ''`,
+	// 20: Whether the entry is a due date set by hand rather than a review; its grade is 0.
+	`ALTER TABLE review_logs ADD COLUMN manual INTEGER NOT NULL DEFAULT 0`,
+	// 21-25: The syntax of a source's Markdown cards; empty for the defaults, Q:, A:, C: and ---.
+	`ALTER TABLE sources ADD COLUMN question_prefix TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sources ADD COLUMN answer_prefix TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sources ADD COLUMN context_prefix TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sources ADD COLUMN separator TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sources ADD COLUMN ignore_case INTEGER NOT NULL DEFAULT 0`,
+	// 26-27: Newline-separated extensions of a source's Markdown files and directories
+	// skipped; empty for the defaults.
+	`ALTER TABLE sources ADD COLUMN extensions TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sources ADD COLUMN exclude TEXT NOT NULL DEFAULT ''`,
+}
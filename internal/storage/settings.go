@@ -0,0 +1,42 @@
+package storage
+
+import "fmt"
+
+// GetSettings retrieves all stored settings as a map of key to value.
+func (db *DB) GetSettings() (map[string]string, error) {
+	rows, err := db.conn.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting row: %w", err)
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+// UpdateSettings inserts or replaces the given settings, leaving other settings untouched.
+func (db *DB) UpdateSettings(settings map[string]string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // Rollback on error or if not committed
+
+	for key, value := range settings {
+		_, err := tx.Exec(`
+			INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to update setting %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
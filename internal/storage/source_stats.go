@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// SourceSync records the card delta produced by a single reconciliation of a source.
+type SourceSync struct {
+	SourceID     int64
+	SyncedAt     time.Time
+	CardsAdded   int
+	CardsRemoved int
+	TotalCards   int
+}
+
+// WeeklySourceStats aggregates the sync deltas of a source over one week.
+type WeeklySourceStats struct {
+	WeekStart    time.Time
+	CardsAdded   int
+	CardsRemoved int
+}
+
+// InsertSourceSync records the outcome of a reconciliation run for a source.
+func (db *DB) InsertSourceSync(sync SourceSync) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO source_syncs (source_id, synced_at, cards_added, cards_removed, total_cards)
+		VALUES (?, ?, ?, ?, ?)
+	`, sync.SourceID, sync.SyncedAt, sync.CardsAdded, sync.CardsRemoved, sync.TotalCards)
+	if err != nil {
+		return fmt.Errorf("failed to insert sync record for source ID %d: %w", sync.SourceID, err)
+	}
+	return nil
+}
+
+// GetSourceSyncsSince retrieves the sync records of a source since the given time, oldest first.
+func (db *DB) GetSourceSyncsSince(sourceID int64, since time.Time) ([]SourceSync, error) {
+	rows, err := db.conn.Query(`
+		SELECT source_id, synced_at, cards_added, cards_removed, total_cards
+		FROM source_syncs
+		WHERE source_id = ? AND synced_at >= ?
+		ORDER BY synced_at ASC
+	`, sourceID, since.Local())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync records for source ID %d: %w", sourceID, err)
+	}
+	defer rows.Close()
+
+	var syncs []SourceSync
+	for rows.Next() {
+		var s SourceSync
+		if err := rows.Scan(&s.SourceID, &s.SyncedAt, &s.CardsAdded, &s.CardsRemoved, &s.TotalCards); err != nil {
+			return nil, fmt.Errorf("failed to scan sync record for source ID %d: %w", sourceID, err)
+		}
+		syncs = append(syncs, s)
+	}
+	return syncs, nil
+}
+
+// GetWeeklySourceStats buckets the sync deltas of a source into the n weeks up to now,
+// oldest week first. Weeks start on Monday in now's location. Weeks without any
+// syncs are included with zero counts.
+func (db *DB) GetWeeklySourceStats(sourceID int64, weeks int, now time.Time) ([]WeeklySourceStats, error) {
+	currentWeek := startOfWeek(now, now.Location())
+	firstWeek := currentWeek.AddDate(0, 0, -7*(weeks-1))
+
+	syncs, err := db.GetSourceSyncsSince(sourceID, firstWeek)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]WeeklySourceStats, weeks)
+	for i := range stats {
+		stats[i].WeekStart = firstWeek.AddDate(0, 0, 7*i)
+	}
+	for _, s := range syncs {
+		i := daysBetween(firstWeek, startOfWeek(s.SyncedAt, now.Location())) / 7
+		if i < 0 || i >= weeks {
+			continue
+		}
+		stats[i].CardsAdded += s.CardsAdded
+		stats[i].CardsRemoved += s.CardsRemoved
+	}
+	return stats, nil
+}
+
+// startOfWeek returns midnight in loc on the Monday of the week containing t.
+func startOfWeek(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	offset := (int(t.Weekday()) + 6) % 7 // Monday = 0
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween returns the number of calendar days from the date of a to that of
+// b, which are in the same location. Counting dates rather than hours keeps a
+// day that changes to or from daylight saving time a whole day.
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
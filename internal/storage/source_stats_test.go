@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetWeeklySourceStatsAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone database unavailable: %v", err)
+	}
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, err := db.InsertSource("/notes", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clocks went forward on Sunday March 8, 2026, so the week starting March 2
+	// is an hour short.
+	for _, s := range []SourceSync{
+		{SourceID: id, SyncedAt: time.Date(2026, 3, 6, 10, 0, 0, 0, loc), CardsAdded: 1},
+		{SourceID: id, SyncedAt: time.Date(2026, 3, 9, 0, 30, 0, 0, loc), CardsAdded: 2},
+		{SourceID: id, SyncedAt: time.Date(2026, 3, 10, 10, 0, 0, 0, loc), CardsAdded: 4, CardsRemoved: 1},
+	} {
+		if err := db.InsertSourceSync(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.GetWeeklySourceStats(id, 2, time.Date(2026, 3, 11, 12, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatalf("GetWeeklySourceStats returned an unexpected error: %v", err)
+	}
+	want := []WeeklySourceStats{
+		{WeekStart: time.Date(2026, 3, 2, 0, 0, 0, 0, loc), CardsAdded: 1},
+		{WeekStart: time.Date(2026, 3, 9, 0, 0, 0, 0, loc), CardsAdded: 6, CardsRemoved: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("Expected %d weeks, but got %+v", len(want), stats)
+	}
+	for i, w := range want {
+		if !stats[i].WeekStart.Equal(w.WeekStart) || stats[i].CardsAdded != w.CardsAdded || stats[i].CardsRemoved != w.CardsRemoved {
+			t.Errorf("Expected week %d to be %+v, but got %+v", i, w, stats[i])
+		}
+	}
+}
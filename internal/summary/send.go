@@ -0,0 +1,262 @@
+package summary
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/netconf"
+)
+
+const sendTimeout = 30 * time.Second
+
+// destination is somewhere the summary is sent, by name.
+type destination struct {
+	name string
+	send func(Summary) error
+}
+
+// destinations returns the destinations configured, in the order the summary is
+// sent to them.
+func destinations(cfg Config) []destination {
+	var ds []destination
+	if cfg.WebhookURL != "" {
+		ds = append(ds, destination{"webhook", func(s Summary) error { return post(cfg.WebhookURL, s) }})
+	}
+	if len(cfg.Email.To) > 0 {
+		ds = append(ds, destination{"email", func(s Summary) error { return email(cfg.Email, s) }})
+	}
+	return ds
+}
+
+// Send delivers the summary to the webhook and the email recipients configured.
+// It tries each before returning their errors.
+func Send(cfg Config, s Summary) error {
+	var errs []error
+	for _, d := range destinations(cfg) {
+		if err := d.send(s); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send weekly summary by %s: %w", d.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subject returns the title of the summary.
+func Subject(s Summary) string {
+	return "Knolhash: your week of " + s.Week.Format("January 2")
+}
+
+// Text returns the summary in a few lines of plain text, e.g. for a chat message.
+func Text(s Summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", Subject(s))
+	fmt.Fprintf(&b, "%d reviews (%s on the week before), %d new cards, %d minutes\n",
+		s.Totals.Reviews, change(s.Totals.Reviews, s.Previous.Reviews), s.Totals.NewCards, minutes(s.Totals.Time))
+	if s.Totals.Learned > 0 {
+		fmt.Fprintf(&b, "Retention %.0f%%\n", 100*s.Totals.Retention())
+	}
+	fmt.Fprintf(&b, "Streak %d days, longest %d\n", s.Streak.Current, s.Streak.Longest)
+	if len(s.WeakTags) > 0 {
+		var tags []string
+		for _, t := range s.WeakTags {
+			tags = append(tags, fmt.Sprintf("%s (%.0f%% forgotten)", t.Tag, 100*t.LapseRate))
+		}
+		fmt.Fprintf(&b, "Weakest tags: %s\n", strings.Join(tags, ", "))
+	}
+	return b.String()
+}
+
+// change describes how a count compares to that of the week before, e.g. +12%.
+func change(count, before int) string {
+	if before == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%+.0f%%", 100*(float64(count)/float64(before)-1))
+}
+
+// minutes rounds a duration to whole minutes.
+func minutes(d time.Duration) int {
+	return int(math.Round(d.Minutes()))
+}
+
+// page is the HTML email of the summary, with inline styles as email clients
+// ignore style sheets.
+var page = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"change":  change,
+	"minutes": minutes,
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", 100*f) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #222; max-width: 32em; margin: 0 auto; padding: 1em;">
+<h2 style="margin-bottom: 0.2em;">Your week of {{.Week.Format "January 2"}}</h2>
+<p style="color: #666; margin-top: 0;">Knolhash weekly summary</p>
+<table style="border-collapse: collapse; width: 100%;">
+<tr><td style="padding: 0.4em 0;">Reviews</td><td style="text-align: right;"><strong>{{.Totals.Reviews}}</strong> <span style="color: #666;">({{change .Totals.Reviews .Previous.Reviews}} on the week before)</span></td></tr>
+<tr><td style="padding: 0.4em 0;">New cards</td><td style="text-align: right;"><strong>{{.Totals.NewCards}}</strong></td></tr>
+<tr><td style="padding: 0.4em 0;">Retention</td><td style="text-align: right;"><strong>{{if .Totals.Learned}}{{percent .Totals.Retention}}{{else}}&ndash;{{end}}</strong>{{if .Previous.Learned}} <span style="color: #666;">({{percent .Previous.Retention}} the week before)</span>{{end}}</td></tr>
+<tr><td style="padding: 0.4em 0;">Study time</td><td style="text-align: right;"><strong>{{minutes .Totals.Time}}</strong> minutes</td></tr>
+<tr><td style="padding: 0.4em 0;">Streak</td><td style="text-align: right;"><strong>{{.Streak.Current}}</strong> day{{if ne .Streak.Current 1}}s{{end}} <span style="color: #666;">(longest {{.Streak.Longest}})</span></td></tr>
+</table>
+{{with .WeakTags}}
+<h3>Weakest tags</h3>
+<table style="border-collapse: collapse; width: 100%;">
+{{range .}}<tr><td style="padding: 0.4em 0;">{{.Tag}}</td><td style="text-align: right;">{{percent .LapseRate}} forgotten <span style="color: #666;">({{.Lapses}} of {{.Reviews}} reviews)</span></td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// HTML renders the summary as the page of an HTML email.
+func HTML(s Summary) ([]byte, error) {
+	var b bytes.Buffer
+	if err := page.Execute(&b, s); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// payload is the JSON posted to the webhook. Text makes it a message for chat
+// webhooks, such as Slack's, that read that field.
+type payload struct {
+	Week          string       `json:"week"` // Start of the study week, 2006-01-02
+	Reviews       int          `json:"reviews"`
+	NewCards      int          `json:"new_cards"`
+	Retention     *float64     `json:"retention"` // Null without reviews of learned cards
+	Minutes       int          `json:"minutes"`
+	Streak        int          `json:"streak"`
+	LongestStreak int          `json:"longest_streak"`
+	WeakTags      []weakTagRow `json:"weak_tags"`
+	Text          string       `json:"text"`
+}
+
+// weakTagRow is an entry of the weak tags of a payload.
+type weakTagRow struct {
+	Tag       string  `json:"tag"`
+	Reviews   int     `json:"reviews"`
+	Lapses    int     `json:"lapses"`
+	LapseRate float64 `json:"lapse_rate"`
+}
+
+// post posts the summary as JSON to a webhook.
+func post(url string, s Summary) error {
+	p := payload{
+		Week:          s.Week.Format(time.DateOnly),
+		Reviews:       s.Totals.Reviews,
+		NewCards:      s.Totals.NewCards,
+		Minutes:       minutes(s.Totals.Time),
+		Streak:        s.Streak.Current,
+		LongestStreak: s.Streak.Longest,
+		WeakTags:      []weakTagRow{},
+		Text:          Text(s),
+	}
+	if s.Totals.Learned > 0 {
+		retention := s.Totals.Retention()
+		p.Retention = &retention
+	}
+	for _, t := range s.WeakTags {
+		p.WeakTags = append(p.WeakTags, weakTagRow{Tag: t.Tag, Reviews: t.Reviews, Lapses: t.Lapses, LapseRate: t.LapseRate})
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// email sends the summary as an HTML email, upgrading the connection with
+// STARTTLS when the server offers it.
+func email(cfg Email, s Summary) error {
+	html, err := HTML(s)
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", Subject(s)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	msg.Write(bytes.ReplaceAll(html, []byte("\n"), []byte("\r\n")))
+
+	host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", cfg.SMTPAddr, sendTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", cfg.From, err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,164 @@
+// Package summary reports each study week, once it is over: its reviews,
+// retention, new cards and study time, the streak and the tags forgotten most,
+// sent as an HTML email and/or posted as JSON to a webhook.
+package summary
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/goals"
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/stats"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+const (
+	// keyLastWeek, followed by the name of a destination, records the start of
+	// the last week whose summary was sent there in the settings table.
+	keyLastWeek = "summary.last_week."
+	// keyRetryAt and keyFailures, followed by the name of a destination, record
+	// when the summary is next tried there after failing, and how many times in a
+	// row it failed.
+	keyRetryAt  = "summary.retry_at."
+	keyFailures = "summary.failures."
+
+	defaultTime = "08:00"
+	// clockLayout is the format of the time of day the summary is sent.
+	clockLayout = "15:04"
+
+	// weakTags is how many of the tags forgotten most are listed.
+	weakTags = 3
+
+	// retryDelay is the wait before retrying a destination the summary failed
+	// to reach. It doubles with each failure, up to the next week's summary.
+	retryDelay = time.Minute
+)
+
+// Config enables the weekly summary and says where it goes.
+type Config struct {
+	// Time of day (HH:MM, in the preferred timezone) on the first day of the
+	// study week, a Monday, that the past week's summary is sent; 08:00 by default
+	Time       string `koanf:"time" validate:"omitempty,datetime=15:04"`
+	WebhookURL string `koanf:"webhook_url" validate:"omitempty,url"`
+	Email      Email  `koanf:"email"`
+}
+
+// Email is the SMTP server the summary is sent through and its recipients.
+type Email struct {
+	To       []string `koanf:"to"`
+	From     string   `koanf:"from" validate:"required_with=To"`
+	SMTPAddr string   `koanf:"smtp_addr" validate:"required_with=To,omitempty,hostname_port"` // e.g. smtp.example.com:587
+	Username string   `koanf:"username"`
+	Password string   `koanf:"password"`
+}
+
+// Enabled reports whether the summary is sent anywhere.
+func (c Config) Enabled() bool {
+	return c.WebhookURL != "" || len(c.Email.To) > 0
+}
+
+// Summary is the report of a study week.
+type Summary struct {
+	Week     time.Time // Start of the study week
+	Totals   stats.Totals
+	Previous stats.Totals // Of the week before, to compare against
+	Streak   goals.Streak // When the summary was built
+	WeakTags []stats.Tag  // Tags forgotten most during the week, worst first
+}
+
+// Build reports at now on the study week starting at week.
+func Build(db *storage.DB, p prefs.Preferences, week, now time.Time) (Summary, error) {
+	s := Summary{Week: week}
+	end := week.AddDate(0, 0, 7)
+	var err error
+	if s.Totals, err = stats.TotalsBetween(db, week, end); err != nil {
+		return s, err
+	}
+	if s.Previous, err = stats.TotalsBetween(db, week.AddDate(0, 0, -7), week); err != nil {
+		return s, err
+	}
+	if s.WeakTags, err = stats.WeakTags(db, week, end, weakTags); err != nil {
+		return s, err
+	}
+	trophies, err := goals.Achievements(db, p, now)
+	if err != nil {
+		return s, err
+	}
+	s.Streak = trophies.Streak
+	return s, nil
+}
+
+// CheckDue sends the summary of the last study week to the destinations it is
+// due at now and hasn't been sent to yet. Each destination is recorded once it
+// got the summary, so one that failed is retried without sending it again to the
+// others, after a delay that doubles with each failure.
+func CheckDue(db *storage.DB, cfg Config, now time.Time) error {
+	p, err := prefs.Load(db)
+	if err != nil {
+		return err
+	}
+	now = now.In(p.Location())
+	thisWeek := p.WeekStart(now)
+	c, _ := time.Parse(clockLayout, cmp.Or(cfg.Time, defaultTime)) // Validated at startup
+	scheduled := time.Date(thisWeek.Year(), thisWeek.Month(), thisWeek.Day(), c.Hour(), c.Minute(), 0, 0, thisWeek.Location())
+	if now.Before(scheduled) {
+		return nil
+	}
+
+	week := thisWeek.AddDate(0, 0, -7)
+	values, err := db.GetSettings()
+	if err != nil {
+		return err
+	}
+	var pending []destination
+	for _, d := range destinations(cfg) {
+		if last, err := time.Parse(time.RFC3339, values[keyLastWeek+d.name]); err == nil && !last.Before(week) {
+			continue
+		}
+		if retryAt, err := time.Parse(time.RFC3339, values[keyRetryAt+d.name]); err == nil && retryAt.After(now) {
+			continue
+		}
+		pending = append(pending, d)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	s, err := Build(db, p, week, now)
+	if err != nil {
+		return err
+	}
+	changes := make(map[string]string)
+	var errs []error
+	for _, d := range pending {
+		if err := d.send(s); err != nil {
+			// Failures before this week's summary was due were of an earlier week's
+			failures := 0
+			if retryAt, err := time.Parse(time.RFC3339, values[keyRetryAt+d.name]); err == nil && retryAt.After(scheduled) {
+				failures, _ = strconv.Atoi(values[keyFailures+d.name])
+			}
+			failures++
+			retryAt := now.Add(retryDelay << min(failures-1, 20))
+			if next := scheduled.AddDate(0, 0, 7); retryAt.After(next) {
+				retryAt = next
+			}
+			changes[keyRetryAt+d.name] = retryAt.Format(time.RFC3339)
+			changes[keyFailures+d.name] = strconv.Itoa(failures)
+			errs = append(errs, fmt.Errorf("failed to send weekly summary by %s, retrying at %s: %w", d.name, retryAt.Format(time.DateTime), err))
+			continue
+		}
+		slog.Info("Sent weekly summary", "by", d.name, "week", week.Format(time.DateOnly), "reviews", s.Totals.Reviews)
+		changes[keyLastWeek+d.name] = week.Format(time.RFC3339)
+		changes[keyRetryAt+d.name] = ""
+		changes[keyFailures+d.name] = ""
+	}
+	if err := db.UpdateSettings(changes); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
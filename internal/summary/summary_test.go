@@ -0,0 +1,63 @@
+package summary
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestCheckDueBacksOff(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.UpdateSettings(map[string]string{"prefs.timezone": "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var posts atomic.Int32
+	var up atomic.Bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		if !up.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer hook.Close()
+	cfg := Config{WebhookURL: hook.URL}
+
+	// The summary is due at 08:00 on Monday October 12, 2026
+	scheduled := time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)
+	for _, step := range []struct {
+		after  time.Duration // Since the summary was due
+		up     bool
+		posts  int32 // In total so far
+		failed bool
+	}{
+		{-time.Minute, false, 0, false},
+		{0, false, 1, true},
+		{30 * time.Second, false, 1, false},
+		{time.Minute, false, 2, true}, // Retried after a minute
+		{2 * time.Minute, false, 2, false},
+		{3 * time.Minute, false, 3, true}, // Then after two
+		{5 * time.Minute, false, 3, false},
+		{7 * time.Minute, true, 4, false}, // Then after four, and sent
+		{8 * time.Minute, true, 4, false},
+		{7 * 24 * time.Hour, false, 5, true}, // The next week's summary starts over
+		{7*24*time.Hour + time.Minute, true, 6, false},
+	} {
+		up.Store(step.up)
+		err := CheckDue(db, cfg, scheduled.Add(step.after))
+		if got := posts.Load(); got != step.posts {
+			t.Fatalf("Expected %d posts %v after the summary was due, but got %d (%v)", step.posts, step.after, got, err)
+		}
+		if failed := err != nil; failed != step.failed {
+			t.Errorf("Expected the check %v after the summary was due to fail: %v, but got %v", step.after, step.failed, err)
+		}
+	}
+}
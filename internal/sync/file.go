@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// FindLocalSource returns the local source whose directory holds the file at
+// path, the innermost if sources are nested.
+func FindLocalSource(db *storage.DB, path string) (storage.Source, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return storage.Source{}, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return storage.Source{}, err
+	}
+	var found storage.Source
+	var foundRoot string
+	for _, source := range sources {
+		if source.Type != "local" {
+			continue
+		}
+		root, err := filepath.Abs(source.Path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(root) > len(foundRoot) {
+			found, foundRoot = source, root
+		}
+	}
+	if foundRoot == "" {
+		return storage.Source{}, fmt.Errorf("%s is not in a local source: add the directory holding it first", path)
+	}
+	if found.Archived {
+		return storage.Source{}, fmt.Errorf("%s is in the archived source %s", path, found.Path)
+	}
+	return found, nil
+}
+
+// SyncFile syncs the file at path straight away, e.g. after a card was added to
+// it, by reconciling the local source holding it. Only the cards of files that
+// changed since the last sync change, so this stands in for syncing the file
+// alone.
+func SyncFile(db *storage.DB, path string) (storage.Source, error) {
+	source, err := FindLocalSource(db, path)
+	if err != nil {
+		return source, err
+	}
+
+	running.Lock()
+	defer running.Unlock()
+	defer func() { lastFinished.Store(time.Now().UnixNano()) }()
+
+	slog.Info("Syncing source of file", "id", source.ID, "path", source.Path, "file", path)
+	setPhase(source.ID, source.Path, "Scanning files", -1)
+	err = reconcileLocalSource(db, &source, nil)
+	finish(source.ID, source.Path, err)
+	return source, err
+}
+
+// FileSyntax returns the syntax of the cards of a file in a local source: the
+// source's, with the settings of the _knolhash.yaml files of the directories
+// from the source's root down to the file's. Invalid settings are ignored, as
+// when syncing.
+func FileSyntax(source storage.Source, path string) (parser.Config, error) {
+	root, err := filepath.Abs(source.Path)
+	if err != nil {
+		return parser.Config{}, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return parser.Config{}, err
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(abs))
+	if err != nil {
+		return parser.Config{}, err
+	}
+	cfg := Syntax(source)
+	var settings parser.DirConfig
+	dirs := []string{root}
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], name))
+		}
+	}
+	for _, dir := range dirs {
+		own, err := parser.ReadDirConfig(dir)
+		if err == nil {
+			err = own.Under(settings).Apply(cfg).Validate()
+		}
+		if err == nil {
+			settings = own.Under(settings)
+		}
+	}
+	return settings.Apply(cfg), nil
+}
@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/conorfennell/knolhash/internal/gitsource"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// CloneUsage describes a clone in the repos cache and its disk usage.
+type CloneUsage struct {
+	Path        string
+	SourceID    int64 // 0 if the clone no longer belongs to any source
+	BytesBefore int64
+	BytesAfter  int64 // 0 if the clone was removed
+	Removed     bool
+	Err         error
+}
+
+// CollectGarbage prunes clones in the repos cache that no longer belong to a
+// git source, runs housekeeping on the remaining ones and reports the disk
+// usage of every clone. Clones of archived sources are kept so they can be unarchived.
+// With several databases sharing the cache, as tenants do, a clone is kept if a
+// source in any of them uses it.
+func CollectGarbage(dbs ...*storage.DB) ([]CloneUsage, error) {
+	running.Lock()
+	defer running.Unlock()
+
+	owners := make(map[string]int64)
+	for _, db := range dbs {
+		sources, err := db.GetAllSources()
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range sources {
+			if source.Type != "git" {
+				continue
+			}
+			localPath, err := gitUrlToLocalPath(reposDir, source.Path)
+			if err != nil {
+				continue
+			}
+			owners[filepath.Clean(localPath)] = source.ID
+		}
+	}
+
+	clones, err := findClones(reposDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []CloneUsage
+	for _, clone := range clones {
+		u := CloneUsage{Path: clone, SourceID: owners[clone]}
+		u.BytesBefore, _ = dirSize(clone)
+
+		if u.SourceID == 0 {
+			slog.Info("Removing clone of deleted source", "path", clone)
+			if u.Err = os.RemoveAll(clone); u.Err == nil {
+				u.Removed = true
+				removeEmptyParents(filepath.Dir(clone), reposDir)
+			}
+		} else {
+			u.Err = gitsource.Housekeep(clone)
+			u.BytesAfter, _ = dirSize(clone)
+		}
+
+		if u.Err != nil {
+			slog.Warn("Garbage collection failed for clone", "path", clone, "error", u.Err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// findClones returns every git working tree below dir, without descending into them.
+func findClones(dir string) ([]string, error) {
+	var clones []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			clones = append(clones, filepath.Clean(path))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return clones, err
+}
+
+// dirSize returns the total size in bytes of all regular files below dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// removeEmptyParents removes dir and its parents up to (but excluding) root while they are empty.
+// It never goes past the root of the volume, such as / or C:\.
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && dir != "." && filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/conorfennell/knolhash/internal/cloudsource"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// BrokenLink is a relative link or image in a card that points at a file missing
+// from the card's source.
+type BrokenLink struct {
+	CardHash   string
+	Question   string
+	SourceID   int64
+	SourcePath string
+	File       string // The file the card is in, from the source's root
+	Target     string // The link destination, as written
+	Image      bool
+}
+
+// CheckLinks scans the content of every card for relative links and images and
+// reports those whose target is missing, resolved from the card's file in the
+// source's local copy. Cards not synced since files were recorded are skipped.
+func CheckLinks(db *storage.DB) ([]BrokenLink, error) {
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return nil, err
+	}
+	var broken []BrokenLink
+	for _, source := range sources {
+		root, err := LocalPath(source)
+		if err != nil {
+			return nil, err
+		}
+		cards, err := db.GetCardsBySourceID(source.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, card := range cards {
+			if card.File == "" {
+				continue
+			}
+			for _, field := range []string{card.Question, card.Answer} {
+				for _, l := range relativeLinks(field) {
+					target := filepath.Join(root, filepath.FromSlash(path.Dir(card.File)), filepath.FromSlash(l.path))
+					if _, err := os.Stat(target); err == nil {
+						continue
+					}
+					broken = append(broken, BrokenLink{
+						CardHash:   card.Hash,
+						Question:   card.Question,
+						SourceID:   source.ID,
+						SourcePath: source.Path,
+						File:       card.File,
+						Target:     l.destination,
+						Image:      l.image,
+					})
+				}
+			}
+		}
+	}
+	return broken, nil
+}
+
+// LocalPath returns the directory the files of a source are read from.
+func LocalPath(source storage.Source) (string, error) {
+	switch source.Type {
+	case "git":
+		return gitUrlToLocalPath(reposDir, source.Path)
+	case "url":
+		return urlToLocalPath(urlsDir, source.Path)
+	case "dropbox", "gdrive":
+		return cloudsource.LocalPath(cloudDir, source.Path)
+	default:
+		return source.Path, nil
+	}
+}
+
+// link is a link or image destination in Markdown, with the path it refers to.
+type link struct {
+	destination string
+	path        string // Unescaped, without query or fragment
+	image       bool
+}
+
+// relativeLinks returns the links and images in a Markdown source that refer to
+// files by relative path, skipping URLs, absolute paths and in-page anchors.
+func relativeLinks(source string) []link {
+	src := []byte(source)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+	var links []link
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var destination string
+		var image bool
+		switch n := n.(type) {
+		case *ast.Link:
+			destination = string(n.Destination)
+		case *ast.Image:
+			destination, image = string(n.Destination), true
+		default:
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(destination)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+			return ast.WalkContinue, nil
+		}
+		links = append(links, link{destination: destination, path: u.Path, image: image})
+		return ast.WalkContinue, nil
+	})
+	return links
+}
@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/cloudsource"
+	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/urlsource"
+)
+
+// ErrSourceExists is returned when adding a source that has already been added.
+var ErrSourceExists = errors.New("this source has already been added")
+
+// InvalidSourceError is returned when adding a source that can't be synced,
+// with a message fit to show whoever is adding it.
+type InvalidSourceError struct {
+	Err error
+}
+
+func (e *InvalidSourceError) Error() string { return e.Err.Error() }
+
+func (e *InvalidSourceError) Unwrap() error { return e.Err }
+
+// SourceType returns the type of the source at path: a Dropbox or Google Drive
+// folder, a single file or gist URL, a git repository or a local directory.
+func SourceType(path string) string {
+	if t := cloudsource.Type(path); t != "" {
+		return t
+	}
+	if urlsource.Matches(path) {
+		return "url"
+	}
+	if strings.HasSuffix(path, ".git") || strings.HasPrefix(path, "git@") || strings.HasPrefix(path, "https://") {
+		return "git"
+	}
+	return "local"
+}
+
+// NormalizePath returns the canonical form of a source path, so the same source
+// isn't added twice: local paths are cleaned, e.g. of ./ and trailing slashes,
+// and other paths have surrounding whitespace and trailing slashes removed.
+func NormalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	if SourceType(path) == "local" {
+		return filepath.Clean(path)
+	}
+	return strings.TrimRight(path, "/")
+}
+
+// ValidateSource checks that a source of the given type can be synced from path,
+// returning an error fit to show whoever is adding it.
+func ValidateSource(path, sourceType string) error {
+	switch sourceType {
+	case "local":
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("there is no directory %s on the server", path)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is a file: add the directory holding it", path)
+		}
+	case "git":
+		localPath, err := gitUrlToLocalPath(reposDir, path)
+		if err != nil {
+			return fmt.Errorf("%s is not a git URL: use https://host/owner/repo.git or git@host:owner/repo.git", path)
+		}
+		// The clone is kept below a directory per host, so one level means no repository
+		if rel, err := filepath.Rel(reposDir, localPath); err != nil || !strings.ContainsRune(rel, filepath.Separator) {
+			return fmt.Errorf("%s names a host but no repository", path)
+		}
+	case "url":
+		if _, err := urlToLocalPath(urlsDir, path); err != nil {
+			return fmt.Errorf("%s is not a valid URL", path)
+		}
+	case "dropbox", "gdrive":
+		if _, err := cloudsource.LocalPath(cloudDir, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSource normalizes, validates and adds the source at path. It returns an
+// InvalidSourceError if it can't be synced and ErrSourceExists, with the existing
+// source, if it has already been added.
+func AddSource(db *storage.DB, path string) (storage.Source, error) {
+	source, err := checkSource(db, 0, path)
+	if err != nil {
+		return source, err
+	}
+	if source.ID, err = db.InsertSource(source.Path, source.Type); err != nil {
+		return source, err
+	}
+	return source, nil
+}
+
+// ChangeSourcePath moves the source with the given ID to path, keeping its cards,
+// and returns it. Errors are those of AddSource.
+func ChangeSourcePath(db *storage.DB, id int64, path string) (storage.Source, error) {
+	source, err := checkSource(db, id, path)
+	if err != nil {
+		return source, err
+	}
+	source.ID = id
+	if err := db.UpdateSourcePath(id, source.Path, source.Type); err != nil {
+		return source, err
+	}
+	return source, nil
+}
+
+// checkSource normalizes and validates path as the path of the source with the
+// given ID, 0 for a new one, returning the source it would be.
+func checkSource(db *storage.DB, id int64, path string) (storage.Source, error) {
+	source := storage.Source{Path: NormalizePath(path)}
+	if source.Path == "" {
+		return source, &InvalidSourceError{Err: errors.New("path cannot be empty")}
+	}
+	source.Type = SourceType(source.Path)
+	if err := ValidateSource(source.Path, source.Type); err != nil {
+		return source, &InvalidSourceError{Err: err}
+	}
+
+	// Compare normalized paths, as sources added before normalization may differ
+	sources, err := db.GetAllSources()
+	if err != nil {
+		return source, err
+	}
+	for _, existing := range sources {
+		if existing.ID != id && NormalizePath(existing.Path) == source.Path {
+			return existing, ErrSourceExists
+		}
+	}
+	return source, nil
+}
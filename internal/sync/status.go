@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"sort"
+	gosync "sync"
+	"time"
+)
+
+// SourceStatus describes the progress of the current or most recent sync of a source.
+type SourceStatus struct {
+	SourceID  int64     `json:"source_id"`
+	Path      string    `json:"path"`
+	Running   bool      `json:"running"`
+	Phase     string    `json:"phase"`
+	Percent   int       `json:"percent"` // -1 when the phase reports no percentage
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// statuses holds the latest SourceStatus of every source synced since startup.
+var statuses = struct {
+	gosync.Mutex
+	bySource map[int64]*SourceStatus
+}{bySource: make(map[int64]*SourceStatus)}
+
+// Statuses returns a snapshot of the sync status of every source synced since startup,
+// ordered by source ID.
+func Statuses() []SourceStatus {
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	list := make([]SourceStatus, 0, len(statuses.bySource))
+	for _, s := range statuses.bySource {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SourceID < list[j].SourceID })
+	return list
+}
+
+// setPhase records that a source has entered a new phase of its sync.
+func setPhase(sourceID int64, path, phase string, percent int) {
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	statuses.bySource[sourceID] = &SourceStatus{
+		SourceID:  sourceID,
+		Path:      path,
+		Running:   true,
+		Phase:     phase,
+		Percent:   percent,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// finish marks the sync of a source as complete, recording err if it failed.
+func finish(sourceID int64, path string, err error) {
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	s := &SourceStatus{
+		SourceID:  sourceID,
+		Path:      path,
+		Phase:     "Done",
+		Percent:   100,
+		UpdatedAt: time.Now(),
+	}
+	if err != nil {
+		s.Phase = "Failed"
+		s.Percent = -1
+		s.Error = err.Error()
+	}
+	statuses.bySource[sourceID] = s
+}
@@ -1,23 +1,69 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	gosync "sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/conorfennell/knolhash/internal/cloudsource"
 	"github.com/conorfennell/knolhash/internal/domain"
 	"github.com/conorfennell/knolhash/internal/gitsource"
+	"github.com/conorfennell/knolhash/internal/hooks"
 	"github.com/conorfennell/knolhash/internal/knol"
 	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/pathsafe"
 	"github.com/conorfennell/knolhash/internal/storage"
+	"github.com/conorfennell/knolhash/internal/urlsource"
 )
 
+var (
+	// reposDir is the directory git sources are cloned into.
+	reposDir = "repos"
+	// urlsDir is the directory URL sources are downloaded into.
+	urlsDir = "urls"
+	// cloudDir is the directory Dropbox and Google Drive folders are downloaded into.
+	cloudDir = "cloud"
+)
+
+// SetDataDir places the directories sources are cloned and downloaded into
+// below dir, instead of the working directory. It must be called before syncing.
+func SetDataDir(dir string) {
+	reposDir = filepath.Join(dir, "repos")
+	urlsDir = filepath.Join(dir, "urls")
+	cloudDir = filepath.Join(dir, "cloud")
+}
+
+// running serialises syncs and garbage collection, which both work on the repos directory.
+var running gosync.Mutex
+
+// lastFinished is when the last sync finished, in Unix nanoseconds.
+var lastFinished atomic.Int64
+
+// LastFinished returns when the last sync finished, e.g. to expire caches of
+// the cards it may have changed; zero if none has.
+func LastFinished() time.Time {
+	if ns := lastFinished.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
 // RunSync iterates over all sources and reconciles them.
 func RunSync(db *storage.DB) {
+	running.Lock()
+	defer running.Unlock()
+	defer func() { lastFinished.Store(time.Now().UnixNano()) }()
+
 	slog.Info("Starting sync process for all sources...")
 	sources, err := db.GetAllSources()
 	if err != nil {
@@ -30,54 +76,213 @@ func RunSync(db *storage.DB) {
 		return
 	}
 
-	reposDir := "repos"
 	if err := os.MkdirAll(reposDir, os.ModePerm); err != nil {
 		slog.Error("Failed to create repos directory", "error", err)
 		os.Exit(1)
 	}
 
+	payload := struct {
+		Sources []hooks.Source `json:"sources"`
+	}{}
+	for _, source := range sources {
+		payload.Sources = append(payload.Sources, hooks.Source{ID: source.ID, Type: source.Type, Path: source.Path})
+	}
+	if err := hooks.Notify(hooks.PreSync, payload); err != nil {
+		slog.Error("Sync cancelled by pre-sync hook", "error", err)
+		return
+	}
+
 	for _, source := range sources {
+		if source.Archived {
+			slog.Info("Skipping archived source", "id", source.ID, "path", source.Path)
+			continue
+		}
+
 		slog.Info("Syncing source", "id", source.ID, "type", source.Type, "path", source.Path)
 
 		sourceToReconcile := source
 
 		if source.Type == "local" {
-			reconcileLocalSource(db, &sourceToReconcile)
+			setPhase(source.ID, source.Path, "Scanning files", -1)
+			finish(source.ID, source.Path, reconcileLocalSource(db, &sourceToReconcile, nil))
 		} else if source.Type == "git" {
 			localRepoPath, err := gitUrlToLocalPath(reposDir, source.Path)
 			if err != nil {
 				slog.Error("Error determining local path for git repo", "url", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
 				continue
 			}
 
-			if err := gitsource.Sync(source.Path, localRepoPath); err != nil {
+			setPhase(source.ID, source.Path, "Fetching", -1)
+			opts := gitsource.Options{
+				RecurseSubmodules: source.Submodules,
+				Mirrors:           source.Mirrors,
+				OnProgress: func(p gitsource.Progress) {
+					setPhase(source.ID, source.Path, p.Phase, p.Percent)
+				},
+			}
+			if err := gitsource.Sync(source.Path, localRepoPath, opts); err != nil {
 				slog.Error("Error syncing git repo", "url", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
 				continue
 			}
 
+			if source.TrustedKeys != "" {
+				setPhase(source.ID, source.Path, "Verifying signature", -1)
+				if err := gitsource.VerifyHead(localRepoPath, source.TrustedKeys); err != nil {
+					slog.Error("Refusing to ingest cards from unverified commit", "url", source.Path, "error", err)
+					finish(source.ID, source.Path, err)
+					continue
+				}
+			}
+
+			setPhase(source.ID, source.Path, "Scanning files", -1)
+			changed, err := gitsource.LastChanged(localRepoPath)
+			if err != nil {
+				slog.Warn("Failed to read file history, using modification times", "url", source.Path, "error", err)
+			}
 			sourceToReconcile.Path = localRepoPath
-			reconcileLocalSource(db, &sourceToReconcile)
+			finish(source.ID, source.Path, reconcileLocalSource(db, &sourceToReconcile, changed))
+		} else if source.Type == "url" {
+			localDir, err := urlToLocalPath(urlsDir, source.Path)
+			if err != nil {
+				slog.Error("Error determining local path for URL source", "url", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
+				continue
+			}
+
+			setPhase(source.ID, source.Path, "Fetching", -1)
+			if err := urlsource.Sync(source.Path, localDir); err != nil {
+				slog.Error("Error fetching URL source", "url", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
+				continue
+			}
+
+			setPhase(source.ID, source.Path, "Scanning files", -1)
+			sourceToReconcile.Path = localDir
+			finish(source.ID, source.Path, reconcileLocalSource(db, &sourceToReconcile, nil))
+		} else if source.Type == "dropbox" || source.Type == "gdrive" {
+			localDir, err := cloudsource.LocalPath(cloudDir, source.Path)
+			if err != nil {
+				slog.Error("Error determining local path for cloud folder", "path", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
+				continue
+			}
+
+			setPhase(source.ID, source.Path, "Fetching", -1)
+			if err := cloudsource.Sync(source.Path, localDir); err != nil {
+				slog.Error("Error syncing cloud folder", "path", source.Path, "error", err)
+				finish(source.ID, source.Path, err)
+				continue
+			}
+
+			setPhase(source.ID, source.Path, "Scanning files", -1)
+			sourceToReconcile.Path = localDir
+			finish(source.ID, source.Path, reconcileLocalSource(db, &sourceToReconcile, nil))
 		}
 	}
 	slog.Info("Sync process complete.")
+
+	notifySyncFinished(sources)
 }
 
-func reconcileLocalSource(db *storage.DB, source *storage.Source) {
+// notifySyncFinished runs the sync-finished hooks with the sources just synced.
+func notifySyncFinished(sources []storage.Source) {
+	errs := make(map[int64]string)
+	for _, st := range Statuses() {
+		errs[st.SourceID] = st.Error
+	}
+	payload := struct {
+		Sources []hooks.SyncedSource `json:"sources"`
+	}{}
+	for _, source := range sources {
+		if source.Archived {
+			continue
+		}
+		dir, _ := LocalPath(source)
+		payload.Sources = append(payload.Sources, hooks.SyncedSource{
+			Source: hooks.Source{ID: source.ID, Type: source.Type, Path: source.Path},
+			Dir:    dir,
+			Error:  errs[source.ID],
+		})
+	}
+	if err := hooks.Notify(hooks.SyncFinished, payload); err != nil {
+		slog.Warn("Hook failed", "event", hooks.SyncFinished, "error", err)
+	}
+}
+
+// reconcileLocalSource inserts new cards found under the source's path and deletes
+// orphaned ones. Problems with individual cards are logged; an error is only
+// returned when the source could not be reconciled at all.
+//
+// The cards of a file over the parser's size limits are left as they were, rather
+// than deleted, until it is back under them.
+//
+// Each card records the file it was found in, the line it starts on and when
+// that file last changed, taken from changed by slash-separated path from the
+// source's root, or else from the file's modification time.
+func reconcileLocalSource(db *storage.DB, source *storage.Source, changed map[string]time.Time) error {
 	var parsedCards []domain.Card
 	var parseErrors []error
+	var addedCards int
 	foundCardHashes := make(map[string]bool)
+	tooLarge := make(map[string]bool) // Files over the parser's limits, whose cards are kept as they were
+	var issues []storage.ParseIssue
 
+	cfg := Syntax(*source)
+	exclude := source.Exclude
+	if len(exclude) == 0 {
+		exclude = DefaultExclude
+	}
+	dirs := make(map[string]parser.DirConfig) // The settings of each directory, from its _knolhash.yaml and those above
 	walkErr := filepath.WalkDir(source.Path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
-			fileCards, parseErr := parser.ParseFile(path)
+		if d.IsDir() && path != source.Path && excluded(source.Path, path, exclude) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			dirs[path] = dirs[filepath.Dir(path)]
+			own, err := parser.ReadDirConfig(path)
+			if err == nil {
+				err = own.Under(dirs[path]).Apply(cfg).Validate()
+			}
+			if err != nil {
+				rel, _ := filepath.Rel(source.Path, filepath.Join(path, parser.DirConfigFile))
+				slog.Warn("Ignoring directory settings", "path", path, "error", err)
+				issues = append(issues, storage.ParseIssue{File: filepath.ToSlash(rel), Severity: storage.SeverityError, Message: err.Error()})
+			} else {
+				dirs[path] = own.Under(dirs[path])
+			}
+			return nil
+		}
+		if fileCfg := dirs[filepath.Dir(path)].Apply(cfg); fileCfg.IsCardFile(d.Name()) {
+			res := parseFile(path, fileCfg)
+			parseErr := res.Err()
 			if parseErr != nil {
 				parseErrors = append(parseErrors, fmt.Errorf("parsing %s: %w", path, parseErr))
 			}
-			for _, card := range fileCards {
+			file, modified := fileProvenance(source.Path, path, d, changed)
+			if errors.Is(parseErr, parser.ErrTooLarge) {
+				slog.Warn("Skipping file over the size limits, keeping its cards", "path", path, "error", parseErr)
+				tooLarge[file] = true
+			}
+			issues = append(issues, parseIssues(file, storage.SeverityError, res.Errors)...)
+			issues = append(issues, parseIssues(file, storage.SeverityWarning, res.Warnings)...)
+			for _, card := range res.Cards {
 				card.Hash = knol.Hash(card)
+				card.File = file
+				card.Media = resolveMedia(file, card.Media)
+				if card.ID != "" && foundCardHashes[card.Hash] {
+					issues = append(issues, storage.ParseIssue{
+						File:     file,
+						Line:     card.Line,
+						Severity: storage.SeverityWarning,
+						Message:  fmt.Sprintf("duplicate ID %q, already used by another card, skipped", card.ID),
+					})
+					continue
+				}
 				parsedCards = append(parsedCards, card)
 				foundCardHashes[card.Hash] = true
 
@@ -86,10 +291,63 @@ func reconcileLocalSource(db *storage.DB, source *storage.Source) {
 					parseErrors = append(parseErrors, fmt.Errorf("db check for %s: %w", card.Hash, findErr))
 					continue
 				}
+				if existingCard == nil {
+					// A card given an ID keeps the history it has under its
+					// content hash, and one with math that under its hash
+					// from before math was normalized.
+					existingCard, findErr = adoptHash(db, card)
+					if findErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db rename to %s: %w", card.Hash, findErr))
+						continue
+					}
+				}
 				if existingCard == nil {
 					slog.Info("New card found, inserting...", "hash", card.Hash)
 					if insertErr := db.InsertCard(card, source.ID); insertErr != nil {
 						parseErrors = append(parseErrors, fmt.Errorf("db insert for %s: %w", card.Hash, insertErr))
+					} else {
+						addedCards++
+					}
+				} else if relink, relinkErr := needsRelink(db, existingCard, source.ID); relinkErr != nil {
+					parseErrors = append(parseErrors, fmt.Errorf("db source check for %s: %w", card.Hash, relinkErr))
+				} else if relink {
+					slog.Info("Re-linking card to source", "hash", card.Hash, "source_id", source.ID)
+					if updateErr := db.UpdateCardSource(card.Hash, source.ID); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db relink for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard != nil && contentChanged(existingCard, card) {
+					// Only cards with an ID keep their hash as their content changes.
+					if updateErr := db.UpdateCardContent(card); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db content update for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard != nil && existingCard.Context != strings.TrimSpace(card.Context) {
+					// Cards synced before contexts were stored have an empty one.
+					if updateErr := db.UpdateCardContext(card.Hash, card.Context); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db context update for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard != nil && existingCard.Hint != strings.TrimSpace(card.Hint) {
+					// The hint isn't part of the hash, so it can change on an existing card.
+					if updateErr := db.UpdateCardHint(card.Hash, card.Hint); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db hint update for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard != nil && !slices.Equal(existingCard.Tags, card.Tags) {
+					// Nor are the tags, e.g. those of a file's frontmatter.
+					if updateErr := db.UpdateCardTags(card.Hash, card.Tags); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db tags update for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard != nil && !slices.Equal(existingCard.Media, card.Media) {
+					if updateErr := db.UpdateCardMedia(card.Hash, card.Media); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db media update for %s: %w", card.Hash, updateErr))
+					}
+				}
+				if existingCard == nil || existingCard.File != card.File || existingCard.Line != card.Line || !existingCard.FileModified.Time.Equal(modified) {
+					if updateErr := db.UpdateCardFile(card.Hash, card.File, card.Line, modified); updateErr != nil {
+						parseErrors = append(parseErrors, fmt.Errorf("db file update for %s: %w", card.Hash, updateErr))
 					}
 				}
 			}
@@ -99,18 +357,18 @@ func reconcileLocalSource(db *storage.DB, source *storage.Source) {
 
 	if walkErr != nil {
 		slog.Error("Error walking directory", "path", source.Path, "error", walkErr)
-		return
+		return walkErr
 	}
 
 	dbCards, err := db.GetCardsBySourceID(source.ID)
 	if err != nil {
 		slog.Error("Error getting cards for source", "source_id", source.ID, "error", err)
-		return
+		return err
 	}
 
 	var orphanedCards int
 	for _, dbCard := range dbCards {
-		if _, found := foundCardHashes[dbCard.Hash]; !found {
+		if _, found := foundCardHashes[dbCard.Hash]; !found && !tooLarge[dbCard.File] {
 			slog.Info("Orphaned card, deleting", "hash", dbCard.Hash)
 			orphanedCards++
 			if err := db.DeleteCardByHash(dbCard.Hash); err != nil {
@@ -119,17 +377,192 @@ func reconcileLocalSource(db *storage.DB, source *storage.Source) {
 		}
 	}
 
+	if err := db.ReplaceParseIssues(source.ID, issues); err != nil {
+		slog.Warn("Failed to record parse issues for source", "source_id", source.ID, "error", err)
+	}
+
 	if err := db.UpdateSourceLastScanned(source.ID); err != nil {
 		slog.Warn("Failed to update last scanned for source", "source_id", source.ID, "error", err)
 	}
 
+	if err := db.InsertSourceSync(storage.SourceSync{
+		SourceID:     source.ID,
+		SyncedAt:     db.Now(),
+		CardsAdded:   addedCards,
+		CardsRemoved: orphanedCards,
+		TotalCards:   len(foundCardHashes),
+	}); err != nil {
+		slog.Warn("Failed to record sync delta for source", "source_id", source.ID, "error", err)
+	}
+
 	slog.Info("reconciliation complete",
 		"path", source.Path,
 		"parsed_cards", len(parsedCards),
+		"added", addedCards,
 		"orphaned_deleted", orphanedCards,
 		"errors", len(parseErrors),
+		"issues", len(issues),
 	)
+	return nil
+}
+
+// parseIssues returns the issues the parser found in file as stored.
+func parseIssues(file, severity string, found []parser.Issue) []storage.ParseIssue {
+	issues := make([]storage.ParseIssue, len(found))
+	for i, issue := range found {
+		issues[i] = storage.ParseIssue{File: file, Line: issue.Line, Severity: severity, Message: issue.Err.Error()}
+	}
+	return issues
 }
+
+// fileProvenance returns the slash-separated path of a file from the source's root
+// and when it last changed.
+func fileProvenance(root, path string, d fs.DirEntry, changed map[string]time.Time) (string, time.Time) {
+	file, err := filepath.Rel(root, path)
+	if err != nil {
+		file = path
+	}
+	file = filepath.ToSlash(file)
+	if modified, ok := changed[file]; ok {
+		return file, modified
+	}
+	var modified time.Time
+	if info, err := d.Info(); err == nil {
+		modified = info.ModTime()
+	}
+	return file, modified
+}
+
+// resolveMedia resolves the paths of the images of a card in file from the
+// source's root, dropping those outside the source, which are never served.
+func resolveMedia(file string, images []string) []string {
+	var media []string
+	for _, image := range images {
+		if p, ok := parser.ResolveMedia(file, image); ok {
+			media = append(media, p)
+		} else {
+			slog.Warn("Image outside its source ignored", "file", file, "image", image)
+		}
+	}
+	return media
+}
+
+// Syntax returns the syntax of a source's Markdown cards.
+func Syntax(source storage.Source) parser.Config {
+	return parser.Config{
+		Question:   source.QuestionPrefix,
+		Answer:     source.AnswerPrefix,
+		Context:    source.ContextPrefix,
+		Separator:  source.Separator,
+		IgnoreCase: source.IgnoreCase,
+		Extensions: source.Extensions,
+	}
+}
+
+// DefaultExclude are the directories skipped in sources that don't set their
+// own: dependencies, git's own files and build output, which copy or generate
+// notes rather than hold them.
+var DefaultExclude = []string{"node_modules", ".git", "build"}
+
+// excluded reports whether the directory dir below root matches a pattern
+// of exclude, by name or by slash-separated path from root, e.g. node_modules,
+// _drafts* or docs/archive.
+func excluded(root, dir string, exclude []string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, filepath.Base(dir)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFile parses the cards of the Markdown, Anki or org file at path, in the
+// syntax of cfg, after the pre-parse hooks of any plugins have transformed it.
+func parseFile(path string, cfg parser.Config) parser.ParseResult {
+	content, err := readCardFile(path)
+	if err != nil {
+		return parser.ParseResult{Errors: []parser.Issue{{Err: err}}}
+	}
+	return parser.ParseCards(path, content, cfg)
+}
+
+// readCardFile reads the file at path, if it is within the parser's size
+// limits, and runs the pre-parse hooks of any plugins over it.
+func readCardFile(path string) ([]byte, error) {
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if err := parser.CheckFileSize(info.Size()); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return hooks.Transform(path, content)
+}
+
+// adoptHash renames the card stored under an earlier hash of a card to its
+// hash, and returns it, or nil if there is none: the content hash of a card
+// given an ID, or the legacy content hash of a card with math.
+func adoptHash(db *storage.DB, card domain.Card) (*storage.Card, error) {
+	var earlier []string
+	if card.ID != "" {
+		earlier = append(earlier, knol.ContentHash(card))
+	}
+	earlier = append(earlier, knol.LegacyContentHash(card))
+	for _, hash := range earlier {
+		if hash == card.Hash {
+			continue
+		}
+		existing, err := db.FindCardByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			continue
+		}
+		slog.Info("Renaming card to its new hash", "hash", hash, "new_hash", card.Hash)
+		if err := db.RenameCard(hash, card.Hash); err != nil {
+			return nil, err
+		}
+		existing.Hash = card.Hash
+		return existing, nil
+	}
+	return nil, nil
+}
+
+// contentChanged reports whether the fields of a card making up its content
+// hash differ from those stored.
+func contentChanged(stored *storage.Card, card domain.Card) bool {
+	return stored.Question != card.Question || stored.Answer != card.Answer || stored.Kind != card.Kind ||
+		stored.Cloze != card.Cloze || !slices.Equal(stored.Distractors, card.Distractors) || !slices.Equal(stored.Steps, card.Steps)
+}
+
+// needsRelink reports whether an existing card found in a source should be linked
+// to it, which is the case when the card has no source or its source no longer exists.
+// Cards that belong to another live source are left alone.
+func needsRelink(db *storage.DB, card *storage.Card, sourceID int64) (bool, error) {
+	if !card.SourceID.Valid {
+		return true, nil
+	}
+	if card.SourceID.Int64 == sourceID {
+		return false, nil
+	}
+	owner, err := db.FindSourceByID(card.SourceID.Int64)
+	if err != nil {
+		return false, err
+	}
+	return owner == nil, nil
+}
+
 func gitUrlToLocalPath(baseDir, repoURL string) (string, error) {
 	parsedURL, err := url.Parse(repoURL)
 	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") {
@@ -140,7 +573,7 @@ func gitUrlToLocalPath(baseDir, repoURL string) (string, error) {
 				if len(hostAndUser) == 2 {
 					host := hostAndUser[1]
 					repoPath := strings.TrimSuffix(parts[1], ".git")
-					return filepath.Join(baseDir, host, repoPath), nil
+					return pathsafe.Join(baseDir, host, repoPath), nil
 				}
 			}
 		}
@@ -148,5 +581,16 @@ func gitUrlToLocalPath(baseDir, repoURL string) (string, error) {
 	}
 
 	sanitizedPath := strings.TrimSuffix(parsedURL.Path, ".git")
-	return filepath.Join(baseDir, parsedURL.Host, sanitizedPath), nil
+	return pathsafe.Join(baseDir, parsedURL.Host, sanitizedPath), nil
+}
+
+// urlToLocalPath returns the directory a URL source is downloaded into, mirroring
+// the URL's host and path (without the file extension) below baseDir.
+func urlToLocalPath(baseDir, rawURL string) (string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return "", fmt.Errorf("could not parse source URL: %s", rawURL)
+	}
+	sanitizedPath := strings.TrimSuffix(parsedURL.Path, path.Ext(parsedURL.Path))
+	return pathsafe.Join(baseDir, parsedURL.Host, sanitizedPath), nil
 }
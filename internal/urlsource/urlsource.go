@@ -0,0 +1,214 @@
+package urlsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/netconf"
+)
+
+const (
+	gistHost    = "gist.github.com"
+	gistAPIBase = "https://api.github.com/gists/"
+
+	// etagFile stores the ETag of the last successful fetch in the source's directory.
+	etagFile = ".etag"
+
+	fetchTimeout = time.Minute
+)
+
+// Matches reports whether path is a URL source: a GitHub Gist page or a raw
+// markdown file served over HTTP(S). Gist clone URLs ending in .git are left
+// to the git source type.
+func Matches(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return false
+	}
+	if u.Host == gistHost {
+		return !strings.HasSuffix(u.Path, ".git")
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".md")
+}
+
+// Sync downloads the markdown file(s) behind rawURL into localDir, replacing
+// the files of a previous sync. The request carries the ETag of the previous
+// sync, so unchanged sources are not downloaded again.
+func Sync(rawURL, localDir string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL %s: %w", rawURL, err)
+	}
+	if err := os.MkdirAll(localDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", localDir, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	if u.Host == gistHost {
+		return syncGist(ctx, gistAPIBase+path.Base(u.Path), localDir)
+	}
+	return syncFile(ctx, rawURL, localDir)
+}
+
+// syncFile downloads a single file into localDir, named after the last element of its URL path.
+func syncFile(ctx context.Context, rawURL, localDir string) error {
+	body, etag, err := fetch(ctx, rawURL, readETag(localDir))
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		slog.Info("URL source unchanged", "url", rawURL)
+		return nil
+	}
+
+	u, _ := url.Parse(rawURL)
+	files := map[string][]byte{path.Base(u.Path): body}
+	if err := replaceFiles(localDir, files); err != nil {
+		return err
+	}
+	writeETag(localDir, etag)
+	slog.Info("Downloaded URL source", "url", rawURL)
+	return nil
+}
+
+// gist is the subset of the GitHub API's gist response used to materialize its files.
+type gist struct {
+	Files map[string]struct {
+		Filename  string `json:"filename"`
+		RawURL    string `json:"raw_url"`
+		Truncated bool   `json:"truncated"`
+		Content   string `json:"content"`
+	} `json:"files"`
+}
+
+// syncGist downloads the markdown files of the gist described at apiURL into localDir.
+func syncGist(ctx context.Context, apiURL, localDir string) error {
+	body, etag, err := fetch(ctx, apiURL, readETag(localDir))
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		slog.Info("Gist unchanged", "url", apiURL)
+		return nil
+	}
+
+	var g gist
+	if err := json.Unmarshal(body, &g); err != nil {
+		return fmt.Errorf("failed to decode gist %s: %w", apiURL, err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range g.Files {
+		if !strings.HasSuffix(strings.ToLower(f.Filename), ".md") {
+			continue
+		}
+		content := []byte(f.Content)
+		if f.Truncated {
+			// The API only inlines the first megabyte of each file.
+			if content, _, err = fetch(ctx, f.RawURL, ""); err != nil {
+				return err
+			}
+		}
+		files[f.Filename] = content
+	}
+
+	if err := replaceFiles(localDir, files); err != nil {
+		return err
+	}
+	writeETag(localDir, etag)
+	slog.Info("Downloaded gist", "url", apiURL, "files", len(files))
+	return nil
+}
+
+// fetch GETs rawURL. If etag is set and the resource has not changed, it returns a nil body.
+func fetch(ctx context.Context, rawURL, etag string) (body []byte, newETag string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request for %s: %w", rawURL, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := netconf.Client().Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, etag, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("failed to fetch %s: unexpected status %s", rawURL, resp.Status)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	return body, resp.Header.Get("ETag"), nil
+}
+
+// replaceFiles writes files into dir and removes any other markdown files left from earlier syncs.
+func replaceFiles(dir string, files map[string][]byte) error {
+	for name := range files {
+		// Names come from the remote, so never let them escape dir.
+		if name != filepath.Base(name) || name == "." || name == ".." {
+			return fmt.Errorf("refusing to write file with unsafe name %q", name)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if _, keep := files[e.Name()]; !keep && strings.HasSuffix(strings.ToLower(e.Name()), ".md") {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return fmt.Errorf("failed to remove stale file %s: %w", e.Name(), err)
+			}
+		}
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readETag returns the ETag recorded by the last sync into dir, or "" if there is none.
+func readETag(dir string) string {
+	etag, err := os.ReadFile(filepath.Join(dir, etagFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Failed to read ETag", "dir", dir, "error", err)
+	}
+	return strings.TrimSpace(string(etag))
+}
+
+// writeETag records etag for the next sync into dir. Failing to do so only costs a re-download.
+func writeETag(dir, etag string) {
+	p := filepath.Join(dir, etagFile)
+	if etag == "" {
+		os.Remove(p)
+		return
+	}
+	if err := os.WriteFile(p, []byte(etag), 0o644); err != nil {
+		slog.Warn("Failed to write ETag", "dir", dir, "error", err)
+	}
+}
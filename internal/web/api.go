@@ -0,0 +1,185 @@
+package web
+
+import (
+	"crypto/subtle"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIConfig opens the JSON API, under /api/, to frontends served from other
+// origins, such as a browser-based review client, and guards it with tokens and
+// rate limits.
+type APIConfig struct {
+	// CORSOrigins are the origins whose pages may call the API, e.g.
+	// https://review.example.org, or * for any
+	CORSOrigins []string `koanf:"cors_origins"`
+	// Tokens maps names, e.g. of the frontends, to bearer tokens. When set,
+	// every API request needs one in its Authorization header.
+	Tokens map[string]string `koanf:"tokens"`
+	// WidgetTokens maps names, e.g. of home dashboards, to tokens that only read
+	// the widgets under /api/widgets/, also given as a token query parameter for
+	// iframes. When set, or with Tokens, the widgets need one of either.
+	WidgetTokens map[string]string `koanf:"widget_tokens"`
+	// RateLimit is the number of API requests allowed per minute for each token,
+	// or each client address without tokens; unlimited when 0
+	RateLimit int `koanf:"rate_limit" validate:"gte=0"`
+}
+
+// APIHandler serves next, applying cfg to the requests for the JSON API. The
+// preflight requests of allowed origins are answered without reaching next.
+func APIHandler(next http.Handler, cfg APIConfig) http.Handler {
+	if len(cfg.CORSOrigins) == 0 && len(cfg.Tokens) == 0 && len(cfg.WidgetTokens) == 0 && cfg.RateLimit == 0 {
+		return next
+	}
+	return &apiGuard{next: next, cfg: cfg, limiter: newRateLimiter(cfg.RateLimit, time.Minute)}
+}
+
+// apiGuard is the handler returned by APIHandler.
+type apiGuard struct {
+	next    http.Handler
+	cfg     APIConfig
+	limiter *rateLimiter
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (g *apiGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && g.allowedOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if r.Method == http.MethodOptions { // Preflights carry no credentials
+		g.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := clientAddress(r)
+	widget := strings.HasPrefix(r.URL.Path, "/api/widgets/")
+	if len(g.cfg.Tokens) > 0 || widget && len(g.cfg.WidgetTokens) > 0 {
+		name, ok := g.token(r, widget)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="knolhash"`)
+			http.Error(w, "Missing or invalid API token", http.StatusUnauthorized)
+			return
+		}
+		key = "token:" + name
+	}
+	if wait, ok := g.limiter.allow(key, time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+	g.next.ServeHTTP(w, r)
+}
+
+// allowedOrigin reports whether pages from origin may call the API.
+func (g *apiGuard) allowedOrigin(origin string) bool {
+	return slices.Contains(g.cfg.CORSOrigins, "*") || slices.Contains(g.cfg.CORSOrigins, origin)
+}
+
+// token returns the name of the token a request carries, if it is one of the
+// configured tokens. The API's tokens are only taken from the Authorization
+// header, so they stay out of access logs and Referer headers; the widgets, which
+// dashboards may embed in an iframe, also take a widget token as a token query
+// parameter.
+func (g *apiGuard) token(r *http.Request, widget bool) (string, bool) {
+	scheme, bearer, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && bearer != "" {
+		if name, ok := matchToken(g.cfg.Tokens, bearer); ok {
+			return name, true
+		}
+		if widget {
+			if name, ok := matchToken(g.cfg.WidgetTokens, bearer); ok {
+				return "widget:" + name, true
+			}
+		}
+		return "", false
+	}
+	if query := r.URL.Query().Get("token"); widget && query != "" {
+		if name, ok := matchToken(g.cfg.WidgetTokens, query); ok {
+			return "widget:" + name, true
+		}
+	}
+	return "", false
+}
+
+// matchToken returns the name of token in tokens, comparing in constant time.
+func matchToken(tokens map[string]string, token string) (string, bool) {
+	for name, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// clientAddress returns the IP address a request came from.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter allows a number of requests per key in each window of time, counted
+// from the key's first request in it.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]rateWindow
+}
+
+// rateWindow is the current window of a key of a rateLimiter.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, windows: make(map[string]rateWindow)}
+}
+
+// allow counts a request for key at now, and reports whether it is within the
+// limit or else how long until the next window, when there is a limit.
+func (l *rateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	if l.limit <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := l.windows[key]
+	if now.Sub(w.start) >= l.window {
+		// Forget the windows that ended, so the map doesn't grow with every client
+		for k, old := range l.windows {
+			if now.Sub(old.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		w = rateWindow{start: now}
+	}
+	if w.count >= l.limit {
+		return w.start.Add(l.window).Sub(now), false
+	}
+	w.count++
+	l.windows[key] = w
+	return 0, true
+}
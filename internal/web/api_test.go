@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestAPITokensReviewApp(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	handler := APIHandler(NewServer(db, false, false), APIConfig{Tokens: map[string]string{"app": "secret"}})
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/app/", "/app/app.js"} {
+		if rec := serve(http.MethodGet, path, "", ""); rec.Code != http.StatusOK {
+			t.Errorf("Expected the client at %s to load without a token, but got %d", path, rec.Code)
+		}
+	}
+	if rec := serve(http.MethodGet, "/app/app.js", "", ""); !strings.Contains(rec.Body.String(), "'Bearer ' + token") {
+		t.Errorf("Expected the client to send its token as a bearer token")
+	}
+
+	next := `{"method": "next"}`
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		if rec := serve(http.MethodPost, "/api/review", token, next); rec.Code != want {
+			t.Errorf("Expected the client's API call with token %q to get %d, but got %d", token, want, rec.Code)
+		}
+	}
+
+	rec := serve(http.MethodPost, "/api/review", "secret", next)
+	var resp struct {
+		Result map[string]any `json:"result"`
+		Error  string         `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Error != "" || resp.Result == nil {
+		t.Errorf("Expected a result for next with the token, but got %q, %v", resp.Error, err)
+	}
+}
+
+func TestAPIWidgetTokens(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	server := NewServer(db, false, false)
+
+	serve := func(handler http.Handler, method, path, bearer string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"method": "next"}`))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	handler := APIHandler(server, APIConfig{Tokens: map[string]string{"app": "secret"}, WidgetTokens: map[string]string{"dashboard": "widget"}})
+	for _, tc := range []struct {
+		method, path, bearer string
+		want                 int
+	}{
+		{http.MethodGet, "/api/widgets/status?token=widget", "", http.StatusOK},
+		{http.MethodGet, "/api/widgets/status", "widget", http.StatusOK},
+		{http.MethodGet, "/api/widgets/status", "secret", http.StatusOK},
+		{http.MethodGet, "/api/widgets/status", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/widgets/status?token=secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/review?token=secret", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/review?token=widget", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/review", "widget", http.StatusUnauthorized},
+		{http.MethodPost, "/api/review", "secret", http.StatusOK},
+	} {
+		if got := serve(handler, tc.method, tc.path, tc.bearer); got != tc.want {
+			t.Errorf("Expected %s %s with bearer %q to get %d, but got %d", tc.method, tc.path, tc.bearer, tc.want, got)
+		}
+	}
+
+	handler = APIHandler(server, APIConfig{WidgetTokens: map[string]string{"dashboard": "widget"}})
+	if got := serve(handler, http.MethodGet, "/api/widgets/status", ""); got != http.StatusUnauthorized {
+		t.Errorf("Expected the widgets to need a widget token, but got %d", got)
+	}
+	if got := serve(handler, http.MethodPost, "/api/review", ""); got != http.StatusOK {
+		t.Errorf("Expected the rest of the API to stay open without API tokens, but got %d", got)
+	}
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/conorfennell/knolhash/internal/rpc"
+)
+
+// maxReviewRequest limits the size of a request to the review API.
+const maxReviewRequest = 1 << 20
+
+// reviewAPI is the review session of the JSON API, shared by its clients as
+// the server has a single user, so cards graded Again on one device come back
+// on another.
+type reviewAPI struct {
+	mu      sync.Mutex
+	session *rpc.Session
+}
+
+// handleReviewAPI serves the methods of `knolhash review` over HTTP, for
+// clients such as the single-page app under /app: each POST /api/review takes
+// a request object, e.g. {"method": "next"}, and answers with a response
+// object, with its result or error.
+func (s *Server) handleReviewAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req rpc.Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReviewRequest)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.reviews.mu.Lock()
+		resp := s.reviews.session.Handle(req)
+		s.reviews.mu.Unlock()
+		writeJSON(w, r, resp)
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestReviewAPIConcurrentClients(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	sourceID, err := db.InsertSource("/notes", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const cards = 8
+	for i := range cards {
+		if err := db.InsertCard(domain.Card{Hash: fmt.Sprintf("card%d", i), Question: "Q", Answer: "A"}, sourceID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewServer(db, false, false)
+
+	call := func(body string) (result map[string]any, errMsg string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/review", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		var resp struct {
+			Result map[string]any `json:"result"`
+			Error  string         `json:"error"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			return nil, fmt.Sprintf("status %d: %v", rec.Code, err)
+		}
+		return resp.Result, resp.Error
+	}
+
+	// Clients on several devices share the session; each grades what it's shown
+	const clients, rounds = 8, 5
+	var wg sync.WaitGroup
+	errs := make(chan string, 2*clients*rounds)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				front, msg := call(`{"method": "next"}`)
+				if msg != "" {
+					errs <- msg
+					return
+				}
+				card, _ := front["card"].(map[string]any)
+				if card == nil {
+					return
+				}
+				if _, msg := call(fmt.Sprintf(`{"method": "grade", "params": {"card": %q, "grade": 1}}`, card["hash"])); msg != "" {
+					errs <- msg
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Errorf("Expected concurrent reviews to succeed, but got %s", msg)
+	}
+
+	// Cards graded Again on any client come back in the shared queue
+	front, msg := call(`{"method": "next"}`)
+	if msg != "" || front["card"] == nil || front["due"] != float64(cards) {
+		t.Errorf("Expected all %d cards still due in the shared session, but got %v, %s", cards, front, msg)
+	}
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// startedAt is the Last-Modified time of the embedded static files, which can
+// only change with the binary. Truncated to seconds, as HTTP dates are.
+var startedAt = time.Now().Truncate(time.Second)
+
+// etag quotes a hex checksum as a strong entity tag.
+func etag(sum string) string {
+	return `"` + sum[:32] + `"`
+}
+
+// handleStatic serves the embedded static files by their path after prefix, and
+// the index.html of a directory for its path ending in /, with their checksum
+// as ETag so clients revalidate them cheaply with conditional requests.
+func (s *Server) handleStatic(staticFS fs.FS, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		if strings.HasSuffix(name, "/") {
+			name += "index.html"
+		}
+		f, err := staticFS.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		content, ok := f.(io.ReadSeeker)
+		if err != nil || info.IsDir() || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if sum, ok := s.assetSums["static/"+name]; ok {
+			w.Header().Set("ETag", etag(sum))
+		}
+		// Cached, but revalidated on every use, as the URLs aren't versioned
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, name, startedAt, content)
+	}
+}
+
+// serveConditional writes the body of a response to a GET request with its
+// checksum as ETag, or 304 Not Modified if the client already has it.
+func serveConditional(w http.ResponseWriter, r *http.Request, name string, body []byte) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", etag(hex.EncodeToString(sum[:])))
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
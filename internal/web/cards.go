@@ -0,0 +1,154 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// handleCard routes the requests for a single card under /cards/{hash}: its
+// detail page, and actions that re-render the detail page when taken from it
+// (view=card) and the stats page otherwise.
+func (s *Server) handleCard() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/cards/"), "/")
+		card, err := s.db.FindCardByHash(hash)
+		if err != nil {
+			slog.Error("Error getting card", "hash", hash, "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if card == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if action == "" {
+			if r.Method != http.MethodGet {
+				s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			s.renderCard(w, r, card)
+			return
+		}
+		if r.Method != http.MethodPost {
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch action {
+		case "suspend":
+			err = s.db.SetCardSuspended(hash, true)
+		case "unsuspend":
+			err = s.db.SetCardSuspended(hash, false)
+		case "reset":
+			err = s.db.ResetCard(hash)
+		case "due":
+			due, parseErr := time.ParseInLocation(time.DateOnly, r.PostFormValue("due"), time.Local)
+			if parseErr != nil {
+				s.renderError(w, r, "Invalid due date", http.StatusBadRequest)
+				return
+			}
+			_, err = s.db.SetCardDueDate(hash, due)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("Error updating card", "hash", hash, "action", action, "error", err)
+			s.renderError(w, r, "Failed to update card", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Card updated", "hash", hash, "action", action)
+
+		if r.PostFormValue("view") != "card" {
+			s.handleGetStats()(w, r)
+			return
+		}
+		card, err = s.db.FindCardByHash(hash)
+		if err != nil || card == nil {
+			slog.Error("Error getting card after update", "hash", hash, "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.renderCard(w, r, card)
+	}
+}
+
+// renderCard renders the detail page of a card with its review history.
+func (s *Server) renderCard(w http.ResponseWriter, r *http.Request, card *storage.Card) {
+	logs, err := s.db.GetReviewLogsByCard(card.Hash)
+	if err != nil {
+		slog.Error("Error getting review history", "hash", card.Hash, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	var source *storage.Source
+	if card.SourceID.Valid {
+		if source, err = s.db.FindSourceByID(card.SourceID.Int64); err != nil {
+			slog.Error("Error getting source of card", "hash", card.Hash, "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.render(w, r, "card_detail", map[string]interface{}{
+		"Card":    card,
+		"Source":  source,
+		"Reviews": logs,
+		"Chart":   newIntervalChart(logs),
+	})
+}
+
+// intervalChart plots a card's interval (its stability in days) after every
+// review, as coordinates for an SVG drawing of Width by Height.
+type intervalChart struct {
+	Width, Height float64
+	ViewBox       string // Leaves room around the plot for the axis labels
+	Line          string // Polyline points
+	Reviews       []chartPoint
+	Resets        []float64 // X of reviews that started the card over as new
+	MaxDays       float64
+	From, To      time.Time
+}
+
+type chartPoint struct {
+	X, Y  float64
+	Grade int
+}
+
+// newIntervalChart lays out the chart for a card's reviews, oldest first.
+// It returns nil without reviews.
+func newIntervalChart(logs []storage.ReviewLog) *intervalChart {
+	if len(logs) == 0 {
+		return nil
+	}
+	c := &intervalChart{Width: 600, Height: 200, From: logs[0].ReviewedAt, To: logs[len(logs)-1].ReviewedAt}
+	c.ViewBox = fmt.Sprintf("-50 -15 %.0f %.0f", c.Width+70, c.Height+40)
+	c.MaxDays = 1 // Keeps the scale for cards that were never remembered for a day
+	for _, log := range logs {
+		c.MaxDays = max(c.MaxDays, log.StabilityAfter)
+	}
+	span := c.To.Sub(c.From)
+
+	var line []string
+	for i, log := range logs {
+		x := 0.0
+		if span > 0 {
+			x = c.Width * float64(log.ReviewedAt.Sub(c.From)) / float64(span)
+		}
+		y := c.Height - c.Height*log.StabilityAfter/c.MaxDays
+		x, y = math.Round(x*10)/10, math.Round(y*10)/10
+		line = append(line, fmt.Sprintf("%.1f,%.1f", x, y))
+		c.Reviews = append(c.Reviews, chartPoint{X: x, Y: y, Grade: log.Grade})
+		if i > 0 && log.StabilityBefore == 0 && !log.Manual {
+			c.Resets = append(c.Resets, x)
+		}
+	}
+	c.Line = strings.Join(line, " ")
+	return c
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+	gosync "sync"
+)
+
+// gzipWriters are reused across responses, as each allocates large buffers.
+var gzipWriters = gosync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// acceptsGzip reports whether the client accepts gzip-encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible reports whether responses of a content type are worth compressing:
+// text, such as HTML, and JSON, but not images, which are already compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" ||
+		mediaType == "application/javascript" || mediaType == "image/svg+xml" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// gzipResponseWriter gzips the body of a response if its content type is
+// compressible, deciding when the header is written. Brotli is left to a reverse
+// proxy, such as Caddy's encode directive, as the standard library lacks it.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer // nil while undecided and when not compressing
+	decided bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if !g.decided {
+		g.decide(code)
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.decided {
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, flushing the compressed data written so far.
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide starts compressing a response with status code, unless it has no body,
+// is a range of the content or is already encoded.
+func (g *gzipResponseWriter) decide(code int) {
+	g.decided = true
+	h := g.Header()
+	if !compressible(h.Get("Content-Type")) {
+		return
+	}
+	h.Add("Vary", "Accept-Encoding")
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusPartialContent ||
+		code == http.StatusNotModified || h.Get("Content-Encoding") != "" {
+		return
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	// The compressed bytes differ, so the ETag can only be weak; If-None-Match
+	// compares weakly, so it still matches the uncompressed one
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	g.gz = gzipWriters.Get().(*gzip.Writer)
+	g.gz.Reset(g.ResponseWriter)
+}
+
+// close finishes the compressed body, if any.
+func (g *gzipResponseWriter) close() {
+	if g.gz == nil {
+		return
+	}
+	g.gz.Close()
+	gzipWriters.Put(g.gz)
+	g.gz = nil
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/conorfennell/knolhash/internal/bundle"
+)
+
+// maxBundleSize limits the size of an uploaded deck bundle.
+const maxBundleSize = 256 << 20
+
+// handlePostDeckImport imports an uploaded .knol bundle as a new local source and
+// re-renders the source list. The deck's cards appear with the next sync.
+func (s *Server) handlePostDeckImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBundleSize)
+		file, header, err := r.FormFile("bundle")
+		if err != nil {
+			s.renderError(w, r, "No deck bundle uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		m, dir, err := bundle.Import(s.db, file, header.Size, header.Filename)
+		if err != nil {
+			slog.Error("Error importing deck", "file", header.Filename, "error", err)
+			s.renderError(w, r, "Failed to import deck: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("Imported deck", "name", m.Name, "cards", m.Cards, "path", dir)
+
+		sources, err := s.db.GetAllSources()
+		if err != nil {
+			slog.Error("Error getting sources after import", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.templates.ExecuteTemplate(w, "source_list", s.sourceListData(sources))
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"net/http"
+	gosync "sync"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// dueCountTTL is how long the deck's due counts are cached. Changes made through
+// the server, such as reviews, and syncs invalidate them sooner.
+const dueCountTTL = 30 * time.Second
+
+// dueCounts are the counts of due cards shown on the deck.
+type dueCounts struct {
+	Due     int
+	Writing int // Writing prompts among the due cards
+}
+
+// dueCache caches the deck's due counts, so rendering it does not load every
+// due card each time.
+type dueCache struct {
+	mu         gosync.Mutex
+	counts     dueCounts
+	loaded     time.Time
+	expires    time.Time
+	generation int // Incremented on invalidation, so a load racing it isn't stored
+}
+
+// get returns the cached counts, calling load if they have expired or been invalidated.
+func (c *dueCache) get(load func() (dueCounts, error)) (dueCounts, error) {
+	c.mu.Lock()
+	if time.Now().Before(c.expires) && !sync.LastFinished().After(c.loaded) {
+		defer c.mu.Unlock()
+		return c.counts, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	loaded := time.Now()
+	counts, err := load()
+	if err != nil {
+		return counts, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.counts, c.loaded, c.expires = counts, loaded, loaded.Add(dueCountTTL)
+	}
+	return counts, nil
+}
+
+// invalidate drops the cached counts.
+func (c *dueCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.expires = time.Time{}
+}
+
+// changes reports whether a request may change cards, reviews or preferences, and
+// so the due counts.
+func changes(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
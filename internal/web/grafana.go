@@ -0,0 +1,129 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/stats"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// grafanaMetric is a daily series served to Grafana's JSON datasource.
+type grafanaMetric struct {
+	Label  string
+	Series func(db *storage.DB, p prefs.Preferences, from, to time.Time) ([]stats.Point, error)
+}
+
+// grafanaMetrics are the series Grafana can query, by target name.
+var grafanaMetrics = map[string]grafanaMetric{
+	"reviews_per_day":   {"Reviews per day", stats.ReviewsPerDay},
+	"retention_per_day": {"Retention per day", stats.RetentionPerDay},
+	"minutes_per_day":   {"Minutes studied per day", stats.MinutesPerDay},
+	"due_forecast": {"Cards due per day", func(db *storage.DB, p prefs.Preferences, from, to time.Time) ([]stats.Point, error) {
+		// Only the future can be forecast.
+		if now := db.Now(); from.Before(now) {
+			from = now
+		}
+		return stats.DueForecast(db, p, from, to)
+	}},
+}
+
+// handleGrafana implements the JSON datasource protocol under /api/grafana/:
+// GET / for the connection test, POST /search and /metrics to list the series,
+// and POST /query to fetch them.
+func (s *Server) handleGrafana() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/grafana/" && r.Method == http.MethodGet:
+			w.Write([]byte("OK"))
+		case r.URL.Path == "/api/grafana/search" && r.Method == http.MethodPost:
+			writeJSON(w, r, slices.Sorted(maps.Keys(grafanaMetrics)))
+		case r.URL.Path == "/api/grafana/metrics" && r.Method == http.MethodPost:
+			type option struct {
+				Label string `json:"label"`
+				Value string `json:"value"`
+			}
+			var options []option
+			for _, name := range slices.Sorted(maps.Keys(grafanaMetrics)) {
+				options = append(options, option{Label: grafanaMetrics[name].Label, Value: name})
+			}
+			writeJSON(w, r, options)
+		case r.URL.Path == "/api/grafana/query" && r.Method == http.MethodPost:
+			s.handleGrafanaQuery(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// handleGrafanaQuery returns the requested series over the dashboard's time range,
+// one point per study day at the day's start.
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Range struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		} `json:"range"`
+		Targets []struct {
+			Target string `json:"target"`
+			Hide   bool   `json:"hide"`
+		} `json:"targets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		slog.Error("Error loading preferences", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	type series struct {
+		Target     string       `json:"target"`
+		Datapoints [][2]float64 `json:"datapoints"` // [value, Unix milliseconds]
+	}
+	result := []series{}
+	for _, t := range req.Targets {
+		m, ok := grafanaMetrics[t.Target]
+		if t.Hide || !ok {
+			continue
+		}
+		points, err := m.Series(s.db, p, req.Range.From, req.Range.To)
+		if err != nil {
+			slog.Error("Error computing Grafana series", "target", t.Target, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		datapoints := make([][2]float64, len(points))
+		for i, pt := range points {
+			datapoints[i] = [2]float64{pt.Value, float64(pt.Time.UnixMilli())}
+		}
+		result = append(result, series{Target: t.Target, Datapoints: datapoints})
+	}
+	writeJSON(w, r, result)
+}
+
+// writeJSON writes v as a JSON response, answering conditional GET requests.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		slog.Error("Error encoding JSON response", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		serveConditional(w, r, "", buf.Bytes())
+		return
+	}
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,22 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// handleGetBrokenLinks renders the report of relative links and images in cards
+// whose targets are missing from their source.
+func (s *Server) handleGetBrokenLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		broken, err := sync.CheckLinks(s.db)
+		if err != nil {
+			slog.Error("Error checking links", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.render(w, r, "broken_links", broken)
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/conorfennell/knolhash/internal/latex"
+)
+
+// kindMath is the kind of the math nodes of a card's Markdown.
+var kindMath = ast.NewNodeKind("Math")
+
+// mathNode is LaTeX math, $...$ or $$...$$, kept raw for rendering in the page.
+type mathNode struct {
+	ast.BaseInline
+	TeX     []byte
+	Display bool
+}
+
+// Kind implements ast.Node.
+func (n *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+// Dump implements ast.Node.
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// mathExtension keeps the LaTeX math of cards out of Markdown's hands, so that
+// the underscores and asterisks of formulas aren't taken for emphasis. Math is
+// rendered as its TeX, escaped, in a span of class math between \( and \) or
+// \[ and \], which the page typesets with KaTeX.
+type mathExtension struct{}
+
+// Extend implements goldmark.Extender.
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(mathParser{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 500)))
+}
+
+// mathParser parses math by the rules of latex.Spans: inline math ends on its
+// line, display math may run over several.
+type mathParser struct{}
+
+// Trigger implements parser.InlineParser.
+func (mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse implements parser.InlineParser.
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := "$"
+	if bytes.HasPrefix(line, []byte("$$")) {
+		delim = "$$"
+	}
+	l, pos := block.Position()
+	block.Advance(len(delim))
+	var tex []byte
+	for {
+		line, _ := block.PeekLine()
+		if line == nil {
+			break
+		}
+		if end := latex.Close(string(line), delim); end >= 0 {
+			block.Advance(end + len(delim))
+			return &mathNode{TeX: append(tex, line[:end]...), Display: delim == "$$"}
+		}
+		if delim == "$" {
+			break
+		}
+		tex = append(tex, line...)
+		block.AdvanceLine()
+	}
+	block.SetPosition(l, pos) // Not math: the $ is text
+	return nil
+}
+
+// mathRenderer renders math nodes.
+type mathRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.render)
+}
+
+func (mathRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	m := n.(*mathNode)
+	if m.Display {
+		w.WriteString(`<span class="math display">\[` + html.EscapeString(string(m.TeX)) + `\]</span>`)
+	} else {
+		w.WriteString(`<span class="math inline">\(` + html.EscapeString(string(m.TeX)) + `\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/conorfennell/knolhash/internal/parser"
+	"github.com/conorfennell/knolhash/internal/sync"
+)
+
+// imageExts are the extensions of the media files served to cards.
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".avif": true,
+}
+
+// mediaURL returns the URL an image of a card is served from, given its path
+// from the source's root.
+func mediaURL(hash, p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return "/media/" + hash + "/" + strings.Join(segments, "/")
+}
+
+// cardMarkdown renders the Markdown of a card found in file, pointing its
+// relative images at /media, where they are served from the card's source.
+func cardMarkdown(md goldmark.Markdown, hash, file, source string) template.HTML {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := n.(*ast.Image)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if image, ok := parser.ImagePath(string(img.Destination)); ok {
+			if p, ok := parser.ResolveMedia(file, image); ok {
+				img.Destination = []byte(mediaURL(hash, p))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return template.HTML("<p>Error rendering markdown</p>")
+	}
+	return template.HTML(buf.String())
+}
+
+// handleGetMedia serves an image shown by a card, /media/{hash}/{path} with the
+// path from the root of the card's source. Only the images recorded for the
+// card when it was synced are served.
+func (s *Server) handleGetMedia() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash, p, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/media/"), "/")
+		card, err := s.db.FindCardByHash(hash)
+		if err != nil {
+			slog.Error("Error getting card", "hash", hash, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if card == nil || !card.SourceID.Valid || !slices.Contains(card.Media, p) ||
+			!imageExts[strings.ToLower(filepath.Ext(p))] || !filepath.IsLocal(filepath.FromSlash(p)) {
+			http.NotFound(w, r)
+			return
+		}
+		source, err := s.db.FindSourceByID(card.SourceID.Int64)
+		if err != nil || source == nil {
+			http.NotFound(w, r)
+			return
+		}
+		root, err := sync.LocalPath(*source)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// An SVG opened on its own must not run scripts with the app's origin.
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		http.ServeFile(w, r, filepath.Join(root, filepath.FromSlash(p)))
+	}
+}
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/review"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// obsidianOrigins are the origins of Obsidian's desktop and mobile apps, which
+// may call the Obsidian API from a plugin.
+var obsidianOrigins = map[string]bool{
+	"app://obsidian.md":     true,
+	"capacitor://localhost": true,
+	"http://localhost":      true,
+}
+
+// cardStates names the scheduling states of storage.Card.State.
+var cardStates = []string{"new", "learning", "review"}
+
+// obsidianCard is a card's place in its note and its scheduling state, for
+// decorating the note in Obsidian's editor.
+type obsidianCard struct {
+	Hash       string     `json:"hash"`
+	SourceID   int64      `json:"source_id"`
+	File       string     `json:"file"`
+	Line       int        `json:"line"`
+	Question   string     `json:"question"`
+	Kind       string     `json:"kind"`
+	Cloze      int        `json:"cloze,omitempty"` // Tells apart the text cloze cards of a question
+	State      string     `json:"state"`
+	Suspended  bool       `json:"suspended"`
+	Due        bool       `json:"due"` // Due now
+	DueDate    time.Time  `json:"due_date"`
+	LastReview *time.Time `json:"last_review,omitempty"`
+	Stability  float64    `json:"stability"`
+	Difficulty float64    `json:"difficulty"`
+}
+
+// newObsidianCard describes a card to the plugin, due if its due date is not after now.
+func newObsidianCard(c storage.Card, now time.Time) obsidianCard {
+	oc := obsidianCard{
+		Hash:       c.Hash,
+		SourceID:   c.SourceID.Int64,
+		File:       c.File,
+		Line:       c.Line,
+		Question:   c.Question,
+		Kind:       c.Kind,
+		Cloze:      c.Cloze,
+		Suspended:  c.Suspended,
+		Due:        !c.Suspended && !c.DueDate.After(now),
+		DueDate:    c.DueDate,
+		Stability:  c.Stability,
+		Difficulty: c.Difficulty,
+	}
+	if c.State >= 0 && c.State < len(cardStates) {
+		oc.State = cardStates[c.State]
+	}
+	if c.LastReview.Valid {
+		oc.LastReview = &c.LastReview.Time
+	}
+	return oc
+}
+
+// handleObsidian serves the API of the Obsidian plugin, which makes a vault
+// synced as a source the place to review its cards. Files are paths from the
+// root of their source, i.e. the vault, and may be narrowed to one source with
+// the source parameter.
+//
+//	GET  /api/obsidian/cards?file=F        the cards of a note, in order
+//	GET  /api/obsidian/card?file=F&line=N  the card of a note at a line
+//	POST /api/obsidian/grade               grade a card: {"card": hash, "grade": 1-4}
+func (s *Server) handleObsidian() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); obsidianOrigins[origin] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Add("Vary", "Origin")
+		}
+		switch {
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/obsidian/cards" && r.Method == http.MethodGet:
+			cards, ok := s.obsidianFileCards(w, r)
+			if !ok {
+				return
+			}
+			list := make([]obsidianCard, 0, len(cards))
+			now := s.db.Now()
+			for _, c := range cards {
+				list = append(list, newObsidianCard(c, now))
+			}
+			writeJSON(w, r, list)
+		case r.URL.Path == "/api/obsidian/card" && r.Method == http.MethodGet:
+			line, err := strconv.Atoi(r.URL.Query().Get("line"))
+			if err != nil || line < 1 {
+				http.Error(w, "Invalid line", http.StatusBadRequest)
+				return
+			}
+			cards, ok := s.obsidianFileCards(w, r)
+			if !ok {
+				return
+			}
+			// The card at a line is the last one starting on or before it.
+			var found *storage.Card
+			for i, c := range cards {
+				if c.Line > 0 && c.Line <= line && (found == nil || c.Line > found.Line) {
+					found = &cards[i]
+				}
+			}
+			if found == nil {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, r, newObsidianCard(*found, s.db.Now()))
+		case r.URL.Path == "/api/obsidian/grade" && r.Method == http.MethodPost:
+			s.handleObsidianGrade(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// obsidianFileCards returns the cards of the file in the request, writing an
+// error and reporting false if there is none or the cards can't be read.
+func (s *Server) obsidianFileCards(w http.ResponseWriter, r *http.Request) ([]storage.Card, bool) {
+	q := r.URL.Query()
+	file := q.Get("file")
+	if file == "" {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return nil, false
+	}
+	cards, err := s.db.GetCardsByFile(file)
+	if err != nil {
+		slog.Error("Error getting cards of file", "file", file, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if q.Has("source") {
+		sourceID, err := strconv.ParseInt(q.Get("source"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid source", http.StatusBadRequest)
+			return nil, false
+		}
+		filtered := cards[:0]
+		for _, c := range cards {
+			if c.SourceID.Int64 == sourceID {
+				filtered = append(filtered, c)
+			}
+		}
+		cards = filtered
+	}
+	return cards, true
+}
+
+// handleObsidianGrade reviews a card graded in Obsidian and returns its new state.
+// Multiple choice cards may be graded by the option chosen instead.
+func (s *Server) handleObsidianGrade(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Card   string `json:"card"`
+		Grade  int    `json:"grade"`
+		Choice *int   `json:"choice"`
+		Hinted bool   `json:"hinted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	card, err := s.db.FindCardByHash(req.Card)
+	if err != nil || card == nil {
+		http.NotFound(w, r)
+		return
+	}
+	g := review.Grade{Rating: fsrs.Rating(req.Grade)}
+	if card.Kind == domain.KindChoice && req.Choice != nil {
+		g = review.Choice(*req.Choice)
+	}
+	if !g.Valid() {
+		http.Error(w, "Invalid grade", http.StatusBadRequest)
+		return
+	}
+	g.Hinted = req.Hinted
+	if err := review.Record(s.db, s.fsrs, card, g); err != nil {
+		slog.Error("Error recording review", "hash", card.Hash, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, newObsidianCard(*card, s.db.Now()))
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// phoneURL returns the URL of the deck page, the server's root, for a phone on
+// the same network to open, shown as a QR code on the deck page. The server is opened on this machine as
+// localhost more often than not, which the phone would take for itself, so a
+// loopback host is replaced with the machine's address on the local network.
+func phoneURL(r *http.Request) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: "/"}
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		u.Scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		if lan := lanAddress(); lan != "" {
+			u.Host = lan
+			if port != "" {
+				u.Host = net.JoinHostPort(lan, port)
+			}
+		}
+	}
+	return u.String()
+}
+
+// lanAddress returns the first private IPv4 address of the machine, or "" if
+// it has none.
+func lanAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil && ipnet.IP.IsPrivate() {
+			return ipnet.IP.String()
+		}
+	}
+	return ""
+}
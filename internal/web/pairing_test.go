@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPhoneURL(t *testing.T) {
+	// A loopback host is replaced with the machine's address on the local
+	// network, where it has one.
+	lan := func(host, port string) string {
+		if addr := lanAddress(); addr != "" {
+			host = addr
+		}
+		return net.JoinHostPort(host, port)
+	}
+	for _, tc := range []struct {
+		host, proto string
+		want        string
+	}{
+		{"knolhash.home:8080", "", "http://knolhash.home:8080/"},
+		{"knolhash.example.org", "https", "https://knolhash.example.org/"},
+		{"192.168.1.20:8080", "http", "http://192.168.1.20:8080/"},
+		{"localhost:8080", "", "http://" + lan("localhost", "8080") + "/"},
+		{"127.0.0.1:8080", "https", "https://" + lan("127.0.0.1", "8080") + "/"},
+		{"[::1]:8080", "", "http://" + lan("::1", "8080") + "/"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tc.host
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		if got := phoneURL(req); got != tc.want {
+			t.Errorf("Expected the phone URL for %s behind %q to be %s, but got %s", tc.host, tc.proto, tc.want, got)
+		}
+	}
+}
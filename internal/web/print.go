@@ -0,0 +1,88 @@
+package web
+
+import (
+	"cmp"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/cloze"
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+// Layouts of a printed deck: a sheet of questions beside their answers, or
+// flashcards folded down the middle, the question on one side and the answer on
+// the other.
+const (
+	printSheet = "sheet"
+	printCards = "cards"
+)
+
+// printCard is a card as printed, with the options of a multiple choice card in
+// a fixed order, as paper can't shuffle them.
+type printCard struct {
+	storage.Card
+	Options []string
+}
+
+// clozeMarks replaces the cloze sentinels in rendered code with marks.
+var clozeMarks = strings.NewReplacer(cloze.MarkStart, "<mark>", cloze.MarkEnd, "</mark>")
+
+// handleGetSourcePrint renders the cards of a source as a page to print, or to
+// save as a PDF from the browser's print dialog, in the layout given by the
+// layout parameter. Suspended cards are left out.
+func (s *Server) handleGetSourcePrint(w http.ResponseWriter, r *http.Request, id int64) {
+	layout := cmp.Or(r.URL.Query().Get("layout"), printSheet)
+	if layout != printSheet && layout != printCards {
+		s.renderError(w, r, "Unknown layout: "+layout, http.StatusBadRequest)
+		return
+	}
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+	cards, err := s.db.GetCardsBySourceID(id)
+	if err != nil {
+		slog.Error("Error getting cards for source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var printed []printCard
+	for _, card := range cards {
+		if card.Suspended {
+			continue
+		}
+		p := printCard{Card: card}
+		if card.Kind == domain.KindChoice {
+			p.Options = append([]string{card.Answer}, card.Distractors...)
+			slices.Sort(p.Options)
+		}
+		printed = append(printed, p)
+	}
+	// In the order they are written in
+	slices.SortFunc(printed, func(a, b printCard) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Cloze, b.Cloze))
+	})
+
+	s.templates.ExecuteTemplate(w, "print", map[string]interface{}{
+		"Source": source,
+		"Layout": layout,
+		"Cards":  printed,
+	})
+}
+
+// printMarks marks the cloze deletions in the code of a card rendered by
+// cardMarkdown, which the review page does in the browser after highlighting.
+func printMarks(h template.HTML) template.HTML {
+	return template.HTML(clozeMarks.Replace(string(h)))
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// render renders a page template, whose root element is #main-content. HTMX
+// requests get just that, to swap into the page; other requests, such as opening
+// a deep link or reloading, get it inside the layout with the navigation.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	w.Header().Add("Vary", "HX-Request")
+	// Restoring history missing from HTMX's cache replaces the whole body
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true" {
+		s.templates.ExecuteTemplate(w, name, data)
+		return
+	}
+	var buf bytes.Buffer
+	if err := s.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		slog.Error("Error rendering page", "template", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.templates.ExecuteTemplate(w, "layout", template.HTML(buf.String()))
+}
+
+// renderError responds with an error message, like http.Error: to HTMX requests
+// as a toast added to the page's #toasts, whatever the request targeted, and to
+// others as plain text.
+func (s *Server) renderError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	if r.Header.Get("HX-Request") != "true" {
+		http.Error(w, message, code)
+		return
+	}
+	w.Header().Set("HX-Retarget", "#toasts")
+	w.Header().Set("HX-Reswap", "beforeend")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	s.templates.ExecuteTemplate(w, "toast", message)
+}
@@ -2,17 +2,31 @@ package web
 
 import (
 	"bytes"
-	"database/sql"
 	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/conorfennell/knolhash/internal/cloze"
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/export"
 	"github.com/conorfennell/knolhash/internal/fsrs"
+	"github.com/conorfennell/knolhash/internal/goals"
+	"github.com/conorfennell/knolhash/internal/hooks"
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/review"
+	"github.com/conorfennell/knolhash/internal/rpc"
 	"github.com/conorfennell/knolhash/internal/storage"
 	"github.com/conorfennell/knolhash/internal/sync"
 	"github.com/yuin/goldmark"
@@ -31,12 +45,18 @@ type Server struct {
 	fsrs      *fsrs.Params
 	templates *template.Template
 	markdown  goldmark.Markdown
+	demo      bool // Reject every change except reviews
+	readOnly  bool // Reject every change, serving a replica of the database
+	dueCounts dueCache
+	assetSums map[string]string // SHA-256 of each embedded asset, for ETags
+	reviews   reviewAPI         // The review of the JSON API's clients, such as /app
 }
 
-// NewServer creates and configures a new server.
-func NewServer(db *storage.DB) *Server {
+// NewServer creates and configures a new server. A demo server only allows
+// browsing and reviewing, and a read-only one, serving a replica, only browsing.
+func NewServer(db *storage.DB, demo, readOnly bool) *Server {
 	md := goldmark.New(
-		goldmark.WithExtensions(),
+		goldmark.WithExtensions(mathExtension{}),
 	)
 
 	funcMap := template.FuncMap{
@@ -47,6 +67,21 @@ func NewServer(db *storage.DB) *Server {
 			}
 			return template.HTML(buf.String())
 		},
+		"cardMarkdown": func(hash, file, source string) template.HTML {
+			return cardMarkdown(md, hash, file, source)
+		},
+		"clozeBlank":      cloze.Blank,
+		"clozeReveal":     cloze.Reveal,
+		"clozeBlankText":  cloze.BlankText,
+		"clozeRevealText": cloze.RevealText,
+		"printMarks":      printMarks,
+		"join":            strings.Join,
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f*100)
+		},
 	}
 
 	tpl, err := template.New("").Funcs(funcMap).ParseFS(templateFiles, "templates/*.html")
@@ -55,22 +90,78 @@ func NewServer(db *storage.DB) *Server {
 		os.Exit(1)
 	}
 
+	assetSums, _, err := AssetChecksums()
+	if err != nil {
+		slog.Error("Failed to checksum embedded assets", "error", err)
+		os.Exit(1)
+	}
+
 	s := &Server{
 		db:        db,
 		router:    http.NewServeMux(),
 		fsrs:      fsrs.DefaultParams(),
 		templates: tpl,
 		markdown:  md,
+		demo:      demo,
+		readOnly:  readOnly,
+		assetSums: assetSums,
+		reviews:   reviewAPI{session: rpc.NewSession(db)},
 	}
+	s.fsrs.Clock = db // Reviews are scheduled by the database's clock
 	s.routes()
 	return s
 }
 
 // ServeHTTP implements the http.Handler interface.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.demo && !demoAllowed(r) {
+		s.renderError(w, r, "Changes are disabled in the demo", http.StatusForbidden)
+		return
+	}
+	if s.readOnly && !readOnlyAllowed(r) {
+		s.renderError(w, r, "Changes are disabled on this read-only replica", http.StatusForbidden)
+		return
+	}
+	if changes(r) {
+		// Before, so the handler sees its own changes, and after, in case a
+		// concurrent request cached the counts meanwhile
+		s.dueCounts.invalidate()
+		defer s.dueCounts.invalidate()
+	}
+	if acceptsGzip(r) {
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		w = gw
+	}
 	s.router.ServeHTTP(w, r)
 }
 
+// demoAllowed reports whether a request may be served in demo mode: anything
+// that only reads, including Grafana queries, and reviews, also from Obsidian
+// and the JSON API.
+func demoAllowed(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return strings.HasPrefix(r.URL.Path, "/review/") || strings.HasPrefix(r.URL.Path, "/api/grafana/") ||
+			r.URL.Path == "/api/obsidian/grade" || r.URL.Path == "/api/review"
+	}
+	return false
+}
+
+// readOnlyAllowed reports whether a request may be served by a read-only
+// replica: anything that only reads, including Grafana queries.
+func readOnlyAllowed(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return strings.HasPrefix(r.URL.Path, "/api/grafana/")
+	}
+	return false
+}
+
 // routes sets up the routing for the server.
 func (s *Server) routes() {
 	staticFS, err := fs.Sub(staticFiles, "static")
@@ -78,22 +169,47 @@ func (s *Server) routes() {
 		slog.Error("Failed to create sub-filesystem for static assets", "error", err)
 		os.Exit(1)
 	}
-	fileServer := http.FileServer(http.FS(staticFS))
 
-	s.router.Handle("/static/", http.StripPrefix("/static/", fileServer))
-	s.router.Handle("/", fileServer)
+	s.router.HandleFunc("/static/", s.handleStatic(staticFS, "/static/"))
+	s.router.HandleFunc("/", s.handleStatic(staticFS, "/"))
 
-	// HTMX-based routes
+	// HTMX-based routes, which also render whole pages when visited directly
+	s.router.HandleFunc("/{$}", s.handleGetDeck())
 	s.router.HandleFunc("/deck", s.handleGetDeck())
 	s.router.HandleFunc("/review/next", s.handleGetNextReview())
 	s.router.HandleFunc("/review/answer/", s.handleShowAnswer())
+	s.router.HandleFunc("/review/hint/", s.handleShowHint())
 	s.router.HandleFunc("/review/", s.handlePostReview())
 
 	// Source management routes
 	s.router.HandleFunc("/sources", s.handleSources())
-	s.router.HandleFunc("/sources/", s.handleDeleteSource())
+	s.router.HandleFunc("/sources/", s.handleSource())
+	s.router.HandleFunc("/sources/import", s.handlePostDeckImport())
 	s.router.HandleFunc("/sync", s.handlePostSync())
+	s.router.HandleFunc("/sync/status", s.handleGetSyncStatus())
+	s.router.HandleFunc("/links", s.handleGetBrokenLinks())
 	s.router.HandleFunc("/cards", s.handleGetCards())
+	s.router.HandleFunc("/cards/", s.handleCard())
+	s.router.HandleFunc("/media/", s.handleGetMedia())
+	s.router.HandleFunc("/export/reviews.jsonl", s.handleGetReviewExport())
+	s.router.HandleFunc("/stats", s.handleGetStats())
+	s.router.HandleFunc("/trophies", s.handleGetTrophies())
+	s.router.HandleFunc("/settings", s.handleSettings())
+	s.router.HandleFunc("/settings/test-notification", s.handlePostTestNotification())
+	s.router.HandleFunc("/settings/theme", s.handleGetTheme())
+
+	// JSON stats API, and time series for Grafana's JSON datasource
+	s.router.HandleFunc("/api/stats/maturity", s.handleGetMaturityAPI())
+	s.router.HandleFunc("/api/grafana/", s.handleGrafana())
+	s.router.HandleFunc("/api/obsidian/", s.handleObsidian())
+	s.router.HandleFunc("/api/review", s.handleReviewAPI())
+	s.router.HandleFunc("/api/widgets/", s.handleGetWidget())
+
+	// The single-page review client, driven by the JSON API
+	s.router.HandleFunc("/app/", s.handleStatic(staticFS, "/"))
+
+	// Build information, for telling apart binaries when debugging
+	s.router.HandleFunc("/api/version", s.handleGetVersion())
 }
 
 // handleGetCards renders a page with all cards sorted by due date.
@@ -102,13 +218,28 @@ func (s *Server) handleGetCards() http.HandlerFunc {
 		cards, err := s.db.GetAllCardsSortedByDueDate()
 		if err != nil {
 			slog.Error("Error getting all cards", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 		data := map[string]interface{}{
 			"Cards": cards,
 		}
-		s.templates.ExecuteTemplate(w, "card_list", data)
+		s.render(w, r, "card_list", data)
+	}
+}
+
+// handleGetReviewExport downloads the whole review log as JSON Lines.
+func (s *Server) handleGetReviewExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := export.ReviewsJSONL(&buf, s.db); err != nil {
+			slog.Error("Error exporting review log", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="knolhash-reviews.jsonl"`)
+		buf.WriteTo(w)
 	}
 }
 
@@ -116,7 +247,7 @@ func (s *Server) handleGetCards() http.HandlerFunc {
 func (s *Server) handlePostSync() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -126,12 +257,10 @@ func (s *Server) handlePostSync() http.HandlerFunc {
 		sources, err := s.db.GetAllSources()
 		if err != nil {
 			slog.Error("Error getting sources after sync", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		data := map[string]interface{}{
-			"Sources": sources,
-		}
+		data := s.sourceListData(sources)
 
 		// Render both the success message and the updated list
 		s.templates.ExecuteTemplate(w, "sync_success", nil)
@@ -139,6 +268,40 @@ func (s *Server) handlePostSync() http.HandlerFunc {
 	}
 }
 
+// handleGetSyncStatus reports the progress of running and recent syncs per source,
+// as an HTML fragment for HTMX requests and as JSON otherwise.
+func (s *Server) handleGetSyncStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := sync.Statuses()
+		if r.Header.Get("HX-Request") == "true" {
+			s.templates.ExecuteTemplate(w, "sync_progress", statuses)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			slog.Error("Error encoding sync status", "error", err)
+		}
+	}
+}
+
+// sourceListData builds the template data for the source list, including the
+// sync status and installed deck of each source keyed by source ID.
+func (s *Server) sourceListData(sources []storage.Source) map[string]interface{} {
+	statuses := make(map[int64]*sync.SourceStatus)
+	for _, status := range sync.Statuses() {
+		statuses[status.SourceID] = &status
+	}
+	decks, err := s.db.GetDecks()
+	if err != nil {
+		slog.Warn("Failed to get installed decks", "error", err)
+	}
+	return map[string]interface{}{
+		"Sources":  sources,
+		"Statuses": statuses,
+		"Decks":    decks,
+	}
+}
+
 // handleSources handles both GET and POST for the sources page.
 func (s *Server) handleSources() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -148,7 +311,7 @@ func (s *Server) handleSources() http.HandlerFunc {
 		case http.MethodPost:
 			s.handlePostSource(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
@@ -158,120 +321,532 @@ func (s *Server) handleGetSources(w http.ResponseWriter, r *http.Request) {
 	sources, err := s.db.GetAllSources()
 	if err != nil {
 		slog.Error("Error getting sources", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	data := map[string]interface{}{
-		"Sources": sources,
-	}
-	s.templates.ExecuteTemplate(w, "sources", data)
+	data := s.sourceListData(sources)
+	s.render(w, r, "sources", data)
 }
 
 // handlePostSource adds a new source and re-renders the source list.
 func (s *Server) handlePostSource(w http.ResponseWriter, r *http.Request) {
-	path := r.PostFormValue("path")
-	if path == "" {
-		http.Error(w, "Path cannot be empty", http.StatusBadRequest)
+	if _, err := sync.AddSource(s.db, r.PostFormValue("path")); err != nil {
+		s.renderSourceError(w, r, "Can't add source", err)
 		return
 	}
 
-	// This is a simplified version of the logic in main.go's addNewSource.
-	// A refactoring would be to move that logic into a shared package.
-	sourceType := "local"
-	if strings.HasSuffix(path, ".git") || strings.HasPrefix(path, "git@") || strings.HasPrefix(path, "https://") {
-		sourceType = "git"
+	// Re-render the source list to be swapped by HTMX
+	sources, err := s.db.GetAllSources()
+	if err != nil {
+		slog.Error("Error getting sources after add", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := s.sourceListData(sources)
+	s.templates.ExecuteTemplate(w, "source_list", data)
+}
+
+// renderSourceError renders an error adding or changing a source as a toast,
+// explaining what to fix unless it failed to store it.
+func (s *Server) renderSourceError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	var invalid *sync.InvalidSourceError
+	switch {
+	case errors.As(err, &invalid):
+		s.renderError(w, r, action+": "+invalid.Error(), http.StatusBadRequest)
+	case errors.Is(err, sync.ErrSourceExists):
+		s.renderError(w, r, action+": "+err.Error(), http.StatusConflict)
+	default:
+		slog.Error("Error storing source", "error", err)
+		s.renderError(w, r, action+": failed to store it", http.StatusInternalServerError)
+	}
+}
+
+// handleSource handles GET, PUT and DELETE for a single source, as well as
+// actions on it such as POST /sources/{id}/archive and the deletion preview at
+// GET /sources/{id}/delete.
+func (s *Server) handleSource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/sources/"), "/")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			s.renderError(w, r, "Invalid source ID", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			s.handleGetSource(w, r, id)
+		case action == "" && r.Method == http.MethodPut:
+			s.handlePutSource(w, r, id)
+		case action == "" && r.Method == http.MethodDelete:
+			s.handleDeleteSource(w, r, id)
+		case action == "delete" && r.Method == http.MethodGet:
+			s.handleGetSourceDelete(w, r, id)
+		case action == "archive" && r.Method == http.MethodPost:
+			s.handleArchiveSource(w, r, id, true)
+		case action == "unarchive" && r.Method == http.MethodPost:
+			s.handleArchiveSource(w, r, id, false)
+		case action == "options" && r.Method == http.MethodPost:
+			s.handlePostSourceOptions(w, r, id)
+		case action == "syntax" && r.Method == http.MethodPost:
+			s.handlePostSourceSyntax(w, r, id)
+		case action == "files" && r.Method == http.MethodPost:
+			s.handlePostSourceFiles(w, r, id)
+		case action == "print" && r.Method == http.MethodGet:
+			s.handleGetSourcePrint(w, r, id)
+		case action == "archive" || action == "unarchive" || action == "options" || action == "syntax" || action == "files" || action == "delete" || action == "print" || action == "":
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		default:
+			http.NotFound(w, r)
+		}
 	}
+}
+
+// sourceStatsWeeks is the number of weeks charted on the source detail page.
+const sourceStatsWeeks = 12
 
-	if _, err := s.db.InsertSource(path, sourceType); err != nil {
-		slog.Error("Error inserting new source", "error", err)
-		http.Error(w, "Failed to add source", http.StatusInternalServerError)
+// handleGetSource renders the detail page of a source with its weekly card deltas.
+func (s *Server) handleGetSource(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Re-render the source list to be swapped by HTMX
-	sources, err := s.db.GetAllSources()
+	cards, err := s.db.GetCardsBySourceID(id)
 	if err != nil {
-		slog.Error("Error getting sources after add", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		slog.Error("Error getting cards for source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		slog.Error("Error loading preferences", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	weeks, err := s.db.GetWeeklySourceStats(id, sourceStatsWeeks, s.db.Now().In(p.Location()))
+	if err != nil {
+		slog.Error("Error getting weekly stats for source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	issues, err := s.db.GetParseIssues(id)
+	if err != nil {
+		slog.Error("Error getting parse issues for source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+
+	var maxAdded, totalAdded, totalRemoved int
+	for _, week := range weeks {
+		maxAdded = max(maxAdded, week.CardsAdded)
+		totalAdded += week.CardsAdded
+		totalRemoved += week.CardsRemoved
+	}
+
 	data := map[string]interface{}{
-		"Sources": sources,
+		"Source":       source,
+		"CardCount":    len(cards),
+		"Weeks":        weeks,
+		"MaxAdded":     maxAdded,
+		"TotalAdded":   totalAdded,
+		"TotalRemoved": totalRemoved,
+		"Issues":       issues,
+
+		"DefaultExclude": sync.DefaultExclude,
 	}
-	s.templates.ExecuteTemplate(w, "source_list", data)
+	s.render(w, r, "source_detail", data)
 }
 
-// handleDeleteSource deletes a source and re-renders the source list.
-func (s *Server) handleDeleteSource() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+// handlePutSource changes the path of a source, keeping its ID and cards,
+// and re-renders the source detail page.
+func (s *Server) handlePutSource(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := sync.ChangeSourcePath(s.db, id, r.PostFormValue("path"))
+	if err != nil {
+		s.renderSourceError(w, r, "Can't change source", err)
+		return
+	}
+	slog.Info("Source path changed", "id", id, "path", source.Path)
+
+	s.handleGetSource(w, r, id)
+}
+
+// handlePostSourceOptions updates the per-source sync options and re-renders the source detail page.
+// Checkbox options are absent from the form when unchecked.
+func (s *Server) handlePostSourceOptions(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	source.Submodules = r.PostFormValue("submodules") == "on"
+
+	source.TrustedKeys = strings.TrimSpace(r.PostFormValue("trusted_keys"))
+
+	source.Mirrors = nil
+	for _, mirror := range strings.Split(r.PostFormValue("mirrors"), "\n") {
+		if mirror = strings.TrimSpace(mirror); mirror != "" {
+			source.Mirrors = append(source.Mirrors, mirror)
 		}
+	}
 
-		idStr := strings.TrimPrefix(r.URL.Path, "/sources/")
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid source ID", http.StatusBadRequest)
-			return
+	if err := s.db.UpdateSourceOptions(source); err != nil {
+		slog.Error("Error updating source options", "id", id, "error", err)
+		s.renderError(w, r, "Failed to update source options", http.StatusInternalServerError)
+		return
+	}
+
+	s.handleGetSource(w, r, id)
+}
+
+// handlePostSourceSyntax updates the syntax of a source's Markdown cards, which
+// applies from its next sync, and re-renders the source detail page.
+func (s *Server) handlePostSourceSyntax(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	source.QuestionPrefix = strings.TrimSpace(r.PostFormValue("question_prefix"))
+	source.AnswerPrefix = strings.TrimSpace(r.PostFormValue("answer_prefix"))
+	source.ContextPrefix = strings.TrimSpace(r.PostFormValue("context_prefix"))
+	source.Separator = strings.TrimSpace(r.PostFormValue("separator"))
+	source.IgnoreCase = r.PostFormValue("ignore_case") == "on"
+	if err := sync.Syntax(*source).Validate(); err != nil {
+		s.renderError(w, r, "Invalid card syntax: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateSourceSyntax(source); err != nil {
+		slog.Error("Error updating source syntax", "id", id, "error", err)
+		s.renderError(w, r, "Failed to update the card syntax", http.StatusInternalServerError)
+		return
+	}
+
+	s.handleGetSource(w, r, id)
+}
+
+// handlePostSourceFiles updates which files of a source are scanned for cards,
+// from comma-separated lists, and re-renders the source detail page.
+func (s *Server) handlePostSourceFiles(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	source.Extensions = splitList(r.PostFormValue("extensions"))
+	source.Exclude = splitList(r.PostFormValue("exclude"))
+	if err := sync.Syntax(*source).Validate(); err != nil {
+		s.renderError(w, r, "Invalid extensions: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateSourceFiles(source); err != nil {
+		slog.Error("Error updating source files", "id", id, "error", err)
+		s.renderError(w, r, "Failed to update the files scanned", http.StatusInternalServerError)
+		return
+	}
+
+	s.handleGetSource(w, r, id)
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
+	}
+	return items
+}
 
-		if err := s.db.DeleteSource(id); err != nil {
-			slog.Error("Error deleting source", "id", id, "error", err)
-			http.Error(w, "Failed to delete source", http.StatusInternalServerError)
-			return
+// handleGetSourceDelete renders the confirmation of deleting a source in place
+// of the source list, with the number of cards and reviews deleted with it.
+func (s *Server) handleGetSourceDelete(w http.ResponseWriter, r *http.Request, id int64) {
+	source, err := s.db.FindSourceByID(id)
+	if err != nil {
+		slog.Error("Error getting source", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+	contents, err := s.db.GetSourceContents(id)
+	if err != nil {
+		slog.Error("Error counting source contents", "id", id, "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.templates.ExecuteTemplate(w, "source_delete", map[string]interface{}{
+		"Source":   source,
+		"Contents": contents,
+	})
+}
+
+// handleDeleteSource deletes a source and re-renders the source list. With
+// keep_cards=on, its cards and their review history are kept without a source.
+func (s *Server) handleDeleteSource(w http.ResponseWriter, r *http.Request, id int64) {
+	keepCards := r.FormValue("keep_cards") == "on"
+	if err := s.db.DeleteSource(id, keepCards); err != nil {
+		slog.Error("Error deleting source", "id", id, "error", err)
+		s.renderError(w, r, "Failed to delete source", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Source deleted", "id", id, "kept_cards", keepCards)
+
+	// Re-render the source list to be swapped by HTMX
+	sources, err := s.db.GetAllSources()
+	if err != nil {
+		slog.Error("Error getting sources after delete", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := s.sourceListData(sources)
+	s.templates.ExecuteTemplate(w, "source_list", data)
+}
+
+// handleArchiveSource archives or unarchives a source and re-renders the source list.
+func (s *Server) handleArchiveSource(w http.ResponseWriter, r *http.Request, id int64, archived bool) {
+	if err := s.db.SetSourceArchived(id, archived); err != nil {
+		slog.Error("Error archiving source", "id", id, "archived", archived, "error", err)
+		s.renderError(w, r, "Failed to archive source", http.StatusInternalServerError)
+		return
+	}
+
+	// Re-render the source list to be swapped by HTMX
+	sources, err := s.db.GetAllSources()
+	if err != nil {
+		slog.Error("Error getting sources after archive", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := s.sourceListData(sources)
+	s.templates.ExecuteTemplate(w, "source_list", data)
+}
+
+// dueQueue returns the cards to study now, within the daily limits of the preferences.
+func (s *Server) dueQueue() ([]storage.Card, error) {
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		return nil, err
+	}
+	return p.DueQueue(s.db)
+}
+
+// reviewSession narrows reviewing to the cards of one context, e.g. a weak area
+// from the stats page, to one kind of card, e.g. writing prompts, or to the
+// cards with a tag. The zero value reviews all due cards. The cards graded
+// Again in the session come back at its end.
+type reviewSession struct {
+	Filtered bool // Only cards of Context
+	Context  string
+	Kind     string         // Only cards of this kind, when set
+	Tag      string         // Only cards with this tag, when set
+	Relearn  review.Relearn // Cards graded Again in the session
+	Reviewed int            // Cards graded in the session
+}
+
+// sessionFromRequest reads the review session from the context, kind, tag,
+// relearn and reviewed query parameters.
+func sessionFromRequest(r *http.Request) reviewSession {
+	q := r.URL.Query()
+	reviewed, _ := strconv.Atoi(q.Get("reviewed"))
+	return reviewSession{Filtered: q.Has("context"), Context: q.Get("context"), Kind: q.Get("kind"), Tag: q.Get("tag"), Relearn: q["relearn"], Reviewed: max(reviewed, 0)}
+}
+
+// Query encodes the session as the query parameters of the review URLs; it is
+// empty for the zero value.
+func (rs reviewSession) Query() string {
+	q := url.Values{}
+	if rs.Filtered {
+		q.Set("context", rs.Context)
+	}
+	if rs.Kind != "" {
+		q.Set("kind", rs.Kind)
+	}
+	if rs.Tag != "" {
+		q.Set("tag", rs.Tag)
+	}
+	if len(rs.Relearn) > 0 {
+		q["relearn"] = rs.Relearn
+	}
+	if rs.Reviewed > 0 {
+		q.Set("reviewed", strconv.Itoa(rs.Reviewed))
+	}
+	return q.Encode()
+}
+
+// sessionProgress is how far a review session has got, shown above its cards.
+type sessionProgress struct {
+	Done     int // Cards graded
+	Total    int // Cards graded and left, counting each card to relearn again
+	Learning int // Cards graded Again, to relearn before the session ends
+}
+
+// progress returns the progress of the session, with cards left in its queue.
+func (rs reviewSession) progress(cards []storage.Card) sessionProgress {
+	return sessionProgress{Done: rs.Reviewed, Total: rs.Reviewed + len(cards), Learning: len(rs.Relearn)}
+}
+
+// reviewQueue returns the cards left to study in a session: the due cards within
+// the daily limits, or the cards of the context not yet reviewed this study day,
+// followed by the cards to relearn.
+func (s *Server) reviewQueue(session reviewSession) ([]storage.Card, error) {
+	var cards []storage.Card
+	var err error
+	if session.Filtered {
+		var p prefs.Preferences
+		if p, err = prefs.Load(s.db); err != nil {
+			return nil, err
 		}
+		cards, err = s.db.GetCardsByContextNotReviewedSince(session.Context, p.DayStart(s.db.Now()))
+	} else {
+		cards, err = s.dueQueue()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cards, err = session.Relearn.Append(s.db, cards); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(cards, func(c storage.Card) bool {
+		return (session.Kind != "" && c.Kind != session.Kind) || (session.Tag != "" && !slices.Contains(c.Tags, session.Tag))
+	}), nil
+}
 
-		// Re-render the source list to be swapped by HTMX
-		sources, err := s.db.GetAllSources()
+// loadDueCounts counts the cards to study now, through the cache.
+func (s *Server) loadDueCounts(p prefs.Preferences) (dueCounts, error) {
+	return s.dueCounts.get(func() (dueCounts, error) {
+		dueCards, err := p.DueQueue(s.db)
 		if err != nil {
-			slog.Error("Error getting sources after delete", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+			return dueCounts{}, err
 		}
-		data := map[string]interface{}{
-			"Sources": sources,
+		counts := dueCounts{Due: len(dueCards)}
+		for _, c := range dueCards {
+			if c.Kind == domain.KindWriting {
+				counts.Writing++
+			}
 		}
-		s.templates.ExecuteTemplate(w, "source_list", data)
-	}
+		return counts, nil
+	})
 }
 
 // handleGetDeck renders the deck view, showing the number of due cards.
 func (s *Server) handleGetDeck() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		dueCards, err := s.db.GetDueCards()
+		s.renderDeck(w, r, "")
+	}
+}
+
+// renderDeck renders the deck view with an optional message.
+func (s *Server) renderDeck(w http.ResponseWriter, r *http.Request, message string) {
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		slog.Error("Error loading preferences for deck view", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	counts, err := s.loadDueCounts(p)
+	if err != nil {
+		slog.Error("Error getting due cards for deck view", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	progress, err := goals.Progress(s.db, p, s.db.Now())
+	if err != nil {
+		slog.Error("Error getting goal progress for deck view", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"DueCount":     counts.Due,
+		"WritingCount": counts.Writing,
+		"HasDueCards":  counts.Due > 0,
+		"Goals":        progress,
+		"Demo":         s.demo,
+		"ReadOnly":     s.readOnly,
+		"Message":      message,
+		"PhoneURL":     phoneURL(r),
+	}
+	if p.Achievements {
+		trophies, err := goals.Achievements(s.db, p, s.db.Now())
 		if err != nil {
-			slog.Error("Error getting due cards for deck view", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			slog.Error("Error getting achievements for deck view", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		data := map[string]interface{}{
-			"DueCount":    len(dueCards),
-			"HasDueCards": len(dueCards) > 0,
-		}
-		s.templates.ExecuteTemplate(w, "deck", data)
+		data["Streak"] = trophies.Streak
 	}
+	s.render(w, r, "deck", data)
 }
 
-// handleGetNextReview renders the front of the next due card.
+// handleGetNextReview renders the front of the next card of the review session.
 func (s *Server) handleGetNextReview() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		dueCards, err := s.db.GetDueCards()
+		session := sessionFromRequest(r)
+		cards, err := s.reviewQueue(session)
 		if err != nil {
 			slog.Error("Error getting next due card", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		if len(dueCards) == 0 {
-			s.templates.ExecuteTemplate(w, "deck", map[string]interface{}{
-				"DueCount":    0,
-				"HasDueCards": false,
-			})
-			return
+		s.renderNextReview(w, r, session, cards)
+	}
+}
+
+// renderNextReview renders the front of the first card in the session's queue,
+// or the deck once the queue is empty.
+func (s *Server) renderNextReview(w http.ResponseWriter, r *http.Request, session reviewSession, cards []storage.Card) {
+	if len(cards) == 0 {
+		message := ""
+		switch {
+		case session.Filtered:
+			message = "You have reviewed every card in this area today."
+		case session.Kind == domain.KindWriting:
+			message = "You have written every writing prompt due."
 		}
-		nextCard := dueCards[0]
-		s.templates.ExecuteTemplate(w, "card_front", nextCard)
+		s.renderDeck(w, r, message)
+		return
+	}
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		slog.Error("Error loading preferences for the review", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	nextCard := cards[0]
+	s.render(w, r, "card_front", shownCard{Card: nextCard, ShownAt: time.Now().UnixMilli(), Session: session, Progress: session.progress(cards), Choices: choices(nextCard, true), AutoReveal: p.AutoReveal})
 }
 
 // handleShowAnswer renders the back of a card.
@@ -283,50 +858,169 @@ func (s *Server) handleShowAnswer() http.HandlerFunc {
 			http.NotFound(w, r)
 			return
 		}
-		s.templates.ExecuteTemplate(w, "card_back", card)
+		q := r.URL.Query()
+		shownAt, _ := strconv.ParseInt(q.Get("shown"), 10, 64)
+		chosen, err := strconv.Atoi(q.Get("choice"))
+		if err != nil {
+			chosen = -1
+		}
+		session := sessionFromRequest(r)
+		cards, err := s.reviewQueue(session)
+		if err != nil {
+			slog.Error("Error getting the review session's cards", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		p, err := prefs.Load(s.db)
+		if err != nil {
+			slog.Error("Error loading preferences for the review", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.render(w, r, "card_back", shownCard{
+			Card:      *card,
+			ShownAt:   shownAt,
+			Session:   session,
+			Progress:  session.progress(cards),
+			Recall:    q.Get("recall"),
+			Choices:   choices(*card, false),
+			Chosen:    chosen,
+			Hinted:    q.Get("hinted") == "true",
+			AutoAgain: p.AutoAgain,
+		})
 	}
 }
 
-// handlePostReview processes a review and renders the next card.
-func (s *Server) handlePostReview() http.HandlerFunc {
+// handleShowHint renders the hint of a card in place of the button that shows it.
+func (s *Server) handleShowHint() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		hash := strings.TrimPrefix(r.URL.Path, "/review/")
-		gradeStr := r.PostFormValue("grade")
-		grade, err := strconv.Atoi(gradeStr)
-		if err != nil {
-			http.Error(w, "Invalid grade", http.StatusBadRequest)
+		hash := strings.TrimPrefix(r.URL.Path, "/review/hint/")
+		card, err := s.db.FindCardByHash(hash)
+		if err != nil || card == nil {
+			http.NotFound(w, r)
 			return
 		}
+		s.templates.ExecuteTemplate(w, "card_hint", card)
+	}
+}
+
+// shownCard is a card under review with the time its question was shown (Unix
+// milliseconds), which is passed along until it is graded to time the review,
+// and the session it is reviewed in, with its progress.
+type shownCard struct {
+	storage.Card
+	ShownAt  int64
+	Session  reviewSession
+	Progress sessionProgress
+	Recall   string         // What was written for a writing prompt, shown next to the notes
+	Choices  []choiceOption // Options of a multiple choice card, shuffled on the front
+	Chosen   int            // Index of the option chosen on the front, -1 if none
+	Hinted   bool           // Whether the hint was shown on the front
+	// AutoReveal and AutoAgain are the preferred timers, in seconds, for showing
+	// the answer and grading Again; 0 is off.
+	AutoReveal int
+	AutoAgain  int
+}
+
+// choiceOption is an option of a multiple choice card with its index in
+// storage.Card.Options, where the right option is 0.
+type choiceOption struct {
+	Index int
+	Text  string
+}
+
+// choices lists the options of a multiple choice card, in order or shuffled. It
+// returns nil for other cards.
+func choices(card storage.Card, shuffle bool) []choiceOption {
+	if card.Kind != domain.KindChoice {
+		return nil
+	}
+	var opts []choiceOption
+	for i, text := range card.Options() {
+		opts = append(opts, choiceOption{Index: i, Text: text})
+	}
+	if shuffle {
+		rand.Shuffle(len(opts), func(i, j int) { opts[i], opts[j] = opts[j], opts[i] })
+	}
+	return opts
+}
 
+// reviewGrade reads the grade of a review from the form. Multiple choice cards are
+// graded from the option chosen: Good if it was right and Again otherwise.
+func reviewGrade(r *http.Request, card *storage.Card) (review.Grade, error) {
+	if card.Kind == domain.KindChoice {
+		chosen, err := strconv.Atoi(r.PostFormValue("choice"))
+		return review.Choice(chosen), err
+	}
+	grade, err := strconv.Atoi(r.PostFormValue("grade"))
+	if err != nil {
+		return review.Grade{}, err
+	}
+	g := review.Grade{Rating: fsrs.Rating(grade)}
+	if !g.Valid() {
+		return g, fmt.Errorf("grade %d out of range", grade)
+	}
+	return g, nil
+}
+
+// notifySessionEnded runs the session-ended hooks, once no cards are left in the
+// review session.
+func (s *Server) notifySessionEnded(session reviewSession) {
+	payload := hooks.Session{Context: session.Context, Kind: session.Kind}
+	if p, err := prefs.Load(s.db); err == nil {
+		newCards, reviews, err := s.db.CountReviewsSince(p.DayStart(s.db.Now()))
+		if err != nil {
+			slog.Warn("Failed to count today's reviews", "error", err)
+		}
+		payload.Reviews = newCards + reviews
+	}
+	hooks.NotifyAsync(hooks.SessionEnded, payload)
+}
+
+// handlePostReview processes a review and renders the next card.
+func (s *Server) handlePostReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/review/")
 		card, err := s.db.FindCardByHash(hash)
 		if err != nil || card == nil {
 			http.NotFound(w, r)
 			return
 		}
 
-		currentFSRSState := fsrs.CardState{
-			Stability:  card.Stability,
-			Difficulty: card.Difficulty,
-			LastReview: card.LastReview.Time,
+		g, err := reviewGrade(r, card)
+		if err != nil {
+			s.renderError(w, r, "Invalid grade: grades run from 1 (Again) to 4 (Easy)", http.StatusBadRequest)
+			return
 		}
 
-		newFSRSState := s.fsrs.NextState(currentFSRSState, fsrs.Rating(grade))
-		newDueDate := fsrs.NextDueDate(newFSRSState.Stability)
-
-		card.Stability = newFSRSState.Stability
-		card.Difficulty = newFSRSState.Difficulty
-		card.DueDate = newDueDate
-		card.LastReview = sql.NullTime{Time: newFSRSState.LastReview, Valid: true}
-		card.State = 2 // Mark as in-review
+		var duration time.Duration
+		if shownAt, err := strconv.ParseInt(r.PostFormValue("shown"), 10, 64); err == nil && shownAt > 0 {
+			duration = max(time.Since(time.UnixMilli(shownAt)), 0)
+		}
 
-		if err := s.db.UpdateCard(card); err != nil {
-			slog.Error("Error updating card state for hash", "hash", hash, "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		g.Hinted = r.PostFormValue("hinted") == "true"
+		g.Duration = duration
+		err = review.Record(s.db, s.fsrs, card, g)
+		if err != nil {
+			slog.Error("Error recording review", "hash", hash, "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		// After review, show the next card
-		s.handleGetNextReview()(w, r)
+		// After review, show the next card, loading the queue only once for both
+		session := sessionFromRequest(r)
+		session.Relearn = session.Relearn.Graded(hash, g.Rating)
+		session.Reviewed++
+		cards, err := s.reviewQueue(session)
+		if err != nil {
+			slog.Error("Error getting next due card", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if len(cards) == 0 {
+			s.notifySessionEnded(session)
+		}
+		s.renderNextReview(w, r, session, cards)
 	}
 }
 
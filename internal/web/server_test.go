@@ -0,0 +1,54 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/conorfennell/knolhash/internal/domain"
+	"github.com/conorfennell/knolhash/internal/storage"
+)
+
+func TestPostReviewGrade(t *testing.T) {
+	db, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	sourceID, err := db.InsertSource("/notes", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertCard(domain.Card{Hash: "abc", Question: "Q", Answer: "A"}, sourceID); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(db, false, false)
+
+	grade := func(g string) int {
+		form := url.Values{"grade": {g}}
+		req := httptest.NewRequest(http.MethodPost, "/review/abc", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, g := range []string{"0", "5", "12", "-1", "good"} {
+		if code := grade(g); code != http.StatusBadRequest {
+			t.Errorf("Expected grade %q to be rejected, but got %d", g, code)
+		}
+	}
+	if logs, err := db.GetReviewLogsByCard("abc"); err != nil || len(logs) != 0 {
+		t.Errorf("Expected no reviews to be logged for invalid grades, but got %d, %v", len(logs), err)
+	}
+
+	if code := grade("3"); code != http.StatusOK {
+		t.Errorf("Expected grade 3 to be recorded, but got %d", code)
+	}
+	card, err := db.FindCardByHash("abc")
+	if err != nil || card == nil || card.State == 0 {
+		t.Errorf("Expected the graded card to leave the new state, but got %+v, %v", card, err)
+	}
+}
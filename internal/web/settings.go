@@ -0,0 +1,178 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/conorfennell/knolhash/internal/notify"
+	"github.com/conorfennell/knolhash/internal/prefs"
+)
+
+// settingsForm holds the values edited on the settings page.
+type settingsForm struct {
+	Prefs  prefs.Preferences
+	Notify notify.Settings
+}
+
+// handleSettings renders the settings page on GET and saves it on POST.
+func (s *Server) handleSettings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.handleGetSettings(w, r)
+		case http.MethodPost:
+			s.handlePostSettings(w, r)
+		default:
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleGetSettings renders the settings page.
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		slog.Error("Error loading preferences", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	settings, err := notify.LoadSettings(s.db)
+	if err != nil {
+		slog.Error("Error loading settings", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderSettings(w, r, settingsForm{Prefs: p, Notify: settings}, "", "")
+}
+
+// handlePostSettings validates and saves the settings form. Invalid settings are
+// not saved; the form is re-rendered with the submitted values and the problem.
+func (s *Server) handlePostSettings(w http.ResponseWriter, r *http.Request) {
+	form, err := settingsFromForm(r)
+	if err == nil {
+		err = form.Prefs.Validate()
+	}
+	if err == nil {
+		err = form.Notify.Validate()
+	}
+	if err != nil {
+		slog.Warn("Rejected settings", "error", err)
+		s.renderSettings(w, r, form, "", err.Error())
+		return
+	}
+
+	if err := form.Prefs.Save(s.db); err != nil {
+		slog.Error("Error saving preferences", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := form.Notify.Save(s.db); err != nil {
+		slog.Error("Error saving notification settings", "error", err)
+		s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Settings saved")
+	s.renderSettings(w, r, form, "Settings saved.", "")
+	s.templates.ExecuteTemplate(w, "theme", form.Prefs.Theme)
+}
+
+// handlePostTestNotification sends a test notification with the submitted, unsaved settings.
+func (s *Server) handlePostTestNotification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			s.renderError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		form, err := settingsFromForm(r)
+		if err == nil {
+			err = form.Notify.Validate()
+		}
+		if err == nil {
+			err = form.Notify.Send("Knolhash", "This is a test notification.")
+		}
+		if err != nil {
+			slog.Warn("Test notification failed", "error", err)
+			s.renderSettings(w, r, form, "", err.Error())
+			return
+		}
+		s.renderSettings(w, r, form, "Test notification sent.", "")
+	}
+}
+
+// handleGetTheme renders a script applying the preferred theme, loaded once by the page shell.
+func (s *Server) handleGetTheme() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := prefs.Load(s.db)
+		if err != nil {
+			slog.Error("Error loading preferences", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.templates.ExecuteTemplate(w, "theme", p.Theme)
+	}
+}
+
+// settingsFromForm reads the submitted settings form. The returned form holds
+// the submitted values even if some of them could not be parsed.
+func settingsFromForm(r *http.Request) (settingsForm, error) {
+	field := func(name string) string {
+		return strings.TrimSpace(r.PostFormValue(name))
+	}
+
+	var form settingsForm
+	var firstErr error
+	intField := func(name string, v *int) {
+		n, err := strconv.Atoi(field(name))
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s must be a whole number, got %q", strings.ReplaceAll(name, "_", " "), field(name))
+		}
+		*v = n
+	}
+
+	form.Prefs = prefs.Preferences{
+		Timezone:     field("timezone"),
+		Theme:        field("theme"),
+		Achievements: r.PostFormValue("achievements") == "on",
+	}
+	intField("day_cutoff", &form.Prefs.DayCutoff)
+	intField("new_cards_per_day", &form.Prefs.NewCardsPerDay)
+	intField("reviews_per_day", &form.Prefs.ReviewsPerDay)
+	intField("reviews_goal", &form.Prefs.ReviewsGoal)
+	intField("new_cards_goal", &form.Prefs.NewCardsGoal)
+	intField("minutes_goal", &form.Prefs.MinutesGoal)
+	intField("auto_reveal_seconds", &form.Prefs.AutoReveal)
+	intField("auto_again_seconds", &form.Prefs.AutoAgain)
+
+	form.Notify = notify.Settings{
+		Provider:      field("provider"),
+		NtfyServer:    field("ntfy_server"),
+		NtfyTopic:     field("ntfy_topic"),
+		NtfyToken:     field("ntfy_token"),
+		PushoverToken: field("pushover_token"),
+		PushoverUser:  field("pushover_user"),
+		Time:          field("time"),
+		Smart:         r.PostFormValue("smart") == "on",
+		QuietStart:    field("quiet_start"),
+		QuietEnd:      field("quiet_end"),
+	}
+	return form, firstErr
+}
+
+// renderSettings renders the settings page with an optional confirmation or error message.
+func (s *Server) renderSettings(w http.ResponseWriter, r *http.Request, form settingsForm, message, errMessage string) {
+	learnedTime, _, err := notify.LearnedTime(s.db, s.db.Now().In(form.Prefs.Location()))
+	if err != nil {
+		slog.Warn("Failed to learn usual review time", "error", err)
+	}
+	s.render(w, r, "settings", map[string]interface{}{
+		"Prefs":       form.Prefs,
+		"Notify":      form.Notify,
+		"LearnedTime": learnedTime,
+		"Message":     message,
+		"Error":       errMessage,
+	})
+}
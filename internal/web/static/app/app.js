@@ -0,0 +1,291 @@
+// The single-page review client: reviews the due cards through the JSON API,
+// POST /api/review, with the methods of `knolhash review`, rendering their
+// Markdown in the browser. The service worker caches the client itself, so it
+// opens without waiting on the network. When the API requires tokens, the
+// client asks for one and keeps it in the browser; it can also be passed once in
+// the address, as /app/#token=....
+(function() {
+    'use strict';
+
+    const cardEl = document.getElementById('card');
+    const dueEl = document.getElementById('due');
+    const errorEl = document.getElementById('error');
+    const grades = [
+        {grade: 1, label: 'Again', help: 'forgotten, show it again this session'},
+        {grade: 2, label: 'Hard', help: 'remembered with difficulty'},
+        {grade: 3, label: 'Good', help: 'remembered'},
+        {grade: 4, label: 'Easy', help: 'remembered easily'}
+    ];
+
+    // The card being reviewed: its front, its back once revealed, whether its
+    // hint was shown and the option chosen on a multiple choice card.
+    let current = null;
+
+    // Where the API token is kept in the browser.
+    const tokenKey = 'knolhash.token';
+
+    // Unauthorized is thrown when the API wants a token, or another one.
+    class Unauthorized extends Error {}
+
+    // Raw HTML in notes is left out, as the server's renderer does.
+    marked.use({renderer: {html: () => ''}});
+
+    async function call(method, params) {
+        const headers = {'Content-Type': 'application/json'};
+        const token = localStorage.getItem(tokenKey);
+        if (token) {
+            headers.Authorization = 'Bearer ' + token;
+        }
+        const resp = await fetch('/api/review', {
+            method: 'POST',
+            headers: headers,
+            body: JSON.stringify({method: method, params: params || {}})
+        });
+        if (resp.status === 401) {
+            throw new Unauthorized(token ? 'The API token was refused.' : 'The server requires an API token.');
+        }
+        if (!resp.ok) {
+            throw new Error((await resp.text()).trim() || resp.statusText);
+        }
+        const body = await resp.json();
+        if (body.error) {
+            throw new Error(body.error);
+        }
+        return body.result;
+    }
+
+    function escapeHTML(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+
+    function markdown(source) {
+        return marked.parse(source || '');
+    }
+
+    function show(html) {
+        cardEl.innerHTML = html;
+        cardEl.removeAttribute('aria-busy');
+        if (window.renderMathInElement) {
+            renderMathInElement(cardEl, {
+                delimiters: [
+                    {left: '\\[', right: '\\]', display: true},
+                    {left: '\\(', right: '\\)', display: false},
+                    {left: '$$', right: '$$', display: true},
+                    {left: '$', right: '$', display: false}
+                ],
+                throwOnError: false
+            });
+        }
+        const focus = cardEl.querySelector('footer button, .options button, input');
+        if (focus) {
+            focus.focus();
+        }
+    }
+
+    function showDue(due) {
+        dueEl.textContent = due + ' due';
+    }
+
+    function fail(err) {
+        if (err instanceof Unauthorized) {
+            askToken(err.message);
+            return;
+        }
+        errorEl.textContent = navigator.onLine ? err.message : 'Offline: reviews continue when the connection is back.';
+        errorEl.hidden = false;
+    }
+
+    function askToken(message) {
+        current = null;
+        show('<header>API token</header><p>' + escapeHTML(message) + ' Enter one of the tokens under api.tokens in the server\'s configuration.</p>' +
+            '<form id="token"><input type="password" name="token" aria-label="API token" autocomplete="current-password" required>' +
+            '<button type="submit">Save</button></form>');
+    }
+
+    async function next() {
+        errorEl.hidden = true;
+        cardEl.setAttribute('aria-busy', 'true');
+        let front;
+        try {
+            front = await call('next');
+        } catch (err) {
+            fail(err);
+            return;
+        }
+        showDue(front.due);
+        if (!front.card) {
+            current = null;
+            show('<header>All done</header><p>No cards are due. Come back later, or <a href="/">open the full site</a>.</p>');
+            return;
+        }
+        current = {front: front.card, back: null, hinted: false, chosen: null};
+        showFront();
+    }
+
+    function frontHTML(card) {
+        let html = '<header>' + (card.kind === 'writing' ? 'Writing Prompt' : 'Question') + '</header>';
+        if (card.context && card.kind !== 'writing') {
+            html += '<p class="context"><small>' + escapeHTML(card.context) + '</small></p>';
+        }
+        html += markdown(card.question);
+        if (card.kind === 'numbered') {
+            html += '<p>Recall answer ' + card.number + '.</p>';
+        }
+        if (card.kind === 'steps') {
+            html += '<p>Recall the steps, in order.</p>';
+        }
+        return html;
+    }
+
+    function showFront() {
+        const card = current.front;
+        let html = frontHTML(card);
+        if (card.hint) {
+            html += current.hinted
+                ? '<p><small>Hint: ' + escapeHTML(card.hint) + '</small></p>'
+                : '<p><button class="secondary outline" data-action="hint">Show hint</button></p>';
+        }
+        if (card.kind === 'choice') {
+            html += '<div class="options" role="group" aria-label="Options">';
+            card.options.forEach((option, i) => {
+                html += '<button class="outline" data-choice="' + option.index + '">' + (i + 1) + '. ' + escapeHTML(option.text) + '</button>';
+            });
+            html += '</div>';
+        } else {
+            html += '<footer><button data-action="reveal">Show answer</button></footer>';
+        }
+        show(html);
+    }
+
+    async function reveal(choice) {
+        if (!current || current.back) {
+            return;
+        }
+        current.chosen = choice === undefined ? null : choice;
+        try {
+            current.back = await call('reveal', {card: current.front.hash});
+        } catch (err) {
+            fail(err);
+            return;
+        }
+        showBack();
+    }
+
+    function showBack() {
+        const card = current.front;
+        const back = current.back;
+        let html = '<header>' + (card.kind === 'writing' ? 'Writing Prompt' : 'Question') + '</header>';
+        html += markdown(back.question);
+        if (card.kind === 'choice') {
+            html += '<p role="status"><strong>' + (current.chosen === back.right ? 'Correct.' : 'Not quite.') + '</strong></p>';
+            html += '<div class="options">';
+            card.options.forEach((option) => {
+                const cls = option.index === back.right ? 'right' : option.index === current.chosen ? 'wrong' : '';
+                html += '<button class="outline ' + cls + '" disabled>' + escapeHTML(option.text) + '</button>';
+            });
+            html += '</div>';
+            html += '<footer><button data-action="continue">Continue</button></footer>';
+        } else {
+            html += '<details open><summary>' + (card.kind === 'numbered' ? 'Answer ' + card.number : 'Answer') + '</summary>';
+            if (back.steps && back.steps.length) {
+                html += '<ol>' + back.steps.map((step) => '<li>' + markdown(step) + '</li>').join('') + '</ol>';
+            }
+            html += markdown(back.answer) + '</details>';
+            if (back.file) {
+                html += '<p><small>From ' + escapeHTML(back.file) + (back.line ? ':' + back.line : '') + '</small></p>';
+            }
+            html += '<footer><div class="grid grades" role="group" aria-label="How well did you remember?">';
+            grades.forEach((g) => {
+                html += '<button data-grade="' + g.grade + '"' + (g.grade < 3 ? ' class="secondary"' : '') +
+                    ' aria-label="' + g.label + ': ' + g.help + '">' + g.label + '</button>';
+            });
+            html += '</div></footer>';
+        }
+        show(html);
+    }
+
+    async function grade(params) {
+        if (!current || !current.back) {
+            return;
+        }
+        params.card = current.front.hash;
+        params.hinted = current.hinted;
+        cardEl.setAttribute('aria-busy', 'true');
+        try {
+            await call('grade', params);
+        } catch (err) {
+            fail(err);
+            return;
+        }
+        next();
+    }
+
+    cardEl.addEventListener('submit', (evt) => {
+        if (evt.target.id === 'token') {
+            evt.preventDefault();
+            localStorage.setItem(tokenKey, evt.target.elements.token.value.trim());
+            next();
+        }
+    });
+
+    cardEl.addEventListener('click', (evt) => {
+        const button = evt.target.closest('button');
+        if (!button || !current) {
+            return;
+        }
+        if (button.dataset.action === 'hint') {
+            current.hinted = true;
+            showFront();
+        } else if (button.dataset.action === 'reveal') {
+            reveal();
+        } else if (button.dataset.action === 'continue') {
+            grade({choice: current.chosen});
+        } else if (button.dataset.choice !== undefined) {
+            reveal(Number(button.dataset.choice));
+        } else if (button.dataset.grade !== undefined) {
+            grade({grade: Number(button.dataset.grade)});
+        }
+    });
+
+    document.addEventListener('keydown', (evt) => {
+        if (!current || evt.ctrlKey || evt.metaKey || evt.altKey || evt.target.matches('input, textarea')) {
+            return;
+        }
+        const card = current.front;
+        const number = Number(evt.key);
+        if (!current.back) {
+            if (evt.key === 'h' && card.hint && !current.hinted) {
+                current.hinted = true;
+                showFront();
+            } else if (card.kind === 'choice' && number >= 1 && number <= card.options.length) {
+                reveal(card.options[number - 1].index);
+            } else if (card.kind !== 'choice' && (evt.key === ' ' || evt.key === 'Enter')) {
+                evt.preventDefault();
+                reveal();
+            }
+        } else if (card.kind === 'choice' && (evt.key === ' ' || evt.key === 'Enter')) {
+            evt.preventDefault();
+            grade({choice: current.chosen});
+        } else if (card.kind !== 'choice' && number >= 1 && number <= 4) {
+            grade({grade: number});
+        }
+    });
+
+    window.addEventListener('online', () => {
+        if (!errorEl.hidden) {
+            current && current.back ? showBack() : next();
+        }
+    });
+
+    const passed = new URLSearchParams(location.hash.slice(1)).get('token');
+    if (passed) {
+        localStorage.setItem(tokenKey, passed);
+        history.replaceState(null, '', location.pathname + location.search);
+    }
+    if ('serviceWorker' in navigator) {
+        navigator.serviceWorker.register('/app/sw.js', {scope: '/app/'});
+    }
+    next();
+})();
@@ -0,0 +1,42 @@
+// The service worker of the single-page review client. The client and the
+// libraries it loads are served from the cache straight away, and refreshed
+// from the network behind it, so the client opens instantly and offline. The
+// API is always asked over the network.
+const CACHE = 'knolhash-app-v2';
+const SHELL = [
+    '/app/',
+    '/app/app.js',
+    '/app/app.css',
+    '/app/manifest.json',
+    '/static/pico.min.css'
+];
+
+self.addEventListener('install', (evt) => {
+    evt.waitUntil(caches.open(CACHE).then((cache) => cache.addAll(SHELL)).then(() => self.skipWaiting()));
+});
+
+self.addEventListener('activate', (evt) => {
+    evt.waitUntil(caches.keys()
+        .then((keys) => Promise.all(keys.filter((key) => key !== CACHE).map((key) => caches.delete(key))))
+        .then(() => self.clients.claim()));
+});
+
+self.addEventListener('fetch', (evt) => {
+    const url = new URL(evt.request.url);
+    if (evt.request.method !== 'GET' || url.pathname.startsWith('/api/')) {
+        return;
+    }
+    evt.respondWith(caches.open(CACHE).then((cache) => cache.match(evt.request).then((cached) => {
+        const fetched = fetch(evt.request).then((resp) => {
+            if (resp.ok || resp.type === 'opaque') {
+                cache.put(evt.request, resp.clone());
+            }
+            return resp;
+        });
+        if (cached) {
+            evt.waitUntil(fetched.catch(() => {}));
+            return cached;
+        }
+        return fetched;
+    })));
+});
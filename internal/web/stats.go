@@ -0,0 +1,162 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/prefs"
+	"github.com/conorfennell/knolhash/internal/stats"
+)
+
+const (
+	// hardestCards is the number of cards listed as the hardest on the stats page.
+	hardestCards = 20
+
+	// Cards at least staleDifficulty difficult whose file hasn't changed in
+	// staleYears are listed as stale, candidates for rewriting.
+	staleDifficulty = 7.0
+	staleYears      = 1
+
+	// Days charted and weeks listed in the study time section of the stats page.
+	// Streaks are counted over the last year.
+	studyTimeDays  = 30
+	studyTimeWeeks = 8
+	streakDays     = 365
+)
+
+// handleGetStats renders the stats page.
+func (s *Server) handleGetStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := prefs.Load(s.db)
+		if err != nil {
+			slog.Error("Error loading preferences", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		// Up to the end of today, as [day, now) is empty at its very start
+		today := p.DayStart(s.db.Now())
+		minutes, err := stats.MinutesPerDay(s.db, p, today.AddDate(0, 0, 1-streakDays), today.AddDate(0, 0, 1))
+		if err != nil {
+			slog.Error("Error getting study time", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		maturity, err := stats.CardMaturity(s.db, s.db.Now())
+		if err != nil {
+			slog.Error("Error getting card maturity", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		areas, err := stats.Areas(s.db)
+		if err != nil {
+			slog.Error("Error getting weak areas", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		histogram, err := stats.DifficultyHistogram(s.db)
+		if err != nil {
+			slog.Error("Error getting difficulty histogram", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		hardest, err := s.db.GetHardestCards(hardestCards)
+		if err != nil {
+			slog.Error("Error getting hardest cards", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		stale, err := s.db.GetStaleCards(s.db.Now().AddDate(-staleYears, 0, 0), staleDifficulty)
+		if err != nil {
+			slog.Error("Error getting stale cards", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		hints, err := stats.Hints(s.db)
+		if err != nil {
+			slog.Error("Error getting hint use", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		maxBin := 0
+		for _, b := range histogram {
+			maxBin = max(maxBin, b.Cards)
+		}
+		s.render(w, r, "stats", map[string]interface{}{
+			"Goal":            p.MinutesGoal,
+			"Today":           minutes[len(minutes)-1].Value,
+			"TimeChart":       newStudyTimeChart(minutes[len(minutes)-studyTimeDays:], p.MinutesGoal),
+			"Weeks":           stats.Weekly(minutes[len(minutes)-7*studyTimeWeeks:]),
+			"Streak":          stats.GoalStreak(minutes, float64(p.MinutesGoal)),
+			"Maturity":        maturity,
+			"MatureDays":      stats.MatureDays,
+			"Areas":           areas,
+			"Histogram":       histogram,
+			"MaxBin":          maxBin,
+			"Hardest":         hardest,
+			"Stale":           stale,
+			"StaleDifficulty": staleDifficulty,
+			"Hints":           hints,
+		})
+	}
+}
+
+// handleGetMaturityAPI returns the card maturity breakdown as JSON.
+func (s *Server) handleGetMaturityAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maturity, err := stats.CardMaturity(s.db, s.db.Now())
+		if err != nil {
+			slog.Error("Error getting card maturity", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, r, maturity)
+	}
+}
+
+// studyTimeChart plots the minutes studied per day as bars, with the daily goal
+// as a horizontal line, as coordinates for an SVG drawing of Width by Height.
+type studyTimeChart struct {
+	Width, Height float64
+	ViewBox       string // Leaves room around the plot for the axis labels
+	Bars          []studyTimeBar
+	GoalY         float64 // Y of the goal line; only drawn with a goal
+	MaxMinutes    float64
+	From, To      time.Time
+}
+
+type studyTimeBar struct {
+	X, Y, Width, Height float64
+	Day                 time.Time
+	Minutes             float64
+	Met                 bool // The goal was reached
+}
+
+// newStudyTimeChart lays out the chart for the minutes studied per day, oldest first.
+func newStudyTimeChart(days []stats.Point, goal int) *studyTimeChart {
+	c := &studyTimeChart{Width: 600, Height: 150, From: days[0].Time, To: days[len(days)-1].Time}
+	c.ViewBox = fmt.Sprintf("-50 -15 %.0f %.0f", c.Width+70, c.Height+40)
+	c.MaxMinutes = max(1, float64(goal))
+	for _, d := range days {
+		c.MaxMinutes = max(c.MaxMinutes, d.Value)
+	}
+	c.MaxMinutes = math.Ceil(c.MaxMinutes)
+
+	slot := c.Width / float64(len(days))
+	for i, d := range days {
+		h := math.Round(c.Height*d.Value/c.MaxMinutes*10) / 10
+		c.Bars = append(c.Bars, studyTimeBar{
+			X:       math.Round(float64(i)*slot*10)/10 + 1,
+			Y:       c.Height - h,
+			Width:   math.Round(slot*10)/10 - 2,
+			Height:  h,
+			Day:     d.Time,
+			Minutes: d.Value,
+			Met:     goal > 0 && d.Value >= float64(goal),
+		})
+	}
+	c.GoalY = math.Round((c.Height-c.Height*float64(goal)/c.MaxMinutes)*10) / 10
+	return c
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/conorfennell/knolhash/internal/goals"
+	"github.com/conorfennell/knolhash/internal/prefs"
+)
+
+// handleGetTrophies renders the trophy page with the study streak and badges.
+func (s *Server) handleGetTrophies() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := prefs.Load(s.db)
+		if err != nil {
+			slog.Error("Error loading preferences", "error", err)
+			s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data := map[string]interface{}{"Enabled": p.Achievements}
+		if p.Achievements {
+			trophies, err := goals.Achievements(s.db, p, s.db.Now())
+			if err != nil {
+				slog.Error("Error getting achievements", "error", err)
+				s.renderError(w, r, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			data["Trophies"] = trophies
+		}
+		s.render(w, r, "trophies", data)
+	}
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"sort"
+
+	"github.com/conorfennell/knolhash/internal/buildinfo"
+)
+
+// AssetChecksums returns the SHA-256 of every embedded template and static file,
+// by path, and one over all of them.
+func AssetChecksums() (files map[string]string, all string, err error) {
+	files = make(map[string]string)
+	for _, fsys := range []fs.FS{templateFiles, staticFiles} {
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			files[p] = hex.EncodeToString(sum[:])
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		h.Write([]byte(p + " " + files[p] + "\n"))
+	}
+	return files, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BuildInfo returns the build information with the checksums of the embedded assets.
+func BuildInfo() buildinfo.Info {
+	info := buildinfo.Get()
+	info.AssetFiles, info.Assets, _ = AssetChecksums()
+	return info
+}
+
+// handleGetVersion returns the build information as JSON.
+func (s *Server) handleGetVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, BuildInfo())
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/conorfennell/knolhash/internal/goals"
+	"github.com/conorfennell/knolhash/internal/prefs"
+)
+
+// widgetRefresh is how often the HTML widget reloads itself.
+const widgetRefresh = 5 * time.Minute
+
+// widgetStatus is the study status shown on home dashboards, such as Homepage,
+// Dashy or Home Assistant.
+type widgetStatus struct {
+	Due           int        `json:"due"`      // Cards to study now, within the day's limits
+	NextDue       *time.Time `json:"next_due"` // When the next card falls due; null if none will
+	Streak        int        `json:"streak"`
+	LongestStreak int        `json:"longest_streak"`
+}
+
+// handleGetWidget serves the study status under /api/widgets/: status as JSON,
+// e.g. for Homepage's custom API widget or a Home Assistant REST sensor, and
+// status.html as a compact page for an iframe, which reloads itself. They take
+// the API's tokens as bearer tokens, and widget tokens also as a token query
+// parameter, as dashboards can't always set headers.
+func (s *Server) handleGetWidget() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path != "/api/widgets/status" && r.URL.Path != "/api/widgets/status.html" {
+			http.NotFound(w, r)
+			return
+		}
+		status, err := s.widgetStatus()
+		if err != nil {
+			slog.Error("Error getting status for widget", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/api/widgets/status" {
+			writeJSON(w, r, status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		s.templates.ExecuteTemplate(w, "widget", map[string]interface{}{
+			"Status":  status,
+			"Refresh": int(widgetRefresh.Seconds()),
+		})
+	}
+}
+
+// widgetStatus gathers the study status now.
+func (s *Server) widgetStatus() (widgetStatus, error) {
+	var status widgetStatus
+	p, err := prefs.Load(s.db)
+	if err != nil {
+		return status, err
+	}
+	counts, err := s.loadDueCounts(p)
+	if err != nil {
+		return status, err
+	}
+	status.Due = counts.Due
+
+	now := s.db.Now()
+	next, err := s.db.GetNextDueDate(now)
+	if err != nil {
+		return status, err
+	}
+	if !next.IsZero() {
+		next = next.In(p.Location())
+		status.NextDue = &next
+	}
+
+	trophies, err := goals.Achievements(s.db, p, now)
+	if err != nil {
+		return status, err
+	}
+	status.Streak, status.LongestStreak = trophies.Streak.Current, trophies.Streak.Longest
+	return status, nil
+}